@@ -5,9 +5,11 @@
 package agabor
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"math"
+	"os"
 
 	"github.com/emer/etable/etable"
 	"github.com/emer/etable/etensor"
@@ -228,35 +230,8 @@ func Convolve(melData *etensor.Float64, filters FilterSet, rawOut *etensor.Float
 		return
 	}
 
-	tMax := 1
-	fMax := 1
-	tMaxStrides := 1
-	if rawOut.NumDims() == 2 {
-		x := melData.Dim(1) - filters.SizeX
-		if x == 0 || x < filters.StrideX {
-			// leave tMax equal to 1
-		} else {
-			tMax = x + 1
-		}
-
-		z := melData.Dim(1) - filters.SizeX
-		tMaxStrides = z/filters.StrideX + 1
-
-		y := melData.Dim(0) - filters.SizeY
-		if y == 0 || y < filters.StrideY {
-			// leave fMax equal to 1
-		} else {
-			fMax = y + 1
-		}
-	} else if rawOut.NumDims() == 4 {
-		tMax1 := rawOut.Shp[1] * filters.StrideX
-		tMax2 := melData.Shp[1] - filters.StrideX
-		tMax = int(math.Min(float64(tMax1), float64(tMax2)))
-
-		fMax1 := rawOut.Shp[0] * filters.StrideY  // limit frequency strides so we don't overrun the output tensor
-		fMax2 := melData.Shp[0] - filters.StrideY // limit strides based on melData in frequency dimension
-		fMax = int(math.Min(float64(fMax1), float64(fMax2)))
-	} else {
+	tMax, fMax, tMaxStrides, ok := strides(melData.Shp, &filters, rawOut.Shapes())
+	if !ok {
 		log.Println("The output tensor should have 2 or 4 dimensions")
 		return
 	}
@@ -314,6 +289,102 @@ func Convolve(melData *etensor.Float64, filters FilterSet, rawOut *etensor.Float
 	}
 }
 
+// Project projects weights over the units of the output of Convolve, of the same shape as rawOut (2D or 4D), back
+// onto the mel input of melShp [frequency, time], the inverse of the layout of Convolve: each weight adds the filter
+// of its unit at the position of the unit to out, positive for the on-center units (the first of each pair, which
+// respond to the filter) and negative for the off-center units (which respond to its negative) -- e.g., to view the
+// receptive field in mel frequency by time of a unit of a model that receives the gabor output
+func Project(weights etensor.Tensor, melShp []int, filters FilterSet, byTime bool, out *etensor.Float64) error {
+	out.SetShape([]int{melShp[0], melShp[1]}, nil, []string{"freq", "time"})
+	out.SetZeros()
+	tMax, fMax, tMaxStrides, ok := strides(melShp, &filters, weights.Shapes())
+	if !ok {
+		err := fmt.Errorf("agabor.Project: the weights should have 2 or 4 dimensions, the shape of the gabor output, not %v", weights.NumDims())
+		log.Println(err)
+		return err
+	}
+	nf := filters.Filters.Dim(0)
+	tIdx := 0
+	for t := 0; t < tMax; t, tIdx = t+filters.StrideX, tIdx+1 {
+		fIdx := 0
+		for f := 0; f < fMax; f, fIdx = f+filters.StrideY, fIdx+1 {
+			for flt := 0; flt < nf; flt++ {
+				var on, off []int // indexes of the on and off units, as in Convolve
+				if weights.NumDims() == 2 {
+					y, x := fIdx*2, flt+tIdx*nf
+					if byTime {
+						x = tIdx + tMaxStrides*flt
+					}
+					on, off = []int{y, x}, []int{y + 1, x}
+				} else {
+					on, off = []int{fIdx, tIdx, 0, flt}, []int{fIdx, tIdx, 1, flt}
+				}
+				if !inShape(on, weights.Shapes()) || !inShape(off, weights.Shapes()) {
+					continue
+				}
+				w := weights.FloatVal(on) - weights.FloatVal(off)
+				if w == 0 {
+					continue
+				}
+				for ff := 0; ff < filters.SizeY && f+ff < melShp[0]; ff++ {
+					for ft := 0; ft < filters.SizeX && t+ft < melShp[1]; ft++ {
+						idx := (f+ff)*melShp[1] + t + ft
+						out.Values[idx] += w * filters.Filters.Value([]int{flt, ff, ft})
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// inShape returns whether the index is within the shape
+func inShape(idx, shp []int) bool {
+	for i, v := range idx {
+		if v < 0 || v >= shp[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// strides returns the limits of the time and frequency positions of the filters in Convolve of mel input of melShp
+// [frequency, time] into output of outShp, 2D or 4D, and the number of time positions of the 2D layout -- not ok if the
+// output isn't 2D or 4D
+func strides(melShp []int, filters *FilterSet, outShp []int) (tMax, fMax, tMaxStrides int, ok bool) {
+	tMax, fMax, tMaxStrides = 1, 1, 1
+	if len(outShp) == 2 {
+		x := melShp[1] - filters.SizeX
+		if x == 0 || x < filters.StrideX {
+			// leave tMax equal to 1
+		} else {
+			tMax = x + 1
+		}
+
+		z := melShp[1] - filters.SizeX
+		tMaxStrides = z/filters.StrideX + 1
+
+		y := melShp[0] - filters.SizeY
+		if y == 0 || y < filters.StrideY {
+			// leave fMax equal to 1
+		} else {
+			fMax = y + 1
+		}
+	} else if len(outShp) == 4 {
+		tMax1 := outShp[1] * filters.StrideX
+		tMax2 := melShp[1] - filters.StrideX
+		tMax = int(math.Min(float64(tMax1), float64(tMax2)))
+
+		fMax1 := outShp[0] * filters.StrideY // limit frequency strides so we don't overrun the output tensor
+		fMax2 := melShp[0] - filters.StrideY // limit strides based on melData in frequency dimension
+		fMax = int(math.Min(float64(fMax1), float64(fMax2)))
+	} else {
+		return 0, 0, 0, false
+	}
+
+	return tMax, fMax, tMaxStrides, true
+}
+
 // ToDo: don't renorm
 // ToTable renders filters into the given etable.Table
 // This is useful for display and validation purposes.
@@ -334,3 +405,32 @@ func Active(specs []Filter) (active []Filter) {
 	}
 	return active
 }
+
+// OpenJSON returns the gabor filter specs in a JSON-formatted file, e.g., saved by SaveJSON
+func OpenJSON(filename string) ([]Filter, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	var specs []Filter
+	if err := json.Unmarshal(b, &specs); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return specs, nil
+}
+
+// SaveJSON saves the gabor filter specs to a JSON-formatted file
+func SaveJSON(specs []Filter, filename string) error {
+	b, err := json.MarshalIndent(specs, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
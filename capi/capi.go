@@ -0,0 +1,167 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// capi exports the sound.SndEnv feature extraction as a C library so that non-Go code
+// (Python, Matlab, ...) can compute exactly the same features as the Go sims.
+// Build the shared library and header (libauditory.h) with:
+//
+//	go build -tags server -buildmode=c-shared -o libauditory.so ./capi
+//
+// (the server tag leaves out wav playback, which is not needed and requires the audio system headers)
+//
+// Each Extract function processes one segment of a mono signal of normalized (-1..1) samples
+// and writes the output tensor in row-major order (rows are frequency, cols are time) to out.
+// The return value is the number of values written, or -1 on error.
+// rows and cols are always set (when processing succeeds) so calling with outLen 0 returns -1
+// after filling in the shape, which the caller can use to allocate out.
+package main
+
+import "C"
+
+import (
+	"errors"
+	"log"
+	"unsafe"
+
+	"github.com/emer/auditory/sound"
+	"github.com/emer/etable/etensor"
+	"github.com/go-audio/audio"
+)
+
+// Env is the processing pipeline shared by all calls -- configured by SetParams
+var Env sound.SndEnv
+
+// configured is true once Env has been set to defaults
+var configured = false
+
+// Defaults sets the parameters to the SndEnv defaults plus a standard set of 4 orientation gabor filters
+func Defaults() {
+	Env.Defaults()
+	Env.Mel.MFCC = true
+	Env.Kwta.On = false
+	Env.NeighInhib.On = false
+	Env.GaborDefaults()
+	configured = true
+}
+
+// SetParams sets the main processing parameters, the rest keep their Defaults values
+//
+//export SetParams
+func SetParams(winMs, stepMs, segmentMs, strideMs C.double, borderSteps, melFilters C.int) {
+	Defaults()
+	Env.Params.WinMs = float64(winMs)
+	Env.Params.StepMs = float64(stepMs)
+	Env.Params.SegmentMs = float64(segmentMs)
+	Env.Params.StrideMs = float64(strideMs)
+	Env.Params.BorderSteps = int(borderSteps)
+	Env.Mel.FBank.NFilters = int(melFilters)
+}
+
+// SegmentCount returns the number of segments in the signal of nSamples at sampleRate, -1 on error
+//
+//export SegmentCount
+func SegmentCount(samples *C.double, nSamples, sampleRate C.int) C.int {
+	err := load(samples, nSamples, sampleRate)
+	if err != nil {
+		log.Println(err)
+		return -1
+	}
+	return C.int(Env.SegCnt)
+}
+
+// ExtractMel writes the mel filter bank output for the segment
+//
+//export ExtractMel
+func ExtractMel(samples *C.double, nSamples, sampleRate, segment C.int, out *C.double, outLen C.int, rows, cols *C.int) C.int {
+	err := process(samples, nSamples, sampleRate, segment)
+	if err != nil {
+		log.Println(err)
+		return -1
+	}
+	return copyOut(&Env.MelFBankSegment, out, outLen, rows, cols)
+}
+
+// ExtractMFCC writes the mel frequency cepstral coefficients for the segment
+// (coefficient 0 is replaced by the log energy, as in SndEnv)
+//
+//export ExtractMFCC
+func ExtractMFCC(samples *C.double, nSamples, sampleRate, segment C.int, out *C.double, outLen C.int, rows, cols *C.int) C.int {
+	err := process(samples, nSamples, sampleRate, segment)
+	if err != nil {
+		log.Println(err)
+		return -1
+	}
+	return copyOut(&Env.MFCCSegment, out, outLen, rows, cols)
+}
+
+// ExtractGabor writes the result of convolving the gabor filters with the mel output for the segment,
+// 2 rows (on-center and off-center) per filter position in frequency and one column per filter per time position
+//
+//export ExtractGabor
+func ExtractGabor(samples *C.double, nSamples, sampleRate, segment C.int, out *C.double, outLen C.int, rows, cols *C.int) C.int {
+	err := process(samples, nSamples, sampleRate, segment)
+	if err != nil {
+		log.Println(err)
+		return -1
+	}
+	tsr := Env.ApplyGabor()
+	return copyOut(tsr, out, outLen, rows, cols)
+}
+
+// load sets the signal and initializes the processing, which also sets the 2D gabor output shape
+func load(samples *C.double, nSamples, sampleRate C.int) error {
+	if !configured {
+		Defaults()
+	}
+	if samples == nil || nSamples <= 0 {
+		return errors.New("capi: no samples")
+	}
+	if sampleRate <= 0 {
+		return errors.New("capi: sample rate <= 0")
+	}
+	Env.Sound.Buf = &audio.IntBuffer{Format: &audio.Format{NumChannels: 1, SampleRate: int(sampleRate)}}
+	vals := unsafe.Slice((*float64)(unsafe.Pointer(samples)), int(nSamples))
+	Env.Signal.SetShape([]int{int(nSamples)}, nil, nil)
+	copy(Env.Signal.Values, vals)
+
+	Env.SetGaborOut2D()
+	if sound.MSecToSamples(Env.Params.SegmentMs, int(sampleRate)) > int(nSamples) {
+		return errors.New("capi: signal shorter than one segment")
+	}
+	return Env.Init()
+}
+
+// process loads the signal and processes the segment
+func process(samples *C.double, nSamples, sampleRate, segment C.int) error {
+	err := load(samples, nSamples, sampleRate)
+	if err != nil {
+		return err
+	}
+	if segment < 0 || int(segment) >= Env.SegCnt {
+		return errors.New("capi: segment out of range")
+	}
+	Env.ProcessSegment(int(segment), 0)
+	return nil
+}
+
+// copyOut sets rows and cols to the shape of the 2D tensor and copies its values to out if it is large enough
+func copyOut(tsr etensor.Tensor, out *C.double, outLen C.int, rows, cols *C.int) C.int {
+	if rows != nil {
+		*rows = C.int(tsr.Dim(0))
+	}
+	if cols != nil {
+		*cols = C.int(tsr.Dim(1))
+	}
+	n := tsr.Len()
+	if out == nil || int(outLen) < n {
+		return -1
+	}
+	dst := unsafe.Slice((*float64)(unsafe.Pointer(out)), n)
+	for i := range dst {
+		dst[i] = tsr.FloatVal1D(i)
+	}
+	return C.int(n)
+}
+
+func main() {} // required for -buildmode=c-shared
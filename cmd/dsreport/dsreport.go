@@ -0,0 +1,411 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// dsreport generates statistics for a dataset of labeled sound files, to document datasets built with this package.
+// The manifest is a speech.Sequences JSON file (see speech.Sequences.SaveJSON), one sequence per sound file.
+// The report has per-phone (unit) counts and durations, a unit duration histogram, per-speaker totals
+// per-file SNR estimates and clipping stats, and the unit timing issues (units past the end of the audio,
+// out of order or overlapping, see speech.Sequence.CheckAlignment), saved as tab separated etable files plus an html page:
+//
+//	dsreport -manifest train.json -out reports/train
+//
+// writes reports/train_phones.tsv, _durations.tsv, _speakers.tsv, _files.tsv, _alignment.tsv and reports/train.html.
+// Build with the server tag to leave out wav playback: go build -tags server ./cmd/dsreport
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/emer/auditory/sound"
+	"github.com/emer/auditory/speech"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// Report holds the dataset statistics tables
+type Report struct {
+
+	// name of the dataset, from the manifest file name
+	Name string `desc:"name of the dataset, from the manifest file name"`
+
+	// width in milliseconds of the duration histogram bins
+	BinMs float64 `desc:"width in milliseconds of the duration histogram bins"`
+
+	// absolute sample value at or above which a sample counts as clipped
+	ClipThr float64 `desc:"absolute sample value at or above which a sample counts as clipped"`
+
+	// overlap and end of audio tolerance in milliseconds of the alignment checks
+	AlignTolMs float64 `desc:"overlap and end of audio tolerance in milliseconds of the alignment checks"`
+
+	// per phone (unit name) counts and durations
+	Phones *etable.Table `desc:"per phone (unit name) counts and durations"`
+
+	// histogram of unit durations
+	Durations *etable.Table `desc:"histogram of unit durations"`
+
+	// per speaker totals
+	Speakers *etable.Table `desc:"per speaker totals"`
+
+	// per file duration, SNR estimate, clipping and number of alignment issues
+	Files *etable.Table `desc:"per file duration, SNR estimate, clipping and number of alignment issues"`
+
+	// unit timing issues, one row per issue
+	Alignment *etable.Table `desc:"unit timing issues, one row per issue"`
+
+	// number of files that could not be loaded
+	Missing int `desc:"number of files that could not be loaded"`
+}
+
+func main() {
+	manifest := flag.String("manifest", "", "speech.Sequences JSON file listing the sound files and their units")
+	out := flag.String("out", "", "path and prefix of the report files, default is the manifest path without extension")
+	binMs := flag.Float64("bin", 10, "width in milliseconds of the duration histogram bins")
+	clip := flag.Float64("clip", 0.999, "absolute sample value at or above which a sample counts as clipped")
+	tol := flag.Float64("tol", 0, "milliseconds units may overlap or extend past the end of the audio before being reported")
+	flag.Parse()
+	if *manifest == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *out == "" {
+		*out = (*manifest)[:len(*manifest)-len(filepath.Ext(*manifest))]
+	}
+
+	var seqs speech.Sequences
+	err := seqs.OpenJSON(*manifest)
+	if err != nil {
+		os.Exit(1)
+	}
+	rp := &Report{Name: filepath.Base(*out), BinMs: *binMs, ClipThr: *clip, AlignTolMs: *tol}
+	rp.Compute(seqs)
+	err = rp.Save(*out)
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// EstimateSNR estimates the signal to noise ratio in dB of the signal from the energy of 20 ms frames,
+// taking the 95th percentile frame as signal and the 5th percentile frame as noise,
+// which doesn't require the silences to be labeled
+func EstimateSNR(signal []float64, sampleRate int) float64 {
+	n := sound.MSecToSamples(20, sampleRate)
+	if n <= 0 || len(signal) < 2*n {
+		return math.NaN()
+	}
+	var frames []float64
+	for st := 0; st+n <= len(signal); st += n {
+		e := 0.0
+		for _, v := range signal[st : st+n] {
+			e += v * v
+		}
+		frames = append(frames, e/float64(n))
+	}
+	sort.Float64s(frames)
+	lo := frames[int(0.05*float64(len(frames)-1))]
+	hi := frames[int(0.95*float64(len(frames)-1))]
+	if lo <= 0 {
+		lo = 1e-12 // digital silence
+	}
+	return 10 * math.Log10(hi/lo)
+}
+
+// Clipped returns the number of samples at or above thr in absolute value
+func Clipped(signal []float64, thr float64) int {
+	n := 0
+	for _, v := range signal {
+		if math.Abs(v) >= thr {
+			n++
+		}
+	}
+	return n
+}
+
+// phoneStats accumulates the durations of one phone
+type phoneStats struct {
+	count        int
+	total        float64
+	min, max     float64
+	mean, varsum float64
+}
+
+// speakerStats accumulates the totals of one speaker
+type speakerStats struct {
+	files, units int
+	ms           float64
+}
+
+// Compute loads each sound file of the sequences and computes all the tables
+func (rp *Report) Compute(seqs speech.Sequences) {
+	phones := map[string]*phoneStats{}
+	speakers := map[string]*speakerStats{}
+	var durs []float64
+
+	rp.Files = etable.New(etable.Schema{
+		{"File", etensor.STRING, nil, nil},
+		{"Speaker", etensor.STRING, nil, nil},
+		{"DurMs", etensor.FLOAT64, nil, nil},
+		{"Units", etensor.INT64, nil, nil},
+		{"SNRdB", etensor.FLOAT64, nil, nil},
+		{"Clipped", etensor.INT64, nil, nil},
+		{"ClipPct", etensor.FLOAT64, nil, nil},
+		{"AlignIssues", etensor.INT64, nil, nil},
+	}, 0)
+	rp.Alignment = etable.New(etable.Schema{
+		{"File", etensor.STRING, nil, nil},
+		{"Unit", etensor.INT64, nil, nil},
+		{"Name", etensor.STRING, nil, nil},
+		{"Issue", etensor.STRING, nil, nil},
+		{"StartMs", etensor.FLOAT64, nil, nil},
+		{"EndMs", etensor.FLOAT64, nil, nil},
+		{"RefMs", etensor.FLOAT64, nil, nil},
+	}, 0)
+
+	for i := range seqs {
+		seq := &seqs[i]
+		spk := seq.Speaker()
+		ss, ok := speakers[spk]
+		if !ok {
+			ss = &speakerStats{}
+			speakers[spk] = ss
+		}
+		ss.files++
+		ss.units += len(seq.Units)
+		for _, u := range seq.Units {
+			d := u.End - u.Start
+			durs = append(durs, d)
+			ps, ok := phones[u.Name]
+			if !ok {
+				ps = &phoneStats{min: d, max: d}
+				phones[u.Name] = ps
+			}
+			ps.count++
+			ps.total += d
+			ps.min = math.Min(ps.min, d)
+			ps.max = math.Max(ps.max, d)
+			delta := d - ps.mean // Welford
+			ps.mean += delta / float64(ps.count)
+			ps.varsum += delta * (d - ps.mean)
+		}
+
+		row := rp.Files.Rows
+		rp.Files.AddRows(1)
+		rp.Files.SetCellString("File", row, seq.File)
+		rp.Files.SetCellString("Speaker", row, spk)
+		rp.Files.SetCellFloat("Units", row, float64(len(seq.Units)))
+		var snd sound.Wave
+		if snd.Load(seq.File) != nil || snd.Buf == nil {
+			rp.Missing++
+			rp.Files.SetCellFloat("SNRdB", row, math.NaN())
+			rp.AddAlignIssues(row, seq.CheckAlignment(0, rp.AlignTolMs))
+			continue
+		}
+		var sig etensor.Float64
+		snd.SoundToTensor(&sig)
+		sr := snd.SampleRate()
+		ms := sound.SamplesToMSec(len(sig.Values), sr)
+		rp.AddAlignIssues(row, seq.CheckAlignment(ms, rp.AlignTolMs))
+		ss.ms += ms
+		clipped := Clipped(sig.Values, rp.ClipThr)
+		rp.Files.SetCellFloat("DurMs", row, ms)
+		rp.Files.SetCellFloat("SNRdB", row, EstimateSNR(sig.Values, sr))
+		rp.Files.SetCellFloat("Clipped", row, float64(clipped))
+		if len(sig.Values) > 0 {
+			rp.Files.SetCellFloat("ClipPct", row, 100*float64(clipped)/float64(len(sig.Values)))
+		}
+	}
+
+	names := make([]string, 0, len(phones))
+	for nm := range phones {
+		names = append(names, nm)
+	}
+	sort.Strings(names)
+	rp.Phones = etable.New(etable.Schema{
+		{"Phone", etensor.STRING, nil, nil},
+		{"Count", etensor.INT64, nil, nil},
+		{"TotalMs", etensor.FLOAT64, nil, nil},
+		{"MeanMs", etensor.FLOAT64, nil, nil},
+		{"SDMs", etensor.FLOAT64, nil, nil},
+		{"MinMs", etensor.FLOAT64, nil, nil},
+		{"MaxMs", etensor.FLOAT64, nil, nil},
+	}, len(names))
+	for r, nm := range names {
+		ps := phones[nm]
+		rp.Phones.SetCellString("Phone", r, nm)
+		rp.Phones.SetCellFloat("Count", r, float64(ps.count))
+		rp.Phones.SetCellFloat("TotalMs", r, ps.total)
+		rp.Phones.SetCellFloat("MeanMs", r, ps.mean)
+		rp.Phones.SetCellFloat("SDMs", r, math.Sqrt(ps.varsum/float64(ps.count)))
+		rp.Phones.SetCellFloat("MinMs", r, ps.min)
+		rp.Phones.SetCellFloat("MaxMs", r, ps.max)
+	}
+
+	spks := make([]string, 0, len(speakers))
+	for nm := range speakers {
+		spks = append(spks, nm)
+	}
+	sort.Strings(spks)
+	rp.Speakers = etable.New(etable.Schema{
+		{"Speaker", etensor.STRING, nil, nil},
+		{"Files", etensor.INT64, nil, nil},
+		{"Units", etensor.INT64, nil, nil},
+		{"TotalMs", etensor.FLOAT64, nil, nil},
+	}, len(spks))
+	for r, nm := range spks {
+		ss := speakers[nm]
+		rp.Speakers.SetCellString("Speaker", r, nm)
+		rp.Speakers.SetCellFloat("Files", r, float64(ss.files))
+		rp.Speakers.SetCellFloat("Units", r, float64(ss.units))
+		rp.Speakers.SetCellFloat("TotalMs", r, ss.ms)
+	}
+
+	rp.Durations = Histogram(durs, rp.BinMs)
+}
+
+// AddAlignIssues adds the issues to the Alignment table and their number to the Files table row
+func (rp *Report) AddAlignIssues(row int, issues []speech.AlignIssue) {
+	rp.Files.SetCellFloat("AlignIssues", row, float64(len(issues)))
+	for _, ai := range issues {
+		r := rp.Alignment.Rows
+		rp.Alignment.AddRows(1)
+		rp.Alignment.SetCellString("File", r, ai.File)
+		rp.Alignment.SetCellFloat("Unit", r, float64(ai.Idx))
+		rp.Alignment.SetCellString("Name", r, ai.Name)
+		rp.Alignment.SetCellString("Issue", r, ai.Kind)
+		rp.Alignment.SetCellFloat("StartMs", r, ai.Start)
+		rp.Alignment.SetCellFloat("EndMs", r, ai.End)
+		rp.Alignment.SetCellFloat("RefMs", r, ai.Ref)
+	}
+}
+
+// Histogram returns a table of the counts of the values in bins of width binMs, starting at 0
+func Histogram(vals []float64, binMs float64) *etable.Table {
+	maxv := 0.0
+	for _, v := range vals {
+		maxv = math.Max(maxv, v)
+	}
+	nbins := 0
+	if len(vals) > 0 && binMs > 0 {
+		nbins = int(maxv/binMs) + 1
+	}
+	dt := etable.New(etable.Schema{
+		{"BinMs", etensor.FLOAT64, nil, nil},
+		{"Count", etensor.INT64, nil, nil},
+	}, nbins)
+	counts := make([]int, nbins)
+	for _, v := range vals {
+		if v >= 0 {
+			counts[int(v/binMs)]++
+		}
+	}
+	for b, c := range counts {
+		dt.SetCellFloat("BinMs", b, float64(b)*binMs)
+		dt.SetCellFloat("Count", b, float64(c))
+	}
+	return dt
+}
+
+// Save saves the tables as tab separated files and the html report, using path prefix out
+func (rp *Report) Save(out string) error {
+	tabs := map[string]*etable.Table{"phones": rp.Phones, "durations": rp.Durations, "speakers": rp.Speakers, "files": rp.Files, "alignment": rp.Alignment}
+	for nm, dt := range tabs {
+		err := dt.SaveCSV(gi.FileName(out+"_"+nm+".tsv"), etable.Tab, etable.Headers)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	f, err := os.Create(out + ".html")
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer f.Close()
+	err = reportTmpl.Execute(f, rp)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// TableHTML holds the header and formatted cells of a table for the html template
+type TableHTML struct {
+	Header []string
+	Rows   [][]string
+}
+
+// ToHTML formats the table cells for the html template
+func ToHTML(dt *etable.Table) TableHTML {
+	th := TableHTML{Header: dt.ColNames}
+	for r := 0; r < dt.Rows; r++ {
+		row := make([]string, len(dt.Cols))
+		for c, col := range dt.Cols {
+			if col.DataType() == etensor.STRING {
+				row[c] = col.StringVal1D(r)
+			} else {
+				row[c] = fmt.Sprintf("%.4g", col.FloatVal1D(r))
+			}
+		}
+		th.Rows = append(th.Rows, row)
+	}
+	return th
+}
+
+// MaxCount returns the largest Count of the durations histogram, for scaling the bars
+func (rp *Report) MaxCount() float64 {
+	m := 1.0
+	for r := 0; r < rp.Durations.Rows; r++ {
+		m = math.Max(m, rp.Durations.CellFloat("Count", r))
+	}
+	return m
+}
+
+// Bars returns the durations histogram as label, count and bar width (percent) for the html template
+func (rp *Report) Bars() [][3]string {
+	mx := rp.MaxCount()
+	bars := make([][3]string, rp.Durations.Rows)
+	for r := range bars {
+		c := rp.Durations.CellFloat("Count", r)
+		bars[r] = [3]string{fmt.Sprintf("%g", rp.Durations.CellFloat("BinMs", r)), fmt.Sprintf("%g", c), fmt.Sprintf("%.1f", 100*c/mx)}
+	}
+	return bars
+}
+
+var reportTmpl = template.Must(template.New("report").Funcs(template.FuncMap{"html": ToHTML}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Name}} dataset report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; text-align: right; }
+.bar { background: #4a7ebb; height: 1em; }
+</style></head><body>
+<h1>{{.Name}} dataset report</h1>
+<p>{{.Files.Rows}} files, {{.Speakers.Rows}} speakers, {{.Phones.Rows}} phones (unit names), {{.Missing}} files could not be loaded, {{.Alignment.Rows}} unit timing issues.
+SNR is estimated from the 95th vs 5th percentile energy of 20 ms frames; samples with absolute value &ge; {{.ClipThr}} count as clipped.</p>
+{{define "table"}}<table><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>{{end}}
+<h2>Phones</h2>
+{{template "table" (html .Phones)}}
+<h2>Unit durations ({{.BinMs}} ms bins)</h2>
+<table><tr><th>BinMs</th><th>Count</th><th></th></tr>
+{{range .Bars}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td><td style="width:400px; text-align:left"><div class="bar" style="width:{{index . 2}}%"></div></td></tr>
+{{end}}</table>
+<h2>Speakers</h2>
+{{template "table" (html .Speakers)}}
+<h2>Files</h2>
+{{template "table" (html .Files)}}
+<h2>Alignment issues</h2>
+<p>Units that start before 0, are empty, end more than {{.AlignTolMs}} ms past the end of the audio, start before the previous unit
+or overlap it by more than {{.AlignTolMs}} ms. RefMs is the end of the audio or the previous unit's start or end.</p>
+{{template "table" (html .Alignment)}}
+</body></html>
+`))
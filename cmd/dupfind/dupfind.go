@@ -0,0 +1,116 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// dupfind finds duplicate and near-duplicate recordings in a dataset by their spectral fingerprints
+// (see sound.Fingerprint), so that copies of a recording don't leak from the training set into the test set.
+// The manifests are speech.Sequences JSON files (see speech.Sequences.SaveJSON), one per set, and the
+// candidate pairs are written as a tab separated etable file, with Leak set for pairs from different sets:
+//
+//	dupfind -manifest train.json,test.json -out reports/dups.tsv
+//
+// Build with the server tag to leave out wav playback: go build -tags server ./cmd/dupfind
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/auditory/sound"
+	"github.com/emer/auditory/speech"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// File is a sound file of one of the sets
+type File struct {
+	Path string
+	Set  string
+}
+
+func main() {
+	manifests := flag.String("manifest", "", "comma separated speech.Sequences JSON files, one per set (e.g. train and test)")
+	out := flag.String("out", "dups.tsv", "tab separated file of the duplicate pairs")
+	minHits := flag.Int("hits", 5, "minimum number of identical sub-fingerprints of a candidate pair")
+	maxBER := flag.Float64("ber", 0.35, "maximum bit error rate of a duplicate pair")
+	flag.Parse()
+	if *manifests == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var files []File
+	for _, mf := range strings.Split(*manifests, ",") {
+		var seqs speech.Sequences
+		if err := seqs.OpenJSON(mf); err != nil {
+			os.Exit(1)
+		}
+		set := strings.TrimSuffix(filepath.Base(mf), filepath.Ext(mf))
+		for _, seq := range seqs {
+			files = append(files, File{seq.File, set})
+		}
+	}
+
+	fi := &sound.FingerprintIndex{}
+	se := &sound.SndEnv{}
+	se.Defaults()
+	se.Params.PadShort = true
+	var idx []int // file of each fingerprint
+	for i, f := range files {
+		fp, err := Print(se, f.Path)
+		if err != nil {
+			continue
+		}
+		fi.Add(fp)
+		idx = append(idx, i)
+	}
+	dups := fi.Duplicates(*minHits, *maxBER)
+
+	dt := etable.New(etable.Schema{
+		{"FileA", etensor.STRING, nil, nil},
+		{"SetA", etensor.STRING, nil, nil},
+		{"FileB", etensor.STRING, nil, nil},
+		{"SetB", etensor.STRING, nil, nil},
+		{"ShiftMs", etensor.FLOAT64, nil, nil},
+		{"Hits", etensor.INT64, nil, nil},
+		{"BER", etensor.FLOAT64, nil, nil},
+		{"Overlap", etensor.INT64, nil, nil},
+		{"Leak", etensor.INT64, nil, nil},
+	}, len(dups))
+	leaks := 0
+	for row, dp := range dups {
+		a, b := files[idx[dp.A]], files[idx[dp.B]]
+		dt.SetCellString("FileA", row, a.Path)
+		dt.SetCellString("SetA", row, a.Set)
+		dt.SetCellString("FileB", row, b.Path)
+		dt.SetCellString("SetB", row, b.Set)
+		dt.SetCellFloat("ShiftMs", row, float64(dp.Shift)*se.Params.StepMs)
+		dt.SetCellFloat("Hits", row, float64(dp.Hits))
+		dt.SetCellFloat("BER", row, dp.BER)
+		dt.SetCellFloat("Overlap", row, float64(dp.Overlap))
+		if a.Set != b.Set {
+			dt.SetCellFloat("Leak", row, 1)
+			leaks++
+		}
+	}
+	if err := dt.SaveCSV(gi.FileName(*out), etable.Tab, etable.Headers); err != nil {
+		os.Exit(1)
+	}
+	fmt.Printf("dupfind: %v files, %v duplicate pairs, %v across sets\n", len(idx), len(dups), leaks)
+}
+
+// Print loads the sound file into se and returns its fingerprint
+func Print(se *sound.SndEnv, path string) (sound.Fingerprint, error) {
+	if err := se.Sound.Load(path); err != nil {
+		return nil, err
+	}
+	se.ToTensor()
+	if err := se.Init(); err != nil {
+		return nil, err
+	}
+	return se.Fingerprint(), nil
+}
@@ -0,0 +1,381 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// featbuild extracts the features of every unit of a corpus into feature stores (see sound.FeatWriter) that can be
+// read without loading them into memory (sound.OpenFeatStore). The manifest is a speech.Sequences JSON file
+// (see speech.Sequences.SaveJSON), one sequence per sound file. The files are processed in chunks, in parallel,
+// each chunk written to its own stores, one per feature, e.g., out/chunk0003_mel.feat, with records named
+// file:unit:name (file and unit indexes in the manifest). Completed chunks are recorded in out/progress.json,
+// so an interrupted job run again with the same arguments, and an unchanged manifest, resumes with the chunks that
+// were not completed.
+// When all the chunks are done the build is recorded in out/manifest.json (see sound.BuildManifest), with the
+// package version, processing parameters and checksums of the sound files:
+//
+//	featbuild -manifest train.json -out feats/train -features mel,gabor -chunk 100
+//
+// Build with the server tag to leave out wav playback: go build -tags server ./cmd/featbuild
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/emer/auditory/sound"
+	"github.com/emer/auditory/speech"
+	"github.com/emer/etable/etensor"
+)
+
+// Config is the configuration of a build, saved in the progress file so a resumed build uses the same one
+type Config struct {
+	Manifest  string
+	Features  []string
+	ChunkSize int
+	SegmentMs float64
+	Files     int
+
+	// sha256 of the manifest file, so a build isn't resumed with an edited manifest
+	ManifestSum string
+}
+
+// Progress is the checkpoint of a build, saved after each completed chunk
+type Progress struct {
+	Config Config
+
+	// the completed chunks, in order of completion
+	Done []int
+}
+
+// Builder processes the chunks of the manifest, recording the completed chunks in the progress file
+type Builder struct {
+	Out     string
+	Seqs    speech.Sequences
+	Workers int
+
+	mu   sync.Mutex
+	prog Progress
+	done map[int]bool
+}
+
+func main() {
+	manifest := flag.String("manifest", "", "speech.Sequences JSON file listing the sound files and their units")
+	out := flag.String("out", "", "directory of the feature stores and the progress file")
+	features := flag.String("features", "mel", "comma separated features to extract -- mel, mfcc, gabor")
+	chunk := flag.Int("chunk", 50, "number of sound files per chunk")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of chunks processed in parallel")
+	segMs := flag.Float64("segment", 100, "segment duration in milliseconds, the segment is centered on the unit")
+	restart := flag.Bool("restart", false, "ignore the progress of a previous build in out and process all the chunks")
+	flag.Parse()
+	if *manifest == "" || *out == "" || *chunk < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	b := &Builder{Out: *out, Workers: *workers}
+	if err := b.Seqs.OpenJSON(*manifest); err != nil {
+		os.Exit(1)
+	}
+	sum, err := FileSum(*manifest)
+	if err != nil {
+		os.Exit(1)
+	}
+	cfg := Config{Manifest: *manifest, ChunkSize: *chunk, SegmentMs: *segMs, Files: len(b.Seqs), ManifestSum: sum}
+	for _, f := range strings.Split(*features, ",") {
+		f = strings.TrimSpace(f)
+		if f != "mel" && f != "mfcc" && f != "gabor" {
+			fmt.Fprintf(os.Stderr, "featbuild: unknown feature %q\n", f)
+			os.Exit(1)
+		}
+		cfg.Features = append(cfg.Features, f)
+	}
+	if err := os.MkdirAll(*out, os.ModePerm); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+	if err := b.Resume(cfg, *restart); err != nil {
+		os.Exit(1)
+	}
+	if err := b.Run(); err != nil {
+		os.Exit(1)
+	}
+	if err := b.SaveManifest(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// FileSum returns the sha256 of the file, in hex
+func FileSum(path string) (string, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		log.Println(err)
+		return "", err
+	}
+	sum := sha256.Sum256(bs)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ProgressFile returns the path of the progress file
+func (b *Builder) ProgressFile() string {
+	return filepath.Join(b.Out, "progress.json")
+}
+
+// NChunks returns the number of chunks
+func (b *Builder) NChunks() int {
+	cs := b.prog.Config.ChunkSize
+	return (len(b.Seqs) + cs - 1) / cs
+}
+
+// Resume starts the build from the progress file, if there is one with the same config and restart is false,
+// otherwise from the beginning
+func (b *Builder) Resume(cfg Config, restart bool) error {
+	b.prog = Progress{Config: cfg}
+	b.done = map[int]bool{}
+	if restart {
+		return b.save()
+	}
+	bs, err := os.ReadFile(b.ProgressFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return b.save()
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	var prv Progress
+	if err := json.Unmarshal(bs, &prv); err != nil {
+		log.Println(err)
+		return err
+	}
+	if !reflect.DeepEqual(prv.Config, cfg) {
+		err := fmt.Errorf("featbuild: %v is from a build with a different config %+v, use -restart to start over", b.ProgressFile(), prv.Config)
+		log.Println(err)
+		return err
+	}
+	b.prog.Done = prv.Done
+	for _, c := range prv.Done {
+		b.done[c] = true
+	}
+	log.Printf("featbuild: resuming, %v of %v chunks done\n", len(b.done), b.NChunks())
+	return nil
+}
+
+// save writes the progress file, through a temporary file so an interruption leaves the previous one
+func (b *Builder) save() error {
+	bs, err := json.MarshalIndent(&b.prog, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	tmp := b.ProgressFile() + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0644); err != nil {
+		log.Println(err)
+		return err
+	}
+	err = os.Rename(tmp, b.ProgressFile())
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// Run processes the chunks not yet done with Workers goroutines, each with its own sound.SndBank
+func (b *Builder) Run() error {
+	var todo []int
+	for c := 0; c < b.NChunks(); c++ {
+		if !b.done[c] {
+			todo = append(todo, c)
+		}
+	}
+	chunks := make(chan int)
+	errs := make(chan error, len(todo))
+	var wg sync.WaitGroup
+	nw := b.Workers
+	if nw < 1 {
+		nw = 1
+	}
+	for w := 0; w < nw; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				if err := b.Chunk(c); err != nil {
+					errs <- err
+					continue
+				}
+				if err := b.complete(c); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for _, c := range todo {
+		chunks <- c
+	}
+	close(chunks)
+	wg.Wait()
+	close(errs)
+	nerr := 0
+	for range errs {
+		nerr++
+	}
+	if nerr > 0 {
+		err := fmt.Errorf("featbuild: %v chunks failed, run again to retry them", nerr)
+		log.Println(err)
+		return err
+	}
+	log.Printf("featbuild: all %v chunks done\n", b.NChunks())
+	return nil
+}
+
+// complete records chunk c as done and saves the progress
+func (b *Builder) complete(c int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done[c] = true
+	b.prog.Done = append(b.prog.Done, c)
+	sort.Ints(b.prog.Done)
+	log.Printf("featbuild: chunk %v done, %v of %v\n", c, len(b.done), b.NChunks())
+	return b.save()
+}
+
+// StorePath returns the path of the feature store of chunk c for the feature
+func (b *Builder) StorePath(c int, feat string) string {
+	return filepath.Join(b.Out, fmt.Sprintf("chunk%04d_%v.feat", c, feat))
+}
+
+// Configure sets the processing parameters of se for the config
+func (b *Builder) Configure(se *sound.SndEnv) {
+	cfg := &b.prog.Config
+	se.Params.SegmentMs = cfg.SegmentMs
+	for _, f := range cfg.Features {
+		switch f {
+		case "mfcc":
+			se.Mel.MFCC = true
+		case "gabor":
+			se.Kwta.On = false
+			se.NeighInhib.On = false
+			se.GaborDefaults()
+			se.SetGaborOut2D()
+		}
+	}
+}
+
+// SaveManifest saves the manifest of the build, with the config, the processing parameters and the checksums of the sound files
+func (b *Builder) SaveManifest() error {
+	bm := sound.NewBuildManifest()
+	if err := bm.AddParams("Config", &b.prog.Config); err != nil {
+		return err
+	}
+	se := &sound.SndEnv{}
+	se.Defaults()
+	b.Configure(se)
+	if err := bm.AddSndEnv("Snd.", se); err != nil {
+		return err
+	}
+	for i := range b.Seqs {
+		if err := bm.AddFile(b.Seqs[i].File); err != nil {
+			return err
+		}
+	}
+	return bm.SaveJSON(filepath.Join(b.Out, "manifest.json"))
+}
+
+// Chunk processes the units of the files of chunk c into its feature stores. The stores are written to
+// temporary files that are renamed when the chunk is complete, so an interrupted chunk is processed again from the start
+func (b *Builder) Chunk(c int) error {
+	cfg := &b.prog.Config
+	st := c * cfg.ChunkSize
+	ed := st + cfg.ChunkSize
+	if ed > len(b.Seqs) {
+		ed = len(b.Seqs)
+	}
+	sb := &sound.SndBank{}
+	sb.Defaults()
+	sb.MaxFiles = 1 // units are processed file by file
+	sb.Seqs = b.Seqs[st:ed]
+	b.Configure(&sb.Snd)
+
+	fws := make(map[string]*sound.FeatWriter, len(cfg.Features))
+	removeTmp := func() {
+		for _, ft := range cfg.Features {
+			tmp := b.StorePath(c, ft) + ".tmp"
+			os.Remove(tmp)
+			os.Remove(tmp + ".json")
+		}
+	}
+	closeAll := func() { // on an error, leaving no temporary files
+		for _, fw := range fws {
+			fw.Close()
+		}
+		removeTmp()
+	}
+	for _, f := range cfg.Features {
+		fw, err := sound.CreateFeatStore(b.StorePath(c, f) + ".tmp")
+		if err != nil {
+			closeAll()
+			return err
+		}
+		fws[f] = fw
+	}
+	for f := range sb.Seqs {
+		for u := range sb.Seqs[f].Units {
+			uf, err := sb.Process(f, u)
+			if err != nil {
+				closeAll()
+				return err
+			}
+			name := fmt.Sprintf("%v:%v:%v", st+f, u, uf.Name)
+			for ft, fw := range fws {
+				var tsr etensor.Tensor
+				switch ft {
+				case "mel":
+					tsr = &uf.Mel
+				case "mfcc":
+					tsr = &uf.MFCC
+				case "gabor":
+					tsr = &uf.Gabor
+				}
+				if err := fw.Write(name, tsr); err != nil {
+					closeAll()
+					return err
+				}
+			}
+		}
+	}
+	var err error
+	for _, fw := range fws {
+		if cerr := fw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		removeTmp()
+		return err
+	}
+	for _, ft := range cfg.Features {
+		tmp := b.StorePath(c, ft) + ".tmp"
+		if err := os.Rename(tmp+".json", b.StorePath(c, ft)+".json"); err != nil {
+			log.Println(err)
+			removeTmp()
+			return err
+		}
+		if err := os.Rename(tmp, b.StorePath(c, ft)); err != nil {
+			log.Println(err)
+			removeTmp()
+			return err
+		}
+	}
+	return nil
+}
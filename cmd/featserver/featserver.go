@@ -0,0 +1,317 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// featserver is a small http server that runs the sound.SndEnv feature extraction pipeline so that
+// distributed training jobs can offload or centralize feature extraction without linking this package.
+//
+// POST a mono wav file as the request body to /extract, e.g.
+//
+//	curl --data-binary @sound.wav "localhost:8080/extract?features=mel,gabor&segmentMs=100"
+//
+// The optional query parameters are features (any of mel, mfcc, power, gabor -- default mel),
+// winMs, stepMs, segmentMs, strideMs, borderSteps and melFilters -- see sound.Params and mel.Params.
+// The params are limited by MaxMelFilters, MaxSegmentSteps, MaxWinSamples and MaxValues, so one request can't use up the memory.
+// The response is a JSON Response with one tensor per segment for each requested feature.
+// GET /params returns the default parameters.
+//
+// Build with the server tag to leave out wav playback: go build -tags server ./cmd/featserver
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/emer/auditory/sound"
+	"github.com/emer/etable/etensor"
+)
+
+// Params are the processing parameters that can be set for each request
+type Params struct {
+	WinMs       float64
+	StepMs      float64
+	SegmentMs   float64
+	StrideMs    float64
+	BorderSteps int
+	MelFilters  int
+	Features    []string
+}
+
+// Tensor is a tensor in a JSON friendly form, values in row-major order
+type Tensor struct {
+	Shape  []int
+	Values []float64
+}
+
+// Response is the result of processing one sound
+type Response struct {
+	SampleRate int
+	Segments   int
+	Params     Params
+
+	// one tensor per segment for each feature requested, keyed by feature name
+	Features map[string][]Tensor
+}
+
+// MaxBytes is the largest wav file accepted
+var MaxBytes int64 = 64 << 20
+
+// MaxMelFilters is the largest melFilters accepted
+var MaxMelFilters = 256
+
+// MaxSegmentSteps is the largest number of steps of a segment accepted, including the border steps
+var MaxSegmentSteps = 1000
+
+// MaxWinSamples is the largest window accepted, in samples at the sample rate of the wav file
+var MaxWinSamples = 1 << 16
+
+// MaxValues is the largest number of feature values of a response, over all the segments and features
+var MaxValues = 1 << 24
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	flag.Parse()
+
+	http.HandleFunc("/extract", HandleExtract)
+	http.HandleFunc("/params", HandleParams)
+	log.Printf("featserver: listening on %v\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// DefaultParams returns the default sound.SndEnv params
+func DefaultParams() Params {
+	se := sound.SndEnv{}
+	se.Defaults()
+	return Params{WinMs: se.Params.WinMs, StepMs: se.Params.StepMs, SegmentMs: se.Params.SegmentMs,
+		StrideMs: se.Params.StrideMs, BorderSteps: se.Params.BorderSteps, MelFilters: se.NFilters(),
+		Features: []string{"mel"}}
+}
+
+// HandleParams writes the default params
+func HandleParams(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, DefaultParams())
+}
+
+// HandleExtract processes the wav file in the request body
+func HandleExtract(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a wav file", http.StatusMethodNotAllowed)
+		return
+	}
+	params, err := ParseParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBytes))
+	if err != nil {
+		if int64(len(b)) >= MaxBytes {
+			http.Error(w, fmt.Sprintf("featserver: wav file larger than %v bytes", MaxBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := Extract(b, params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// ParseParams gets the params from the request query, using the defaults for any not set, and checks that they
+// give at least one step per segment (and enough for the gabor filters, for the gabor feature), at least one mel
+// filter (enough for the mfcc coefficients and the gabor filters) and no more border steps than the steps of the segment,
+// and no more than MaxSegmentSteps steps and MaxMelFilters mel filters -- the durations in samples are checked by
+// CheckSamples, once the sample rate is known
+func ParseParams(r *http.Request) (Params, error) {
+	p := DefaultParams()
+	q := r.URL.Query()
+	floats := map[string]*float64{"winMs": &p.WinMs, "stepMs": &p.StepMs, "segmentMs": &p.SegmentMs, "strideMs": &p.StrideMs}
+	for k, v := range floats {
+		if s := q.Get(k); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || f <= 0 {
+				return p, fmt.Errorf("featserver: %v must be a number > 0", k)
+			}
+			*v = f
+		}
+	}
+	ints := map[string]*int{"borderSteps": &p.BorderSteps, "melFilters": &p.MelFilters}
+	for k, v := range ints {
+		if s := q.Get(k); s != "" {
+			i, err := strconv.Atoi(s)
+			if err != nil || i < 0 {
+				return p, fmt.Errorf("featserver: %v must be an integer >= 0", k)
+			}
+			*v = i
+		}
+	}
+	if s := q.Get("features"); s != "" {
+		p.Features = strings.Split(s, ",")
+		for _, f := range p.Features {
+			switch f {
+			case "mel", "mfcc", "power", "gabor":
+			default:
+				return p, fmt.Errorf("featserver: unknown feature %q, must be mel, mfcc, power or gabor", f)
+			}
+		}
+	}
+	if p.MelFilters < 1 || p.MelFilters > MaxMelFilters {
+		return p, fmt.Errorf("featserver: melFilters must be an integer from 1 to %v", MaxMelFilters)
+	}
+	if p.SegmentMs < p.StepMs {
+		return p, fmt.Errorf("featserver: segmentMs %v must be >= stepMs %v", p.SegmentMs, p.StepMs)
+	}
+	if segSteps := p.SegmentMs / p.StepMs; segSteps > float64(MaxSegmentSteps) || float64(p.BorderSteps) > segSteps {
+		return p, fmt.Errorf("featserver: segmentMs / stepMs must be <= %v, and borderSteps <= the steps of a segment", MaxSegmentSteps)
+	}
+	if steps := sound.SegmentSteps(p.SegmentMs, p.StepMs, p.BorderSteps); steps > MaxSegmentSteps {
+		return p, fmt.Errorf("featserver: segments of %v steps, including the border steps, more than %v", steps, MaxSegmentSteps)
+	}
+	for _, f := range p.Features {
+		if f == "mfcc" {
+			se := sound.SndEnv{}
+			se.Defaults()
+			if p.MelFilters < se.Mel.NCoefs {
+				return p, fmt.Errorf("featserver: the %v mfcc coefficients need melFilters >= %v", se.Mel.NCoefs, se.Mel.NCoefs)
+			}
+		}
+		if f != "gabor" {
+			continue
+		}
+		se := sound.SndEnv{}
+		se.GaborDefaults()
+		if steps := sound.SegmentSteps(p.SegmentMs, p.StepMs, p.BorderSteps); steps < se.GaborFilters.SizeX {
+			return p, fmt.Errorf("featserver: the gabor filters need segments of at least %v steps, segmentMs %v and borderSteps %v give %v",
+				se.GaborFilters.SizeX, p.SegmentMs, p.BorderSteps, steps)
+		}
+		if p.MelFilters < se.GaborFilters.SizeY {
+			return p, fmt.Errorf("featserver: the gabor filters need melFilters >= %v", se.GaborFilters.SizeY)
+		}
+	}
+	return p, nil
+}
+
+// CheckSamples checks that the window, step and stride of the params are each at least one sample at the sample rate,
+// and the window no more than MaxWinSamples
+func CheckSamples(p Params, sampleRate int) error {
+	for k, ms := range map[string]float64{"winMs": p.WinMs, "stepMs": p.StepMs, "strideMs": p.StrideMs} {
+		if sound.MSecToSamples(ms, sampleRate) < 1 {
+			return fmt.Errorf("featserver: %v %v is less than one sample at the sample rate %v", k, ms, sampleRate)
+		}
+	}
+	if win := sound.MSecToSamples(p.WinMs, sampleRate); win > MaxWinSamples {
+		return fmt.Errorf("featserver: winMs %v is %v samples at the sample rate %v, more than %v", p.WinMs, win, sampleRate, MaxWinSamples)
+	}
+	return nil
+}
+
+// Extract decodes the wav data and processes every segment
+func Extract(wavData []byte, params Params) (*Response, error) {
+	se := sound.SndEnv{}
+	se.Defaults()
+	se.Mel.MFCC = false
+	se.Kwta.On = false
+	se.NeighInhib.On = false
+	se.Params.WinMs = params.WinMs
+	se.Params.StepMs = params.StepMs
+	se.Params.SegmentMs = params.SegmentMs
+	se.Params.StrideMs = params.StrideMs
+	se.Params.BorderSteps = params.BorderSteps
+	se.Mel.FBank.NFilters = params.MelFilters
+	for _, f := range params.Features {
+		switch f {
+		case "mfcc":
+			se.Mel.MFCC = true
+		case "gabor":
+			se.GaborDefaults()
+			se.SetGaborOut2D()
+		}
+	}
+
+	err := se.Sound.Decode(bytes.NewReader(wavData))
+	if err != nil {
+		return nil, err
+	}
+	if se.Sound.Buf == nil || se.Sound.Channels() != 1 {
+		return nil, fmt.Errorf("featserver: wav data must be mono")
+	}
+	if err := CheckSamples(params, se.Sound.SampleRate()); err != nil {
+		return nil, err
+	}
+	se.ToTensor()
+	if se.NSamples() < sound.MSecToSamples(se.Params.SegmentMs, se.Sound.SampleRate()) {
+		return nil, fmt.Errorf("featserver: sound is shorter than one segment")
+	}
+	err = se.Init()
+	if err != nil {
+		return nil, err
+	}
+	nvals := 0 // per segment
+	for _, f := range params.Features {
+		switch f {
+		case "mel":
+			nvals += se.MelFBankSegment.Len()
+		case "mfcc":
+			nvals += se.MFCCSegment.Len()
+		case "power":
+			nvals += se.LogPowerSegment.Len()
+		case "gabor":
+			nvals += se.GborOutput.Len()
+		}
+	}
+	if nvals > 0 && se.SegCnt > MaxValues/nvals {
+		return nil, fmt.Errorf("featserver: %v segments of %v values, more than %v values -- use a longer strideMs or a shorter sound", se.SegCnt, nvals, MaxValues)
+	}
+
+	resp := &Response{SampleRate: se.Sound.SampleRate(), Segments: se.SegCnt, Params: params, Features: map[string][]Tensor{}}
+	for s := 0; s < se.SegCnt; s++ {
+		se.ProcessSegment(s, 0)
+		for _, f := range params.Features {
+			var tsr etensor.Tensor
+			switch f {
+			case "mel":
+				tsr = &se.MelFBankSegment
+			case "mfcc":
+				tsr = &se.MFCCSegment
+			case "power":
+				tsr = &se.LogPowerSegment
+			case "gabor":
+				tsr = se.ApplyGabor()
+			}
+			resp.Features[f] = append(resp.Features[f], ToTensor(tsr))
+		}
+	}
+	return resp, nil
+}
+
+// ToTensor copies the etensor -- NaN and infinite values, which JSON can't represent, are set to 0
+func ToTensor(tsr etensor.Tensor) Tensor {
+	t := Tensor{Shape: append([]int{}, tsr.Shapes()...), Values: make([]float64, tsr.Len())}
+	for i := range t.Values {
+		v := tsr.FloatVal1D(i)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			v = 0
+		}
+		t.Values[i] = v
+	}
+	return t
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// gaininv processes a sound file at several gains and reports how much each feature varies with the gain
+// (see sound.SndEnv.GainInvariance), to check which normalization settings make the features level-invariant:
+//
+//	gaininv -wav sa1.wav -gains -20,-10,0,10,20 -logoff 1
+//
+// Build with the server tag to leave out wav playback: go build -tags server ./cmd/gaininv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/emer/auditory/sound"
+)
+
+func main() {
+	wav := flag.String("wav", "", "sound file to process")
+	gains := flag.String("gains", "-20,-10,0,10,20", "comma separated gains in dB")
+	logOff := flag.Float64("logoff", 0, "offset added before the log of the mel filter bank output (Mel.FBank.LogOff)")
+	kwta := flag.Bool("kwta", false, "apply kwta to the gabor output")
+	flag.Parse()
+	if *wav == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	var dbs []float64
+	for _, s := range strings.Split(*gains, ",") {
+		db, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gaininv: bad gain %q\n", s)
+			os.Exit(1)
+		}
+		dbs = append(dbs, db)
+	}
+
+	se := sound.SndEnv{}
+	se.Defaults()
+	se.Mel.MFCC = true
+	se.Mel.FBank.LogOff = *logOff
+	se.Kwta.On = *kwta
+	se.KwtaPool = false // 2D gabor output
+	se.NeighInhib.On = false
+	se.GaborDefaults()
+	se.SetGaborOut2D()
+	if err := se.Sound.Load(*wav); err != nil {
+		os.Exit(1)
+	}
+	se.ToTensor()
+	dt, err := se.GainInvariance(dbs)
+	if err != nil {
+		os.Exit(1)
+	}
+	fmt.Printf("%-8s %12s %12s %8s\n", "Feature", "VarGain", "VarFeature", "Ratio")
+	for r := 0; r < dt.Rows; r++ {
+		fmt.Printf("%-8s %12.4g %12.4g %8.4f\n", dt.CellString("Feature", r), dt.CellFloat("VarGain", r), dt.CellFloat("VarFeature", r), dt.CellFloat("Ratio", r))
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// qbe searches a processed corpus for the segments most similar to a query by example (see sound.SearchIndex),
+// e.g., for exemplar based modeling. The corpus is the mel feature stores of featbuild, and the query is a part of a
+// sound file, processed with the default sound.SndEnv params as featbuild does. The hits are written as a tab
+// separated etable file, with the record names of featbuild (file:unit:name) and the times of the match within the record:
+//
+//	qbe -store 'feats/train/chunk*_mel.feat' -query word.wav -start 120 -end 480 -k 20 -out hits.tsv
+//
+// Build with the server tag to leave out wav playback: go build -tags server ./cmd/qbe
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/auditory/sound"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+func main() {
+	stores := flag.String("store", "", "comma separated mel feature stores (see featbuild), or glob patterns of them")
+	query := flag.String("query", "", "sound file of the query")
+	startMs := flag.Float64("start", 0, "start of the query in the sound file in milliseconds")
+	endMs := flag.Float64("end", 0, "end of the query in the sound file in milliseconds, 0 for the end of the file")
+	k := flag.Int("k", 10, "number of hits")
+	prefilter := flag.Int("prefilter", 0, "if > 0 only align this many records, those closest to the query on average (see sound.SearchIndex)")
+	out := flag.String("out", "hits.tsv", "tab separated file of the hits")
+	flag.Parse()
+	if *stores == "" || *query == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	se := &sound.SndEnv{}
+	se.Defaults()
+	se.Params.PadShort = true
+	qf, err := Query(se, *query, *startMs, *endMs)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	si := &sound.SearchIndex{Prefilter: *prefilter}
+	var open []*sound.FeatStore
+	for _, pat := range strings.Split(*stores, ",") {
+		paths, err := filepath.Glob(strings.TrimSpace(pat))
+		if err != nil || len(paths) == 0 {
+			fmt.Fprintf(os.Stderr, "qbe: no feature stores match %q\n", pat)
+			os.Exit(1)
+		}
+		for _, p := range paths {
+			fs, err := sound.OpenFeatStore(p)
+			if err != nil {
+				os.Exit(1)
+			}
+			si.AddStore(fs, filepath.Base(p)+":")
+			open = append(open, fs)
+		}
+	}
+	for _, fs := range open {
+		fs.Close() // the documents are copies
+	}
+
+	hits := si.Search(qf, *k)
+	dt := etable.New(etable.Schema{
+		{"Rank", etensor.INT64, nil, nil},
+		{"Record", etensor.STRING, nil, nil},
+		{"StartMs", etensor.FLOAT64, nil, nil},
+		{"EndMs", etensor.FLOAT64, nil, nil},
+		{"Dist", etensor.FLOAT64, nil, nil},
+		{"NormDist", etensor.FLOAT64, nil, nil},
+	}, len(hits))
+	for row, h := range hits {
+		dt.SetCellFloat("Rank", row, float64(row+1))
+		dt.SetCellString("Record", row, h.Name)
+		dt.SetCellFloat("StartMs", row, float64(h.Start)*se.Params.StepMs)
+		dt.SetCellFloat("EndMs", row, float64(h.End)*se.Params.StepMs)
+		dt.SetCellFloat("Dist", row, h.Dist)
+		dt.SetCellFloat("NormDist", row, h.NormDist)
+	}
+	if err := dt.SaveCSV(gi.FileName(*out), etable.Tab, etable.Headers); err != nil {
+		os.Exit(1)
+	}
+	fmt.Printf("qbe: query of %v frames, %v records, %v hits\n", len(qf), len(si.Docs), len(hits))
+}
+
+// Query loads the part of the sound file from startMs to endMs (0 for the end) into se and returns its mel frames
+func Query(se *sound.SndEnv, path string, startMs, endMs float64) ([][]float64, error) {
+	if err := se.Sound.Load(path); err != nil {
+		return nil, err
+	}
+	se.ToTensor()
+	sr := se.Sound.SampleRate()
+	st, ed := sound.MSecToSamples(startMs, sr), len(se.Signal.Values)
+	if endMs > 0 && sound.MSecToSamples(endMs, sr) < ed {
+		ed = sound.MSecToSamples(endMs, sr)
+	}
+	if st < 0 || st >= ed {
+		err := fmt.Errorf("qbe: query %v..%v ms is empty", startMs, endMs)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+	se.Signal.Values = append([]float64{}, se.Signal.Values[st:ed]...)
+	se.Signal.SetShape([]int{ed - st}, nil, nil)
+	if err := se.Init(); err != nil {
+		return nil, err
+	}
+	return se.UtteranceFrames(), nil
+}
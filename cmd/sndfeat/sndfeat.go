@@ -0,0 +1,273 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// sndfeat extracts the features of every segment of a set of sound files, the wav files of a directory (searched
+// recursively) or those listed in a file, one per line, and writes them to out, one tensor per file and feature,
+// [segments, ...] (see auditory.Pipeline), as NumPy .npy files, e.g., out/sa1_mel.npy, tab separated CSV files or,
+// for the feat format, one feature store per feature (see sound.FeatWriter), e.g., out/mel.feat, with a record per
+// file named by its path -- or, for the npz format, one NumPy .npz file per file of all its features, e.g.,
+// out/sa1.npz with arrays mel, mfcc and gabor (see export.SaveNPZ) -- or, for the arrow format, one dataset of all
+// the files, out/features.arrow (see export.DatasetWriter), with each segment labeled by the TIMIT phone at its
+// center if phn is set, from the .PHN.MS file of the sound file. The processing params are the defaults of auditory.NewPipeline, overridden by those in
+// the optional JSON config (see sound.Config), e.g.:
+//
+//	{"Params": {"SegmentMs": 200, "StrideMs": 100}, "Mel": {"FBank": {"NFilters": 40}},
+//	 "GaborFilters": {"SizeX": 8, "StrideX": 4}, "GaborSpecs": [{"WaveLen": 2, "Orientation": 0, "SigmaWidth": 0.5, "SigmaLength": 0.5}]}
+//
+// The summary of the processing (see sound.SndEnv.Describe) is written to out/sndfeat.txt:
+//
+//	sndfeat -dir corpus/wav -out feats -features mel,mfcc,gabor -format npy -config sndfeat.json
+//
+// Build with the server tag to leave out wav playback: go build -tags server ./cmd/sndfeat
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/auditory"
+	"github.com/emer/auditory/export"
+	"github.com/emer/auditory/sound"
+	"github.com/emer/auditory/speech"
+	"github.com/emer/auditory/speech/timit"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// outputs are the pipeline outputs of the features
+var outputs = map[string]string{"mel": "Mel", "mfcc": "MFCC", "gabor": "Gabor"}
+
+func main() {
+	dir := flag.String("dir", "", "directory of the wav files, searched recursively")
+	list := flag.String("list", "", "file listing the sound files, one per line, instead of dir")
+	out := flag.String("out", "", "directory of the feature files")
+	features := flag.String("features", "mel", "comma separated features to extract -- mel, mfcc, gabor")
+	format := flag.String("format", "npy", "format of the feature files -- npy, npz, csv, feat or arrow")
+	phn := flag.Bool("phn", false, "for the arrow format, label the segments by the phones of the TIMIT .PHN.MS file of each sound file")
+	config := flag.String("config", "", "JSON file of the processing params, see sound.Config")
+	kwta := flag.Bool("kwta", false, "apply kwta to the gabor output")
+	workers := flag.Int("workers", 0, "number of goroutines processing the segments of each file, 0 for the number of cpus")
+	flag.Parse()
+	if (*dir == "") == (*list == "") || *out == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *format != "npy" && *format != "npz" && *format != "csv" && *format != "feat" && *format != "arrow" {
+		fmt.Fprintf(os.Stderr, "sndfeat: unknown format %q\n", *format)
+		os.Exit(1)
+	}
+
+	opts := []auditory.Option{auditory.WithCache(&sound.FilterCache{})}
+	var feats []string
+	for _, f := range strings.Split(*features, ",") {
+		f = strings.TrimSpace(f)
+		switch f {
+		case "mel":
+		case "mfcc":
+			opts = append(opts, auditory.WithMFCC())
+		case "gabor":
+			opts = append(opts, auditory.WithGabors(nil))
+			if *kwta {
+				opts = append(opts, auditory.WithKWTA())
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "sndfeat: unknown feature %q\n", f)
+			os.Exit(1)
+		}
+		feats = append(feats, f)
+	}
+	pl := auditory.NewPipeline(opts...)
+	pl.Snd.Workers = *workers
+	if *config != "" {
+		if err := pl.Snd.OpenConfig(*config); err != nil {
+			os.Exit(1)
+		}
+	}
+	if *kwta {
+		outputs["gabor"] = "GaborKwta"
+	}
+
+	var files []string
+	var err error
+	if *dir != "" {
+		files, err = WavFiles(*dir)
+	} else {
+		files, err = ListFiles(*list)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "sndfeat: no sound files\n")
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(*out, os.ModePerm); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	fws := map[string]*sound.FeatWriter{}
+	if *format == "feat" {
+		for _, f := range feats {
+			fw, err := sound.CreateFeatStore(filepath.Join(*out, f+".feat"))
+			if err != nil {
+				os.Exit(1)
+			}
+			fws[f] = fw
+		}
+	}
+	var dw *export.DatasetWriter
+	if *format == "arrow" {
+		if dw, err = export.CreateDataset(filepath.Join(*out, "features.arrow")); err != nil {
+			os.Exit(1)
+		}
+	}
+	nerr := 0
+	for i, fn := range files {
+		outs, err := pl.Process(fn)
+		if err != nil {
+			nerr++
+			continue
+		}
+		if i == 0 {
+			if err := os.WriteFile(filepath.Join(*out, "sndfeat.txt"), []byte(pl.Describe()), 0644); err != nil {
+				log.Println(err)
+			}
+		}
+		base := OutName(fn, *dir)
+		if *format == "arrow" {
+			tsrs := map[string]etensor.Tensor{}
+			for _, f := range feats {
+				tsrs[f] = outs[outputs[f]]
+			}
+			var units []speech.Unit
+			if *phn {
+				units, _ = timit.LoadTimes(PhnFile(fn), nil, false)
+			}
+			if err := dw.Append(fn, &pl.Snd, tsrs, units); err != nil {
+				nerr++
+			}
+			log.Printf("sndfeat: %v, %v of %v\n", fn, i+1, len(files))
+			continue
+		}
+		if *format == "npz" {
+			tsrs := map[string]etensor.Tensor{}
+			for _, f := range feats {
+				tsrs[f] = outs[outputs[f]]
+			}
+			if err := Save(filepath.Join(*out, base+".npz"), nil, func(_ etensor.Tensor, path string) error {
+				return export.SaveNPZ(tsrs, path)
+			}); err != nil {
+				nerr++
+			}
+			log.Printf("sndfeat: %v, %v of %v\n", fn, i+1, len(files))
+			continue
+		}
+		for _, f := range feats {
+			tsr := outs[outputs[f]]
+			switch *format {
+			case "feat":
+				err = fws[f].Write(fn, tsr)
+			case "npy":
+				err = Save(filepath.Join(*out, base+"_"+f+".npy"), tsr, export.SaveNPY)
+			case "csv":
+				err = Save(filepath.Join(*out, base+"_"+f+".csv"), tsr, func(tsr etensor.Tensor, path string) error {
+					return etensor.SaveCSV(tsr, gi.FileName(path), '\t')
+				})
+			}
+			if err != nil {
+				nerr++
+			}
+		}
+		log.Printf("sndfeat: %v, %v of %v\n", fn, i+1, len(files))
+	}
+	for _, fw := range fws {
+		if err := fw.Close(); err != nil {
+			nerr++
+		}
+	}
+	if dw != nil {
+		if err := dw.Close(); err != nil {
+			nerr++
+		}
+	}
+	if nerr > 0 {
+		fmt.Fprintf(os.Stderr, "sndfeat: %v errors\n", nerr)
+		os.Exit(1)
+	}
+}
+
+// WavFiles returns the wav files in dir and its subdirectories
+func WavFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.EqualFold(filepath.Ext(path), ".wav") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println(err)
+	}
+	return files, err
+}
+
+// ListFiles returns the files listed in the file, one per line, skipping blank lines and lines starting with #
+func ListFiles(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer f.Close()
+	var files []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		ln := strings.TrimSpace(sc.Text())
+		if ln == "" || strings.HasPrefix(ln, "#") {
+			continue
+		}
+		files = append(files, ln)
+	}
+	if err := sc.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return files, nil
+}
+
+// OutName returns the path of the feature files of the sound file, relative to out and without the feature and
+// extension: its path relative to dir, or its base name for listed files
+func OutName(fn, dir string) string {
+	name := filepath.Base(fn)
+	if dir != "" {
+		if rel, err := filepath.Rel(dir, fn); err == nil {
+			name = rel
+		}
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// PhnFile returns the path of the TIMIT phone times in milliseconds of the sound file, e.g., SA1.PHN.MS for SA1.WAV,
+// as read by timit.LoadTimes
+func PhnFile(fn string) string {
+	return strings.TrimSuffix(fn, filepath.Ext(fn)) + ".PHN.MS"
+}
+
+// Save saves the tensor to path by save, making the directory of path first
+func Save(path string, tsr etensor.Tensor, save func(tsr etensor.Tensor, path string) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		log.Println(err)
+		return err
+	}
+	return save(tsr, path)
+}
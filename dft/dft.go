@@ -5,10 +5,12 @@
 package dft
 
 import (
+	"encoding/json"
+	"log"
 	"math"
+	"os"
 
 	"github.com/emer/etable/etensor"
-	"gonum.org/v1/gonum/dsp/fourier"
 )
 
 // Dft struct holds the variables for doing a fourier transform
@@ -28,6 +30,21 @@ type Params struct {
 
 	//  how much of current power to include
 	CurSmooth float64 `inactive:"+" desc:" how much of current power to include"`
+
+	// the FFT implementation -- FFTGonum (the default) or FFTRadix2, the radix-2 FFT of this package, e.g., for builds of this package with the nogonum tag
+	Backend FFTBackends `desc:"the FFT implementation -- FFTGonum (the default) or FFTRadix2, the radix-2 FFT of this package, e.g., for builds of this package with the nogonum tag"`
+
+	// [def: WindowHamming] the window function tapering each window of samples before the FFT, reducing the spectral leakage of the power -- WindowRectangular for none
+	WindowType WindowTypes `default:"WindowHamming" desc:"the window function tapering each window of samples before the FFT, reducing the spectral leakage of the power -- WindowRectangular for none"`
+
+	// the FFT, for reuse while the window length and backend are the same
+	fft        FFT
+	fftN       int
+	fftBackend FFTBackends
+
+	// the coefficients of the window function, for reuse while the window length and type are the same
+	taper     []float64
+	taperType WindowTypes
 }
 
 func (dft *Params) Defaults() {
@@ -36,24 +53,45 @@ func (dft *Params) Defaults() {
 	dft.CompLogPow = true
 	dft.LogOffSet = 1.0
 	dft.LogMin = -100
+	dft.WindowType = WindowHamming
 }
 
 // Filter filters the current window_in input data according to current settings -- called by ProcessStep, but can be called separately
 func (dft *Params) Filter(step int, windowIn *etensor.Float64, winSamples int, power *etensor.Float64, logPower *etensor.Float64, powerForSegment *etensor.Float64, logPowerForSegment *etensor.Float64) {
 	fftCoefs := make([]complex128, winSamples)
 	dft.FftReal(fftCoefs, windowIn)
-	fft := fourier.NewCmplxFFT(len(fftCoefs))
-	fftCoefs = fft.Coefficients(nil, fftCoefs)
+	fftCoefs = dft.FFT(winSamples).Coefficients(fftCoefs, fftCoefs)
 	dft.Power(step, winSamples, fftCoefs, power, logPower, powerForSegment, logPowerForSegment)
-	fft = nil
-	fftCoefs = nil
 }
 
-// FftReal
+// FFT returns the FFT of length n from the Backend, reusing the previous one if n and the Backend are the same
+func (dft *Params) FFT(n int) FFT {
+	if dft.fft == nil || dft.fftN != n || dft.fftBackend != dft.Backend {
+		dft.fft = NewFFT(dft.Backend, n)
+		dft.fftN = n
+		dft.fftBackend = dft.Backend
+	}
+	return dft.fft
+}
+
+// ResetCache drops the FFT and window coefficients kept for reuse, e.g., for a copy of the params used in another
+// goroutine, as the FFTs hold buffers that are not safe for concurrent use
+func (dft *Params) ResetCache() {
+	dft.fft = nil
+	dft.fftN = 0
+	dft.taper = nil
+}
+
+// FftReal sets the coefficients to the samples of in, tapered by the WindowType (see Taper)
 func (dft *Params) FftReal(fftCoefs []complex128, in *etensor.Float64) {
+	taper := dft.Taper(len(fftCoefs))
 	var c complex128
 	for i := 0; i < len(fftCoefs); i++ {
-		c = complex(in.FloatVal1D(i), 0)
+		v := in.FloatVal1D(i)
+		if taper != nil {
+			v *= taper[i]
+		}
+		c = complex(v, 0)
 		fftCoefs[i] = c
 	}
 }
@@ -83,3 +121,32 @@ func (dft *Params) Power(step int, winSamples int, fftCoefs []complex128, power
 		}
 	}
 }
+
+// OpenJSON opens the params from a JSON-formatted file, e.g., saved by SaveJSON -- the params not in the file are unchanged
+func (dft *Params) OpenJSON(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	err = json.Unmarshal(b, dft)
+	if err != nil {
+		log.Println(err)
+	}
+	dft.ResetCache() // the backend or window may have changed
+	return err
+}
+
+// SaveJSON saves the params to a JSON-formatted file
+func (dft *Params) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(dft, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
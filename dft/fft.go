@@ -0,0 +1,163 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dft
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/cmplx"
+)
+
+// FFT computes the discrete fourier transform of sequences of a fixed length
+type FFT interface {
+
+	// Coefficients returns the fourier coefficients of seq, in dst if it has the length of seq, otherwise in a new slice
+	Coefficients(dst, seq []complex128) []complex128
+}
+
+// FFTBackends are the FFT implementations, see RegisterFFT
+type FFTBackends int32
+
+const (
+	// FFTGonum is the gonum fourier package, left out of this package when building with the nogonum tag -- the other
+	// packages of the module (mel, sound) use gonum directly, so the module as a whole still needs it
+	FFTGonum FFTBackends = iota
+
+	// FFTRadix2 is the radix-2 FFT of this package, with no dependencies -- lengths that aren't a power of 2 use Bluestein's algorithm
+	FFTRadix2
+)
+
+// fftBackends are the registered FFT constructors
+var fftBackends = map[FFTBackends]func(n int) FFT{
+	FFTRadix2: func(n int) FFT { return NewRadix2(n) },
+}
+
+// RegisterFFT adds a backend, e.g., an adapter of another FFT package (such as go-dsp) under a new FFTBackends value
+func RegisterFFT(backend FFTBackends, newFFT func(n int) FFT) {
+	fftBackends[backend] = newFFT
+}
+
+// NewFFT returns an FFT of length n from the backend, or from FFTRadix2 if the backend isn't registered (logged)
+func NewFFT(backend FFTBackends, n int) FFT {
+	nf, ok := fftBackends[backend]
+	if !ok {
+		log.Printf("dft.NewFFT: backend %v is not registered, using FFTRadix2\n", backend)
+		nf = fftBackends[FFTRadix2]
+	}
+	return nf(n)
+}
+
+// Radix2 is an iterative radix-2 FFT, or Bluestein's chirp-z algorithm (using radix-2 FFTs) for lengths that aren't a power of 2
+type Radix2 struct {
+	n       int
+	twiddle []complex128 // exp(-2 pi i k / n) for the power of 2 length
+
+	// Bluestein, for n not a power of 2
+	m     int          // power of 2 length of the convolution
+	inner *Radix2      // FFT of length m
+	chirp []complex128 // exp(-pi i k^2 / n)
+	bconv []complex128 // FFT of the conjugate chirp, wrapped
+	buf   []complex128
+}
+
+// NewRadix2 returns a Radix2 FFT of length n
+func NewRadix2(n int) *Radix2 {
+	r := &Radix2{n: n}
+	if n&(n-1) == 0 {
+		r.twiddle = make([]complex128, n/2)
+		for k := range r.twiddle {
+			r.twiddle[k] = cmplx.Rect(1, -2*math.Pi*float64(k)/float64(n))
+		}
+		return r
+	}
+	r.m = 1
+	for r.m < 2*n-1 {
+		r.m <<= 1
+	}
+	r.inner = NewRadix2(r.m)
+	r.chirp = make([]complex128, n)
+	for k := range r.chirp {
+		kk := (k * k) % (2 * n) // keep the angle small for precision
+		r.chirp[k] = cmplx.Rect(1, -math.Pi*float64(kk)/float64(n))
+	}
+	r.bconv = make([]complex128, r.m)
+	r.bconv[0] = cmplx.Conj(r.chirp[0])
+	for k := 1; k < n; k++ {
+		r.bconv[k] = cmplx.Conj(r.chirp[k])
+		r.bconv[r.m-k] = r.bconv[k]
+	}
+	r.inner.transform(r.bconv)
+	r.buf = make([]complex128, r.m)
+	return r
+}
+
+// Coefficients returns the fourier coefficients of seq, see FFT
+func (r *Radix2) Coefficients(dst, seq []complex128) []complex128 {
+	if len(seq) != r.n {
+		panic(fmt.Sprintf("dft.Radix2: sequence length %v is not the FFT length %v", len(seq), r.n))
+	}
+	if len(dst) != r.n {
+		dst = make([]complex128, r.n)
+	}
+	if r.inner == nil {
+		copy(dst, seq)
+		r.transform(dst)
+		return dst
+	}
+	for k := range r.buf {
+		r.buf[k] = 0
+	}
+	for k, v := range seq {
+		r.buf[k] = v * r.chirp[k]
+	}
+	r.inner.transform(r.buf)
+	for k := range r.buf {
+		r.buf[k] *= r.bconv[k]
+	}
+	r.inner.inverse(r.buf)
+	for k := range dst {
+		dst[k] = r.buf[k] * r.chirp[k]
+	}
+	return dst
+}
+
+// transform does the in place FFT of x, whose length is the power of 2 length of r
+func (r *Radix2) transform(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ { // bit reversal permutation
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		step := n / size
+		for st := 0; st < n; st += size {
+			for k := 0; k < half; k++ {
+				t := r.twiddle[k*step] * x[st+k+half]
+				x[st+k+half] = x[st+k] - t
+				x[st+k] += t
+			}
+		}
+	}
+}
+
+// inverse does the in place inverse FFT of x, scaled by 1/n
+func (r *Radix2) inverse(x []complex128) {
+	for k := range x {
+		x[k] = cmplx.Conj(x[k])
+	}
+	r.transform(x)
+	sc := 1 / float64(len(x))
+	for k := range x {
+		x[k] = cmplx.Conj(x[k]) * complex(sc, 0)
+	}
+}
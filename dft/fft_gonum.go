@@ -0,0 +1,14 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !nogonum
+// +build !nogonum
+
+package dft
+
+import "gonum.org/v1/gonum/dsp/fourier"
+
+func init() {
+	RegisterFFT(FFTGonum, func(n int) FFT { return fourier.NewCmplxFFT(n) })
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dft
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// naiveDFT is the direct computation of the discrete fourier transform
+func naiveDFT(seq []complex128) []complex128 {
+	n := len(seq)
+	out := make([]complex128, n)
+	for k := range out {
+		for j, v := range seq {
+			out[k] += v * cmplx.Rect(1, -2*math.Pi*float64((j*k)%n)/float64(n))
+		}
+	}
+	return out
+}
+
+// checkFFT compares the coefficients of the FFT with the direct DFT of a random sequence of length n
+func checkFFT(t *testing.T, name string, fft FFT, n int, rnd *rand.Rand) {
+	t.Helper()
+	seq := make([]complex128, n)
+	for k := range seq {
+		seq[k] = complex(rnd.Float64()*2-1, rnd.Float64()*2-1)
+	}
+	ref := naiveDFT(seq)
+	cs := fft.Coefficients(nil, seq)
+	if len(cs) != n {
+		t.Fatalf("%v n %v: %v coefficients", name, n, len(cs))
+	}
+	tol := 1e-10 * float64(n)
+	for k := range cs {
+		if d := cmplx.Abs(cs[k] - ref[k]); d > tol {
+			t.Errorf("%v n %v: coefficient %v differs from the direct DFT by %v", name, n, k, d)
+			return
+		}
+	}
+}
+
+func TestRadix2(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 4, 8, 64, 512, 1024} {
+		checkFFT(t, "radix-2", NewRadix2(n), n, rnd)
+	}
+}
+
+func TestBluestein(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for _, n := range []int{3, 5, 6, 7, 100, 400, 441, 551} { // 400 and 551 are 25 ms windows at 16 and 22.05 kHz
+		checkFFT(t, "bluestein", NewRadix2(n), n, rnd)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	for b, nf := range fftBackends {
+		for _, n := range []int{16, 400} {
+			checkFFT(t, fmt.Sprintf("backend %v", b), nf(n), n, rnd)
+		}
+	}
+}
+
+func TestCoefficientsDst(t *testing.T) {
+	fft := NewRadix2(6)
+	seq := []complex128{1, 2, 3, 4, 5, 6}
+	dst := make([]complex128, 6)
+	if cs := fft.Coefficients(dst, seq); &cs[0] != &dst[0] {
+		t.Error("Coefficients did not use dst of the FFT length")
+	}
+	if cs := fft.Coefficients(make([]complex128, 3), seq); len(cs) != 6 {
+		t.Errorf("Coefficients with a short dst returned %v coefficients", len(cs))
+	}
+}
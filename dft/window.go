@@ -0,0 +1,60 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dft
+
+import "math"
+
+// WindowTypes are the window functions tapering each window of samples before the FFT, see Params.WindowType
+type WindowTypes int32
+
+const (
+	// WindowRectangular leaves the samples as they are, with the most spectral leakage
+	WindowRectangular WindowTypes = iota
+
+	// WindowHamming is 0.54 - 0.46 cos(2 pi i / (n-1)), the usual window of speech front ends
+	WindowHamming
+
+	// WindowHann is 0.5 - 0.5 cos(2 pi i / (n-1)), going to 0 at the ends
+	WindowHann
+
+	// WindowBlackman is 0.42 - 0.5 cos(2 pi i / (n-1)) + 0.08 cos(4 pi i / (n-1)), with the least leakage but the widest peaks
+	WindowBlackman
+)
+
+// WindowCoefs returns the n coefficients of the window function, all 1 for WindowRectangular
+func WindowCoefs(wt WindowTypes, n int) []float64 {
+	coefs := make([]float64, n)
+	for i := range coefs {
+		if n == 1 {
+			coefs[i] = 1
+			continue
+		}
+		x := 2 * math.Pi * float64(i) / float64(n-1)
+		switch wt {
+		case WindowHamming:
+			coefs[i] = 0.54 - 0.46*math.Cos(x)
+		case WindowHann:
+			coefs[i] = 0.5 - 0.5*math.Cos(x)
+		case WindowBlackman:
+			coefs[i] = 0.42 - 0.5*math.Cos(x) + 0.08*math.Cos(2*x)
+		default:
+			coefs[i] = 1
+		}
+	}
+	return coefs
+}
+
+// Taper returns the coefficients of the WindowType for windows of n samples, reusing the previous ones if n and
+// the WindowType are the same -- nil for WindowRectangular
+func (dft *Params) Taper(n int) []float64 {
+	if dft.WindowType == WindowRectangular {
+		return nil
+	}
+	if len(dft.taper) != n || dft.taperType != dft.WindowType {
+		dft.taper = WindowCoefs(dft.WindowType, n)
+		dft.taperType = dft.WindowType
+	}
+	return dft.taper
+}
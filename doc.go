@@ -3,7 +3,7 @@
 // license that can be found in the LICENSE file.
 
 /*
-Package auditory is the overall repository for audition processing code in Go (golang) focused on filtering speech wav files via mel filters. A further step using gabors provides filtering for input to neural networks. The processing code is split into 4 packages, sound, mel, dft and agabor, that can be used independently. A fifth package, trm, is a work in progress port of Gnuspeech. Example code is in examples/processspeech.
+Package auditory is the overall repository for audition processing code in Go (golang) focused on filtering speech wav files via mel filters. A further step using gabors provides filtering for input to neural networks. The processing code is split into 4 packages, sound, mel, dft and agabor, that can be used independently. A fifth package, trm, is a work in progress port of Gnuspeech. Example code is in examples/processspeech. For the common case, a wav file in and its mel, mfcc and gabor output out, NewPipeline sets up the processing in one call, with options such as WithMFCC, WithGabors and WithKWTA.
 
 The `sound` package contains code for loading a wav file into a buffer and then converting to a floating point tensor. There are functions for trimming and padding.
 
@@ -4,34 +4,27 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/emer/auditory/agabor"
-	"github.com/emer/auditory/dft"
-	"github.com/emer/auditory/mel"
 	"github.com/emer/auditory/sound"
+	"github.com/emer/auditory/sound/img"
 	"github.com/emer/auditory/speech"
-	"github.com/emer/auditory/speech/grafestes"
-	"github.com/emer/auditory/speech/synthcvs"
 	"github.com/emer/auditory/speech/timit"
 	"github.com/emer/emergent/egui"
+	"github.com/emer/etable/eplot"
 	"github.com/emer/etable/etable"
 	"github.com/emer/etable/etensor"
 	"github.com/emer/etable/etview"
-	"github.com/emer/leabra/fffb"
-	"github.com/emer/vision/kwta"
 	"github.com/goki/gi/gi"
 	"github.com/goki/gi/gimain"
 	"github.com/goki/gi/giv"
 	"github.com/goki/ki/ki"
 	"github.com/goki/ki/kit"
-	"gonum.org/v1/gonum/dsp/fourier"
 )
 
 func main() {
@@ -69,132 +62,6 @@ type Table struct {
 	Sels []string `desc:"selected row ids in ascending order in view"`
 }
 
-// CurSnd meta info for the sound processed
-type CurSnd struct {
-	Sound string
-	StEnd string
-	Path  string
-	Name  string
-}
-
-// WinParams defines the sound input parameters for auditory processing
-type WinParams struct {
-
-	// [def: 25] input window -- number of milliseconds worth of sound to filter at a time
-	WinMs float64 `default:"25" desc:"input window -- number of milliseconds worth of sound to filter at a time"`
-
-	// [def: 10] input step -- number of milliseconds worth of sound that the input is stepped along to obtain the next window sample
-	StepMs float64 `default:"10" desc:"input step -- number of milliseconds worth of sound that the input is stepped along to obtain the next window sample"`
-
-	// start of sound segment in milliseconds
-	SegmentStart float64 `desc:"start of sound segment in milliseconds"`
-
-	// end of sound segment in milliseconds
-	SegmentEnd float64 `desc:"end of sound segment in milliseconds"`
-
-	// [def: 0] overlap with previous and next segment
-	BorderSteps int `default:"0" desc:"overlap with previous and next segment"`
-
-	// specific channel to process, if input has multiple channels, and we only process one of them (-1 = process all)
-	Channel int `desc:"specific channel to process, if input has multiple channels, and we only process one of them (-1 = process all)"`
-
-	// if resize is true segment durations will be lengthened (a bit before and a bit after) to be align with gabor filter size and striding
-	Resize bool `desc:"if resize is true segment durations will be lengthened (a bit before and a bit after) to be align with gabor filter size and striding"`
-
-	// use the user entered start/end times, ignoring the current sound selection times, the current file will be used
-	TimeMode bool `desc:"use the user entered start/end times, ignoring the current sound selection times, the current file will be used"`
-
-	// [view: -] number of samples to process each step
-	WinSamples int `view:"-" desc:"number of samples to process each step"`
-
-	// [view: -] number of samples to step input by
-	StepSamples int `view:"-" desc:"number of samples to step input by"`
-
-	// [view: -] SegmentSteps plus steps overlapping next segment or for padding if no next segment
-	StepsTotal int `view:"-" desc:"SegmentSteps plus steps overlapping next segment or for padding if no next segment"`
-
-	// [view: -] pre-calculated start position for each step
-	Steps []int `view:"-" desc:"pre-calculated start position for each step"`
-}
-
-type ProcessParams struct {
-
-	// [view: inline]
-	Dft dft.Params `view:"inline" desc:""`
-
-	// [view: +] power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)
-	Power etensor.Float64 `view:"+" desc:"power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)"`
-
-	// [view: +] log power of the dft, up to the nyquist liit frequency (1/2 input.WinSamples)
-	LogPower etensor.Float64 `view:"+" desc:"log power of the dft, up to the nyquist liit frequency (1/2 input.WinSamples)"`
-
-	// [view: no-inline] full segment's worth of power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)
-	PowerSegment etensor.Float64 `view:"no-inline" desc:"full segment's worth of power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)"`
-
-	// [view: no-inline] full segment's worth of log power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)
-	LogPowerSegment etensor.Float64 `view:"no-inline" desc:"full segment's worth of log power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)"`
-
-	// [view: no-inline] sum of log power per segment step
-	Energy etensor.Float64 `view:"no-inline" desc:"sum of log power per segment step"`
-
-	// [view: inline]
-	Mel mel.Params `view:"inline"`
-
-	// [view: no-inline] mel scale transformation of dft_power, using triangular filters, resulting in the mel filterbank output -- the natural log of this is typically applied
-	MelFBank etensor.Float64 `view:"no-inline" desc:"mel scale transformation of dft_power, using triangular filters, resulting in the mel filterbank output -- the natural log of this is typically applied"`
-
-	// [view: no-inline] full segment's worth of mel feature-bank output
-	MelFBankSegment etensor.Float64 `view:"no-inline" desc:"full segment's worth of mel feature-bank output"`
-
-	// [view: no-inline] the actual filters
-	MelFilters etensor.Float64 `view:"no-inline" desc:"the actual filters"`
-
-	// [view: no-inline] discrete cosine transform of the log_mel_filter_out values, producing the final mel-frequency cepstral coefficients
-	MFCCDct etensor.Float64 `view:"no-inline" desc:"discrete cosine transform of the log_mel_filter_out values, producing the final mel-frequency cepstral coefficients"`
-
-	// [view: no-inline] full segment's worth of discrete cosine transform of the log_mel_filter_out values, producing the final mel-frequency cepstral coefficients
-	MFCCSegment etensor.Float64 `view:"no-inline" desc:"full segment's worth of discrete cosine transform of the log_mel_filter_out values, producing the final mel-frequency cepstral coefficients"`
-
-	// [view: no-inline] MFCC deltas are the differences over time of the MFC coefficeints
-	MFCCDeltas etensor.Float64 `view:"no-inline" desc:"MFCC deltas are the differences over time of the MFC coefficeints"`
-
-	// [view: no-inline] MFCC delta deltas are the differences over time of the MFCC deltas
-	MFCCDeltaDeltas etensor.Float64 `view:"no-inline" desc:"MFCC delta deltas are the differences over time of the MFCC deltas"`
-}
-
-type GaborParams struct {
-
-	// [view: no-inline] array of params describing each gabor filter
-	GaborSpecs []agabor.Filter `view:"no-inline" desc:"array of params describing each gabor filter"`
-
-	// [view: inline] a set of gabor filters with same x and y dimensions
-	GaborSet agabor.FilterSet `view:"inline" desc:"a set of gabor filters with same x and y dimensions"`
-
-	// [view: no-inline] raw output of Gabor -- full segment's worth of gabor steps
-	GborOutput etensor.Float32 `view:"no-inline" desc:"raw output of Gabor -- full segment's worth of gabor steps"`
-
-	// [view: no-inline] post-kwta output of full segment's worth of gabor steps
-	GborKwta etensor.Float32 `view:"no-inline" desc:"post-kwta output of full segment's worth of gabor steps"`
-
-	// [view: no-inline] inhibition values for A1 KWTA
-	Inhibs fffb.Inhibs `view:"no-inline" desc:"inhibition values for A1 KWTA"`
-
-	// [view: no-inline] A1 simple extra Gi from neighbor inhibition tensor
-	ExtGi etensor.Float32 `view:"no-inline" desc:"A1 simple extra Gi from neighbor inhibition tensor"`
-
-	// [view: no-inline] neighborhood inhibition for V1s -- each unit gets inhibition from same feature in nearest orthogonal neighbors -- reduces redundancy of feature code
-	NeighInhib kwta.NeighInhib `view:"no-inline" desc:"neighborhood inhibition for V1s -- each unit gets inhibition from same feature in nearest orthogonal neighbors -- reduces redundancy of feature code"`
-
-	// [view: no-inline] kwta parameters, using FFFB form
-	Kwta kwta.KWTA `view:"no-inline" desc:"kwta parameters, using FFFB form"`
-
-	// [view: -] discrete fourier transform (fft) output complex representation
-	FftCoefs []complex128 `view:"-" desc:"discrete fourier transform (fft) output complex representation"`
-
-	// [view: -] struct for fast fourier transform
-	Fft *fourier.CmplxFFT `view:"-" desc:"struct for fast fourier transform"`
-}
-
 type App struct {
 
 	// [view: -] name of this environment
@@ -236,6 +103,9 @@ type App struct {
 	// [view: -] name of the last sound file loaded, compare with this and don't reload if processing sound from same file'
 	LastFile string `view:"-" desc:"name of the last sound file loaded, compare with this and don't reload if processing sound from same file'"`
 
+	// recently loaded sound files, so going back and forth between the units of a few files doesn't reload them
+	SndCache SndCache `desc:"recently loaded sound files, so going back and forth between the units of a few files doesn't reload them"`
+
 	// [view: -] used to prevent reloading a file we are already processing
 	Load bool `view:"-" desc:"used to prevent reloading a file we are already processing"`
 
@@ -269,9 +139,72 @@ type App struct {
 	// display the gabor filtering result by time and then by filter, default is to order by filter and then time
 	ByTime bool `desc:"display the gabor filtering result by time and then by filter, default is to order by filter and then time"`
 
+	// [def: 25] units shorter than this many milliseconds (e.g. shorter than the window) are handled according to ShortPolicy when the transcription is loaded
+	MinDurMs float64 `default:"25" desc:"units shorter than this many milliseconds (e.g. shorter than the window) are handled according to ShortPolicy when the transcription is loaded"`
+
+	// what to do with units shorter than MinDurMs -- pad (extend to MinDurMs), skip or merge with the shorter neighbor
+	ShortPolicy speech.ShortPolicies `desc:"what to do with units shorter than MinDurMs -- pad (extend to MinDurMs), skip or merge with the shorter neighbor"`
+
+	// split sound files with no transcription into pseudo-units at dips in their energy (see AutoSeg), instead of one unknown unit
+	AutoSegment bool `desc:"split sound files with no transcription into pseudo-units at dips in their energy (see AutoSeg), instead of one unknown unit"`
+
+	// [view: inline] parameters of the automatic segmentation of sound files with no transcription
+	AutoSeg sound.AutoSegParams `view:"inline" desc:"parameters of the automatic segmentation of sound files with no transcription"`
+
+	// [view: inline] clustering of the units of the sounds table into pseudo-labels, the Cluster column, e.g., for units from AutoSeg
+	Cluster sound.ClusterParams `view:"inline" desc:"clustering of the units of the sounds table into pseudo-labels, the Cluster column, e.g., for units from AutoSeg"`
+
 	// directory for storing images of mel, gabors, filtered result, etc
 	ImgDir string `desc:"directory for storing images of mel, gabors, filtered result, etc"`
 
+	// directory the Export Units action writes the wav snippets of the sounds table rows to
+	ExportDir string `desc:"directory the Export Units action writes the wav snippets of the sounds table rows to"`
+
+	// [def: 0] milliseconds of sound before the start of each unit to include in exported snippets
+	ExportPreMs float64 `default:"0" desc:"milliseconds of sound before the start of each unit to include in exported snippets"`
+
+	// [def: 0] milliseconds of sound after the end of each unit to include in exported snippets
+	ExportPostMs float64 `default:"0" desc:"milliseconds of sound after the end of each unit to include in exported snippets"`
+
+	// feature whose distribution of values is plotted in the Hist tabs
+	HistFeature StatFeatures `desc:"feature whose distribution of values is plotted in the Hist tabs"`
+
+	// [def: 50] number of bins of the Hist plots
+	HistBins int `default:"50" desc:"number of bins of the Hist plots"`
+
+	// [view: -] statistics of the features of sound 1 and 2
+	Stats [2]*etable.Table `view:"-" desc:"statistics of the features of sound 1 and 2"`
+
+	// [view: -] histograms of HistFeature of sound 1 and 2
+	Hist [2]*etable.Table `view:"-" desc:"histograms of HistFeature of sound 1 and 2"`
+
+	// [view: -] views of the stats tables
+	StatsViews [2]*etview.TableView `view:"-" desc:"views of the stats tables"`
+
+	// [view: -] plots of the histogram tables
+	HistPlots [2]*eplot.Plot2D `view:"-" desc:"plots of the histogram tables"`
+
+	// [view: -] prototypes of the phones of the sounds table, see PhoneProtos
+	Protos *etable.Table `view:"-" desc:"prototypes of the phones of the sounds table, see PhoneProtos"`
+
+	// [view: -] view of the prototypes table
+	ProtosView *etview.TableView `view:"-" desc:"view of the prototypes table"`
+
+	// [view: -] log power spectrogram of the whole open sound file, see View
+	Spectrogram etensor.Float64 `view:"-" desc:"log power spectrogram of the whole open sound file, see View"`
+
+	// [view: -] waveform of the whole open sound file, the min and max sample of each span of time, see View
+	Waveform *etable.Table `view:"-" desc:"waveform of the whole open sound file, the min and max sample of each span of time, see View"`
+
+	// [view: -] tab view of the sounds table, spectrogram and waveform
+	FileTabs *gi.TabView `view:"-" desc:"tab view of the sounds table, spectrogram and waveform"`
+
+	// [view: -] grid of the spectrogram
+	SpecGrid *etview.TensorGrid `view:"-" desc:"grid of the spectrogram"`
+
+	// [view: -] plot of the waveform
+	WavePlot *eplot.Plot2D `view:"-" desc:"plot of the waveform"`
+
 	// [view: -] status label
 	StatLabel *gi.Label `view:"-" desc:"status label"`
 }
@@ -294,6 +227,8 @@ func (ap *App) Init() {
 	ap.PParams2.Mel.Defaults()
 	ap.PParams1.Mel.MFCC = true
 	ap.PParams2.Mel.MFCC = true
+	ap.MelDefaults(&ap.PParams1)
+	ap.MelDefaults(&ap.PParams2)
 	ap.PParams1.Dft.Defaults()
 	ap.PParams2.Dft.Defaults()
 	ap.InitGabors(&ap.GParams1)
@@ -301,17 +236,32 @@ func (ap *App) Init() {
 	ap.UpdateGabors(&ap.GParams1)
 	ap.UpdateGabors(&ap.GParams2)
 	ap.ByTime = true
+	ap.MinDurMs = 25
+	ap.ShortPolicy = speech.ShortPad
+	ap.AutoSegment = true
+	ap.AutoSeg.Defaults()
+	ap.Cluster.Defaults()
 	ap.GUI.Active = false
 	ap.ImgDir = "/Users/rohrlich/emer/auditory/examples/gaborview/phoneImages/"
-}
-
-// WinDefaults initializes the sound processing parameters
-func (ap *App) WinDefaults(wparams *WinParams) {
-	wparams.WinMs = 25.0
-	wparams.StepMs = 10.0
-	wparams.Channel = 0
-	wparams.BorderSteps = 0
-	wparams.Resize = true
+	ap.ExportDir = "snippets"
+	ap.SndCache.MaxFiles = 8
+	ap.HistFeature = StatMel
+	ap.HistBins = 50
+	for i := range ap.Stats {
+		if ap.Stats[i] == nil { // keep the tables of the views on re-init
+			ap.Stats[i] = &etable.Table{}
+			ap.Hist[i] = &etable.Table{}
+		}
+		ConfigStatsTable(ap.Stats[i])
+		ConfigHistTable(ap.Hist[i], ap.HistBins)
+	}
+	if ap.Protos == nil {
+		ap.Protos = (&sound.PhoneProtos{}).Table()
+	}
+	if ap.Waveform == nil {
+		ap.Waveform = &etable.Table{}
+		ConfigWaveformTable(ap.Waveform)
+	}
 }
 
 // Config configures environment elements
@@ -326,47 +276,6 @@ func (ap *App) Config() {
 	ap.ConfigSoundsTable()
 }
 
-// InitGabors renders the gabor filters using the gabor specifications
-func (ap *App) InitGabors(params *GaborParams) {
-	params.GaborSet.Filters.SetMetaData("min", "-.25")
-	params.GaborSet.Filters.SetMetaData("max", ".25")
-
-	params.GaborSet.SizeX = 8
-	params.GaborSet.SizeY = 8
-	params.GaborSet.Gain = 1.5
-	params.GaborSet.StrideX = 6
-	params.GaborSet.StrideY = 3
-	params.GaborSet.Distribute = false
-
-	orient := []float64{0, 45, 90, 135}
-	wavelen := []float64{2.0}
-	phase := []float64{0}
-	sigma := []float64{0.5}
-
-	params.GaborSpecs = nil // in case there are some specs already
-
-	for _, or := range orient {
-		for _, wv := range wavelen {
-			for _, ph := range phase {
-				for _, wl := range sigma {
-					spec := agabor.Filter{WaveLen: wv, Orientation: or, SigmaWidth: wl, SigmaLength: wl, PhaseOffset: ph, CircleEdge: true}
-					params.GaborSpecs = append(params.GaborSpecs, spec)
-				}
-			}
-		}
-	}
-}
-
-// UpdateGabors rerenders based on current spec and filterset values
-func (ap *App) UpdateGabors(params *GaborParams) {
-	if params.GaborSet.SizeX < params.GaborSet.StrideX {
-		gi.PromptDialog(nil, gi.DlgOpts{Title: "Stride > size", Prompt: "The stride in X is greater than the filter size in X"}, gi.AddOk, gi.AddCancel, nil, nil)
-	}
-	active := agabor.Active(params.GaborSpecs)
-	params.GaborSet.Filters.SetShape([]int{len(active), params.GaborSet.SizeY, params.GaborSet.SizeX}, nil, nil)
-	agabor.ToTensor(params.GaborSpecs, &params.GaborSet)
-}
-
 // ProcessSetup grabs params from the selected sounds table row and sets params for the actual processing step
 func (ap *App) ProcessSetup(wparams *WinParams, cur *CurSnd) error {
 	if ap.SndsTable.Table.Rows == 0 {
@@ -425,219 +334,39 @@ func (ap *App) ProcessSetup(wparams *WinParams, cur *CurSnd) error {
 	return nil
 }
 
-func (ap *App) LoadSound(wparams *WinParams) (err error) {
-	err = ap.Sound.Load(ap.SndFile)
+// ProcessView runs ProcessSetup, Process and ApplyGabor for the selected sound,
+// reporting any processing error in a dialog, and updates the window
+func (ap *App) ProcessView(wparams *WinParams, pparams *ProcessParams, gparams *GaborParams, cur *CurSnd) {
+	err := ap.ProcessSetup(wparams, cur)
 	if err != nil {
-		log.Printf("LoadTranscription: error loading sound -- %v\n, err", ap.SndFile)
-		return
-	}
-
-	if ap.Load {
-		ap.ToTensor(wparams) // actually load the sound
-	}
-	return
-}
-
-// Process generates the mel output and from that the result of the convolution with the gabor filters
-// Must call ProcessSetup() first !
-func (ap *App) Process(wparams *WinParams, pparams *ProcessParams, gparams *GaborParams) (err error) {
-	ap.LoadSound(wparams)
-
-	if ap.Sound.Buf == nil {
-		gi.PromptDialog(nil, gi.DlgOpts{Title: "Sound buffer is empty", Prompt: "Open a sound file before processing"}, gi.AddOk, gi.NoCancel, nil, nil)
-		return errors.New("Load a sound file and try again")
-	}
-
-	if wparams.SegmentEnd <= wparams.SegmentStart {
-		gi.PromptDialog(nil, gi.DlgOpts{Title: "End <= Start", Prompt: "SegmentEnd must be greater than SegmentStart."}, gi.AddOk, gi.NoCancel, nil, nil)
-		return errors.New("SegmentEnd <= SegmentStart")
-	}
-
-	if wparams.Resize {
-		duration := wparams.SegmentEnd - wparams.SegmentStart
-		stepMs := wparams.StepMs
-		sizeXMs := float64(gparams.GaborSet.SizeX) * stepMs
-		strideXMs := float64(gparams.GaborSet.StrideX) * stepMs
-		add := 0.0
-		if duration < sizeXMs {
-			add = sizeXMs - duration
-		} else { // duration is longer than one filter so find the next stride end
-			d := duration
-			d -= sizeXMs
-			rem := float64(int(d) % int(strideXMs))
-			if rem > 0 {
-				add = strideXMs - rem
-			}
-		}
-		if wparams.SegmentStart-add < 0 {
-			wparams.SegmentEnd += add
-		} else {
-			wparams.SegmentStart -= add / 2
-			wparams.SegmentEnd += add / 2
-		}
-		ap.GUI.UpdateWindow()
-	}
-
-	sr := ap.Sound.SampleRate()
-	if sr <= 0 {
-		fmt.Println("sample rate <= 0")
-		return errors.New("sample rate <= 0")
-
-	}
-	wparams.WinSamples = sound.MSecToSamples(wparams.WinMs, sr)
-	wparams.StepSamples = sound.MSecToSamples(wparams.StepMs, sr)
-
-	// round up to nearest step interval
-	segmentMs := wparams.SegmentEnd - wparams.SegmentStart
-	segmentMs = segmentMs + wparams.StepMs*float64(int(segmentMs)%int(wparams.StepMs))
-	steps := int(segmentMs / wparams.StepMs)
-	wparams.StepsTotal = steps + 2*wparams.BorderSteps
-
-	winSamplesHalf := wparams.WinSamples/2 + 1
-	pparams.Mel.FBank.NFilters = 32
-	pparams.Mel.InitFilters(wparams.WinSamples, ap.Sound.SampleRate(), &pparams.MelFilters) // call after non-default values are set!
-	ap.Window.SetShape([]int{wparams.WinSamples}, nil, nil)
-	pparams.Power.SetShape([]int{winSamplesHalf}, nil, nil)
-	pparams.LogPower.CopyShapeFrom(&pparams.Power)
-	pparams.PowerSegment.SetShape([]int{winSamplesHalf, wparams.StepsTotal}, nil, nil)
-	if pparams.Dft.CompLogPow {
-		pparams.LogPowerSegment.CopyShapeFrom(&pparams.PowerSegment)
+		return // ProcessSetup prompts
 	}
-	gparams.FftCoefs = make([]complex128, wparams.WinSamples)
-	gparams.Fft = fourier.NewCmplxFFT(len(gparams.FftCoefs))
-
-	pparams.Mel.FBank.LoHz = 0
-
-	// 2 reasons for this code
-	// 1 - the amount of signal handed to the fft has a "border" (some extra signal) to avoid edge effects.
-	// On the first step there is no signal to act as the "border" so we pad the data handed on the front.
-	// 2 - signals needs to be aligned when the number when multiple signals are input (e.g. 100 and 300 ms)
-	// so that the leading edge (right edge) is the same time point.
-	// This code does this by generating negative offsets for the start of the processing.
-	// Also see SndToWindow for the use of the step values
-	stepsBack := wparams.BorderSteps
-	wparams.Steps = make([]int, wparams.StepsTotal)
-	for i := 0; i < wparams.StepsTotal; i++ {
-		wparams.Steps[i] = wparams.StepSamples * (i - stepsBack)
-	}
-
-	pparams.MelFBank.SetShape([]int{pparams.Mel.FBank.NFilters}, nil, nil)
-	pparams.MelFBankSegment.SetShape([]int{pparams.Mel.FBank.NFilters, wparams.StepsTotal}, nil, nil)
-	pparams.Energy.SetShape([]int{wparams.StepsTotal}, nil, nil)
-	if pparams.Mel.MFCC {
-		pparams.MFCCDct.SetShape([]int{pparams.Mel.FBank.NFilters}, nil, nil)
-		pparams.MFCCSegment.SetShape([]int{pparams.Mel.NCoefs, wparams.StepsTotal}, nil, nil)
-		pparams.MFCCDeltas.SetShape([]int{pparams.Mel.NCoefs, wparams.StepsTotal}, nil, nil)
-		pparams.MFCCDeltaDeltas.SetShape([]int{pparams.Mel.NCoefs, wparams.StepsTotal}, nil, nil)
-	}
-	samples := sound.MSecToSamples(wparams.SegmentEnd-wparams.SegmentStart, ap.Sound.SampleRate())
-	siglen := len(ap.Signal.Values) - samples*ap.Sound.Channels()
-	siglen = siglen / ap.Sound.Channels()
-
-	pparams.Power.SetZeros()
-	pparams.LogPower.SetZeros()
-	pparams.PowerSegment.SetZeros()
-	pparams.LogPowerSegment.SetZeros()
-	pparams.MelFBankSegment.SetZeros()
-	pparams.MFCCSegment.SetZeros()
-	pparams.Energy.SetZeros()
-
-	for s := 0; s < int(wparams.StepsTotal); s++ {
-		err := ap.ProcessStep(s, wparams, pparams, gparams)
-		if err != nil {
-			fmt.Println(err)
-			break
-		}
-	}
-
-	for s := 0; s < wparams.StepsTotal; s++ {
-		e := 0.0
-		for f := 0; f < pparams.LogPowerSegment.Shape.Dim(1); f++ {
-			e += pparams.LogPowerSegment.FloatValRowCell(f, s)
-		}
-		pparams.Energy.SetFloat1D(s, e)
-	}
-
-	for s := 0; s < wparams.StepsTotal; s++ {
-		pparams.MFCCSegment.SetFloatRowCell(0, s, pparams.Energy.FloatVal1D(s))
+	err = ap.Process(wparams, pparams, gparams)
+	if err == nil {
+		err = ap.ApplyGabor(pparams, gparams)
 	}
-
-	// calculate the MFCC deltas (change in MFCC coeficient over time - basically first derivative)
-	// One source of the equation - https://priv	acycanada.net/mel-frequency-cepstral-coefficient/#Mel-filterbank-Computation
-
-	//denominator = 2 * sum([i**2 for i in range(1, N+1)])
-	// N: For each frame, calculate delta features based on preceding and following N frames
-	N := 2
-	if pparams.Mel.MFCC && pparams.Mel.Deltas {
-		for s := 0; s < int(wparams.StepsTotal); s++ {
-			prv := 0.0
-			nxt := 0.0
-			for i := 0; i < pparams.Mel.NCoefs; i++ {
-				nume := 0.0
-				for n := 1; n <= N; n++ {
-					sprv := s - n
-					snxt := s + n
-					if sprv < 0 {
-						sprv = 0
-					}
-					if snxt > wparams.StepsTotal-1 {
-						snxt = wparams.StepsTotal - 1
-					}
-					prv += pparams.MFCCSegment.FloatValRowCell(i, sprv)
-					nxt += pparams.MFCCSegment.FloatValRowCell(i, snxt)
-					nume += float64(n) * (nxt - prv)
-
-					denom := n * n
-					d := nume / 2.0 * float64(denom)
-					pparams.MFCCDeltas.SetFloatRowCell(i, s, d)
-				}
-			}
-		}
-		for s := 0; s < int(wparams.StepsTotal); s++ {
-			prv := 0.0
-			nxt := 0.0
-			for i := 0; i < pparams.Mel.NCoefs; i++ {
-				nume := 0.0
-				for n := 1; n <= N; n++ {
-					sprv := s - n
-					snxt := s + n
-					if sprv < 0 {
-						sprv = 0
-					}
-					if snxt > wparams.StepsTotal-1 {
-						snxt = wparams.StepsTotal - 1
-					}
-					prv += pparams.MFCCDeltas.FloatValRowCell(i, sprv)
-					nxt += pparams.MFCCDeltas.FloatValRowCell(i, snxt)
-					nume += float64(n) * (nxt - prv)
-
-					denom := n * n
-					d := nume / 2.0 * float64(denom)
-					pparams.MFCCDeltaDeltas.SetFloatRowCell(i, s, d)
-				}
-			}
+	if err != nil {
+		gi.PromptDialog(nil, gi.DlgOpts{Title: "Processing error", Prompt: err.Error()}, gi.AddOk, gi.NoCancel, nil, nil)
+	} else {
+		set := 0
+		if pparams == &ap.PParams2 {
+			set = 1
 		}
+		ap.UpdateStatsView(set, pparams, gparams)
 	}
-	return nil
+	ap.GUI.UpdateWindow()
 }
 
-// ProcessStep processes a step worth of sound input from current input_pos, and increment input_pos by input.step_samples
-// Process the data by doing a fourier transform and computing the power spectrum, then apply mel filters to get the frequency
-// bands that mimic the non-linear human perception of sound
-func (ap *App) ProcessStep(step int, wparams *WinParams, pparams *ProcessParams, gparams *GaborParams) error {
-	offset := wparams.Steps[step]
-	start := sound.MSecToSamples(wparams.SegmentStart, ap.Sound.SampleRate()) + offset
-	err := ap.SndToWindow(start, wparams)
-	if err == nil {
-		gparams.Fft.Reset(wparams.WinSamples)
-		pparams.Dft.Filter(step, &ap.Window, wparams.WinSamples, &pparams.Power, &pparams.LogPower, &pparams.PowerSegment, &pparams.LogPowerSegment)
-		pparams.Mel.FilterDft(step, &pparams.Power, &pparams.MelFBankSegment, &pparams.MelFBank, &pparams.MelFilters)
-		if pparams.Mel.MFCC {
-			pparams.Mel.CepstrumDct(step, &pparams.MelFBank, &pparams.MFCCSegment, &pparams.MFCCDct)
-			pparams.MFCCSegment.SetFloatRowCell(0, step, pparams.Energy.FloatVal1D(step))
-		}
+// UpdateStatsView updates the feature statistics and the histogram of HistFeature of sound set (0 or 1) and their views
+func (ap *App) UpdateStatsView(set int, pparams *ProcessParams, gparams *GaborParams) {
+	UpdateStats(pparams, gparams, ap.HistFeature, ap.HistBins, ap.Stats[set], ap.Hist[set])
+	if ap.StatsViews[set] != nil {
+		ap.StatsViews[set].UpdateTable()
+	}
+	if ap.HistPlots[set] != nil {
+		ap.HistPlots[set].Params.Title = ap.HistFeature.String() + " values"
+		ap.HistPlots[set].Update()
 	}
-	return err
 }
 
 // LoadTranscription loads the transcription file. The sound file is loaded at start of processing by calling ToTensor()
@@ -657,9 +386,17 @@ func (ap *App) LoadTranscription(fpth string) {
 		seq.Units, err = timit.LoadTimes(fnm, names, false) // names can be empty for timit, LoadTimes loads names
 		if err != nil {
 			fmt.Println("LoadTranscription: transcription/timing data file not found.")
-			fmt.Println("Use the TimeMode option (a WParam) to analyze and view sections of the audio")
-			seq.Units = append(seq.Units, *new(speech.Unit))
-			seq.Units[0].Name = "unknown" // name it with non-closure consonant (i.e. bcl -> b, gcl -> g)
+			seq.Units = nil
+			if ap.AutoSegment {
+				seq.Units, _ = ap.AutoSeg.AutoSegmentFile(seq.File)
+			}
+			if len(seq.Units) > 0 {
+				fmt.Println("The units are from the automatic segmentation at energy dips (see AutoSeg)")
+			} else {
+				fmt.Println("Use the TimeMode option (a WParam) to analyze and view sections of the audio")
+				seq.Units = append(seq.Units, *new(speech.Unit))
+				seq.Units[0].Name = "unknown" // name it with non-closure consonant (i.e. bcl -> b, gcl -> g)
+			}
 		} else {
 			fnm = fn + ".TXT" // full text transcription
 			seq.Text, err = timit.LoadText(fnm)
@@ -668,6 +405,9 @@ func (ap *App) LoadTranscription(fpth string) {
 		fmt.Println("NextSound: ap.Corpus no match")
 	}
 
+	durMs, _ := sound.WaveDurMs(seq.File)
+	seq.CheckAlignment(durMs, 0)                           // logs each timing issue
+	seq.CheckDurations(ap.MinDurMs, durMs, ap.ShortPolicy) // logs each short unit
 	ap.Sequence = append(ap.Sequence, *seq)
 	if seq.Units == nil {
 		fmt.Println("AdjSeqTimes: SpeechSeq.Units is nil. Some problem with loading file transcription and timing data")
@@ -709,6 +449,7 @@ func (ap *App) LoadTranscription(fpth string) {
 		ap.SndsTable.Table.SetCellFloat("Duration", r, s.AEnd-s.AStart)
 		ap.SndsTable.Table.SetCellString("File", r, nm)
 		ap.SndsTable.Table.SetCellString("Dir", r, fpth)
+		ap.SndsTable.Table.SetCellFloat("Cluster", r, -1)
 	}
 	ap.SndsTable.View.UpdateTable()
 	ap.GUI.Active = true
@@ -717,135 +458,163 @@ func (ap *App) LoadTranscription(fpth string) {
 	return
 }
 
-// ToTensor loads the sound file, e.g. .wav file, the transcription is loaded in LoadTranscription()
-func (ap *App) ToTensor(wparams *WinParams) bool {
-	ap.Sound.SoundToTensor(&ap.Signal)
-	return true
-}
-
-// FilterSounds filters the table available sounds
-func (ap *App) FilterSounds(sound string) {
-	ap.SndsTable.View.Table.FilterColName("Sound", sound, false, true, true)
-}
-
-// UnfilterSounds clears the table of sounds
-func (ap *App) UnfilterSounds() {
-	ap.SndsTable.View.Table.Sequential()
-}
-
-// AdjSeqTimes adjust for any offset if the sequence doesn't start at 0 ms. Also adjust for random silence that
-// might have been added to front of signal
-func (ap *App) AdjSeqTimes(seq *speech.Sequence) {
-	silence := seq.Silence // random silence added to start of sequence for variability
-	offset := 0.0
-	if seq.Units[0].Start > 0 {
-		offset = seq.Units[0].Start // some sequences are sections of longer ones so times don't start at zero (not true for timit)
+// ExportUnits writes the sound of each row of the sounds table, as currently filtered, to a wav file in ExportDir
+// named by label and source file (see sound.SnippetName), with ExportPreMs and ExportPostMs of padding,
+// e.g., to make a data set of phone exemplars. It returns the number of files written
+func (ap *App) ExportUnits() (int, error) {
+	snds := map[string]*sound.Wave{} // loaded sound files, by path
+	if err := os.MkdirAll(ap.ExportDir, os.ModePerm); err != nil {
+		log.Println(err)
+		return 0, err
 	}
-	for i := range seq.Units {
-		seq.Units[i].AStart = seq.Units[i].Start + silence - offset
-		seq.Units[i].AEnd = seq.Units[i].End + silence - offset
+	n := 0
+	for _, idx := range ap.SndsTable.View.Table.Idxs {
+		id := ap.SndsTable.Table.CellString("Dir", idx) + "/" + ap.SndsTable.Table.CellString("File", idx)
+		fn := ""
+		for _, s := range ap.Sequence {
+			if strings.Contains(s.File, id) {
+				fn = s.File
+			}
+		}
+		snd, ok := snds[fn]
+		if !ok {
+			snd = &sound.Wave{}
+			if err := snd.Load(fn); err != nil {
+				return n, err
+			}
+			snds[fn] = snd
+		}
+		st := ap.SndsTable.Table.CellFloat("Start", idx)
+		ed := ap.SndsTable.Table.CellFloat("End", idx)
+		sn, err := snd.Snippet(st-ap.ExportPreMs, ed+ap.ExportPostMs)
+		if err != nil {
+			return n, err
+		}
+		nm := sound.SnippetName(ap.SndsTable.Table.CellString("Sound", idx), fn, idx)
+		if err := sn.WriteWave(filepath.Join(ap.ExportDir, nm)); err != nil {
+			return n, err
+		}
+		n++
 	}
+	return n, nil
 }
 
-// IdxFmSnd simplies the lookup by keeping the corpus conditional in one function
-func (ap *App) IdxFmSnd(seq speech.Sequence, s string) (idx int, ok bool) {
-	idx = -1
-	ok = false
-	if ap.Corpus == "TIMIT" {
-		idx, ok = timit.IdxFmSnd(s, seq.ID)
-	} else if ap.Corpus == "SYNTHCVS" {
-		idx, ok = synthcvs.IdxFmSnd(s, seq.ID)
-	} else if ap.Corpus == "GRAFESTES" {
-		idx, ok = grafestes.IdxFmSnd(s, seq.ID)
-	} else {
-		fmt.Println("IdxFmSnd: fell through corpus ifelse ")
+// ClusterUnits clusters the features of the units of the rows of the sounds table, as currently filtered, into
+// Cluster.K clusters (see sound.ClusterParams), setting the Cluster column of the rows to the pseudo-label of their
+// unit, 0 to K-1, and -1 for the other rows. The segments are processed with the default SndEnv params, centered on the units
+func (ap *App) ClusterUnits() error {
+	sb, refs := ap.UnitBank(ap.Cluster.Feature == "gabor")
+	labels, _, err := sb.Cluster(refs, &ap.Cluster, sb.Snd.NewRand("cluster"))
+	if err != nil {
+		return err
 	}
-	return
+	for r := 0; r < ap.SndsTable.Table.Rows; r++ {
+		ap.SndsTable.Table.SetCellFloat("Cluster", r, -1)
+	}
+	for i, idx := range ap.SndsTable.View.Table.Idxs {
+		ap.SndsTable.Table.SetCellFloat("Cluster", idx, float64(labels[i]))
+	}
+	ap.SndsTable.View.UpdateTable()
+	return nil
 }
 
-// IdxFmSnd simplies the lookup by keeping the corpus conditional in one function
-func (ap *App) SndFmIdx(seq speech.Sequence, idx int) (snd string, ok bool) {
-	snd = ""
-	ok = false
-	if ap.Corpus == "TIMIT" {
-		snd, ok = timit.SndFmIdx(idx, seq.ID)
-	} else {
-		fmt.Println("SndFmIdx: fell through corpus ifelse ")
+// PhoneProtos sets the Protos table to the prototypes of the phones of the rows of the sounds table, as currently
+// filtered, the average mel, mfcc and gabor output of the units of each phone (see sound.PhoneProtos)
+func (ap *App) PhoneProtos() error {
+	sb, refs := ap.UnitBank(true)
+	pp := &sound.PhoneProtos{}
+	if err := sb.Prototypes(refs, pp); err != nil {
+		return err
 	}
-	return
+	ap.Protos = pp.Table()
+	if ap.ProtosView != nil {
+		ap.ProtosView.SetTable(ap.Protos, nil)
+	}
+	return nil
 }
 
-// SndToWindow gets sound from the signal (i.e. the slice of input values) at given position
-func (ap *App) SndToWindow(start int, wparams *WinParams) error {
-	end := start + wparams.WinSamples
-	if end > len(ap.Signal.Values) {
-		return errors.New("SndToWindow: end beyond signal length!!")
+// UnitBank returns a SndBank of the units of the rows of the sounds table, as currently filtered, with the refs of
+// the units in the order of the rows, processed with the default SndEnv params and mfcc, and gabors if gabor is set
+func (ap *App) UnitBank(gabor bool) (*sound.SndBank, []sound.UnitRef) {
+	sb := &sound.SndBank{}
+	sb.Defaults()
+	se := &sb.Snd
+	se.Params.PadShort = true
+	se.Mel.MFCC = true
+	if gabor {
+		se.Kwta.On = false
+		se.NeighInhib.On = false
+		se.GaborDefaults()
+		se.SetGaborOut2D()
 	}
-	var pad []float64
-	if start < 0 && end <= 0 {
-		pad = make([]float64, end-start)
-		ap.Window.Values = pad[0:]
-	} else if start < 0 && end > 0 {
-		pad = make([]float64, 0-start)
-		ap.Window.Values = pad[0:]
-		ap.Window.Values = append(ap.Window.Values, ap.Signal.Values[0:end]...)
-	} else {
-		ap.Window.Values = ap.Signal.Values[start:end]
+	files := map[string]int{} // sequence of each file in sb
+	refs := make([]sound.UnitRef, 0, len(ap.SndsTable.View.Table.Idxs))
+	for _, idx := range ap.SndsTable.View.Table.Idxs {
+		id := ap.SndsTable.Table.CellString("Dir", idx) + "/" + ap.SndsTable.Table.CellString("File", idx)
+		fn := ""
+		for _, s := range ap.Sequence {
+			if strings.Contains(s.File, id) {
+				fn = s.File
+			}
+		}
+		f, ok := files[fn]
+		if !ok {
+			f = len(sb.Seqs)
+			files[fn] = f
+			sb.Seqs = append(sb.Seqs, speech.Sequence{File: fn})
+		}
+		seq := &sb.Seqs[f]
+		seq.Units = append(seq.Units, speech.Unit{Name: ap.SndsTable.Table.CellString("Sound", idx),
+			Start: ap.SndsTable.Table.CellFloat("Start", idx), End: ap.SndsTable.Table.CellFloat("End", idx)})
+		refs = append(refs, sound.UnitRef{File: f, Unit: len(seq.Units) - 1})
 	}
-	return nil
+	return sb, refs
 }
 
-// ApplyGabor convolves the gabor filters with the mel output
-func (ap *App) ApplyGabor(pparams *ProcessParams, gparams *GaborParams) {
-	// determine gabor output size
-	y1 := pparams.MelFBankSegment.Dim(0)
-	y2 := gparams.GaborSet.SizeY
-	y := float64(y1 - y2)
-	sy := (int(math.Floor(y/float64(gparams.GaborSet.StrideY))) + 1) * 2 // double - two rows, off-center and on-center
-
-	x1 := pparams.MelFBankSegment.Dim(1)
-	x2 := gparams.GaborSet.SizeX
-	x := x1 - x2
-	active := agabor.Active(gparams.GaborSpecs)
-	sx := (int(math.Floor(float64(x)/float64(gparams.GaborSet.StrideX))) + 1) * len(active)
-
-	ap.UpdateGabors(gparams)
-	gparams.GborOutput.SetShape([]int{sy, sx}, nil, []string{"freq", "time"})
-	gparams.ExtGi.SetShape([]int{sy, gparams.GaborSet.Filters.Dim(0)}, nil, nil) // passed in for each channel
-	gparams.GborOutput.SetMetaData("odd-row", "true")
-	gparams.GborOutput.SetMetaData("grid-fill", ".9")
-	gparams.GborKwta.CopyShapeFrom(&gparams.GborOutput)
-	gparams.GborKwta.CopyMetaData(&gparams.GborOutput)
-
-	agabor.Convolve(&pparams.MelFBankSegment, gparams.GaborSet, &gparams.GborOutput, ap.ByTime)
-	//agabor.Convolve(ch, &pparams.MFCCSegment, gparams.GaborSet, &gparams.GborOutput, ap.ByTime)
-	// NeighInhib only works for 4D (pooled input) and this ap is 2D
-	//if ap.NeighInhib.On {
-	//	ap.NeighInhib.Inhib4(&gparams.GborOutput, &ap.ExtGi)
-	//} else {
-	//	ap.ExtGi.SetZeros()
-	//}
+// SndConfig returns the processing params of view 1 as a sound.Config, e.g., for headless runs to process sounds
+// as the view does (see sound.SndEnv.OpenConfig) -- the params the view doesn't have are the SndEnv defaults
+func (ap *App) SndConfig() *sound.Config {
+	se := &sound.SndEnv{}
+	se.Defaults()
+	wp, pp, gp := &ap.WParams1, &ap.PParams1, &ap.GParams1
+	se.Params.WinMs, se.Params.StepMs, se.Params.BorderSteps, se.Params.Channel = wp.WinMs, wp.StepMs, wp.BorderSteps, wp.Channel
+	se.DFT, se.Mel = pp.Dft, pp.Mel
+	se.GaborSpecs = gp.GaborSpecs
+	gf, gs := &se.GaborFilters, &gp.GaborSet
+	gf.SizeX, gf.SizeY, gf.StrideX, gf.StrideY, gf.Gain, gf.Distribute = gs.SizeX, gs.SizeY, gs.StrideX, gs.StrideY, gs.Gain, gs.Distribute
+	se.NeighInhib, se.Kwta = gp.NeighInhib, gp.Kwta
+	se.ByTime = ap.ByTime
+	return se.Config()
+}
 
-	if gparams.Kwta.On {
-		ap.ApplyKwta(gparams)
-		//tsr = &gparams.GborKwta
-		//} else {
-		//	tsr = &gparams.GborOutput
+// SetSndConfig sets the processing params of both views to those of the config, e.g., opened from a sound.Config
+// JSON file saved by SaveJSON
+func (ap *App) SetSndConfig(cf *sound.Config) {
+	for _, ps := range []struct {
+		wp *WinParams
+		pp *ProcessParams
+		gp *GaborParams
+	}{{&ap.WParams1, &ap.PParams1, &ap.GParams1}, {&ap.WParams2, &ap.PParams2, &ap.GParams2}} {
+		ps.wp.WinMs, ps.wp.StepMs, ps.wp.BorderSteps, ps.wp.Channel = cf.Params.WinMs, cf.Params.StepMs, cf.Params.BorderSteps, cf.Params.Channel
+		ps.pp.Dft, ps.pp.Mel = cf.DFT, cf.Mel
+		ps.pp.Dft.ResetCache()
+		ps.gp.GaborSpecs = append([]agabor.Filter(nil), cf.GaborSpecs...)
+		gs, cg := &ps.gp.GaborSet, &cf.GaborFilters
+		gs.SizeX, gs.SizeY, gs.StrideX, gs.StrideY, gs.Gain, gs.Distribute = cg.SizeX, cg.SizeY, cg.StrideX, cg.StrideY, cg.Gain, cg.Distribute
+		ps.gp.NeighInhib, ps.gp.Kwta = cf.NeighInhib, cf.Kwta
+		ap.UpdateGabors(ps.gp)
 	}
-	//return tsr
+	ap.ByTime = cf.ByTime
 }
 
-// ApplyKwta runs the kwta algorithm on the raw activations
-func (ap *App) ApplyKwta(gparams *GaborParams) {
-	gparams.GborKwta.CopyFrom(&gparams.GborOutput)
-	if gparams.Kwta.On {
-		// This app is 2D only - no pools
-		//if ap.KwtaPool == true {
-		//	ap.Kwta.KWTAPool(&gparams.GborOutput, &gparams.GborKwta, &ap.Inhibs, &ap.ExtGi)
-		//} else {
-		gparams.Kwta.KWTALayer(&gparams.GborOutput, &gparams.GborKwta, &gparams.ExtGi)
-		//}
-	}
+// FilterSounds filters the table available sounds
+func (ap *App) FilterSounds(sound string) {
+	ap.SndsTable.View.Table.FilterColName("Sound", sound, false, true, true)
+}
+
+// UnfilterSounds clears the table of sounds
+func (ap *App) UnfilterSounds() {
+	ap.SndsTable.View.Table.Sequential()
 }
 
 // / ConfigSoundsTable
@@ -862,6 +631,7 @@ func (ap *App) ConfigSoundsTable() {
 		{"Duration", etensor.FLOAT64, nil, nil},
 		{"File", etensor.STRING, nil, nil},
 		{"Dir", etensor.STRING, nil, nil},
+		{"Cluster", etensor.INT64, nil, nil},
 	}
 	ap.SndsTable.Table.SetFromSchema(sch, 0)
 }
@@ -874,67 +644,71 @@ func (ap *App) ConfigTableView(tv *etview.TableView) {
 		// ToDo: add option modifier for Process params 2
 		if sig == int64(giv.SliceViewDoubleClicked) {
 			ap.GUI.ToolBar.UpdateActions()
-			err := ap.ProcessSetup(&ap.WParams1, &ap.CurSnd1)
-			if err == nil {
-				err = ap.Process(&ap.WParams1, &ap.PParams1, &ap.GParams1)
-				if err == nil {
-					ap.ApplyGabor(&ap.PParams1, &ap.GParams1)
-					ap.GUI.UpdateWindow()
-				}
-			}
+			ap.ProcessView(&ap.WParams1, &ap.PParams1, &ap.GParams1, &ap.CurSnd1)
 
 		}
 	})
 }
 
-// View opens the file with the selected sound in a spectrogram viewer application (currently Audacity)
-func (ap *App) View() {
-	//f := gn.WavsPath + ks + "_" + cs + "_" + vs + ".wav"
-	arg1 := ap.SndFile
-	arg2 := "-a"
-	arg3 := "Audacity"
-	cmd := exec.Command("open", arg1, arg2, arg3)
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
-	if err != nil {
-		log.Fatal(err)
+// View shows the spectrogram and waveform of the whole open sound file, as processed by the window and dft
+// params of sound 1, in the Spectrogram and Waveform tabs
+func (ap *App) View() error {
+	if len(ap.Signal.Values) == 0 {
+		return errors.New("no sound file is open -- process a sound of the sounds table first")
+	}
+	rate := ap.Sound.SampleRate()
+	spec := sound.Spectrogram(ap.Signal.Values, rate, ap.WParams1.WinMs, ap.WParams1.StepMs, &ap.PParams1.Dft)
+	ap.Spectrogram.SetShape(spec.Shapes(), nil, spec.DimNames())
+	copy(ap.Spectrogram.Values, spec.Values)
+	sound.ConfigureForDisplay(&ap.Spectrogram, sound.DisplayLogPower)
+	UpdateWaveform(ap.Waveform, ap.Signal.Values, rate, 2000)
+	if ap.SpecGrid != nil {
+		ap.SpecGrid.SetTensor(&ap.Spectrogram)
+	}
+	if ap.WavePlot != nil {
+		ap.WavePlot.Params.Title = filepath.Base(ap.SndFile)
+		ap.WavePlot.Update()
 	}
+	if ap.FileTabs != nil {
+		ap.FileTabs.SelectTabByName("Spectrogram")
+	}
+	return nil
 }
 
-// SnapShot1
+// SnapShot1 saves the mel and gabor output images of sound 1 to ImgDir, in a directory of the sound
 func (ap *App) SnapShot1() {
-	if ap.PParams1.MelFBankSegment.Shape.NumDims() >= 2 {
-		dir := ap.ImgDir + ap.CurSnd1.Sound
-		f, err := os.Stat(dir)
+	if ap.PParams1.MelFBankSegment.Shape.NumDims() < 2 {
+		return
+	}
+	dir := ap.ImgDir + ap.CurSnd1.Sound
+	f, err := os.Stat(dir)
+	if err != nil {
+		err = os.Mkdir(dir, os.ModePerm)
 		if err != nil {
-			err = os.Mkdir(dir, os.ModePerm)
-		} else {
-			if f.IsDir() != true {
-				fmt.Println("file exists with name of what should be a directory!")
-				return
-			}
+			fmt.Println(err)
+			return
+		}
+	} else {
+		if f.IsDir() != true {
+			fmt.Println("file exists with name of what should be a directory!")
+			return
 		}
+	}
 
-		// ToDo: Convert ap.PParams1.MelFBankSegment to Float32 so it can be passed to GreyTensor or
-		// need 64 bit GreyTensor
-		//img := vfilter.GreyTensorToImage(nil, ap.PParams1.MelFBankSegment, 0, true)
-		//fn := ap.ImgDir
-		//fn += ap.CurSnd1.Sound + "/" + ap.CurSnd1.Sound + "_mel_" + ap.CurSnd1.Path + "_" + ap.CurSnd1.StEnd + ".png"
-		//err = gi.SaveImage(fn, img)
-		//if err != nil {
-		//	fmt.Println(err)
-		//}
+	ip := img.Params{}
+	ip.Defaults()
+	fn := dir + "/" + ap.CurSnd1.Sound + "_mel_" + ap.CurSnd1.Path + "_" + ap.CurSnd1.StEnd + ".png"
+	if err := img.SaveTensorPNG(&ap.PParams1.MelFBankSegment, &ip, fn); err != nil {
+		fmt.Println(err)
 	}
 
-	//if ap.GParams1.GborOutput.NumDims() >= 2 {
-	//	img := vfilter.GreyTensorToImage(nil, &ap.GParams1.GborOutput, 0, true)
-	//	fn := "/Users/rohrlich/emer/auditory/examples/gaborview/phoneImages/"
-	//	fn += ap.CurSnd1.Sound + "/" + ap.CurSnd1.Sound + "_result_" + ap.CurSnd1.Path + "_" + ap.CurSnd1.StEnd + ".png"
-	//	err := gi.SaveImage(fn, img)
-	//	if err != nil {
-	//		fmt.Println(err)
-	//	}
-	//}
+	if ap.GParams1.GborOutput.NumDims() >= 2 {
+		ip.Kind = sound.DisplaySigned
+		fn := dir + "/" + ap.CurSnd1.Sound + "_result_" + ap.CurSnd1.Path + "_" + ap.CurSnd1.StEnd + ".png"
+		if err := img.SaveTensorPNG(&ap.GParams1.GborOutput, &ip, fn); err != nil {
+			fmt.Println(err)
+		}
+	}
 }
 
 // TimitSxFilter
@@ -948,6 +722,19 @@ func TimitSxFilter(fv *giv.FileView, fi *giv.FileInfo) bool {
 	return false
 }
 
+// AddGridTab adds a tab with a grid view of the tensor, with the color map for its kind of values
+// (see sound.ConfigureForDisplay) and the display range following the values as they are processed
+func AddGridTab(tv *gi.TabView, name string, tsr etensor.Tensor, kind sound.DisplayKinds) *etview.TensorGrid {
+	tg := tv.AddNewTab(etview.KiT_TensorGrid, name).(*etview.TensorGrid)
+	tg.SetStretchMax()
+	sound.ConfigureForDisplay(tsr, kind)
+	tg.SetTensor(tsr)
+	// set Display after setting tensor
+	tg.Disp.Range.FixMin = false
+	tg.Disp.Range.FixMax = false
+	return tg
+}
+
 // ConfigGUI configures the Cogent Core gui interface for this simulation,
 func (ap *App) ConfigGUI() *gi.Window {
 	gi.SetAppName("Gabor View")
@@ -1026,18 +813,46 @@ func (ap *App) ConfigGUI() *gi.Window {
 		},
 	})
 
+	ap.GUI.AddToolbarItem(egui.ToolbarItem{Label: "Open Config",
+		Icon:    "file-open",
+		Tooltip: "Opens a sound.Config JSON file of processing params, e.g., saved by Save Config, setting the params of both views",
+		Active:  egui.ActiveAlways,
+		Func: func() {
+			giv.FileViewDialog(ap.GUI.ViewPort, ap.OpenPath, ".json", giv.DlgOpts{Title: "Open Config", Prompt: "Open a JSON file of processing params."}, nil,
+				ap.GUI.Win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+					if sig == int64(gi.DialogAccepted) {
+						dlg, _ := send.Embed(gi.KiT_Dialog).(*gi.Dialog)
+						cf := ap.SndConfig()
+						if err := cf.OpenJSON(giv.FileViewDialogValue(dlg)); err != nil {
+							return
+						}
+						ap.SetSndConfig(cf)
+						ap.GUI.UpdateWindow()
+					}
+				})
+		},
+	})
+
+	ap.GUI.AddToolbarItem(egui.ToolbarItem{Label: "Save Config",
+		Icon:    "file-save",
+		Tooltip: "Saves the processing params of view 1 to a sound.Config JSON file, for headless runs to process sounds as the view does",
+		Active:  egui.ActiveAlways,
+		Func: func() {
+			giv.FileViewDialog(ap.GUI.ViewPort, ap.OpenPath, ".json", giv.DlgOpts{Title: "Save Config", Prompt: "Save the processing params to a JSON file."}, nil,
+				ap.GUI.Win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+					if sig == int64(gi.DialogAccepted) {
+						dlg, _ := send.Embed(gi.KiT_Dialog).(*gi.Dialog)
+						ap.SndConfig().SaveJSON(giv.FileViewDialogValue(dlg))
+					}
+				})
+		},
+	})
+
 	ap.GUI.AddToolbarItem(egui.ToolbarItem{Label: "Process 1", Icon: "play",
 		Tooltip: "Process the segment of audio from SegmentStart to SegmentEnd applying the gabor filters to the Mel tensor",
 		Active:  egui.ActiveRunning,
 		Func: func() {
-			err := ap.ProcessSetup(&ap.WParams1, &ap.CurSnd1)
-			if err == nil {
-				err = ap.Process(&ap.WParams1, &ap.PParams1, &ap.GParams1)
-				if err == nil {
-					ap.ApplyGabor(&ap.PParams1, &ap.GParams1)
-					ap.GUI.UpdateWindow()
-				}
-			}
+			ap.ProcessView(&ap.WParams1, &ap.PParams1, &ap.GParams1, &ap.CurSnd1)
 		},
 	})
 
@@ -1045,14 +860,7 @@ func (ap *App) ConfigGUI() *gi.Window {
 		Tooltip: "Process the segment of audio from SegmentStart to SegmentEnd applying the gabor filters to the Mel tensor",
 		Active:  egui.ActiveRunning,
 		Func: func() {
-			err := ap.ProcessSetup(&ap.WParams2, &ap.CurSnd2)
-			if err == nil {
-				err = ap.Process(&ap.WParams2, &ap.PParams2, &ap.GParams2)
-				if err == nil {
-					ap.ApplyGabor(&ap.PParams2, &ap.GParams2)
-					ap.GUI.UpdateWindow()
-				}
-			}
+			ap.ProcessView(&ap.WParams2, &ap.PParams2, &ap.GParams2, &ap.CurSnd2)
 		},
 	})
 
@@ -1075,14 +883,7 @@ func (ap *App) ConfigGUI() *gi.Window {
 				ap.WParams1.SegmentStart += d
 				ap.WParams1.SegmentEnd += d
 			}
-			err := ap.ProcessSetup(&ap.WParams1, &ap.CurSnd1)
-			if err == nil {
-				err = ap.Process(&ap.WParams1, &ap.PParams1, &ap.GParams1)
-				if err == nil {
-					ap.ApplyGabor(&ap.PParams1, &ap.GParams1)
-					ap.GUI.UpdateWindow()
-				}
-			}
+			ap.ProcessView(&ap.WParams1, &ap.PParams1, &ap.GParams1, &ap.CurSnd1)
 		},
 	})
 
@@ -1105,14 +906,7 @@ func (ap *App) ConfigGUI() *gi.Window {
 				ap.WParams2.SegmentStart += d
 				ap.WParams2.SegmentEnd += d
 			}
-			err := ap.ProcessSetup(&ap.WParams2, &ap.CurSnd2)
-			if err == nil {
-				err = ap.Process(&ap.WParams2, &ap.PParams2, &ap.GParams2)
-				if err == nil {
-					ap.ApplyGabor(&ap.PParams2, &ap.GParams2)
-					ap.GUI.UpdateWindow()
-				}
-			}
+			ap.ProcessView(&ap.WParams2, &ap.PParams2, &ap.GParams2, &ap.CurSnd2)
 		},
 	})
 
@@ -1120,8 +914,12 @@ func (ap *App) ConfigGUI() *gi.Window {
 		Tooltip: "Call this to see the result of changing the Gabor specifications",
 		Active:  egui.ActiveAlways,
 		Func: func() {
-			ap.UpdateGabors(&ap.GParams1)
-			ap.UpdateGabors(&ap.GParams2)
+			for _, gp := range []*GaborParams{&ap.GParams1, &ap.GParams2} {
+				err := ap.UpdateGabors(gp)
+				if err != nil {
+					gi.PromptDialog(nil, gi.DlgOpts{Title: "Stride > size", Prompt: err.Error()}, gi.AddOk, gi.NoCancel, nil, nil)
+				}
+			}
 			ap.GUI.UpdateWindow()
 		},
 	})
@@ -1152,6 +950,41 @@ func (ap *App) ConfigGUI() *gi.Window {
 	//	},
 	//})
 
+	ap.GUI.AddToolbarItem(egui.ToolbarItem{Label: "Export Units", Icon: "file-save",
+		Tooltip: "write the sound of each row of the sounds table, as filtered, to a wav file in ExportDir, padded by ExportPreMs and ExportPostMs",
+		Active:  egui.ActiveRunning,
+		Func: func() {
+			n, err := ap.ExportUnits()
+			if err != nil {
+				gi.PromptDialog(nil, gi.DlgOpts{Title: "Export error", Prompt: err.Error()}, gi.AddOk, gi.NoCancel, nil, nil)
+				return
+			}
+			gi.PromptDialog(nil, gi.DlgOpts{Title: "Export done", Prompt: fmt.Sprintf("%v snippets written to %v", n, ap.ExportDir)}, gi.AddOk, gi.NoCancel, nil, nil)
+		},
+	})
+
+	ap.GUI.AddToolbarItem(egui.ToolbarItem{Label: "Phone Prototypes", Icon: "update",
+		Tooltip: "average the features of the units of each phone of the sounds table, as filtered, into the Protos table -- export it from the table view menu",
+		Active:  egui.ActiveRunning,
+		Func: func() {
+			if err := ap.PhoneProtos(); err != nil {
+				gi.PromptDialog(nil, gi.DlgOpts{Title: "Prototypes error", Prompt: err.Error()}, gi.AddOk, gi.NoCancel, nil, nil)
+			}
+			ap.GUI.UpdateWindow()
+		},
+	})
+
+	ap.GUI.AddToolbarItem(egui.ToolbarItem{Label: "Cluster Units", Icon: "update",
+		Tooltip: "cluster the features of the units of the sounds table, as filtered, into pseudo-labels in the Cluster column (see Cluster params)",
+		Active:  egui.ActiveRunning,
+		Func: func() {
+			if err := ap.ClusterUnits(); err != nil {
+				gi.PromptDialog(nil, gi.DlgOpts{Title: "Cluster error", Prompt: err.Error()}, gi.AddOk, gi.NoCancel, nil, nil)
+			}
+			ap.GUI.UpdateWindow()
+		},
+	})
+
 	ap.GUI.ToolBar.AddSeparator("filt")
 
 	ap.GUI.AddToolbarItem(egui.ToolbarItem{Label: "Filter sounds...", Icon: "search",
@@ -1172,11 +1005,13 @@ func (ap *App) ConfigGUI() *gi.Window {
 	})
 
 	ap.GUI.AddToolbarItem(egui.ToolbarItem{Label: "View", Icon: "file-open",
-		Tooltip: "opens spectrogram view of selected sound in external application 'Audacity' - edit code to use a different application",
+		Tooltip: "shows the spectrogram and waveform of the whole open sound file in the Spectrogram and Waveform tabs, with the window and dft params of sound 1",
 		Active:  egui.ActiveRunning,
 		Func: func() {
-			ap.View()
-			//giv.CallMethod(ap, "ViewSpectrogram", ap.GUI.ViewPort)
+			if err := ap.View(); err != nil {
+				gi.PromptDialog(nil, gi.DlgOpts{Title: "View error", Prompt: err.Error()}, gi.AddOk, gi.NoCancel, nil, nil)
+			}
+			ap.GUI.UpdateWindow()
 		},
 	})
 
@@ -1192,6 +1027,15 @@ func (ap *App) ConfigGUI() *gi.Window {
 	ap.SndsTable.View = tv1.AddNewTab(etview.KiT_TableView, "Sounds").(*etview.TableView)
 	ap.ConfigTableView(ap.SndsTable.View)
 	ap.SndsTable.View.SetTable(ap.SndsTable.Table, nil)
+	ap.ProtosView = tv1.AddNewTab(etview.KiT_TableView, "Protos").(*etview.TableView)
+	ap.ProtosView.SetTable(ap.Protos, nil)
+	ap.SpecGrid = AddGridTab(tv1, "Spectrogram", &ap.Spectrogram, sound.DisplayLogPower)
+	ap.WavePlot = tv1.AddNewTab(eplot.KiT_Plot2D, "Waveform").(*eplot.Plot2D)
+	ap.WavePlot.SetTable(ap.Waveform)
+	ap.WavePlot.Params.XAxisCol = "Time"
+	ap.WavePlot.SetColParams("Min", eplot.On, eplot.FloatMin, 0, eplot.FloatMax, 0)
+	ap.WavePlot.SetColParams("Max", eplot.On, eplot.FloatMin, 0, eplot.FloatMax, 0)
+	ap.FileTabs = tv1
 
 	split1.SetSplits(.75, .25)
 
@@ -1208,109 +1052,28 @@ func (ap *App) ConfigGUI() *gi.Window {
 
 	tv := gi.AddNewTabView(split, "tv")
 
-	tg := tv.AddNewTab(etview.KiT_TensorGrid, "Gabors").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	ap.PParams1.LogPowerSegment.SetMetaData("grid-min", "10")
-	tg.SetTensor(&ap.GParams1.GaborSet.Filters)
-	// set Display after setting tensor
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
-
-	tg = tv.AddNewTab(etview.KiT_TensorGrid, "Power").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	ap.PParams1.LogPowerSegment.SetMetaData("grid-min", "10")
-	tg.SetTensor(&ap.PParams1.LogPowerSegment)
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
-
-	tg = tv.AddNewTab(etview.KiT_TensorGrid, "Mel").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	tg.SetTensor(&ap.PParams1.MelFBankSegment)
-	// set Display after setting tensor
-	tg.Disp.ColorMap = "ColdHot"
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
-
-	tg = tv.AddNewTab(etview.KiT_TensorGrid, "Result").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	tg.SetTensor(&ap.GParams1.GborOutput)
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
-
-	tg = tv.AddNewTab(etview.KiT_TensorGrid, "MFCC").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	tg.SetTensor(&ap.PParams1.MFCCSegment)
-	// set Display after setting tensor
-	tg.Disp.ColorMap = "ColdHot"
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
-
-	tg = tv.AddNewTab(etview.KiT_TensorGrid, "Deltas").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	tg.SetTensor(&ap.PParams1.MFCCDeltas)
-	tg.Disp.ColorMap = "ColdHot"
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
+	AddGridTab(tv, "Gabors", &ap.GParams1.GaborSet.Filters, sound.DisplaySigned)
+	AddGridTab(tv, "Power", &ap.PParams1.LogPowerSegment, sound.DisplayLogPower)
+	AddGridTab(tv, "Mel", &ap.PParams1.MelFBankSegment, sound.DisplayLevel)
+	AddGridTab(tv, "Result", &ap.GParams1.GborOutput, sound.DisplaySigned)
+	AddGridTab(tv, "MFCC", &ap.PParams1.MFCCSegment, sound.DisplaySigned)
+	AddGridTab(tv, "Deltas", &ap.PParams1.MFCCDeltas, sound.DisplaySigned)
+	AddGridTab(tv, "DeltaDeltas", &ap.PParams1.MFCCDeltaDeltas, sound.DisplaySigned)
 
-	tg = tv.AddNewTab(etview.KiT_TensorGrid, "DeltaDeltas").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	tg.SetTensor(&ap.PParams1.MFCCDeltaDeltas)
-	tg.Disp.ColorMap = "ColdHot"
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
+	ap.ConfigStatsTabs(tv, 0)
 
 	tv2 := gi.AddNewTabView(split, "tv2")
 	split.SetSplits(.3, .15, .15, .2, .2)
 
-	tg = tv2.AddNewTab(etview.KiT_TensorGrid, "Gabors").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	tg.SetTensor(&ap.GParams2.GaborSet.Filters)
-	// set Display after setting tensor
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
-
-	tg = tv2.AddNewTab(etview.KiT_TensorGrid, "Power").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	ap.PParams2.LogPowerSegment.SetMetaData("grid-min", "10")
-	tg.SetTensor(&ap.PParams2.LogPowerSegment)
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
+	AddGridTab(tv2, "Gabors", &ap.GParams2.GaborSet.Filters, sound.DisplaySigned)
+	AddGridTab(tv2, "Power", &ap.PParams2.LogPowerSegment, sound.DisplayLogPower)
+	AddGridTab(tv2, "Mel", &ap.PParams2.MelFBankSegment, sound.DisplayLevel)
+	AddGridTab(tv2, "Result", &ap.GParams2.GborOutput, sound.DisplaySigned)
+	AddGridTab(tv2, "MFCC", &ap.PParams2.MFCCSegment, sound.DisplaySigned)
+	AddGridTab(tv2, "Deltas", &ap.PParams2.MFCCDeltas, sound.DisplaySigned)
+	AddGridTab(tv2, "DeltaDeltas", &ap.PParams2.MFCCDeltaDeltas, sound.DisplaySigned)
 
-	tg = tv2.AddNewTab(etview.KiT_TensorGrid, "Mel").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	tg.SetTensor(&ap.PParams2.MelFBankSegment)
-	tg.Disp.ColorMap = "ColdHot"
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
-
-	tg = tv2.AddNewTab(etview.KiT_TensorGrid, "Result").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	tg.SetTensor(&ap.GParams2.GborOutput)
-	// set Display after setting tensor
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
-
-	tg = tv2.AddNewTab(etview.KiT_TensorGrid, "MFCC").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	tg.SetTensor(&ap.PParams2.MFCCSegment)
-	tg.Disp.ColorMap = "ColdHot"
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
-
-	tg = tv2.AddNewTab(etview.KiT_TensorGrid, "Deltas").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	tg.SetTensor(&ap.PParams2.MFCCDeltas)
-	tg.Disp.ColorMap = "ColdHot"
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
-
-	tg = tv2.AddNewTab(etview.KiT_TensorGrid, "DeltaDeltas").(*etview.TensorGrid)
-	tg.SetStretchMax()
-	tg.SetTensor(&ap.PParams2.MFCCDeltaDeltas)
-	// set Display after setting tensor
-	tg.Disp.ColorMap = "ColdHot"
-	tg.Disp.Range.FixMin = false
-	tg.Disp.Range.FixMax = false
+	ap.ConfigStatsTabs(tv2, 1)
 
 	ap.StatLabel = gi.AddNewLabel(mfr, "status", "Status...")
 	ap.StatLabel.SetStretchMaxWidth()
@@ -1320,6 +1083,21 @@ func (ap *App) ConfigGUI() *gi.Window {
 	return ap.GUI.Win
 }
 
+// ConfigStatsTabs adds the Stats and Hist tabs of sound set (0 or 1) to the tab view
+func (ap *App) ConfigStatsTabs(tv *gi.TabView, set int) {
+	stv := tv.AddNewTab(etview.KiT_TableView, "Stats").(*etview.TableView)
+	stv.SetTable(ap.Stats[set], nil)
+	ap.StatsViews[set] = stv
+
+	plt := tv.AddNewTab(eplot.KiT_Plot2D, "Hist").(*eplot.Plot2D)
+	plt.SetTable(ap.Hist[set])
+	plt.Params.Type = eplot.Bar
+	plt.Params.XAxisCol = "Value"
+	plt.Params.Title = ap.HistFeature.String() + " values"
+	plt.SetColParams("Count", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	ap.HistPlots[set] = plt
+}
+
 // CmdArgs
 func (ap *App) CmdArgs() {
 
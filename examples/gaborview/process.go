@@ -0,0 +1,766 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// process.go contains the sound processing of the app, kept free of any GUI code
+// so it can be used without the gui -- dialogs and window updates are in gbv.go
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/emer/auditory/agabor"
+	"github.com/emer/auditory/dft"
+	"github.com/emer/auditory/mel"
+	"github.com/emer/auditory/sound"
+	"github.com/emer/auditory/speech"
+	"github.com/emer/auditory/speech/grafestes"
+	"github.com/emer/auditory/speech/synthcvs"
+	"github.com/emer/auditory/speech/timit"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/fffb"
+	"github.com/emer/vision/kwta"
+	"github.com/goki/ki/kit"
+)
+
+// CurSnd meta info for the sound processed
+type CurSnd struct {
+	Sound string
+	StEnd string
+	Path  string
+	Name  string
+}
+
+// WinParams defines the sound input parameters for auditory processing
+type WinParams struct {
+
+	// [def: 25] input window -- number of milliseconds worth of sound to filter at a time
+	WinMs float64 `default:"25" desc:"input window -- number of milliseconds worth of sound to filter at a time"`
+
+	// [def: 10] input step -- number of milliseconds worth of sound that the input is stepped along to obtain the next window sample
+	StepMs float64 `default:"10" desc:"input step -- number of milliseconds worth of sound that the input is stepped along to obtain the next window sample"`
+
+	// start of sound segment in milliseconds
+	SegmentStart float64 `desc:"start of sound segment in milliseconds"`
+
+	// end of sound segment in milliseconds
+	SegmentEnd float64 `desc:"end of sound segment in milliseconds"`
+
+	// [def: 0] overlap with previous and next segment
+	BorderSteps int `default:"0" desc:"overlap with previous and next segment"`
+
+	// specific channel to process, if input has multiple channels, and we only process one of them (-1 = process all)
+	Channel int `desc:"specific channel to process, if input has multiple channels, and we only process one of them (-1 = process all)"`
+
+	// if resize is true segment durations will be lengthened (a bit before and a bit after) to be align with gabor filter size and striding
+	Resize bool `desc:"if resize is true segment durations will be lengthened (a bit before and a bit after) to be align with gabor filter size and striding"`
+
+	// use the user entered start/end times, ignoring the current sound selection times, the current file will be used
+	TimeMode bool `desc:"use the user entered start/end times, ignoring the current sound selection times, the current file will be used"`
+
+	// [view: -] number of samples to process each step
+	WinSamples int `view:"-" desc:"number of samples to process each step"`
+
+	// [view: -] number of samples to step input by
+	StepSamples int `view:"-" desc:"number of samples to step input by"`
+
+	// [view: -] SegmentSteps plus steps overlapping next segment or for padding if no next segment
+	StepsTotal int `view:"-" desc:"SegmentSteps plus steps overlapping next segment or for padding if no next segment"`
+
+	// [view: -] pre-calculated start position for each step
+	Steps []int `view:"-" desc:"pre-calculated start position for each step"`
+}
+
+type ProcessParams struct {
+
+	// [view: inline]
+	Dft dft.Params `view:"inline" desc:""`
+
+	// [view: +] power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)
+	Power etensor.Float64 `view:"+" desc:"power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)"`
+
+	// [view: +] log power of the dft, up to the nyquist liit frequency (1/2 input.WinSamples)
+	LogPower etensor.Float64 `view:"+" desc:"log power of the dft, up to the nyquist liit frequency (1/2 input.WinSamples)"`
+
+	// [view: no-inline] full segment's worth of power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)
+	PowerSegment etensor.Float64 `view:"no-inline" desc:"full segment's worth of power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)"`
+
+	// [view: no-inline] full segment's worth of log power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)
+	LogPowerSegment etensor.Float64 `view:"no-inline" desc:"full segment's worth of log power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)"`
+
+	// [view: no-inline] sum of log power per segment step
+	Energy etensor.Float64 `view:"no-inline" desc:"sum of log power per segment step"`
+
+	// [view: inline]
+	Mel mel.Params `view:"inline"`
+
+	// [view: no-inline] mel scale transformation of dft_power, using triangular filters, resulting in the mel filterbank output -- the natural log of this is typically applied
+	MelFBank etensor.Float64 `view:"no-inline" desc:"mel scale transformation of dft_power, using triangular filters, resulting in the mel filterbank output -- the natural log of this is typically applied"`
+
+	// [view: no-inline] full segment's worth of mel feature-bank output
+	MelFBankSegment etensor.Float64 `view:"no-inline" desc:"full segment's worth of mel feature-bank output"`
+
+	// [view: no-inline] the actual filters
+	MelFilters etensor.Float64 `view:"no-inline" desc:"the actual filters"`
+
+	// [view: no-inline] discrete cosine transform of the log_mel_filter_out values, producing the final mel-frequency cepstral coefficients
+	MFCCDct etensor.Float64 `view:"no-inline" desc:"discrete cosine transform of the log_mel_filter_out values, producing the final mel-frequency cepstral coefficients"`
+
+	// [view: no-inline] full segment's worth of discrete cosine transform of the log_mel_filter_out values, producing the final mel-frequency cepstral coefficients
+	MFCCSegment etensor.Float64 `view:"no-inline" desc:"full segment's worth of discrete cosine transform of the log_mel_filter_out values, producing the final mel-frequency cepstral coefficients"`
+
+	// [view: no-inline] MFCC deltas are the differences over time of the MFC coefficeints
+	MFCCDeltas etensor.Float64 `view:"no-inline" desc:"MFCC deltas are the differences over time of the MFC coefficeints"`
+
+	// [view: no-inline] MFCC delta deltas are the differences over time of the MFCC deltas
+	MFCCDeltaDeltas etensor.Float64 `view:"no-inline" desc:"MFCC delta deltas are the differences over time of the MFCC deltas"`
+
+	// [view: inline] outputs of the windows already processed for the current file, reused by overlapping or repeated units
+	Cache StepCache `view:"inline" desc:"outputs of the windows already processed for the current file, reused by overlapping or repeated units"`
+}
+
+type GaborParams struct {
+
+	// [view: no-inline] array of params describing each gabor filter
+	GaborSpecs []agabor.Filter `view:"no-inline" desc:"array of params describing each gabor filter"`
+
+	// [view: inline] a set of gabor filters with same x and y dimensions
+	GaborSet agabor.FilterSet `view:"inline" desc:"a set of gabor filters with same x and y dimensions"`
+
+	// [view: no-inline] raw output of Gabor -- full segment's worth of gabor steps
+	GborOutput etensor.Float32 `view:"no-inline" desc:"raw output of Gabor -- full segment's worth of gabor steps"`
+
+	// [view: no-inline] post-kwta output of full segment's worth of gabor steps
+	GborKwta etensor.Float32 `view:"no-inline" desc:"post-kwta output of full segment's worth of gabor steps"`
+
+	// [view: no-inline] inhibition values for A1 KWTA
+	Inhibs fffb.Inhibs `view:"no-inline" desc:"inhibition values for A1 KWTA"`
+
+	// [view: no-inline] A1 simple extra Gi from neighbor inhibition tensor
+	ExtGi etensor.Float32 `view:"no-inline" desc:"A1 simple extra Gi from neighbor inhibition tensor"`
+
+	// [view: no-inline] neighborhood inhibition for V1s -- each unit gets inhibition from same feature in nearest orthogonal neighbors -- reduces redundancy of feature code
+	NeighInhib kwta.NeighInhib `view:"no-inline" desc:"neighborhood inhibition for V1s -- each unit gets inhibition from same feature in nearest orthogonal neighbors -- reduces redundancy of feature code"`
+
+	// [view: no-inline] kwta parameters, using FFFB form
+	Kwta kwta.KWTA `view:"no-inline" desc:"kwta parameters, using FFFB form"`
+}
+
+// WinDefaults initializes the sound processing parameters
+func (ap *App) WinDefaults(wparams *WinParams) {
+	wparams.WinMs = 25.0
+	wparams.StepMs = 10.0
+	wparams.Channel = 0
+	wparams.BorderSteps = 0
+	wparams.Resize = true
+}
+
+// MelDefaults sets the initial mel filter bank parameters -- these, including LoHz and HiHz,
+// can then be changed in the gui and are used as is by Process
+func (ap *App) MelDefaults(pparams *ProcessParams) {
+	pparams.Mel.FBank.NFilters = 32
+	pparams.Mel.FBank.LoHz = 0
+	pparams.Mel.FBank.HiHz = 8000
+}
+
+// InitGabors renders the gabor filters using the gabor specifications
+func (ap *App) InitGabors(params *GaborParams) {
+	params.GaborSet.Filters.SetMetaData("min", "-.25")
+	params.GaborSet.Filters.SetMetaData("max", ".25")
+
+	params.GaborSet.SizeX = 8
+	params.GaborSet.SizeY = 8
+	params.GaborSet.Gain = 1.5
+	params.GaborSet.StrideX = 6
+	params.GaborSet.StrideY = 3
+	params.GaborSet.Distribute = false
+
+	orient := []float64{0, 45, 90, 135}
+	wavelen := []float64{2.0}
+	phase := []float64{0}
+	sigma := []float64{0.5}
+
+	params.GaborSpecs = nil // in case there are some specs already
+
+	for _, or := range orient {
+		for _, wv := range wavelen {
+			for _, ph := range phase {
+				for _, wl := range sigma {
+					spec := agabor.Filter{WaveLen: wv, Orientation: or, SigmaWidth: wl, SigmaLength: wl, PhaseOffset: ph, CircleEdge: true}
+					params.GaborSpecs = append(params.GaborSpecs, spec)
+				}
+			}
+		}
+	}
+}
+
+// UpdateGabors rerenders based on current spec and filterset values.
+// The filters are rendered even if an error is returned for a stride greater than the filter size
+func (ap *App) UpdateGabors(params *GaborParams) error {
+	active := agabor.Active(params.GaborSpecs)
+	params.GaborSet.Filters.SetShape([]int{len(active), params.GaborSet.SizeY, params.GaborSet.SizeX}, nil, nil)
+	agabor.ToTensor(params.GaborSpecs, &params.GaborSet)
+	if params.GaborSet.SizeX < params.GaborSet.StrideX {
+		return errors.New("The stride in X is greater than the filter size in X")
+	}
+	return nil
+}
+
+// LoadSound loads SndFile, unless it is the file already loaded, using the sound cache so the files of
+// recently processed units are not read and converted again
+func (ap *App) LoadSound(wparams *WinParams) (err error) {
+	if !ap.Load && ap.Sound.Buf != nil {
+		return
+	}
+	if cs, ok := ap.SndCache.Get(ap.SndFile); ok {
+		ap.Sound.Buf = cs.Sound.Buf
+		ap.Signal.SetShape([]int{len(cs.Signal)}, nil, nil)
+		copy(ap.Signal.Values, cs.Signal)
+		return
+	}
+	err = ap.Sound.Load(ap.SndFile)
+	if err != nil {
+		log.Printf("LoadTranscription: error loading sound -- %v\n, err", ap.SndFile)
+		return
+	}
+	ap.ToTensor(wparams) // actually load the sound
+	ap.SndCache.Add(ap.SndFile, ap.Sound, ap.Signal.Values)
+	return
+}
+
+// CachedSnd is a sound file and its signal held in SndCache
+type CachedSnd struct {
+	File   string
+	Sound  sound.Wave
+	Signal []float64
+}
+
+// SndCache holds the most recently loaded sound files and their signals, so iterating over the units of
+// a corpus loads each file once
+type SndCache struct {
+
+	// [def: 8] maximum number of files held, the least recently used file is dropped when full
+	MaxFiles int `default:"8" desc:"maximum number of files held, the least recently used file is dropped when full"`
+
+	// [view: -] cached files, most recently used last
+	Snds []*CachedSnd `view:"-" desc:"cached files, most recently used last"`
+}
+
+// Get returns the cached file, making it the most recently used
+func (sc *SndCache) Get(file string) (*CachedSnd, bool) {
+	for i, cs := range sc.Snds {
+		if cs.File == file {
+			sc.Snds = append(append(sc.Snds[:i:i], sc.Snds[i+1:]...), cs)
+			return cs, true
+		}
+	}
+	return nil, false
+}
+
+// Add adds the file to the cache, dropping the least recently used file if the cache is full
+func (sc *SndCache) Add(file string, snd sound.Wave, signal []float64) {
+	if sc.MaxFiles <= 0 {
+		return
+	}
+	if len(sc.Snds) >= sc.MaxFiles {
+		sc.Snds = sc.Snds[1:]
+	}
+	sc.Snds = append(sc.Snds, &CachedSnd{File: file, Sound: snd, Signal: append([]float64{}, signal...)})
+}
+
+// StepCache holds the dft, mel and mfcc outputs of each window of the current sound file, by window start sample,
+// so consecutive or overlapping units of the same file reuse the windows already processed instead of
+// re-windowing the sound. The cache is reset whenever the file or the processing params change
+type StepCache struct {
+
+	// [view: -] file and params the cached steps were computed with
+	Key string `view:"-" desc:"file and params the cached steps were computed with"`
+
+	// [view: -] power, log power, mel and mfcc values of each window, by window start sample
+	Steps map[int][]float64 `view:"-" desc:"power, log power, mel and mfcc values of each window, by window start sample"`
+
+	// number of steps taken from the cache
+	Hits int `inactive:"+" desc:"number of steps taken from the cache"`
+
+	// number of steps processed
+	Misses int `inactive:"+" desc:"number of steps processed"`
+}
+
+// Check resets the cache if key differs from the key of the cached steps
+func (sc *StepCache) Check(key string) {
+	if sc.Steps != nil && sc.Key == key {
+		return
+	}
+	sc.Key = key
+	sc.Steps = map[int][]float64{}
+	sc.Hits = 0
+	sc.Misses = 0
+}
+
+// stepTensors returns the segment tensors whose step columns are cached
+func stepTensors(pparams *ProcessParams) []*etensor.Float64 {
+	tsrs := []*etensor.Float64{&pparams.PowerSegment, &pparams.MelFBankSegment}
+	if pparams.Dft.CompLogPow {
+		tsrs = append(tsrs, &pparams.LogPowerSegment)
+	}
+	if pparams.Mel.MFCC {
+		tsrs = append(tsrs, &pparams.MFCCSegment)
+	}
+	return tsrs
+}
+
+// Save stores the step column of the segment tensors as the outputs of the window starting at start
+func (sc *StepCache) Save(start, step int, pparams *ProcessParams) {
+	var vals []float64
+	for _, tsr := range stepTensors(pparams) {
+		for r := 0; r < tsr.Dim(0); r++ {
+			vals = append(vals, tsr.Value([]int{r, step}))
+		}
+	}
+	sc.Steps[start] = vals
+	sc.Misses++
+}
+
+// Load sets the step column of the segment tensors to the outputs of the window starting at start, returning false if not cached
+func (sc *StepCache) Load(start, step int, pparams *ProcessParams) bool {
+	vals, ok := sc.Steps[start]
+	if !ok {
+		return false
+	}
+	i := 0
+	for _, tsr := range stepTensors(pparams) {
+		for r := 0; r < tsr.Dim(0); r++ {
+			tsr.Set([]int{r, step}, vals[i])
+			i++
+		}
+	}
+	sc.Hits++
+	return true
+}
+
+// Process generates the mel output and from that the result of the convolution with the gabor filters
+// Must call ProcessSetup() first ! Errors are returned for the caller to report, e.g. ProcessView shows a dialog
+func (ap *App) Process(wparams *WinParams, pparams *ProcessParams, gparams *GaborParams) (err error) {
+	ap.LoadSound(wparams)
+
+	if ap.Sound.Buf == nil {
+		return errors.New("Sound buffer is empty, open a sound file before processing")
+	}
+
+	if wparams.SegmentEnd <= wparams.SegmentStart {
+		return errors.New("SegmentEnd must be greater than SegmentStart")
+	}
+
+	if wparams.Resize {
+		wparams.SegmentStart, wparams.SegmentEnd = sound.ResizeSegment(wparams.SegmentStart, wparams.SegmentEnd, wparams.StepMs, gparams.GaborSet.SizeX, gparams.GaborSet.StrideX)
+	}
+
+	sr := ap.Sound.SampleRate()
+	if sr <= 0 {
+		fmt.Println("sample rate <= 0")
+		return errors.New("sample rate <= 0")
+
+	}
+	wparams.WinSamples = sound.MSecToSamples(wparams.WinMs, sr)
+	wparams.StepSamples = sound.MSecToSamples(wparams.StepMs, sr)
+
+	// round up to nearest step interval
+	segmentMs := wparams.SegmentEnd - wparams.SegmentStart
+	segmentMs = segmentMs + wparams.StepMs*float64(int(segmentMs)%int(wparams.StepMs))
+	steps := int(segmentMs / wparams.StepMs)
+	wparams.StepsTotal = steps + 2*wparams.BorderSteps
+
+	winSamplesHalf := wparams.WinSamples/2 + 1
+	err = pparams.Mel.InitFilters(wparams.WinSamples, ap.Sound.SampleRate(), &pparams.MelFilters) // call after non-default values are set!
+	if err != nil {
+		return err
+	}
+	ap.Window.SetShape([]int{wparams.WinSamples}, nil, nil)
+	pparams.Power.SetShape([]int{winSamplesHalf}, nil, nil)
+	pparams.LogPower.CopyShapeFrom(&pparams.Power)
+	pparams.PowerSegment.SetShape([]int{winSamplesHalf, wparams.StepsTotal}, nil, nil)
+	if pparams.Dft.CompLogPow {
+		pparams.LogPowerSegment.CopyShapeFrom(&pparams.PowerSegment)
+	}
+
+	// 2 reasons for this code
+	// 1 - the amount of signal handed to the fft has a "border" (some extra signal) to avoid edge effects.
+	// On the first step there is no signal to act as the "border" so we pad the data handed on the front.
+	// 2 - signals needs to be aligned when the number when multiple signals are input (e.g. 100 and 300 ms)
+	// so that the leading edge (right edge) is the same time point.
+	// This code does this by generating negative offsets for the start of the processing.
+	// Also see SndToWindow for the use of the step values
+	stepsBack := wparams.BorderSteps
+	wparams.Steps = make([]int, wparams.StepsTotal)
+	for i := 0; i < wparams.StepsTotal; i++ {
+		wparams.Steps[i] = wparams.StepSamples * (i - stepsBack)
+	}
+
+	pparams.MelFBank.SetShape([]int{pparams.Mel.FBank.NFilters}, nil, nil)
+	pparams.MelFBankSegment.SetShape([]int{pparams.Mel.FBank.NFilters, wparams.StepsTotal}, nil, nil)
+	pparams.Energy.SetShape([]int{wparams.StepsTotal}, nil, nil)
+	if pparams.Mel.MFCC {
+		pparams.MFCCDct.SetShape([]int{pparams.Mel.FBank.NFilters}, nil, nil)
+		pparams.MFCCSegment.SetShape([]int{pparams.Mel.NCoefs, wparams.StepsTotal}, nil, nil)
+		pparams.MFCCDeltas.SetShape([]int{pparams.Mel.NCoefs, wparams.StepsTotal}, nil, nil)
+		pparams.MFCCDeltaDeltas.SetShape([]int{pparams.Mel.NCoefs, wparams.StepsTotal}, nil, nil)
+	}
+	samples := sound.MSecToSamples(wparams.SegmentEnd-wparams.SegmentStart, ap.Sound.SampleRate())
+	siglen := len(ap.Signal.Values) - samples*ap.Sound.Channels()
+	siglen = siglen / ap.Sound.Channels()
+
+	pparams.Power.SetZeros()
+	pparams.LogPower.SetZeros()
+	pparams.PowerSegment.SetZeros()
+	pparams.LogPowerSegment.SetZeros()
+	pparams.MelFBankSegment.SetZeros()
+	pparams.MFCCSegment.SetZeros()
+	pparams.Energy.SetZeros()
+
+	pparams.Cache.Check(fmt.Sprintf("%v %v %v %+v %+v %v %v", ap.SndFile, wparams.WinSamples, wparams.Channel, pparams.Dft, pparams.Mel.FBank, pparams.Mel.MFCC, pparams.Mel.NCoefs))
+	for s := 0; s < int(wparams.StepsTotal); s++ {
+		err := ap.ProcessStep(s, wparams, pparams, gparams)
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+	}
+
+	for s := 0; s < wparams.StepsTotal; s++ {
+		e := 0.0
+		for f := 0; f < pparams.LogPowerSegment.Shape.Dim(1); f++ {
+			e += pparams.LogPowerSegment.FloatValRowCell(f, s)
+		}
+		pparams.Energy.SetFloat1D(s, e)
+	}
+
+	for s := 0; s < wparams.StepsTotal; s++ {
+		pparams.MFCCSegment.SetFloatRowCell(0, s, pparams.Energy.FloatVal1D(s))
+	}
+	if pparams.Mel.MFCC && pparams.Mel.CMVN {
+		pparams.Mel.NormalizeCMVN(&pparams.MFCCSegment) // before the deltas, which are of the normalized mfcc
+	}
+
+	// the deltas and delta-deltas over time, see mel.Deltas
+	if pparams.Mel.MFCC && pparams.Mel.Deltas {
+		mel.Deltas(&pparams.MFCCSegment, &pparams.MFCCDeltas, pparams.Mel.DeltaWin)
+		mel.Deltas(&pparams.MFCCDeltas, &pparams.MFCCDeltaDeltas, pparams.Mel.DeltaWin)
+	}
+	return nil
+}
+
+// ProcessStep processes a step worth of sound input from current input_pos, and increment input_pos by input.step_samples
+// Process the data by doing a fourier transform and computing the power spectrum, then apply mel filters to get the frequency
+// bands that mimic the non-linear human perception of sound
+func (ap *App) ProcessStep(step int, wparams *WinParams, pparams *ProcessParams, gparams *GaborParams) error {
+	offset := wparams.Steps[step]
+	start := sound.MSecToSamples(wparams.SegmentStart, ap.Sound.SampleRate()) + offset
+	if pparams.Cache.Steps != nil && pparams.Cache.Load(start, step, pparams) {
+		return nil
+	}
+	err := ap.SndToWindow(start, wparams)
+	if err == nil {
+		pparams.Dft.Filter(step, &ap.Window, wparams.WinSamples, &pparams.Power, &pparams.LogPower, &pparams.PowerSegment, &pparams.LogPowerSegment)
+		pparams.Mel.FilterDft(step, &pparams.Power, &pparams.MelFBankSegment, &pparams.MelFBank, &pparams.MelFilters)
+		if pparams.Mel.MFCC {
+			pparams.Mel.CepstrumDct(step, &pparams.MelFBank, &pparams.MFCCSegment, &pparams.MFCCDct)
+			pparams.MFCCSegment.SetFloatRowCell(0, step, pparams.Energy.FloatVal1D(step))
+		}
+		if pparams.Cache.Steps != nil {
+			pparams.Cache.Save(start, step, pparams)
+		}
+	}
+	return err
+}
+
+// ToTensor loads the sound file, e.g. .wav file, the transcription is loaded in LoadTranscription()
+func (ap *App) ToTensor(wparams *WinParams) bool {
+	ap.Sound.SoundToTensor(&ap.Signal)
+	return true
+}
+
+// AdjSeqTimes adjust for any offset if the sequence doesn't start at 0 ms. Also adjust for random silence that
+// might have been added to front of signal
+func (ap *App) AdjSeqTimes(seq *speech.Sequence) {
+	silence := seq.Silence // random silence added to start of sequence for variability
+	offset := 0.0
+	if seq.Units[0].Start > 0 {
+		offset = seq.Units[0].Start // some sequences are sections of longer ones so times don't start at zero (not true for timit)
+	}
+	for i := range seq.Units {
+		seq.Units[i].AStart = seq.Units[i].Start + silence - offset
+		seq.Units[i].AEnd = seq.Units[i].End + silence - offset
+	}
+}
+
+// IdxFmSnd simplies the lookup by keeping the corpus conditional in one function
+func (ap *App) IdxFmSnd(seq speech.Sequence, s string) (idx int, ok bool) {
+	idx = -1
+	ok = false
+	if ap.Corpus == "TIMIT" {
+		idx, ok = timit.IdxFmSnd(s, seq.ID)
+	} else if ap.Corpus == "SYNTHCVS" {
+		idx, ok = synthcvs.IdxFmSnd(s, seq.ID)
+	} else if ap.Corpus == "GRAFESTES" {
+		idx, ok = grafestes.IdxFmSnd(s, seq.ID)
+	} else {
+		fmt.Println("IdxFmSnd: fell through corpus ifelse ")
+	}
+	return
+}
+
+// IdxFmSnd simplies the lookup by keeping the corpus conditional in one function
+func (ap *App) SndFmIdx(seq speech.Sequence, idx int) (snd string, ok bool) {
+	snd = ""
+	ok = false
+	if ap.Corpus == "TIMIT" {
+		snd, ok = timit.SndFmIdx(idx, seq.ID)
+	} else {
+		fmt.Println("SndFmIdx: fell through corpus ifelse ")
+	}
+	return
+}
+
+// SndToWindow gets sound from the signal (i.e. the slice of input values) at given position
+func (ap *App) SndToWindow(start int, wparams *WinParams) error {
+	end := start + wparams.WinSamples
+	if end > len(ap.Signal.Values) {
+		return errors.New("SndToWindow: end beyond signal length!!")
+	}
+	var pad []float64
+	if start < 0 && end <= 0 {
+		pad = make([]float64, end-start)
+		ap.Window.Values = pad[0:]
+	} else if start < 0 && end > 0 {
+		pad = make([]float64, 0-start)
+		ap.Window.Values = pad[0:]
+		ap.Window.Values = append(ap.Window.Values, ap.Signal.Values[0:end]...)
+	} else {
+		ap.Window.Values = ap.Signal.Values[start:end]
+	}
+	return nil
+}
+
+// ApplyGabor convolves the gabor filters with the mel output, returning any error from UpdateGabors
+func (ap *App) ApplyGabor(pparams *ProcessParams, gparams *GaborParams) error {
+	// determine gabor output size
+	y1 := pparams.MelFBankSegment.Dim(0)
+	y2 := gparams.GaborSet.SizeY
+	y := float64(y1 - y2)
+	sy := (int(math.Floor(y/float64(gparams.GaborSet.StrideY))) + 1) * 2 // double - two rows, off-center and on-center
+
+	x1 := pparams.MelFBankSegment.Dim(1)
+	x2 := gparams.GaborSet.SizeX
+	x := x1 - x2
+	active := agabor.Active(gparams.GaborSpecs)
+	sx := (int(math.Floor(float64(x)/float64(gparams.GaborSet.StrideX))) + 1) * len(active)
+
+	err := ap.UpdateGabors(gparams)
+	gparams.GborOutput.SetShape([]int{sy, sx}, nil, []string{"freq", "time"})
+	gparams.ExtGi.SetShape([]int{sy, gparams.GaborSet.Filters.Dim(0)}, nil, nil) // passed in for each channel
+	gparams.GborOutput.SetMetaData("odd-row", "true")
+	gparams.GborOutput.SetMetaData("grid-fill", ".9")
+	gparams.GborKwta.CopyShapeFrom(&gparams.GborOutput)
+	gparams.GborKwta.CopyMetaData(&gparams.GborOutput)
+
+	agabor.Convolve(&pparams.MelFBankSegment, gparams.GaborSet, &gparams.GborOutput, ap.ByTime)
+	//agabor.Convolve(ch, &pparams.MFCCSegment, gparams.GaborSet, &gparams.GborOutput, ap.ByTime)
+	// NeighInhib only works for 4D (pooled input) and this ap is 2D
+	//if ap.NeighInhib.On {
+	//	ap.NeighInhib.Inhib4(&gparams.GborOutput, &ap.ExtGi)
+	//} else {
+	//	ap.ExtGi.SetZeros()
+	//}
+
+	if gparams.Kwta.On {
+		ap.ApplyKwta(gparams)
+		//tsr = &gparams.GborKwta
+		//} else {
+		//	tsr = &gparams.GborOutput
+	}
+	//return tsr
+	return err
+}
+
+// ApplyKwta runs the kwta algorithm on the raw activations
+func (ap *App) ApplyKwta(gparams *GaborParams) {
+	gparams.GborKwta.CopyFrom(&gparams.GborOutput)
+	if gparams.Kwta.On {
+		// This app is 2D only - no pools
+		//if ap.KwtaPool == true {
+		//	ap.Kwta.KWTAPool(&gparams.GborOutput, &gparams.GborKwta, &ap.Inhibs, &ap.ExtGi)
+		//} else {
+		gparams.Kwta.KWTALayer(&gparams.GborOutput, &gparams.GborKwta, &gparams.ExtGi)
+		//}
+	}
+}
+
+// StatFeatures are the feature tensors whose distributions are shown in the Stats and Hist tabs
+type StatFeatures int32
+
+const (
+	StatMel   StatFeatures = iota // mel filter bank output of the segment
+	StatMFCC                      // mfcc of the segment
+	StatGabor                     // raw gabor output
+	StatKwta                      // gabor output after kwta
+	StatFeaturesN
+)
+
+var KiT_StatFeatures = kit.Enums.AddEnum(StatFeaturesN, kit.NotBitFlag, nil)
+
+var statNames = []string{"Mel", "MFCC", "Gabor", "Kwta"}
+
+func (sf StatFeatures) String() string {
+	if sf < 0 || sf >= StatFeaturesN {
+		return "Unknown"
+	}
+	return statNames[sf]
+}
+
+// FeatureTensor returns the tensor of the feature
+func FeatureTensor(sf StatFeatures, pparams *ProcessParams, gparams *GaborParams) etensor.Tensor {
+	switch sf {
+	case StatMFCC:
+		return &pparams.MFCCSegment
+	case StatGabor:
+		return &gparams.GborOutput
+	case StatKwta:
+		return &gparams.GborKwta
+	}
+	return &pparams.MelFBankSegment
+}
+
+// ConfigStatsTable configures the table of the statistics of each feature, one row per feature
+func ConfigStatsTable(dt *etable.Table) {
+	dt.SetMetaData("name", "Stats")
+	dt.SetMetaData("desc", "statistics of the values of each feature tensor of the current segment")
+	dt.SetMetaData("read-only", "true")
+	sch := etable.Schema{
+		{"Feature", etensor.STRING, nil, nil},
+		{"N", etensor.INT64, nil, nil},
+		{"Mean", etensor.FLOAT64, nil, nil},
+		{"SD", etensor.FLOAT64, nil, nil},
+		{"Min", etensor.FLOAT64, nil, nil},
+		{"Max", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, int(StatFeaturesN))
+}
+
+// ConfigHistTable configures the histogram table of nbins bins
+func ConfigHistTable(dt *etable.Table, nbins int) {
+	dt.SetMetaData("name", "Hist")
+	dt.SetMetaData("desc", "histogram of the values of the selected feature tensor of the current segment")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("XAxisCol", "Value")
+	dt.SetMetaData("Type", "Bar")
+	sch := etable.Schema{
+		{"Value", etensor.FLOAT64, nil, nil},
+		{"Count", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, nbins)
+}
+
+// ConfigWaveformTable configures the table of the waveform of a sound file, see UpdateWaveform
+func ConfigWaveformTable(dt *etable.Table) {
+	dt.SetMetaData("name", "Waveform")
+	dt.SetMetaData("desc", "min and max sample of each span of time of the whole sound file")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("XAxisCol", "Time")
+	sch := etable.Schema{
+		{"Time", etensor.FLOAT64, nil, nil},
+		{"Min", etensor.FLOAT64, nil, nil},
+		{"Max", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+// UpdateWaveform sets the rows of the waveform table to the min and max sample of each of up to n spans of the
+// signal, with the time in milliseconds of the start of the span -- so long files plot as their envelope
+func UpdateWaveform(dt *etable.Table, signal []float64, sampleRate int, n int) {
+	span := (len(signal) + n - 1) / n
+	if span < 1 {
+		span = 1
+	}
+	rows := (len(signal) + span - 1) / span
+	dt.SetNumRows(rows)
+	for r := 0; r < rows; r++ {
+		st := r * span
+		ed := st + span
+		if ed > len(signal) {
+			ed = len(signal)
+		}
+		min, max := signal[st], signal[st]
+		for _, v := range signal[st+1 : ed] {
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+		dt.SetCellFloat("Time", r, float64(st)*1000/float64(sampleRate))
+		dt.SetCellFloat("Min", r, min)
+		dt.SetCellFloat("Max", r, max)
+	}
+}
+
+// FeatureStats returns the number of values, mean, standard deviation, min and max of the tensor
+func FeatureStats(tsr etensor.Tensor) (n int, mean, sd, min, max float64) {
+	n = tsr.Len()
+	if n == 0 {
+		return
+	}
+	min = math.MaxFloat64
+	max = -math.MaxFloat64
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		v := tsr.FloatVal1D(i)
+		sum += v
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	mean = sum / float64(n)
+	ss := 0.0
+	for i := 0; i < n; i++ {
+		d := tsr.FloatVal1D(i) - mean
+		ss += d * d
+	}
+	sd = math.Sqrt(ss / float64(n))
+	return
+}
+
+// UpdateStats fills the stats table with the statistics of each feature, and the histogram table, of nbins
+// bins spanning the min to max of the values, with the distribution of the hist feature
+func UpdateStats(pparams *ProcessParams, gparams *GaborParams, hist StatFeatures, nbins int, stats, hdt *etable.Table) {
+	ConfigStatsTable(stats)
+	for sf := StatMel; sf < StatFeaturesN; sf++ {
+		n, mean, sd, min, max := FeatureStats(FeatureTensor(sf, pparams, gparams))
+		stats.SetCellString("Feature", int(sf), sf.String())
+		stats.SetCellFloat("N", int(sf), float64(n))
+		stats.SetCellFloat("Mean", int(sf), mean)
+		stats.SetCellFloat("SD", int(sf), sd)
+		stats.SetCellFloat("Min", int(sf), min)
+		stats.SetCellFloat("Max", int(sf), max)
+	}
+	if nbins < 1 {
+		nbins = 1
+	}
+	ConfigHistTable(hdt, nbins)
+	tsr := FeatureTensor(hist, pparams, gparams)
+	n, _, _, min, max := FeatureStats(tsr)
+	wd := (max - min) / float64(nbins)
+	for b := 0; b < nbins; b++ {
+		hdt.SetCellFloat("Value", b, min+(float64(b)+0.5)*wd)
+	}
+	for i := 0; i < n; i++ {
+		b := nbins - 1
+		if wd > 0 {
+			b = int((tsr.FloatVal1D(i) - min) / wd)
+		}
+		if b >= nbins {
+			b = nbins - 1
+		}
+		hdt.SetCellFloat("Count", b, hdt.CellFloat("Count", b)+1)
+	}
+}
@@ -52,6 +52,9 @@ type Params struct {
 	// [def: 6] [view: +] overlap with previous segment
 	BorderSteps int `default:"6" view:"+" desc:"overlap with previous segment"`
 
+	// where the steps of each segment are, see sound.AlignStart -- sound.AlignStrides for the alignment of earlier versions of this example
+	Align sound.StepAligns `desc:"where the steps of each segment are, see sound.AlignStart -- sound.AlignStrides for the alignment of earlier versions of this example"`
+
 	// [viewif: Channels=1] specific channel to process, if input has multiple channels, and we only process one of them (-1 = process all)
 	Channel  int `viewif:"Channels=1" desc:"specific channel to process, if input has multiple channels, and we only process one of them (-1 = process all)"`
 	PadValue float64
@@ -187,7 +190,9 @@ type SndProcess struct {
 	ByTime bool `desc:"display the gabor filtering result by time and then by filter, default is to order by filter and then time"`
 }
 
-func (sp *SndProcess) Config() {
+// Config sets the processing params, filters and tensors for the sound -- returns the error of Mel.InitFilters, e.g., for
+// a HiHz above the nyquist frequency with StrictNyquist
+func (sp *SndProcess) Config() error {
 	sp.Params.SegmentMs = 100 // set param overrides here before calling config
 	sr := sp.Sound.SampleRate()
 	sp.Params.WinSamples = MSecToSamples(sp.Params.WinMs, sr)
@@ -201,7 +206,10 @@ func (sp *SndProcess) Config() {
 	sp.Dft.Defaults()
 	sp.Mel.Defaults()
 	// override any default Mel values here - then call InitFilters
-	sp.Mel.InitFilters(sp.Params.WinSamples, sp.Sound.SampleRate(), &sp.MelFilters)
+	err := sp.Mel.InitFilters(sp.Params.WinSamples, sp.Sound.SampleRate(), &sp.MelFilters)
+	if err != nil {
+		return err
+	}
 	sp.Samples.SetShape([]int{sp.Params.WinSamples}, nil, nil)
 	sp.Power.SetShape([]int{sp.Params.WinSamples/2 + 1}, nil, nil)
 	sp.LogPower.SetShape([]int{sp.Params.WinSamples/2 + 1}, nil, nil)
@@ -266,20 +274,10 @@ func (sp *SndProcess) Config() {
 	sp.GaborTsr.SetMetaData("odd-row", "true")
 	sp.GaborTsr.SetMetaData("grid-fill", ".9")
 
-	// 2 reasons for this code
-	// 1 - the amount of signal handed to the fft has a "border" (some extra signal) to avoid edge effects.
-	// On the first step there is no signal to act as the "border" so we pad the data handed on the front.
-	// 2 - signals needs to be aligned when the number when multiple signals are input (e.g. 100 and 300 ms)
-	// so that the leading edge (right edge) is the same time point.
-	// This code does this by generating negative offsets for the start of the processing.
-	// Also see SndToWindow for the use of the step values
-	strides := int(sp.Params.SegmentMs / sp.Params.StrideMs)
-	stepsPerStride := int(sp.Params.StrideMs / sp.Params.StepMs)
-	stepsBack := stepsPerStride*(strides-1) + sp.Params.BorderSteps
-	sp.Params.Steps = make([]int, sp.Params.SegmentSteps)
-	for i := 0; i < sp.Params.SegmentSteps; i++ {
-		sp.Params.Steps[i] = sp.Params.StepSamples * (i - stepsBack)
-	}
+	// the step alignment model of sound.SndEnv -- set Params.Align to sound.AlignStrides for the alignment of earlier versions
+	stepsBack := sound.StepsBack(sp.Params.Align, float64(sp.Params.SegmentMs), float64(sp.Params.StrideMs), float64(sp.Params.StepMs), sp.Params.BorderSteps)
+	sp.Params.Steps = sound.StepOffsets(stepsBack, sp.Params.StepSamples, sp.Params.SegmentSteps)
+	return nil
 }
 
 // Initialize sets all the tensor result data to zeros
@@ -315,7 +313,9 @@ func (sp *SndProcess) ProcessSound(fn string) {
 		return
 	}
 	sp.LoadSound(&sp.Sound)
-	sp.Config()
+	if err := sp.Config(); err != nil {
+		return
+	}
 	sp.Pad(sp.Signal.Values)
 	sp.ProcessSegment()
 	sp.ApplyGabor()
@@ -503,12 +503,13 @@ func (sp *SndProcess) ConfigGUI() *gi.Window {
 	pv := tv.AddNewTab(etview.KiT_TensorGrid, "Power").(*etview.TensorGrid)
 	pv.SetStretchMax()
 	sp.PowerGrid = pv
-	sp.LogPowerSegment.SetMetaData("grid-min", "10")
+	sound.ConfigureForDisplay(&sp.LogPowerSegment, sound.DisplayLogPower)
 	pv.SetTensor(&sp.LogPowerSegment)
 
 	mv := tv.AddNewTab(etview.KiT_TensorGrid, "MelFBank").(*etview.TensorGrid)
 	mv.SetStretchMax()
 	sp.MelGrid = mv
+	sound.ConfigureForDisplay(&sp.MelFBankSegment, sound.DisplayLevel)
 	mv.SetTensor(&sp.MelFBankSegment)
 
 	//gv := tv.AddNewTab(etview.KiT_TensorGrid, "Gabor Filtering Result").(*etview.TensorGrid)
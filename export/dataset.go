@@ -0,0 +1,377 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/emer/auditory/sound"
+	"github.com/emer/auditory/speech"
+	"github.com/emer/etable/etensor"
+)
+
+// DatasetUtt is the index entry of an utterance of a dataset
+type DatasetUtt struct {
+
+	// id of the utterance, e.g., its sound file
+	ID string `desc:"id of the utterance, e.g., its sound file"`
+
+	// number of segments, the rows of its record batch
+	Segments int `desc:"number of segments, the rows of its record batch"`
+
+	// the units of the utterance, their labels and timings, if it has a transcription
+	Units []speech.Unit `desc:"the units of the utterance, their labels and timings, if it has a transcription"`
+}
+
+// DatasetIndex is the index of a dataset, saved as JSON next to the data file (path + ".json")
+type DatasetIndex struct {
+
+	// the features, the feature columns of the record batches, sorted
+	Features []string `desc:"the features, the feature columns of the record batches, sorted"`
+
+	// shape of a segment of each feature
+	Shapes map[string][]int `desc:"shape of a segment of each feature"`
+
+	// the utterances, in the order of their record batches
+	Utts []DatasetUtt `desc:"the utterances, in the order of their record batches"`
+}
+
+// DatasetWriter writes the features of the utterances of a corpus to a dataset, an Apache Arrow IPC file (Feather
+// v2) of one record batch per utterance, with one row per segment, so training reads the features of any utterance
+// directly, without processing its sound again each epoch -- with pyarrow.ipc.open_file too. The columns are
+// Utterance (its id), Segment, StartMs and EndMs (the time of the segment in the sound), Label (the name of the
+// unit at the center of the segment, if there are units) and one column per feature of the float32 values of the
+// segment, a fixed size list with its shape in the "shape" metadata of the field. The index of the utterance ids,
+// their units and the shapes of the features is written as JSON next to the data file, see DatasetIndex
+type DatasetWriter struct {
+
+	// path of the data file
+	Path string `desc:"path of the data file"`
+
+	// the index of the utterances written
+	Index DatasetIndex `desc:"the index of the utterances written"`
+
+	mem    memory.Allocator
+	f      *os.File
+	w      *ipc.FileWriter
+	schema *arrow.Schema
+}
+
+// CreateDataset creates the data file at path, and the index at path + ".json" when the writer is closed
+func CreateDataset(path string) (*DatasetWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &DatasetWriter{Path: path, mem: memory.NewGoAllocator(), f: f}, nil
+}
+
+// Append writes the features of an utterance processed by se, outs of [segments, ...] by feature, e.g., from
+// sound.SndEnv.ProcessSegments, with the units of its transcription (nil for none), labeling each segment by the
+// unit at its center. The first utterance sets the features and their shapes, which all the others must have
+func (dw *DatasetWriter) Append(id string, se *sound.SndEnv, outs map[string]etensor.Tensor, units []speech.Unit) error {
+	feats := make([]string, 0, len(outs))
+	for nm := range outs {
+		feats = append(feats, nm)
+	}
+	sort.Strings(feats)
+	if dw.w == nil {
+		if err := dw.create(feats, outs); err != nil {
+			return err
+		}
+	}
+	nseg := -1
+	for i, nm := range feats {
+		tsr := outs[nm]
+		if i >= len(dw.Index.Features) || dw.Index.Features[i] != nm || !sameShape(tsr.Shapes()[1:], dw.Index.Shapes[nm]) {
+			err := fmt.Errorf("export.DatasetWriter: features of %v, %v, differ from those of the dataset, %v of shapes %v", id, feats, dw.Index.Features, dw.Index.Shapes)
+			log.Println(err)
+			return err
+		}
+		if nseg >= 0 && tsr.Dim(0) != nseg {
+			err := fmt.Errorf("export.DatasetWriter: features of %v have different numbers of segments", id)
+			log.Println(err)
+			return err
+		}
+		nseg = tsr.Dim(0)
+	}
+	if len(feats) != len(dw.Index.Features) {
+		err := fmt.Errorf("export.DatasetWriter: features of %v, %v, differ from those of the dataset, %v", id, feats, dw.Index.Features)
+		log.Println(err)
+		return err
+	}
+
+	ub := array.NewStringBuilder(dw.mem)
+	sb := array.NewInt32Builder(dw.mem)
+	stb := array.NewFloat64Builder(dw.mem)
+	edb := array.NewFloat64Builder(dw.mem)
+	lb := array.NewStringBuilder(dw.mem)
+	sr := se.Sound.SampleRate()
+	ps := &se.Params
+	for s := 0; s < nseg; s++ {
+		st := s * ps.StrideSamples
+		stMs := sound.SamplesToMSec(st+ps.Steps[0], sr)
+		edMs := sound.SamplesToMSec(st+ps.Steps[len(ps.Steps)-1]+ps.WinSamples, sr)
+		ub.Append(id)
+		sb.Append(int32(s))
+		stb.Append(stMs)
+		edb.Append(edMs)
+		lb.Append(unitAt(units, (stMs+edMs)/2))
+	}
+	cols := []array.Interface{ub.NewArray(), sb.NewArray(), stb.NewArray(), edb.NewArray(), lb.NewArray()}
+	for _, b := range []array.Builder{ub, sb, stb, edb, lb} {
+		b.Release()
+	}
+	for _, nm := range feats {
+		tsr := outs[nm]
+		n := tsr.Len() / nseg
+		fb := array.NewFixedSizeListBuilder(dw.mem, int32(n), arrow.PrimitiveTypes.Float32)
+		vb := fb.ValueBuilder().(*array.Float32Builder)
+		vb.Reserve(tsr.Len())
+		for s := 0; s < nseg; s++ {
+			fb.Append(true)
+			for i := s * n; i < (s+1)*n; i++ {
+				vb.Append(float32(tsr.FloatVal1D(i)))
+			}
+		}
+		cols = append(cols, fb.NewArray())
+		fb.Release()
+	}
+	rec := array.NewRecord(dw.schema, cols, int64(nseg))
+	err := dw.w.Write(rec)
+	rec.Release()
+	for _, c := range cols {
+		c.Release()
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	dw.Index.Utts = append(dw.Index.Utts, DatasetUtt{ID: id, Segments: nseg, Units: units})
+	return nil
+}
+
+// create sets the features and their shapes and the schema, and starts the file
+func (dw *DatasetWriter) create(feats []string, outs map[string]etensor.Tensor) error {
+	dw.Index.Features = feats
+	dw.Index.Shapes = map[string][]int{}
+	fields := []arrow.Field{
+		{Name: "Utterance", Type: arrow.BinaryTypes.String},
+		{Name: "Segment", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "StartMs", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "EndMs", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "Label", Type: arrow.BinaryTypes.String},
+	}
+	for _, nm := range feats {
+		shp := append([]int(nil), outs[nm].Shapes()[1:]...)
+		dw.Index.Shapes[nm] = shp
+		n := 1
+		ss := make([]string, len(shp))
+		for i, d := range shp {
+			n *= d
+			ss[i] = strconv.Itoa(d)
+		}
+		md := arrow.NewMetadata([]string{"shape"}, []string{strings.Join(ss, ",")})
+		fields = append(fields, arrow.Field{Name: nm, Type: arrow.FixedSizeListOf(int32(n), arrow.PrimitiveTypes.Float32), Metadata: md})
+	}
+	dw.schema = arrow.NewSchema(fields, nil)
+	w, err := ipc.NewFileWriter(dw.f, ipc.WithSchema(dw.schema), ipc.WithAllocator(dw.mem))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	dw.w = w
+	return nil
+}
+
+// Close finishes and closes the data file and writes the index
+func (dw *DatasetWriter) Close() error {
+	var err error
+	if dw.w != nil {
+		err = dw.w.Close()
+	}
+	if cerr := dw.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	b, err := json.Marshal(&dw.Index)
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(dw.Path+".json", b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// sameShape returns whether the shapes are the same
+func sameShape(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// unitAt returns the name of the unit at the time in milliseconds, "" for none
+func unitAt(units []speech.Unit, ms float64) string {
+	for _, u := range units {
+		if ms >= u.Start && ms < u.End {
+			return u.Name
+		}
+	}
+	return ""
+}
+
+// DatasetUtterance is the features of an utterance read from a dataset, with the time and label of each segment
+type DatasetUtterance struct {
+
+	// id of the utterance
+	ID string `desc:"id of the utterance"`
+
+	// start of each segment in milliseconds
+	StartMs []float64 `desc:"start of each segment in milliseconds"`
+
+	// end of each segment in milliseconds
+	EndMs []float64 `desc:"end of each segment in milliseconds"`
+
+	// label of each segment, the unit at its center
+	Labels []string `desc:"label of each segment, the unit at its center"`
+
+	// the features, [segments, ...] by name
+	Features map[string]*etensor.Float32 `desc:"the features, [segments, ...] by name"`
+}
+
+// Dataset is a dataset opened for reading, see DatasetWriter
+type Dataset struct {
+
+	// the index of the utterances
+	Index DatasetIndex `desc:"the index of the utterances"`
+
+	f   *os.File
+	r   *ipc.FileReader
+	ids map[string]int
+}
+
+// OpenDataset opens the dataset written at path by a DatasetWriter
+func OpenDataset(path string) (*Dataset, error) {
+	b, err := os.ReadFile(path + ".json")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	ds := &Dataset{}
+	if err := json.Unmarshal(b, &ds.Index); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	ds.f, err = os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	ds.r, err = ipc.NewFileReader(ds.f)
+	if err != nil {
+		log.Println(err)
+		ds.f.Close()
+		return nil, err
+	}
+	if ds.r.NumRecords() != len(ds.Index.Utts) {
+		err := fmt.Errorf("export.OpenDataset: %v has %v utterances, its index %v", path, ds.r.NumRecords(), len(ds.Index.Utts))
+		log.Println(err)
+		ds.Close()
+		return nil, err
+	}
+	ds.ids = make(map[string]int, len(ds.Index.Utts))
+	for i, u := range ds.Index.Utts {
+		ds.ids[u.ID] = i
+	}
+	return ds, nil
+}
+
+// Len returns the number of utterances
+func (ds *Dataset) Len() int {
+	return len(ds.Index.Utts)
+}
+
+// Find returns the index of the utterance of the id
+func (ds *Dataset) Find(id string) (int, bool) {
+	i, ok := ds.ids[id]
+	return i, ok
+}
+
+// Utterance reads utterance i, its features and the time and label of each segment
+func (ds *Dataset) Utterance(i int) (*DatasetUtterance, error) {
+	if i < 0 || i >= ds.Len() {
+		err := fmt.Errorf("export.Dataset: utterance %v out of range, there are %v", i, ds.Len())
+		log.Println(err)
+		return nil, err
+	}
+	rec, err := ds.r.Record(i)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	nseg := int(rec.NumRows())
+	du := &DatasetUtterance{ID: ds.Index.Utts[i].ID, Features: map[string]*etensor.Float32{}}
+	for c, fld := range rec.Schema().Fields() {
+		col := rec.Column(c)
+		switch fld.Name {
+		case "StartMs":
+			du.StartMs = append([]float64(nil), col.(*array.Float64).Float64Values()...)
+		case "EndMs":
+			du.EndMs = append([]float64(nil), col.(*array.Float64).Float64Values()...)
+		case "Label":
+			ls := col.(*array.String)
+			du.Labels = make([]string, nseg)
+			for s := range du.Labels {
+				du.Labels[s] = ls.Value(s)
+			}
+		case "Utterance", "Segment":
+		default:
+			shp, ok := ds.Index.Shapes[fld.Name]
+			if !ok {
+				continue
+			}
+			vals := col.(*array.FixedSizeList).ListValues().(*array.Float32).Float32Values()
+			tsr := etensor.NewFloat32(append([]int{nseg}, shp...), nil, nil)
+			copy(tsr.Values, vals)
+			du.Features[fld.Name] = tsr
+		}
+	}
+	return du, nil
+}
+
+// Close closes the data file
+func (ds *Dataset) Close() error {
+	err := ds.r.Close()
+	if cerr := ds.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
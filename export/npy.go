@@ -0,0 +1,182 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package export writes and reads feature tensors, e.g., the MelFBankSegment, MFCCSegment and GborOutput of a
+sound.SndEnv, in the NumPy .npy and .npz formats, so features computed in Go can be analyzed in Python with
+numpy.load, and arrays saved by numpy.save and numpy.savez can be read in Go
+*/
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/emer/etable/etensor"
+)
+
+// WriteNPY writes the values of the tensor to w in the NumPy .npy format (version 1.0), in row-major order, as
+// little-endian float32 for a Float32 tensor and float64 otherwise, e.g., for reading features with numpy.load
+func WriteNPY(w io.Writer, tsr etensor.Tensor) error {
+	descr := "<f8"
+	if tsr.DataType() == etensor.FLOAT32 {
+		descr = "<f4"
+	}
+	shp := make([]string, tsr.NumDims())
+	for i, n := range tsr.Shapes() {
+		shp[i] = fmt.Sprint(n)
+	}
+	shape := strings.Join(shp, ", ")
+	if len(shp) == 1 {
+		shape += ","
+	}
+	hdr := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", descr, shape)
+	pad := 64 - (10+len(hdr)+1)%64 // the magic, version and header length take 10 bytes, the header ends with a newline
+	if pad == 64 {
+		pad = 0
+	}
+	hdr += strings.Repeat(" ", pad) + "\n"
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("\x93NUMPY\x01\x00")
+	binary.Write(bw, binary.LittleEndian, uint16(len(hdr)))
+	bw.WriteString(hdr)
+	var b [8]byte
+	n := tsr.Len()
+	if f32, ok := tsr.(*etensor.Float32); ok {
+		for _, v := range f32.Values {
+			binary.LittleEndian.PutUint32(b[:4], math.Float32bits(v))
+			bw.Write(b[:4])
+		}
+	} else {
+		for i := 0; i < n; i++ {
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(tsr.FloatVal1D(i)))
+			bw.Write(b[:])
+		}
+	}
+	return bw.Flush()
+}
+
+// SaveNPY saves the tensor to the file in the NumPy .npy format, see WriteNPY
+func SaveNPY(tsr etensor.Tensor, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	err = WriteNPY(f, tsr)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// npyHeader matches the fields of the header of a .npy file
+var npyHeader = regexp.MustCompile(`'descr':\s*'([^']*)'.*'fortran_order':\s*(True|False).*'shape':\s*\(([^)]*)\)`)
+
+// ReadNPY reads a tensor in the NumPy .npy format from r (versions 1 to 3), of little-endian float32 or float64
+// values in row-major order, returning a Float32 or Float64 tensor of its shape
+func ReadNPY(r io.Reader) (etensor.Tensor, error) {
+	br := bufio.NewReader(r)
+	var pre [8]byte
+	if _, err := io.ReadFull(br, pre[:]); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if string(pre[:6]) != "\x93NUMPY" {
+		err := errors.New("export.ReadNPY: not a .npy file")
+		log.Println(err)
+		return nil, err
+	}
+	var hlen int
+	if pre[6] == 1 {
+		var n uint16
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		hlen = int(n)
+	} else {
+		var n uint32
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		hlen = int(n)
+	}
+	hdr := make([]byte, hlen)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	m := npyHeader.FindStringSubmatch(string(hdr))
+	if m == nil {
+		err := fmt.Errorf("export.ReadNPY: bad header %q", hdr)
+		log.Println(err)
+		return nil, err
+	}
+	if m[2] == "True" {
+		err := errors.New("export.ReadNPY: fortran order arrays are not supported, save a C order array, e.g., with numpy.ascontiguousarray")
+		log.Println(err)
+		return nil, err
+	}
+	var shp []int
+	for _, d := range strings.Split(m[3], ",") {
+		if d = strings.TrimSpace(d); d == "" {
+			continue
+		}
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			err = fmt.Errorf("export.ReadNPY: bad shape %q", m[3])
+			log.Println(err)
+			return nil, err
+		}
+		shp = append(shp, n)
+	}
+	if len(shp) == 0 {
+		shp = []int{1} // a scalar
+	}
+	switch m[1] {
+	case "<f4":
+		tsr := etensor.NewFloat32(shp, nil, nil)
+		if err := binary.Read(br, binary.LittleEndian, tsr.Values); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		return tsr, nil
+	case "<f8":
+		tsr := etensor.NewFloat64(shp, nil, nil)
+		if err := binary.Read(br, binary.LittleEndian, tsr.Values); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		return tsr, nil
+	}
+	err := fmt.Errorf("export.ReadNPY: unsupported dtype %q, only little-endian float32 and float64 are read", m[1])
+	log.Println(err)
+	return nil, err
+}
+
+// OpenNPY opens a tensor saved in the NumPy .npy format, see ReadNPY
+func OpenNPY(filename string) (etensor.Tensor, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer f.Close()
+	return ReadNPY(f)
+}
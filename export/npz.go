@@ -0,0 +1,101 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"archive/zip"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/emer/auditory/sound"
+	"github.com/emer/etable/etensor"
+)
+
+// SaveNPZ saves the tensors to the file in the NumPy .npz format, a zip archive of one .npy file per tensor (see
+// WriteNPY), as by numpy.savez -- numpy.load(filename)[name] is the tensor of the name. The tensors are saved in
+// the order of their names
+func SaveNPZ(tsrs map[string]etensor.Tensor, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	zw := zip.NewWriter(f)
+	names := make([]string, 0, len(tsrs))
+	for nm := range tsrs {
+		names = append(names, nm)
+	}
+	sort.Strings(names)
+	for _, nm := range names {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: nm + ".npy", Method: zip.Store})
+		if err == nil {
+			err = WriteNPY(w, tsrs[nm])
+		}
+		if err != nil {
+			log.Println(err)
+			f.Close()
+			return err
+		}
+	}
+	err = zw.Close()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// OpenNPZ opens the tensors saved in the NumPy .npz format, e.g., by numpy.savez or numpy.savez_compressed, by
+// their names, see ReadNPY
+func OpenNPZ(filename string) (map[string]etensor.Tensor, error) {
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer zr.Close()
+	tsrs := map[string]etensor.Tensor{}
+	for _, zf := range zr.File {
+		r, err := zf.Open()
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		tsr, err := ReadNPY(r)
+		r.Close()
+		if err != nil {
+			err = fmt.Errorf("export.OpenNPZ: %v in %v: %v", zf.Name, filename, err)
+			log.Println(err)
+			return nil, err
+		}
+		tsrs[strings.TrimSuffix(zf.Name, ".npy")] = tsr
+	}
+	return tsrs, nil
+}
+
+// SaveOutputs saves the named outputs of the SndEnv (see sound.OutputNames), e.g., "Mel", "MFCC" and "Gabor" of
+// the current segment, to the file in the NumPy .npz format, named by their names with the Prefix of the SndEnv --
+// all the computed outputs (see SndEnv.Outputs) for no names
+func SaveOutputs(se *sound.SndEnv, names []string, filename string) error {
+	if len(names) == 0 {
+		names = se.Outputs()
+	}
+	tsrs := make(map[string]etensor.Tensor, len(names))
+	for _, nm := range names {
+		tsr := se.Output(nm)
+		if tsr == nil {
+			err := fmt.Errorf("export.SaveOutputs: %q is not an output of the SndEnv, see sound.OutputNames", nm)
+			log.Println(err)
+			return err
+		}
+		tsrs[se.Prefix+nm] = tsr
+	}
+	return SaveNPZ(tsrs, filename)
+}
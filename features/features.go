@@ -0,0 +1,165 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package features computes common spectral descriptors of each step -- the centroid, bandwidth, rolloff, flux and
+flatness of the power spectrum and the zero crossing rate of the samples -- as a tensor of [features, steps] that can be
+fed to a network alongside the mel and gabor outputs, see sound.SndEnv.Features
+*/
+package features
+
+import (
+	"math"
+
+	"github.com/emer/auditory/vad"
+	"github.com/emer/etable/etensor"
+)
+
+// Features are the spectral descriptors, the rows of the output of Segment
+type Features int32
+
+const (
+	// Centroid is the center of mass of the power spectrum, the "brightness" of the sound
+	Centroid Features = iota
+
+	// Bandwidth is the standard deviation of the power spectrum around its centroid
+	Bandwidth
+
+	// Rolloff is the frequency below which RolloffPct of the power lies
+	Rolloff
+
+	// Flux is the euclidean distance between the power spectrum and that of the previous step, each normalized to a sum of 1
+	// so it measures the change of the shape of the spectrum, not of the level -- 0 for the first step of a segment
+	Flux
+
+	// Flatness is the geometric over the arithmetic mean of the power spectrum, 0 for a pure tone and 1 for white noise
+	Flatness
+
+	// ZCR is the zero crossing rate of the samples of the step, the proportion of successive samples that change sign
+	ZCR
+
+	FeaturesN
+)
+
+// Names are the names of the Features, in order
+var Names = []string{"Centroid", "Bandwidth", "Rolloff", "Flux", "Flatness", "ZCR"}
+
+// Params are the params of the spectral features
+type Params struct {
+
+	// compute the spectral features
+	On bool `desc:"compute the spectral features"`
+
+	// [def: 0.85] [viewif: On] proportion of the power below the Rolloff frequency
+	RolloffPct float64 `viewif:"On" default:"0.85" desc:"proportion of the power below the Rolloff frequency"`
+
+	// [def: true] [viewif: On] express the Centroid, Bandwidth and Rolloff as a proportion of the nyquist frequency, 0..1 as suits network inputs, instead of in Hz
+	NormHz bool `viewif:"On" default:"true" desc:"express the Centroid, Bandwidth and Rolloff as a proportion of the nyquist frequency, 0..1 as suits network inputs, instead of in Hz"`
+}
+
+// Defaults
+func (fp *Params) Defaults() {
+	fp.RolloffPct = 0.85
+	fp.NormHz = true
+}
+
+// CentroidOf returns the centroid of the power spectrum, in bins -- 0 for a silent spectrum
+func CentroidOf(power []float64) float64 {
+	sum, wsum := 0.0, 0.0
+	for k, p := range power {
+		sum += p
+		wsum += float64(k) * p
+	}
+	if sum == 0 {
+		return 0
+	}
+	return wsum / sum
+}
+
+// BandwidthOf returns the standard deviation of the power spectrum around its centroid, in bins
+func BandwidthOf(power []float64, centroid float64) float64 {
+	sum, wsum := 0.0, 0.0
+	for k, p := range power {
+		d := float64(k) - centroid
+		sum += p
+		wsum += d * d * p
+	}
+	if sum == 0 {
+		return 0
+	}
+	return math.Sqrt(wsum / sum)
+}
+
+// RolloffOf returns the bin below which the proportion pct of the power of the spectrum lies, interpolated
+func RolloffOf(power []float64, pct float64) float64 {
+	sum := 0.0
+	for _, p := range power {
+		sum += p
+	}
+	if sum == 0 {
+		return 0
+	}
+	thr := pct * sum
+	cum := 0.0
+	for k, p := range power {
+		if cum+p >= thr {
+			if p == 0 {
+				return float64(k)
+			}
+			return float64(k) + (thr-cum)/p
+		}
+		cum += p
+	}
+	return float64(len(power))
+}
+
+// FluxOf returns the euclidean distance between the power spectra, each normalized to a sum of 1 -- 0 if either is silent
+func FluxOf(power, prev []float64) float64 {
+	sum, psum := 0.0, 0.0
+	for k, p := range power {
+		sum += p
+		psum += prev[k]
+	}
+	if sum == 0 || psum == 0 {
+		return 0
+	}
+	d := 0.0
+	for k, p := range power {
+		df := p/sum - prev[k]/psum
+		d += df * df
+	}
+	return math.Sqrt(d)
+}
+
+// Segment sets the rows of out [FeaturesN, steps] to the features of each step of the power spectra of a segment,
+// power [bins, steps] (e.g., sound.SndEnv.PowerSegment) of windows of winSamples samples at the sample rate -- all but
+// ZCR, which needs the samples (see vad.ZeroCrossRate) and is left as it is
+func (fp *Params) Segment(power *etensor.Float64, sampleRate, winSamples int, out *etensor.Float64) {
+	nb, steps := power.Dim(0), power.Dim(1)
+	if out.NumDims() != 2 || out.Dim(0) != int(FeaturesN) || out.Dim(1) != steps {
+		out.SetShape([]int{int(FeaturesN), steps}, nil, []string{"Feature", "Time"})
+	}
+	hzPer := float64(sampleRate) / float64(winSamples) // hz of a bin
+	if fp.NormHz {
+		hzPer /= float64(sampleRate) / 2
+	}
+	col := make([]float64, nb)
+	prev := make([]float64, nb)
+	for s := 0; s < steps; s++ {
+		for k := range col {
+			col[k] = power.Values[k*steps+s]
+		}
+		ctr := CentroidOf(col)
+		out.Values[int(Centroid)*steps+s] = ctr * hzPer
+		out.Values[int(Bandwidth)*steps+s] = BandwidthOf(col, ctr) * hzPer
+		out.Values[int(Rolloff)*steps+s] = RolloffOf(col, fp.RolloffPct) * hzPer
+		if s > 0 {
+			out.Values[int(Flux)*steps+s] = FluxOf(col, prev)
+		} else {
+			out.Values[int(Flux)*steps+s] = 0
+		}
+		out.Values[int(Flatness)*steps+s] = vad.SpectralFlatness(col)
+		col, prev = prev, col
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package gammatone is a gammatone filterbank, an alternative to the mel filterbank of package mel that models the
+// filtering of the cochlea: the filters are spaced evenly on the ERB (equivalent rectangular bandwidth) rate scale
+// with bandwidths proportional to the ERB at their center frequencies (Glasberg & Moore, 1990; Patterson et al, 1992).
+// The filters are applied to the power of the dft, as the mel filters are, or in the time domain to the window of
+// samples, and the output of a segment, a cochleagram, has the [filters, steps] layout of the mel output
+package gammatone
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/cmplx"
+
+	"github.com/emer/etable/etensor"
+)
+
+// Params are the gammatone filterbank parameters
+type Params struct {
+
+	// [def: 32] [view: +] number of gammatone filters
+	NFilters int `view:"+" default:"32" desc:"number of gammatone filters"`
+
+	// [def: 100] [view: +] center frequency of the lowest filter in Hz
+	LoHz float64 `view:"+" default:"100" desc:"center frequency of the lowest filter in Hz"`
+
+	// [def: 8000] [view: +] center frequency of the highest filter in Hz, limited to the nyquist frequency
+	HiHz float64 `view:"+" default:"8000" desc:"center frequency of the highest filter in Hz, limited to the nyquist frequency"`
+
+	// [def: 4] order of the filters, 4 matches the auditory filter shapes
+	Order int `default:"4" desc:"order of the filters, 4 matches the auditory filter shapes"`
+
+	// filter the window of samples in the time domain (see FilterWindow) instead of weighting the power of the dft (see FilterDft)
+	TimeDomain bool `desc:"filter the window of samples in the time domain (see FilterWindow) instead of weighting the power of the dft (see FilterDft)"`
+
+	// [def: 0] add this amount when taking the log of the filter outputs -- e.g., 1.0 makes everything positive
+	LogOff float64 `default:"0" desc:"add this amount when taking the log of the filter outputs -- e.g., 1.0 makes everything positive"`
+
+	// [def: -10] minimum value a log can produce -- puts a lower limit on log output
+	LogMin float64 `default:"-10" desc:"minimum value a log can produce -- puts a lower limit on log output"`
+
+	// [view: -] center frequency of each filter in Hz
+	CtrHz []float64 `view:"-" desc:"center frequency of each filter in Hz"`
+
+	// [view: -] sample rate the filters were initialized for
+	SampleRate int `view:"-" desc:"sample rate the filters were initialized for"`
+}
+
+// Defaults sets the default params
+func (gt *Params) Defaults() {
+	gt.NFilters = 32
+	gt.LoHz = 100
+	gt.HiHz = 8000
+	gt.Order = 4
+	gt.LogOff = 0
+	gt.LogMin = -10
+}
+
+// ERB returns the equivalent rectangular bandwidth in Hz of the auditory filter centered at hz (Glasberg & Moore, 1990)
+func ERB(hz float64) float64 {
+	return 24.7 * (4.37*hz/1000 + 1)
+}
+
+// HzToERBRate converts a frequency to the ERB rate scale, the number of ERBs below the frequency
+func HzToERBRate(hz float64) float64 {
+	return 21.4 * math.Log10(4.37*hz/1000+1)
+}
+
+// ERBRateToHz converts an ERB rate to frequency
+func ERBRateToHz(erbs float64) float64 {
+	return (math.Pow(10, erbs/21.4) - 1) * 1000 / 4.37
+}
+
+// Bandwidth returns the bandwidth parameter b in Hz of the filter centered at hz, 1.019 ERB for order 4
+func (gt *Params) Bandwidth(hz float64) float64 {
+	return 1.019 * ERB(hz)
+}
+
+// InitFilters computes the center frequencies and, for FilterDft, the power weights of the filters for the dft size
+// and sample rate, filters shape [NFilters, dftSize/2+1], each peaking at 1 at its center frequency. HiHz is limited to
+// the nyquist frequency with a warning
+func (gt *Params) InitFilters(dftSize int, sampleRate int, filters *etensor.Float64) error {
+	if sampleRate <= 0 {
+		err := errors.New("gammatone.InitFilters: sample rate <= 0")
+		log.Println(err)
+		return err
+	}
+	hiHz := gt.HiHz
+	if nyq := float64(sampleRate) / 2; hiHz > nyq {
+		log.Printf("gammatone.InitFilters: HiHz %v is above the nyquist frequency %v for sample rate %v, limited to %v\n", hiHz, nyq, sampleRate, nyq)
+		hiHz = nyq
+	}
+	if gt.LoHz >= hiHz || gt.NFilters < 1 {
+		err := fmt.Errorf("gammatone.InitFilters: LoHz %v must be below HiHz %v with at least one filter", gt.LoHz, hiHz)
+		log.Println(err)
+		return err
+	}
+	if gt.Order < 1 {
+		gt.Order = 4
+	}
+	gt.SampleRate = sampleRate
+	gt.CtrHz = make([]float64, gt.NFilters)
+	lo, hi := HzToERBRate(gt.LoHz), HzToERBRate(hiHz)
+	for f := range gt.CtrHz {
+		e := lo
+		if gt.NFilters > 1 {
+			e += float64(f) * (hi - lo) / float64(gt.NFilters-1)
+		}
+		gt.CtrHz[f] = ERBRateToHz(e)
+	}
+
+	nbins := dftSize/2 + 1
+	filters.SetShape([]int{gt.NFilters, nbins}, nil, nil)
+	for f, fc := range gt.CtrHz {
+		b := gt.Bandwidth(fc)
+		for k := 0; k < nbins; k++ {
+			x := (float64(k)*float64(sampleRate)/float64(dftSize) - fc) / b
+			filters.Values[f*nbins+k] = math.Pow(1+x*x, -float64(gt.Order)) // squared magnitude response
+		}
+	}
+	return nil
+}
+
+// CenterFreqs returns the center frequency, in Hz, of each filter -- call after InitFilters
+func (gt *Params) CenterFreqs() []float64 {
+	return gt.CtrHz
+}
+
+// logOut returns the log output of the filter sum
+func (gt *Params) logOut(sum float64) float64 {
+	sum += gt.LogOff
+	if sum <= 0 {
+		return gt.LogMin
+	}
+	return math.Max(math.Log(sum), gt.LogMin)
+}
+
+// FilterDft applies the filters to the power of the dft, setting the log output of each filter in fBankData and column step of segmentData
+func (gt *Params) FilterDft(step int, dftPowerOut *etensor.Float64, segmentData *etensor.Float64, fBankData *etensor.Float64, filters *etensor.Float64) {
+	nbins := filters.Dim(1)
+	for f := 0; f < gt.NFilters; f++ {
+		sum := 0.0
+		for k := 0; k < nbins && k < dftPowerOut.Len(); k++ {
+			sum += filters.Values[f*nbins+k] * dftPowerOut.Values[k]
+		}
+		val := gt.logOut(sum)
+		fBankData.SetFloat1D(f, val)
+		segmentData.Set([]int{f, step}, val)
+	}
+}
+
+// FilterWindow filters the window of samples with each gammatone filter in the time domain, by complex demodulation
+// to the center frequency and Order cascaded one pole lowpass filters (Holdsworth et al, 1988), setting the log of
+// the mean power of each filter output, times the squared window length to match the scale of the dft power, in
+// fBankData and column step of segmentData, as FilterDft. Each window is filtered from rest, so the output of the
+// narrow low frequency filters builds up over the window
+func (gt *Params) FilterWindow(step int, window *etensor.Float64, segmentData *etensor.Float64, fBankData *etensor.Float64) {
+	sr := float64(gt.SampleRate)
+	n := window.Len()
+	state := make([]complex128, gt.Order)
+	for f, fc := range gt.CtrHz {
+		a := 1 - math.Exp(-2*math.Pi*gt.Bandwidth(fc)/sr) // one pole coefficient
+		for i := range state {
+			state[i] = 0
+		}
+		sum := 0.0
+		for t := 0; t < n; t++ {
+			x := complex(window.Values[t], 0) * cmplx.Rect(1, -2*math.Pi*fc*float64(t)/sr)
+			for i := range state {
+				state[i] += complex(a, 0) * (x - state[i])
+				x = state[i]
+			}
+			sum += real(x)*real(x) + imag(x)*imag(x)
+		}
+		val := gt.logOut(sum * float64(n))
+		fBankData.SetFloat1D(f, val)
+		segmentData.Set([]int{f, step}, val)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package meddis is an optional, biologically detailed stage modeling the inner hair cell / auditory nerve synapse
+// of Meddis (1986, 1988), applied per frequency channel to the band filtered waveform (e.g. gammatone
+// or BandFilter outputs). The transmitter reservoir model adapts to sustained input, giving the
+// probabilistic firing rate a strong onset emphasis followed by a lower sustained rate.
+// Meddis R (1986) Simulation of mechanical to neural transduction in the auditory receptor, JASA 79(3)
+// Meddis R (1988) Simulation of auditory-neural transduction: Further studies, JASA 83(3)
+package meddis
+
+import (
+	"log"
+
+	"github.com/emer/etable/etensor"
+)
+
+// Params are the parameters of the hair cell model, the defaults are the high spontaneous rate
+// fiber parameters of Meddis (1988)
+type Params struct {
+
+	// [def: 5] permeability offset -- with B sets the spontaneous permeability
+	A float64 `default:"5" desc:"permeability offset -- with B sets the spontaneous permeability"`
+
+	// [def: 300] permeability rate -- input level at which permeability is half max
+	B float64 `default:"300" desc:"permeability rate -- input level at which permeability is half max"`
+
+	// [def: 2000] maximum permeability, per second
+	G float64 `default:"2000" desc:"maximum permeability, per second"`
+
+	// [def: 5.05] replenishment rate of the free transmitter pool from the factory, per second
+	Y float64 `default:"5.05" desc:"replenishment rate of the free transmitter pool from the factory, per second"`
+
+	// [def: 2500] loss rate of transmitter from the cleft, per second
+	L float64 `default:"2500" desc:"loss rate of transmitter from the cleft, per second"`
+
+	// [def: 6580] reuptake rate of transmitter from the cleft into the reprocessing store, per second
+	R float64 `default:"6580" desc:"reuptake rate of transmitter from the cleft into the reprocessing store, per second"`
+
+	// [def: 66.31] rate of return from the reprocessing store to the free pool, per second
+	X float64 `default:"66.31" desc:"rate of return from the reprocessing store to the free pool, per second"`
+
+	// [def: 1] maximum amount of free transmitter
+	M float64 `default:"1" desc:"maximum amount of free transmitter"`
+
+	// [def: 50000] firing rate proportionality factor -- firing probability per second is H times the cleft contents
+	H float64 `default:"50000" desc:"firing rate proportionality factor -- firing probability per second is H times the cleft contents"`
+
+	// [def: 1000] scales the input waveform (normalized -1..1 samples) into the model's input units, where about 30 is threshold and 300 is half saturation
+	Gain float64 `default:"1000" desc:"scales the input waveform (normalized -1..1 samples) into the model's input units, where about 30 is threshold and 300 is half saturation"`
+}
+
+// Defaults
+func (mp *Params) Defaults() {
+	mp.A = 5
+	mp.B = 300
+	mp.G = 2000
+	mp.Y = 5.05
+	mp.L = 2500
+	mp.R = 6580
+	mp.X = 66.31
+	mp.M = 1
+	mp.H = 50000
+	mp.Gain = 1000
+}
+
+// State is the state of the transmitter reservoirs of one channel
+type State struct {
+	Q float64 // free transmitter pool
+	C float64 // transmitter in the cleft
+	W float64 // reprocessing store
+}
+
+// Rest returns the steady state of the reservoirs with no input, from which processing starts
+func (mp *Params) Rest() State {
+	k := mp.G * mp.A / (mp.A + mp.B)
+	c := mp.M * mp.Y * k / (mp.L*k + mp.Y*(mp.L+mp.R))
+	return State{Q: c * (mp.L + mp.R) / k, C: c, W: c * mp.R / mp.X}
+}
+
+// Step advances the state by one sample of input s (in model units) with time step dt seconds
+// and returns the firing probability for the sample, i.e. H * C * dt
+func (mp *Params) Step(st *State, s, dt float64) float64 {
+	k := 0.0
+	if s+mp.A > 0 {
+		k = mp.G * (s + mp.A) / (s + mp.A + mp.B)
+	}
+	rel := k * dt * st.Q
+	rep := mp.Y * dt * (mp.M - st.Q)
+	if rep < 0 {
+		rep = 0
+	}
+	ret := mp.X * dt * st.W
+	lost := mp.L * dt * st.C
+	reup := mp.R * dt * st.C
+	st.Q += rep + ret - rel
+	st.C += rel - lost - reup
+	st.W += reup - ret
+	return mp.H * st.C * dt
+}
+
+// Process runs the model over one channel's waveform, starting from the rest state, and writes the firing
+// probability per sample to out, which is resized as needed. Multiply by the sample rate for the rate in spikes / s
+func (mp *Params) Process(in []float64, sampleRate int, out []float64) []float64 {
+	if sampleRate <= 0 {
+		log.Println("meddis.Process: sample rate <= 0")
+		return out
+	}
+	if cap(out) < len(in) {
+		out = make([]float64, len(in))
+	}
+	out = out[:len(in)]
+	dt := 1 / float64(sampleRate)
+	st := mp.Rest()
+	for i, v := range in {
+		out[i] = mp.Step(&st, mp.Gain*v, dt)
+	}
+	return out
+}
+
+// ProcessChannels runs the model over each channel of a [channel, time] tensor of band filtered waveforms,
+// e.g. the output of a gammatone filter bank, writing the firing probabilities to out with the same shape
+func (mp *Params) ProcessChannels(in *etensor.Float64, sampleRate int, out *etensor.Float64) {
+	if in.NumDims() != 2 {
+		log.Println("meddis.ProcessChannels: input must be a 2D [channel, time] tensor")
+		return
+	}
+	out.CopyShapeFrom(in)
+	nt := in.Dim(1)
+	for ch := 0; ch < in.Dim(0); ch++ {
+		mp.Process(in.Values[ch*nt:(ch+1)*nt], sampleRate, out.Values[ch*nt:(ch+1)*nt])
+	}
+}
@@ -5,8 +5,12 @@
 package mel
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"math"
+	"os"
 
 	"github.com/emer/etable/etensor"
 	"gonum.org/v1/gonum/dsp/fourier"
@@ -41,6 +45,9 @@ type FilterBank struct {
 
 	// [view: -] 1.0 / (ren_max - ren_min)
 	RenormScale float64 `view:"-" desc:"1.0 / (ren_max - ren_min)"`
+
+	// return an error from InitFilters if HiHz is above the nyquist frequency of the sound, instead of clamping HiHz to the nyquist frequency with a warning
+	StrictNyquist bool `desc:"return an error from InitFilters if HiHz is above the nyquist frequency of the sound, instead of clamping HiHz to the nyquist frequency with a warning"`
 }
 
 // Params
@@ -61,8 +68,26 @@ type Params struct {
 	// [def: false] [view: +]  compute the MFCC deltas and delta-deltas
 	Deltas bool `view:"+" default:"false" desc:" compute the MFCC deltas and delta-deltas"`
 
+	// [def: 2] [viewif: Deltas] number of steps before and after each step the deltas are computed over, see Deltas
+	DeltaWin int `viewif:"Deltas" default:"2" desc:"number of steps before and after each step the deltas are computed over, see Deltas"`
+
+	// [def: false] compute the deltas and delta-deltas of the filter bank output too, not just of the MFCC
+	FBankDeltas bool `default:"false" desc:"compute the deltas and delta-deltas of the filter bank output too, not just of the MFCC"`
+
 	// [def: 13] [viewif: MFCC]  number of mfcc coefficients to output -- typically 1/2 of the number of filterbank features
 	NCoefs int `viewif:"MFCC" default:"13" desc:" number of mfcc coefficients to output -- typically 1/2 of the number of filterbank features"`
+
+	// [def: 22] [viewif: MFCC] sinusoidal lifter coefficient L, each mfcc coefficient n is scaled by 1 + L/2 sin(pi n / L), as in HTK and Kaldi -- 0 for no liftering
+	Lifter float64 `viewif:"MFCC" default:"22" desc:"sinusoidal lifter coefficient L, each mfcc coefficient n is scaled by 1 + L/2 sin(pi n / L), as in HTK and Kaldi -- 0 for no liftering"`
+
+	// [def: false] [viewif: MFCC] cepstral mean and variance normalization -- normalize each mfcc coefficient by its mean and variance over the segment, or a sliding window, see NormalizeCMVN
+	CMVN bool `viewif:"MFCC" default:"false" desc:"cepstral mean and variance normalization -- normalize each mfcc coefficient by its mean and variance over the segment, or a sliding window, see NormalizeCMVN"`
+
+	// [def: 0] [viewif: CMVN] number of steps of the sliding window, centered on each step, over which the mean and variance are computed -- 0 for the whole segment
+	CMVNWindow int `viewif:"CMVN" default:"0" desc:"number of steps of the sliding window, centered on each step, over which the mean and variance are computed -- 0 for the whole segment"`
+
+	// [def: true] [viewif: CMVN] divide by the standard deviation as well -- otherwise only the mean is subtracted (CMN)
+	CMVNVar bool `viewif:"CMVN" default:"true" desc:"divide by the standard deviation as well -- otherwise only the mean is subtracted (CMN)"`
 }
 
 // Defaults
@@ -71,10 +96,41 @@ func (mel *Params) Defaults() {
 	mel.MFCC = true
 	mel.NCoefs = 13
 	mel.Deltas = true
+	mel.DeltaWin = 2
+	mel.FBankDeltas = false
+	mel.Lifter = 22
+	mel.CMVN = false
+	mel.CMVNWindow = 0
+	mel.CMVNVar = true
 }
 
-// InitFilters computes the filter bin values
-func (mel *Params) InitFilters(dftSize int, sampleRate int, filters *etensor.Float64) {
+// InitFilters computes the filter bin values. If HiHz is above the nyquist frequency of sampleRate (e.g. the 8000 Hz
+// default with 8 kHz files) the filters above nyquist would be empty, so HiHz is clamped to the nyquist frequency, for this
+// call only, with a warning -- or an error is returned if FBank.StrictNyquist is set.
+// An error is also returned if LoHz is not below the (clamped) HiHz, and a warning is logged if filters
+// are narrower than a dft bin, in which case there are too many filters for the dft size
+func (mel *Params) InitFilters(dftSize int, sampleRate int, filters *etensor.Float64) error {
+	if sampleRate <= 0 {
+		err := errors.New("mel.InitFilters: sample rate <= 0")
+		log.Println(err)
+		return err
+	}
+	hiHz := mel.FBank.HiHz
+	nyq := float64(sampleRate) / 2
+	if hiHz > nyq {
+		if mel.FBank.StrictNyquist {
+			err := fmt.Errorf("mel.InitFilters: HiHz %v is above the nyquist frequency %v for sample rate %v", hiHz, nyq, sampleRate)
+			log.Println(err)
+			return err
+		}
+		log.Printf("mel.InitFilters: HiHz %v is above the nyquist frequency %v for sample rate %v, clamped to %v\n", hiHz, nyq, sampleRate, nyq)
+		hiHz = nyq
+	}
+	if mel.FBank.LoHz >= hiHz {
+		err := fmt.Errorf("mel.InitFilters: LoHz %v must be below HiHz %v", mel.FBank.LoHz, hiHz)
+		log.Println(err)
+		return err
+	}
 	mel.BinPts = make([]int32, mel.FBank.NFilters+2) // plus 2 because we need end points to create the right number of bins
 	mel.HzPts = make([]float64, mel.FBank.NFilters+2)
 	mel.FBank.Renorm = false
@@ -82,7 +138,7 @@ func (mel *Params) InitFilters(dftSize int, sampleRate int, filters *etensor.Flo
 		mel.FBank.RenormScale = 1.0 / (mel.FBank.RenormMax - mel.FBank.RenormMin)
 	}
 
-	hiMel := FreqToMel(mel.FBank.HiHz)
+	hiMel := FreqToMel(hiHz)
 	loMel := FreqToMel(mel.FBank.LoHz)
 	incr := (hiMel - loMel) / float64(mel.FBank.NFilters+1)
 
@@ -92,8 +148,19 @@ func (mel *Params) InitFilters(dftSize int, sampleRate int, filters *etensor.Flo
 		mel.HzPts[i] = hz
 		mel.BinPts[i] = int32(FreqToBin(hz, float64(dftSize), float64(sampleRate)))
 	}
+	for i := 1; i < len(mel.BinPts); i++ {
+		if mel.BinPts[i] == mel.BinPts[i-1] {
+			log.Printf("mel.InitFilters: %v filters from %v to %v Hz are too many for the dft size %v at sample rate %v, some filters collapse to a single bin\n", mel.FBank.NFilters, mel.FBank.LoHz, hiHz, dftSize, sampleRate)
+			break
+		}
+	}
 
-	maxBins := len(mel.BinPts)
+	maxBins := len(mel.BinPts) // the widest filter, in bins -- can be more than the points for few filters
+	for f := 0; f < mel.FBank.NFilters; f++ {
+		if w := int(mel.BinPts[f+2]-mel.BinPts[f]) + 1; w > maxBins {
+			maxBins = w
+		}
+	}
 	filters.SetShape([]int{mel.FBank.NFilters, maxBins}, nil, nil)
 
 	for f := 0; f < mel.FBank.NFilters; f++ {
@@ -106,7 +173,10 @@ func (mel *Params) InitFilters(dftSize int, sampleRate int, filters *etensor.Flo
 		fi := 0
 		bin := 0
 		for bin = binMin; bin <= binCtr; bin, fi = bin+1, fi+1 {
-			fval := (float64(bin) - float64(binMin)) / pkmin
+			fval := 1.0 // collapsed filter
+			if pkmin > 0 {
+				fval = (float64(bin) - float64(binMin)) / pkmin
+			}
 			filters.SetFloat([]int{f, fi}, float64(fval))
 		}
 		for ; bin <= binMax; bin, fi = bin+1, fi+1 {
@@ -114,6 +184,16 @@ func (mel *Params) InitFilters(dftSize int, sampleRate int, filters *etensor.Flo
 			filters.SetFloat([]int{f, fi}, float64(fval))
 		}
 	}
+	return nil
+}
+
+// CenterFreqs returns the center frequency, in Hz, of each mel filter -- call after InitFilters
+func (mel *Params) CenterFreqs() []float64 {
+	ctrs := make([]float64, mel.FBank.NFilters)
+	for f := range ctrs {
+		ctrs[f] = mel.HzPts[f+1]
+	}
+	return ctrs
 }
 
 // FilterDft applies the mel filters to power of dft
@@ -188,6 +268,14 @@ func (mel *Params) FftReal(out []complex128, in *etensor.Float64) {
 	}
 }
 
+// LifterCoef returns the sinusoidal lifter scale of mfcc coefficient n, 1 + Lifter/2 sin(pi n / Lifter), 1 if Lifter is 0
+func (mel *Params) LifterCoef(n int) float64 {
+	if mel.Lifter <= 0 {
+		return 1
+	}
+	return 1 + 0.5*mel.Lifter*math.Sin(math.Pi*float64(n)/mel.Lifter)
+}
+
 // CepstrumDct applies a discrete cosine transform (DCT) to get the cepstrum coefficients on the mel filterbank values
 func (mel *Params) CepstrumDct(step int, fBankData *etensor.Float64, mfccSegment *etensor.Float64, mfccDct *etensor.Float64) {
 	sz := copy(mfccDct.Values, fBankData.Values)
@@ -203,10 +291,111 @@ func (mel *Params) CepstrumDct(step int, fBankData *etensor.Float64, mfccSegment
 	el0 := mfccOut[0]
 	mfccOut[0] = math.Log(1.0 + el0*el0) // replace with log energy instead..
 
-	// copy only NCoefs
+	// copy only NCoefs, liftered
 	for i := 0; i < mel.NCoefs; i++ {
-		mfccSegment.SetFloat([]int{i, step}, mfccOut[i])
+		mfccSegment.SetFloat([]int{i, step}, mfccOut[i]*mel.LifterCoef(i))
 	}
 
 	// calculate deltas
 }
+
+// NormalizeCMVN normalizes each coefficient (row) of the [coefs, steps] mfcc segment, subtracting its mean and, if
+// CMVNVar, dividing by its standard deviation, over all the steps or, if CMVNWindow > 0, over the CMVNWindow steps
+// centered on each step (shifted to stay within the segment at its ends)
+func (mel *Params) NormalizeCMVN(mfccSegment *etensor.Float64) {
+	nc, steps := mfccSegment.Dim(0), mfccSegment.Dim(1)
+	win := mel.CMVNWindow
+	if win <= 0 || win > steps {
+		win = steps
+	}
+	sum := make([]float64, steps+1) // prefix sums of the row and its squares
+	sumSq := make([]float64, steps+1)
+	norm := make([]float64, steps)
+	for c := 0; c < nc; c++ {
+		row := mfccSegment.Values[c*steps : (c+1)*steps]
+		for s, v := range row {
+			sum[s+1] = sum[s] + v
+			sumSq[s+1] = sumSq[s] + v*v
+		}
+		for s := range row {
+			st := s - win/2
+			if st < 0 {
+				st = 0
+			}
+			if st+win > steps {
+				st = steps - win
+			}
+			n := float64(win)
+			mean := (sum[st+win] - sum[st]) / n
+			v := row[s] - mean
+			if mel.CMVNVar {
+				vr := (sumSq[st+win]-sumSq[st])/n - mean*mean
+				v /= math.Sqrt(math.Max(vr, 0) + 1e-8)
+			}
+			norm[s] = v
+		}
+		copy(row, norm)
+	}
+}
+
+// Deltas sets dst to the deltas over time of each row of the [rows, steps] src, e.g., the mfcc or the filter bank output
+// of a segment, by the standard regression over the n steps before and after each step:
+// d[t] = sum_{i=1..n} i (c[t+i] - c[t-i]) / (2 sum_{i=1..n} i^2), with the steps beyond the ends of the segment
+// repeating the first or last step. The delta-deltas are the Deltas of the deltas. dst is reshaped as needed
+func Deltas(src, dst *etensor.Float64, n int) {
+	rows, steps := src.Dim(0), src.Dim(1)
+	dst.SetShape([]int{rows, steps}, nil, src.DimNames())
+	if n < 1 {
+		n = 1
+	}
+	denom := 0.0
+	for i := 1; i <= n; i++ {
+		denom += float64(i * i)
+	}
+	denom *= 2
+	for r := 0; r < rows; r++ {
+		row := src.Values[r*steps : (r+1)*steps]
+		for t := range row {
+			nume := 0.0
+			for i := 1; i <= n; i++ {
+				prv, nxt := t-i, t+i
+				if prv < 0 {
+					prv = 0
+				}
+				if nxt > steps-1 {
+					nxt = steps - 1
+				}
+				nume += float64(i) * (row[nxt] - row[prv])
+			}
+			dst.Values[r*steps+t] = nume / denom
+		}
+	}
+}
+
+// OpenJSON opens the params from a JSON-formatted file, e.g., saved by SaveJSON -- the params not in the file are unchanged
+func (mel *Params) OpenJSON(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	err = json.Unmarshal(b, mel)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// SaveJSON saves the params to a JSON-formatted file
+func (mel *Params) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(mel, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
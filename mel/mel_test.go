@@ -0,0 +1,187 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mel
+
+import (
+	"bytes"
+	"log"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emer/etable/etensor"
+)
+
+// captureLog returns what fn logs
+func captureLog(fn func()) string {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	fn()
+	return buf.String()
+}
+
+// checkTriangles checks that each filter is the triangle from its first to its last bin point, peaking at 1 at its center
+func checkTriangles(t *testing.T, mel *Params, filters *etensor.Float64) {
+	t.Helper()
+	for f := 0; f < mel.FBank.NFilters; f++ {
+		lo, ctr, hi := int(mel.BinPts[f]), int(mel.BinPts[f+1]), int(mel.BinPts[f+2])
+		for bin := lo; bin <= hi; bin++ {
+			want := 1.0
+			if bin < ctr {
+				want = float64(bin-lo) / float64(ctr-lo)
+			} else if bin > ctr {
+				want = float64(hi-bin) / float64(hi-ctr)
+			}
+			if got := filters.Value([]int{f, bin - lo}); got != want {
+				t.Errorf("filter %v bin %v: got %v, want %v", f, bin, got, want)
+			}
+		}
+	}
+}
+
+func TestInitFilters16k(t *testing.T) {
+	var mel Params
+	mel.Defaults()
+	var filters etensor.Float64
+	var err error
+	logged := captureLog(func() { err = mel.InitFilters(400, 16000, &filters) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logged != "" {
+		t.Errorf("unexpected warning at the nyquist frequency: %v", logged)
+	}
+	// the bin points before HiHz was checked against the nyquist frequency
+	want := []int32{0, 1, 2, 4, 6, 8, 10, 12, 14, 17, 20, 23, 26, 29, 33, 37, 41, 46, 51, 57, 63, 69, 76, 84, 92, 100, 110, 120, 131, 143, 155, 169, 184, 200}
+	if !reflect.DeepEqual(mel.BinPts, want) {
+		t.Errorf("BinPts got %v, want %v", mel.BinPts, want)
+	}
+	if hz := mel.HzPts[len(mel.HzPts)-1]; hz < 7999.999 || hz > 8000.001 {
+		t.Errorf("last HzPts got %v, want 8000", hz)
+	}
+	if filters.Dim(0) != 32 || filters.Dim(1) != 34 {
+		t.Errorf("filters shape got %v, want [32 34]", filters.Shapes())
+	}
+	checkTriangles(t, &mel, &filters)
+}
+
+func TestInitFiltersClamp(t *testing.T) {
+	var mel Params
+	mel.Defaults()
+	var filters etensor.Float64
+	var err error
+	logged := captureLog(func() { err = mel.InitFilters(200, 8000, &filters) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logged, "clamped to 4000") {
+		t.Errorf("no clamp warning, logged: %q", logged)
+	}
+	if mel.FBank.HiHz != 8000 {
+		t.Errorf("HiHz got %v, should be unchanged by the clamp", mel.FBank.HiHz)
+	}
+	if hz := mel.HzPts[len(mel.HzPts)-1]; hz < 3999.999 || hz > 4000.001 {
+		t.Errorf("last HzPts got %v, want 4000", hz)
+	}
+	if bin := mel.BinPts[len(mel.BinPts)-1]; bin != 100 {
+		t.Errorf("last BinPts got %v, want the nyquist bin 100", bin)
+	}
+	checkTriangles(t, &mel, &filters)
+}
+
+func TestInitFiltersStrictNyquist(t *testing.T) {
+	var mel Params
+	mel.Defaults()
+	mel.FBank.StrictNyquist = true
+	var filters etensor.Float64
+	var err error
+	captureLog(func() { err = mel.InitFilters(200, 8000, &filters) })
+	if err == nil {
+		t.Fatal("no error for HiHz above the nyquist frequency with StrictNyquist")
+	}
+	if filters.Len() != 0 {
+		t.Errorf("filters set despite the error: %v", filters.Shapes())
+	}
+	if err := mel.InitFilters(400, 16000, &filters); err != nil {
+		t.Errorf("error at the nyquist frequency with StrictNyquist: %v", err)
+	}
+}
+
+// ramp returns a [rows, steps] tensor, each row a linear ramp of slope r+1
+func ramp(rows, steps int) *etensor.Float64 {
+	tsr := etensor.NewFloat64([]int{rows, steps}, nil, nil)
+	for r := 0; r < rows; r++ {
+		for t := 0; t < steps; t++ {
+			tsr.Values[r*steps+t] = float64(r+1)*float64(t) - 3
+		}
+	}
+	return tsr
+}
+
+func TestDeltasRamp(t *testing.T) {
+	const rows, steps = 3, 12
+	src := ramp(rows, steps)
+	for n := 1; n <= 3; n++ {
+		var d, dd etensor.Float64
+		Deltas(src, &d, n)
+		Deltas(&d, &dd, n)
+		if d.Dim(0) != rows || d.Dim(1) != steps {
+			t.Fatalf("n %v: deltas shape %v", n, d.Shapes())
+		}
+		for r := 0; r < rows; r++ {
+			slope := float64(r + 1)
+			for s := n; s < steps-n; s++ { // away from the ends, the delta of a ramp is its slope
+				if got := d.Values[r*steps+s]; math.Abs(got-slope) > 1e-12 {
+					t.Errorf("n %v row %v step %v: delta %v, want the slope %v", n, r, s, got, slope)
+				}
+			}
+			for s := 2 * n; s < steps-2*n; s++ {
+				if got := dd.Values[r*steps+s]; math.Abs(got) > 1e-12 {
+					t.Errorf("n %v row %v step %v: delta-delta %v, want 0", n, r, s, got)
+				}
+			}
+		}
+	}
+}
+
+func TestDeltasEdges(t *testing.T) {
+	const steps = 8
+	src := ramp(1, steps)
+	// with the first and last steps repeated beyond the ends of the segment, the ramp flattens at both ends:
+	// n 1: d[0] = (c[1] - c[0]) / 2
+	// n 2: d[0] = (1 (c[1] - c[0]) + 2 (c[2] - c[0])) / 10 and d[1] = (1 (c[2] - c[0]) + 2 (c[3] - c[0])) / 10
+	for _, c := range []struct {
+		n    int
+		ends []float64 // the deltas of the first steps, mirrored at the last steps
+	}{
+		{1, []float64{0.5}},
+		{2, []float64{0.5, 0.8}},
+	} {
+		var d etensor.Float64
+		Deltas(src, &d, c.n)
+		for i, want := range c.ends {
+			if got := d.Values[i]; math.Abs(got-want) > 1e-12 {
+				t.Errorf("n %v: delta of step %v %v, want %v", c.n, i, got, want)
+			}
+			if got := d.Values[steps-1-i]; math.Abs(got-want) > 1e-12 {
+				t.Errorf("n %v: delta of step %v %v, want %v", c.n, steps-1-i, got, want)
+			}
+		}
+	}
+	// a spike at the first step, repeated before the start, is seen by the first n+1 steps
+	spike := etensor.NewFloat64([]int{1, steps}, nil, nil)
+	spike.Values[0] = 10
+	var d etensor.Float64
+	Deltas(spike, &d, 2)
+	want := []float64{-3, -3, -2, 0, 0, 0, 0, 0}
+	for s, w := range want {
+		if got := d.Values[s]; math.Abs(got-w) > 1e-12 {
+			t.Errorf("spike: delta of step %v %v, want %v", s, got, w)
+		}
+	}
+}
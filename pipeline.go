@@ -0,0 +1,145 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auditory
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/emer/auditory/agabor"
+	"github.com/emer/auditory/sound"
+	"github.com/emer/etable/etensor"
+)
+
+// Pipeline is the common case of the sound processing in one constructor: a wav file in, its mel output -- and mfcc
+// and gabor output, by the options -- out, over all its segments. Set it up with NewPipeline and its options and
+// call Process for each file:
+//
+//	pl := auditory.NewPipeline(auditory.WithMFCC(), auditory.WithGabors(nil))
+//	outs, err := pl.Process("sa1.wav")
+//	gabor := outs["Gabor"] // [segments, units y, units x]
+//
+// For anything the options don't cover set the params of Snd directly, before Process
+type Pipeline struct {
+
+	// the sound processing, its params and outputs
+	Snd sound.SndEnv `desc:"the sound processing, its params and outputs"`
+
+	// gabor filter the mel output, set by WithGabors -- the gabor output is 2D, see SndEnv.SetGaborOut2D
+	Gabors bool `desc:"gabor filter the mel output, set by WithGabors -- the gabor output is 2D, see SndEnv.SetGaborOut2D"`
+
+	// the sound file processed last
+	File string `inactive:"+" desc:"the sound file processed last"`
+}
+
+// Option is an option of NewPipeline, setting the params of the Pipeline
+type Option func(pl *Pipeline)
+
+// NewPipeline returns a Pipeline with the default params of SndEnv, short sounds padded to a whole segment and mel
+// output only, no kwta, then sets the options in order
+func NewPipeline(opts ...Option) *Pipeline {
+	pl := &Pipeline{}
+	pl.Snd.Defaults()
+	pl.Snd.Params.PadShort = true
+	pl.Snd.Kwta.On = false
+	pl.Snd.NeighInhib.On = false
+	for _, opt := range opts {
+		opt(pl)
+	}
+	return pl
+}
+
+// WithMFCC adds the mfcc output, with energy and deltas
+func WithMFCC() Option {
+	return func(pl *Pipeline) {
+		pl.Snd.Mel.MFCC = true
+		pl.Snd.Mel.Deltas = true
+	}
+}
+
+// WithGabors adds the gabor output, filtering the mel output by the specs with the filter size and strides of
+// SndEnv.GaborDefaults -- nil specs for its standard filters
+func WithGabors(specs []agabor.Filter) Option {
+	return func(pl *Pipeline) {
+		pl.Snd.GaborDefaults()
+		if specs != nil {
+			pl.Snd.GaborSpecs = specs
+		}
+		pl.Gabors = true
+	}
+}
+
+// WithKWTA adds kwta over the whole gabor output, as GaborKwta, see SndEnv.Kwta
+func WithKWTA() Option {
+	return func(pl *Pipeline) {
+		pl.Snd.Kwta.On = true
+		pl.Snd.KwtaPool = false
+	}
+}
+
+// WithSegment sets the length of the segments and the stride between them, in milliseconds
+func WithSegment(segmentMs, strideMs float64) Option {
+	return func(pl *Pipeline) {
+		pl.Snd.Params.SegmentMs = segmentMs
+		pl.Snd.Params.StrideMs = strideMs
+	}
+}
+
+// WithFloat32 stacks the outputs as float32, halving their memory, see SndEnv.Float32
+func WithFloat32() Option {
+	return func(pl *Pipeline) {
+		pl.Snd.Float32 = true
+	}
+}
+
+// WithCache shares the filter cache between pipelines, e.g., of the workers of a batch job, see sound.FilterCache
+func WithCache(fc *sound.FilterCache) Option {
+	return func(pl *Pipeline) {
+		pl.Snd.Cache = fc
+	}
+}
+
+// Outputs returns the names of the outputs of Process: Mel, plus MFCC, Gabor and GaborKwta by the options
+func (pl *Pipeline) Outputs() []string {
+	names := []string{"Mel"}
+	if pl.Snd.Mel.MFCC {
+		names = append(names, "MFCC")
+	}
+	if pl.Gabors {
+		names = append(names, "Gabor")
+		if pl.Snd.Kwta.On {
+			names = append(names, "GaborKwta")
+		}
+	}
+	return names
+}
+
+// Process loads the wav file and returns its Outputs stacked over all its segments, [segments, ...], see
+// SndEnv.ProcessSegments
+func (pl *Pipeline) Process(file string) (map[string]etensor.Tensor, error) {
+	se := &pl.Snd
+	if err := se.Sound.Load(file); err != nil {
+		return nil, err
+	}
+	pl.File = file
+	if !se.ToTensor() {
+		err := fmt.Errorf("auditory.Pipeline: couldn't convert %v to a signal", file)
+		log.Println(err)
+		return nil, err
+	}
+	if pl.Gabors {
+		se.SetGaborOut2D()
+	}
+	if err := se.Init(); err != nil {
+		return nil, err
+	}
+	return se.ProcessSegments(pl.Outputs())
+}
+
+// Describe returns a human readable summary of the processing of the last file processed, with the version of
+// auditory, see SndEnv.Describe -- e.g., for the log of an experiment
+func (pl *Pipeline) Describe() string {
+	return fmt.Sprintf("auditory %s (%s)\nfile: %s\n", Version, GitCommit, pl.File) + pl.Snd.Describe()
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package pitch estimates the fundamental frequency (F0) of speech frame by frame, by the YIN algorithm (de Cheveigné &
+Kawahara, "YIN, a fundamental frequency estimator for speech and music", JASA 2002) or by the normalized
+autocorrelation -- for prosody sensitive models (see sound.SndEnv.Pitch) and for checking the pitch of synthesized speech
+(see Track)
+*/
+package pitch
+
+import (
+	"math"
+)
+
+// Methods are the ways of estimating the fundamental frequency of a frame
+type Methods int32
+
+const (
+	// MethodYIN finds the first dip of the cumulative mean normalized difference function below 1 - MinVoicing
+	MethodYIN Methods = iota
+
+	// MethodACF finds the highest peak of the normalized autocorrelation, preferring the shortest period among
+	// peaks nearly as high to avoid octave errors
+	MethodACF
+)
+
+// Params are the params of the pitch estimation
+type Params struct {
+
+	// estimate the pitch
+	On bool `desc:"estimate the pitch"`
+
+	// [viewif: On] how the pitch is estimated
+	Method Methods `viewif:"On" desc:"how the pitch is estimated"`
+
+	// [def: 60] [viewif: On] lowest fundamental frequency in Hz -- the frames are long enough for two periods of it, see FrameSamples
+	MinHz float64 `viewif:"On" default:"60" desc:"lowest fundamental frequency in Hz -- the frames are long enough for two periods of it, see FrameSamples"`
+
+	// [def: 500] [viewif: On] highest fundamental frequency in Hz
+	MaxHz float64 `viewif:"On" default:"500" desc:"highest fundamental frequency in Hz"`
+
+	// [def: 0.85] [viewif: On] frames with a periodicity (1 minus the normalized difference for YIN, the normalized autocorrelation for ACF) below this are unvoiced, with a pitch of 0
+	MinVoicing float64 `viewif:"On" default:"0.85" desc:"frames with a periodicity (1 minus the normalized difference for YIN, the normalized autocorrelation for ACF) below this are unvoiced, with a pitch of 0"`
+
+	// [def: 0.001] [viewif: On] frames with an rms below this are silent, unvoiced with a periodicity of 0
+	SilenceRms float64 `viewif:"On" default:"0.001" desc:"frames with an rms below this are silent, unvoiced with a periodicity of 0"`
+}
+
+// Defaults
+func (pp *Params) Defaults() {
+	pp.Method = MethodYIN
+	pp.MinHz = 60
+	pp.MaxHz = 500
+	pp.MinVoicing = 0.85
+	pp.SilenceRms = 0.001
+}
+
+// Lags returns the shortest and longest periods in samples searched, for MaxHz and MinHz
+func (pp *Params) Lags(sampleRate int) (lo, hi int) {
+	lo = int(math.Floor(float64(sampleRate) / pp.MaxHz))
+	hi = int(math.Ceil(float64(sampleRate) / pp.MinHz))
+	if lo < 2 {
+		lo = 2
+	}
+	return lo, hi
+}
+
+// FrameSamples returns the number of samples of the frames passed to Estimate, two of the longest periods
+func (pp *Params) FrameSamples(sampleRate int) int {
+	_, hi := pp.Lags(sampleRate)
+	return 2 * (hi + 1)
+}
+
+// Estimate returns the fundamental frequency in Hz of the frame, 0 if it is unvoiced, and its periodicity, 0..1 --
+// the frame should have at least FrameSamples samples, the first half of them are compared with the frame shifted
+// by each period
+func (pp *Params) Estimate(frame []float64, sampleRate int) (hz, voicing float64) {
+	lo, hi := pp.Lags(sampleRate)
+	if hi > len(frame)/2 {
+		hi = len(frame) / 2
+	}
+	if hi <= lo {
+		return 0, 0
+	}
+	e := 0.0
+	for _, v := range frame {
+		e += v * v
+	}
+	if math.Sqrt(e/float64(len(frame))) < pp.SilenceRms {
+		return 0, 0
+	}
+	var lag float64
+	switch pp.Method {
+	case MethodACF:
+		lag, voicing = pp.acf(frame, lo, hi)
+	default:
+		lag, voicing = pp.yin(frame, lo, hi)
+	}
+	if lag <= 0 || voicing < pp.MinVoicing {
+		return 0, voicing
+	}
+	return float64(sampleRate) / lag, voicing
+}
+
+// yin returns the period in samples, interpolated, and the periodicity by the YIN algorithm
+func (pp *Params) yin(frame []float64, lo, hi int) (lag, voicing float64) {
+	w := len(frame) - hi - 1 // so the lags up to hi+1 are compared over the same samples
+	d := make([]float64, hi+2)
+	for tau := 1; tau <= hi+1 && tau+w <= len(frame); tau++ {
+		s := 0.0
+		for j := 0; j < w; j++ {
+			df := frame[j] - frame[j+tau]
+			s += df * df
+		}
+		d[tau] = s
+	}
+	cmnd := make([]float64, len(d)) // cumulative mean normalized difference
+	cmnd[0] = 1
+	sum := 0.0
+	for tau := 1; tau < len(d); tau++ {
+		sum += d[tau]
+		if sum == 0 {
+			cmnd[tau] = 1
+		} else {
+			cmnd[tau] = d[tau] * float64(tau) / sum
+		}
+	}
+	thr := 1 - pp.MinVoicing
+	best := -1
+	for tau := lo; tau <= hi; tau++ {
+		if cmnd[tau] < thr {
+			for tau+1 <= hi && cmnd[tau+1] < cmnd[tau] { // down to the bottom of the dip
+				tau++
+			}
+			best = tau
+			break
+		}
+	}
+	if best < 0 { // no dip below the threshold, the lowest one for the periodicity
+		best = lo
+		for tau := lo + 1; tau <= hi; tau++ {
+			if cmnd[tau] < cmnd[best] {
+				best = tau
+			}
+		}
+	}
+	voicing = 1 - cmnd[best]
+	if voicing < 0 {
+		voicing = 0
+	}
+	return interp(cmnd, best), voicing
+}
+
+// acf returns the period in samples, interpolated, and the periodicity by the normalized autocorrelation
+func (pp *Params) acf(frame []float64, lo, hi int) (lag, voicing float64) {
+	w := len(frame) - hi - 1 // so the lags up to hi+1 are compared over the same samples
+	r := make([]float64, hi+2)
+	e0 := 0.0
+	for j := 0; j < w; j++ {
+		e0 += frame[j] * frame[j]
+	}
+	for tau := lo - 1; tau <= hi+1 && tau+w <= len(frame); tau++ {
+		s, et := 0.0, 0.0
+		for j := 0; j < w; j++ {
+			s += frame[j] * frame[j+tau]
+			et += frame[j+tau] * frame[j+tau]
+		}
+		if e0 > 0 && et > 0 {
+			r[tau] = s / math.Sqrt(e0*et)
+		}
+	}
+	best := lo
+	for tau := lo + 1; tau <= hi; tau++ {
+		if r[tau] > r[best] {
+			best = tau
+		}
+	}
+	for tau := lo + 1; tau < best; tau++ { // the shortest period of a peak nearly as high, not a multiple of it
+		if r[tau] >= r[tau-1] && r[tau] >= r[tau+1] && r[tau] >= 0.95*r[best] {
+			best = tau
+			break
+		}
+	}
+	neg := make([]float64, len(r)) // interp finds minima
+	for i, v := range r {
+		neg[i] = -v
+	}
+	voicing = r[best]
+	if voicing < 0 {
+		voicing = 0
+	}
+	return interp(neg, best), voicing
+}
+
+// interp returns the position of the minimum of the parabola through the values at i-1, i and i+1
+func interp(vals []float64, i int) float64 {
+	if i <= 0 || i+1 >= len(vals) {
+		return float64(i)
+	}
+	a, b, c := vals[i-1], vals[i], vals[i+1]
+	den := a - 2*b + c
+	if den == 0 {
+		return float64(i)
+	}
+	off := 0.5 * (a - c) / den
+	if off < -1 || off > 1 {
+		return float64(i)
+	}
+	return float64(i) + off
+}
+
+// Track returns the fundamental frequency in Hz (0 if unvoiced) and periodicity of each frame of the signal, frame i
+// centered at i * stepMs milliseconds -- e.g., for comparing the pitch of synthesized speech with its intended contour
+func Track(signal []float64, sampleRate int, stepMs float64, pp *Params) (hz, voicing []float64) {
+	step := int(math.Round(stepMs * float64(sampleRate) / 1000))
+	if step <= 0 {
+		return nil, nil
+	}
+	n := pp.FrameSamples(sampleRate)
+	nfr := len(signal)/step + 1
+	hz = make([]float64, nfr)
+	voicing = make([]float64, nfr)
+	for i := range hz {
+		hz[i], voicing[i] = pp.Estimate(Frame(signal, i*step, n), sampleRate)
+	}
+	return hz, voicing
+}
+
+// Frame returns the n samples of the signal centered at ctr, with zeros outside the signal
+func Frame(signal []float64, ctr, n int) []float64 {
+	frame := make([]float64, n)
+	st := ctr - n/2
+	for i := range frame {
+		if t := st + i; t >= 0 && t < len(signal) {
+			frame[i] = signal[t]
+		}
+	}
+	return frame
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+
+	"github.com/emer/etable/etensor"
+)
+
+// Affine is an affine transform of feature frames, y = A x + B, e.g., a speaker adaptation transform (as in fMLLR)
+// of the mel output. A is d x d, rows first, and B has d values, for frames of d features
+type Affine struct {
+
+	// the matrix, one slice per row
+	A [][]float64 `desc:"the matrix, one slice per row"`
+
+	// the offset
+	B []float64 `desc:"the offset"`
+}
+
+// NewAffine returns the identity transform of frames of d features
+func NewAffine(d int) *Affine {
+	af := &Affine{A: make([][]float64, d), B: make([]float64, d)}
+	for i := range af.A {
+		af.A[i] = make([]float64, d)
+		af.A[i][i] = 1
+	}
+	return af
+}
+
+// Dim returns the number of features of the frames
+func (af *Affine) Dim() int {
+	return len(af.B)
+}
+
+// Apply transforms each frame, each column, of tsr [features, steps] in place
+func (af *Affine) Apply(tsr *etensor.Float64) error {
+	d, steps := tsr.Dim(0), tsr.Dim(1)
+	if d != af.Dim() || len(af.A) != d {
+		err := fmt.Errorf("sound.Affine: transform of %v features applied to frames of %v features", af.Dim(), d)
+		log.Println(err)
+		return err
+	}
+	x := make([]float64, d)
+	for s := 0; s < steps; s++ {
+		for i := range x {
+			x[i] = tsr.Values[i*steps+s]
+		}
+		for i := 0; i < d; i++ {
+			y := af.B[i]
+			for j, a := range af.A[i] {
+				y += a * x[j]
+			}
+			tsr.Values[i*steps+s] = y
+		}
+	}
+	return nil
+}
+
+// DiagAffine returns the diagonal transform that maps features with the statistics of src to the statistics of dst,
+// both collected with ByCol over the same features, e.g., src from the frames of one speaker and dst from all
+// speakers -- per feature mean and variance normalization toward the population, a diagonal approximation of fMLLR
+func DiagAffine(src, dst *RunStats) (*Affine, error) {
+	d := len(src.Mean)
+	if len(dst.Mean) != d {
+		err := fmt.Errorf("sound.DiagAffine: statistics of %v and %v features", d, len(dst.Mean))
+		log.Println(err)
+		return nil, err
+	}
+	af := NewAffine(d)
+	for i := 0; i < d; i++ {
+		a := math.Sqrt((dst.Var(i) + dst.Eps) / (src.Var(i) + src.Eps))
+		af.A[i][i] = a
+		af.B[i] = dst.Mean[i] - a*src.Mean[i]
+	}
+	return af, nil
+}
+
+// SpeakerAffines are affine transforms by speaker, see speech.Sequence.Speaker
+type SpeakerAffines map[string]*Affine
+
+// OpenJSON opens the transforms from a JSON-formatted file
+func (sa *SpeakerAffines) OpenJSON(filename string) error {
+	*sa = SpeakerAffines{} // reset
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return json.Unmarshal(b, sa)
+}
+
+// SaveJSON saves the transforms to a JSON-formatted file
+func (sa *SpeakerAffines) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(sa, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
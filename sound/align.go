@@ -0,0 +1,137 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/cmplx"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/auditory/speech"
+	"github.com/go-audio/wav"
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// WaveDurMs returns the duration in milliseconds of the wav file, from its header, without decoding the samples --
+// files of other formats (see RegisterDecoder) are decoded
+func WaveDurMs(fn string) (float64, error) {
+	if ext := strings.ToLower(filepath.Ext(fn)); ext != ".wav" && ext != ".wave" {
+		if _, ok := DecoderFor(fn); ok {
+			var snd Wave
+			if err := snd.Load(fn); err != nil {
+				return 0, err
+			}
+			return SamplesToMSec(snd.Buf.NumFrames(), snd.SampleRate()), nil
+		}
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		log.Printf("sound.WaveDurMs: couldn't open %s %v", fn, err)
+		return 0, err
+	}
+	defer f.Close()
+	d := wav.NewDecoder(f)
+	if err := d.FwdToPCM(); err != nil {
+		log.Printf("sound.WaveDurMs: %s %v", fn, err)
+		return 0, err
+	}
+	frameBytes := int64(d.NumChans) * int64(d.BitDepth/8)
+	if frameBytes <= 0 || d.SampleRate == 0 {
+		err := fmt.Errorf("sound.WaveDurMs: %s has no valid format", fn)
+		log.Println(err)
+		return 0, err
+	}
+	return SamplesToMSec(int(d.PCMLen()/frameBytes), int(d.SampleRate)), nil
+}
+
+// CheckAlignment checks the unit times of each sequence against the duration of its sound file and each other,
+// see speech.Sequence.CheckAlignment, and returns the issues of all the files. A sound file that can't be read
+// is reported as a "no audio" issue and its units are only checked against each other
+func CheckAlignment(seqs speech.Sequences, tolMs float64) []speech.AlignIssue {
+	var report []speech.AlignIssue
+	for i := range seqs {
+		seq := &seqs[i]
+		durMs, err := WaveDurMs(seq.File)
+		if err != nil {
+			report = append(report, speech.AlignIssue{File: seq.File, Idx: -1, Kind: "no audio"})
+		}
+		report = append(report, seq.CheckAlignment(durMs, tolMs)...)
+	}
+	return report
+}
+
+// Lag returns the time offset in samples of b relative to a, estimated by their cross-correlation computed with the fft:
+// b[t + lag] best matches a[t], so a positive lag means b is delayed -- e.g., between two channels of a recording to
+// resync, or synthesized speech and its reference. corr is the correlation at the lag normalized by the energies of
+// a and b, 1 for b an exact delayed copy of a. See LagWithin to limit the lags searched
+func Lag(a, b []float64) (lag int, corr float64) {
+	return LagWithin(a, b, 0)
+}
+
+// LagWithin is Lag searching only the lags from -maxLag to maxLag samples, all the lags if maxLag is 0
+func LagWithin(a, b []float64, maxLag int) (lag int, corr float64) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, 0
+	}
+	n := 1
+	for n < len(a)+len(b)-1 {
+		n *= 2
+	}
+	pa := make([]float64, n)
+	pb := make([]float64, n)
+	copy(pa, a)
+	copy(pb, b)
+	fft := fourier.NewFFT(n)
+	ca := fft.Coefficients(nil, pa)
+	cb := fft.Coefficients(nil, pb)
+	for k := range cb {
+		cb[k] *= cmplx.Conj(ca[k])
+	}
+	xc := fft.Sequence(nil, cb) // xc[k] = sum over t of b[t + k] a[t], negative k wrapped to n + k
+	lo, hi := -(len(a) - 1), len(b)-1
+	if maxLag > 0 {
+		if lo < -maxLag {
+			lo = -maxLag
+		}
+		if hi > maxLag {
+			hi = maxLag
+		}
+	}
+	best := math.Inf(-1)
+	for k := lo; k <= hi; k++ {
+		v := xc[(k+n)%n]
+		if v > best {
+			best = v
+			lag = k
+		}
+	}
+	ea, eb := 0.0, 0.0
+	for _, v := range a {
+		ea += v * v
+	}
+	for _, v := range b {
+		eb += v * v
+	}
+	if ea == 0 || eb == 0 {
+		return 0, 0
+	}
+	return lag, best / float64(n) / math.Sqrt(ea*eb)
+}
+
+// Resync returns b shifted earlier by lag samples (later for a negative lag), padded with zeros to its length, so it
+// lines up with the signal it was offset from, see Lag
+func Resync(b []float64, lag int) []float64 {
+	out := make([]float64, len(b))
+	for t := range out {
+		if s := t + lag; s >= 0 && s < len(b) {
+			out[t] = b[s]
+		}
+	}
+	return out
+}
@@ -0,0 +1,357 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package augment does on-the-fly data augmentation of the signal of a sound.SndEnv before Init: mixing in noise, from
+noise files or white or pink noise, at a target signal to noise ratio, reverberation by convolution with a room impulse
+response, time stretching and pitch shifting (see sound.TimeStretch and sound.PitchShift), and random gain. Set SeqEnv.Augment to the Augment method of a Params to augment each sound file as it is
+loaded, each time differently
+*/
+package augment
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+
+	"github.com/emer/auditory/sound"
+	"github.com/emer/etable/etensor"
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// MixAtSNR returns the signal with the noise added at a signal to noise ratio of snrDb dB, by their rms -- the noise
+// is looped if it is shorter than the signal and read from offset. A silent signal or noise is returned as is
+func MixAtSNR(signal, noise []float64, snrDb float64, offset int) []float64 {
+	out := append([]float64{}, signal...)
+	if len(noise) == 0 {
+		return out
+	}
+	nseg := make([]float64, len(signal))
+	for i := range nseg {
+		nseg[i] = noise[(offset+i)%len(noise)]
+	}
+	rs, rn := sound.RMS(signal), sound.RMS(nseg)
+	if rs == 0 || rn == 0 {
+		return out
+	}
+	g := rs / rn / math.Pow(10, snrDb/20)
+	for i, v := range nseg {
+		out[i] += g * v
+	}
+	return out
+}
+
+// WhiteNoise returns n samples of gaussian white noise with an rms of 1
+func WhiteNoise(n int, rnd *rand.Rand) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = rnd.NormFloat64()
+	}
+	return out
+}
+
+// PinkNoise returns n samples of pink (1/f) noise with an rms of 1, white noise filtered by the -3 dB per octave
+// filter of Paul Kellet
+func PinkNoise(n int, rnd *rand.Rand) []float64 {
+	out := make([]float64, n)
+	var b0, b1, b2, b3, b4, b5, b6 float64
+	for i := range out {
+		w := rnd.NormFloat64()
+		b0 = 0.99886*b0 + w*0.0555179
+		b1 = 0.99332*b1 + w*0.0750759
+		b2 = 0.96900*b2 + w*0.1538520
+		b3 = 0.86650*b3 + w*0.3104856
+		b4 = 0.55000*b4 + w*0.5329522
+		b5 = -0.7616*b5 - w*0.0168980
+		out[i] = b0 + b1 + b2 + b3 + b4 + b5 + b6 + w*0.5362
+		b6 = w * 0.115926
+	}
+	if r := sound.RMS(out); r > 0 {
+		for i := range out {
+			out[i] /= r
+		}
+	}
+	return out
+}
+
+// SyntheticRIR returns a room impulse response of an exponentially decaying gaussian noise tail, falling 60 dB in
+// rt60Ms milliseconds, after the direct path of 1 at the start -- lasting rt60Ms, so long enough for the audible tail
+func SyntheticRIR(rt60Ms float64, sampleRate int, rnd *rand.Rand) []float64 {
+	n := sound.MSecToSamples(rt60Ms, sampleRate)
+	if n < 1 {
+		return []float64{1}
+	}
+	rir := make([]float64, n)
+	decay := math.Log(1000) / float64(n) // amplitude falls by 1000, 60 dB, over n samples
+	for i := range rir {
+		rir[i] = 0.1 * rnd.NormFloat64() * math.Exp(-decay*float64(i))
+	}
+	rir[0] = 1
+	return rir
+}
+
+// Reverb returns the signal convolved with the room impulse response, computed with the fft, mixed with the dry
+// signal by wet (1 for all reverberant) and scaled to the rms of the signal -- the same length as the signal, the
+// tail past its end is cut off
+func Reverb(signal, rir []float64, wet float64) []float64 {
+	if len(signal) == 0 || len(rir) == 0 {
+		return append([]float64{}, signal...)
+	}
+	n := 1
+	for n < len(signal)+len(rir)-1 {
+		n *= 2
+	}
+	ps := make([]float64, n)
+	pr := make([]float64, n)
+	copy(ps, signal)
+	copy(pr, rir)
+	fft := fourier.NewFFT(n)
+	cs := fft.Coefficients(nil, ps)
+	cr := fft.Coefficients(nil, pr)
+	for k := range cs {
+		cs[k] *= cr[k]
+	}
+	conv := fft.Sequence(nil, cs)
+	out := make([]float64, len(signal))
+	for i := range out {
+		out[i] = wet*conv[i]/float64(n) + (1-wet)*signal[i]
+	}
+	if ro, rs := sound.RMS(out), sound.RMS(signal); ro > 0 {
+		for i := range out {
+			out[i] *= rs / ro
+		}
+	}
+	return out
+}
+
+// Gain returns the signal scaled by db dB
+func Gain(signal []float64, db float64) []float64 {
+	g := math.Pow(10, db/20)
+	out := make([]float64, len(signal))
+	for i, v := range signal {
+		out[i] = g * v
+	}
+	return out
+}
+
+// NoiseTypes are the kinds of noise mixed in by Params
+type NoiseTypes int32
+
+const (
+	// NoiseFiles mixes in a random stretch of a random one of the NoiseFiles
+	NoiseFiles NoiseTypes = iota
+
+	// NoiseWhite mixes in white noise
+	NoiseWhite
+
+	// NoisePink mixes in pink noise
+	NoisePink
+)
+
+// Params are the params of the random augmentation of signals, see Apply and Augment
+type Params struct {
+
+	// [def: 0.5] probability of mixing in noise
+	NoiseProb float64 `default:"0.5" desc:"probability of mixing in noise"`
+
+	// [viewif: NoiseProb>0] the kind of noise mixed in
+	Noise NoiseTypes `viewif:"NoiseProb>0" desc:"the kind of noise mixed in"`
+
+	// [viewif: Noise=NoiseFiles] the noise sound files, mixed down to mono and resampled to the rate of the signal as needed -- each is loaded once
+	NoiseFiles []string `viewif:"Noise=NoiseFiles" desc:"the noise sound files, mixed down to mono and resampled to the rate of the signal as needed -- each is loaded once"`
+
+	// [def: 0] [viewif: NoiseProb>0] lowest signal to noise ratio in dB, drawn uniformly between SNRLoDb and SNRHiDb
+	SNRLoDb float64 `viewif:"NoiseProb>0" default:"0" desc:"lowest signal to noise ratio in dB, drawn uniformly between SNRLoDb and SNRHiDb"`
+
+	// [def: 20] [viewif: NoiseProb>0] highest signal to noise ratio in dB
+	SNRHiDb float64 `viewif:"NoiseProb>0" default:"20" desc:"highest signal to noise ratio in dB"`
+
+	// [def: 0.3] probability of adding reverberation, by a SyntheticRIR
+	ReverbProb float64 `default:"0.3" desc:"probability of adding reverberation, by a SyntheticRIR"`
+
+	// [def: 200] [viewif: ReverbProb>0] shortest reverberation time (RT60) in milliseconds, drawn uniformly between RT60LoMs and RT60HiMs
+	RT60LoMs float64 `viewif:"ReverbProb>0" default:"200" desc:"shortest reverberation time (RT60) in milliseconds, drawn uniformly between RT60LoMs and RT60HiMs"`
+
+	// [def: 800] [viewif: ReverbProb>0] longest reverberation time (RT60) in milliseconds
+	RT60HiMs float64 `viewif:"ReverbProb>0" default:"800" desc:"longest reverberation time (RT60) in milliseconds"`
+
+	// [def: 1] [viewif: ReverbProb>0] proportion of the reverberant signal mixed with the dry signal, see Reverb
+	Wet float64 `viewif:"ReverbProb>0" default:"1" desc:"proportion of the reverberant signal mixed with the dry signal, see Reverb"`
+
+	// [def: 0] probability of time stretching the signal, see sound.TimeStretch -- this changes the times of the units of speech sequences, so it is off by default and only suits signals whose labels don't depend on time
+	StretchProb float64 `default:"0" desc:"probability of time stretching the signal, see sound.TimeStretch -- this changes the times of the units of speech sequences, so it is off by default and only suits signals whose labels don't depend on time"`
+
+	// [def: 0.9] [viewif: StretchProb>0] lowest stretch factor, drawn uniformly between StretchLo and StretchHi -- below 1 is faster
+	StretchLo float64 `viewif:"StretchProb>0" default:"0.9" desc:"lowest stretch factor, drawn uniformly between StretchLo and StretchHi -- below 1 is faster"`
+
+	// [def: 1.1] [viewif: StretchProb>0] highest stretch factor -- above 1 is slower
+	StretchHi float64 `viewif:"StretchProb>0" default:"1.1" desc:"highest stretch factor -- above 1 is slower"`
+
+	// [def: 0.3] probability of shifting the pitch of the signal, keeping its duration, see sound.PitchShift
+	PitchProb float64 `default:"0.3" desc:"probability of shifting the pitch of the signal, keeping its duration, see sound.PitchShift"`
+
+	// [def: 0.9] [viewif: PitchProb>0] lowest pitch factor, drawn uniformly between PitchLo and PitchHi -- below 1 is lower
+	PitchLo float64 `viewif:"PitchProb>0" default:"0.9" desc:"lowest pitch factor, drawn uniformly between PitchLo and PitchHi -- below 1 is lower"`
+
+	// [def: 1.1] [viewif: PitchProb>0] highest pitch factor -- above 1 is higher
+	PitchHi float64 `viewif:"PitchProb>0" default:"1.1" desc:"highest pitch factor -- above 1 is higher"`
+
+	// [def: -6] lowest random gain in dB, drawn uniformly between GainLoDb and GainHiDb -- both 0 for none
+	GainLoDb float64 `default:"-6" desc:"lowest random gain in dB, drawn uniformly between GainLoDb and GainHiDb -- both 0 for none"`
+
+	// [def: 6] highest random gain in dB
+	GainHiDb float64 `default:"6" desc:"highest random gain in dB"`
+
+	// [view: -] random number source -- if nil, Augment derives one from the Seed of the SndEnv (see sound.SndEnv.NewRand)
+	Rand *rand.Rand `view:"-" desc:"random number source -- if nil, Augment derives one from the Seed of the SndEnv (see sound.SndEnv.NewRand)"`
+
+	noises map[string][]float64 // the loaded noise files, by file and sample rate
+}
+
+// Defaults
+func (ap *Params) Defaults() {
+	ap.NoiseProb = 0.5
+	ap.Noise = NoisePink
+	ap.SNRLoDb = 0
+	ap.SNRHiDb = 20
+	ap.ReverbProb = 0.3
+	ap.RT60LoMs = 200
+	ap.RT60HiMs = 800
+	ap.Wet = 1
+	ap.StretchProb = 0
+	ap.StretchLo = 0.9
+	ap.StretchHi = 1.1
+	ap.PitchProb = 0.3
+	ap.PitchLo = 0.9
+	ap.PitchHi = 1.1
+	ap.GainLoDb = -6
+	ap.GainHiDb = 6
+}
+
+// uniform returns a random number between lo and hi
+func uniform(rnd *rand.Rand, lo, hi float64) float64 {
+	return lo + rnd.Float64()*(hi-lo)
+}
+
+// noise returns the samples of the noise file at the sample rate, loading it the first time
+func (ap *Params) noise(fn string, sampleRate int) ([]float64, error) {
+	key := fmt.Sprintf("%s@%d", fn, sampleRate)
+	if ns, ok := ap.noises[key]; ok {
+		return ns, nil
+	}
+	var snd sound.Wave
+	if err := snd.Load(fn); err != nil {
+		return nil, err
+	}
+	var sig etensor.Float64
+	if !snd.SoundToTensor(&sig) {
+		err := fmt.Errorf("augment.Params: couldn't get the samples of noise file %v", fn)
+		log.Println(err)
+		return nil, err
+	}
+	ns := sig.Values
+	if sr := snd.SampleRate(); sr != sampleRate {
+		ns = sound.ResampleSlice(ns, sr, sampleRate)
+	}
+	if ap.noises == nil {
+		ap.noises = map[string][]float64{}
+	}
+	ap.noises[key] = ns
+	return ns, nil
+}
+
+// Apply randomly augments the signal [samples], or each channel of [channels, samples], in place, drawing from rnd:
+// time stretching with probability StretchProb, which reshapes sig to the new length, pitch shifting with probability
+// PitchProb, reverberation with probability ReverbProb, then noise with probability NoiseProb, then the random gain.
+// The channels get the same augmentation, the same room and the same stretch of noise
+func (ap *Params) Apply(sig *etensor.Float64, sampleRate int, rnd *rand.Rand) error {
+	nch, n := 1, sig.Len()
+	if sig.NumDims() > 1 {
+		nch, n = sig.Dim(0), sig.Dim(1)
+	}
+	stretch, shift := 1.0, 1.0
+	if ap.StretchProb > 0 && rnd.Float64() < ap.StretchProb {
+		stretch = uniform(rnd, ap.StretchLo, ap.StretchHi)
+	}
+	if ap.PitchProb > 0 && rnd.Float64() < ap.PitchProb {
+		shift = uniform(rnd, ap.PitchLo, ap.PitchHi)
+	}
+	if stretch != 1 {
+		chans := make([][]float64, nch)
+		for ch := range chans {
+			chans[ch] = sound.TimeStretch(sig.Values[ch*n:(ch+1)*n], stretch, sampleRate)
+		}
+		n = len(chans[0])
+		if sig.NumDims() > 1 {
+			sig.SetShape([]int{nch, n}, nil, sig.DimNames())
+		} else {
+			sig.SetShape([]int{n}, nil, sig.DimNames())
+		}
+		for ch, st := range chans {
+			copy(sig.Values[ch*n:(ch+1)*n], st)
+		}
+	}
+	var rir []float64
+	if ap.ReverbProb > 0 && rnd.Float64() < ap.ReverbProb {
+		rir = SyntheticRIR(uniform(rnd, ap.RT60LoMs, ap.RT60HiMs), sampleRate, rnd)
+	}
+	var noise []float64
+	snr, offset := 0.0, 0
+	if ap.NoiseProb > 0 && rnd.Float64() < ap.NoiseProb {
+		snr = uniform(rnd, ap.SNRLoDb, ap.SNRHiDb)
+		switch ap.Noise {
+		case NoiseWhite:
+			noise = WhiteNoise(n, rnd)
+		case NoisePink:
+			noise = PinkNoise(n, rnd)
+		default:
+			if len(ap.NoiseFiles) == 0 {
+				err := errors.New("augment.Params: no NoiseFiles to mix in")
+				log.Println(err)
+				return err
+			}
+			ns, err := ap.noise(ap.NoiseFiles[rnd.Intn(len(ap.NoiseFiles))], sampleRate)
+			if err != nil {
+				return err
+			}
+			noise = ns
+			if len(ns) > 0 {
+				offset = rnd.Intn(len(ns))
+			}
+		}
+	}
+	gain := 0.0
+	if ap.GainLoDb != 0 || ap.GainHiDb != 0 {
+		gain = uniform(rnd, ap.GainLoDb, ap.GainHiDb)
+	}
+	for ch := 0; ch < nch; ch++ {
+		out := sig.Values[ch*n : (ch+1)*n]
+		aug := out
+		if shift != 1 {
+			aug = sound.PitchShift(aug, shift, sampleRate)
+		}
+		if rir != nil {
+			aug = Reverb(aug, rir, ap.Wet)
+		}
+		if noise != nil {
+			aug = MixAtSNR(aug, noise, snr, offset)
+		}
+		if gain != 0 {
+			aug = Gain(aug, gain)
+		}
+		copy(out, aug)
+	}
+	return nil
+}
+
+// Augment randomly augments the Signal of the SndEnv, see Apply, with Rand or a source derived from the Seed of se
+// the first time -- set SeqEnv.Augment to it, to augment each sound file as it is loaded, before Init
+func (ap *Params) Augment(se *sound.SndEnv) error {
+	if ap.Rand == nil {
+		ap.Rand = se.NewRand("augment")
+	}
+	return ap.Apply(&se.Signal, se.Sound.SampleRate(), ap.Rand)
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/emer/auditory/speech"
+	"github.com/emer/etable/etensor"
+)
+
+// AutoSegParams are the parameters of the automatic segmentation of unlabeled sound at dips in its energy, see AutoSegment
+type AutoSegParams struct {
+
+	// [def: 10] duration in milliseconds of the frames whose energy is computed
+	FrameMs float64 `default:"10" desc:"duration in milliseconds of the frames whose energy is computed"`
+
+	// [def: 3] number of frames of the moving average smoothing the energy, 1 for none
+	SmoothFrames int `default:"3" desc:"number of frames of the moving average smoothing the energy, 1 for none"`
+
+	// [def: 35] frames more than this many dB below the loudest frame are silence
+	SilenceDb float64 `default:"35" desc:"frames more than this many dB below the loudest frame are silence"`
+
+	// [def: 60] silences shorter than this many milliseconds within sound are ignored, e.g., stop closures
+	MinSilenceMs float64 `default:"60" desc:"silences shorter than this many milliseconds within sound are ignored, e.g., stop closures"`
+
+	// [def: 6] a sound is split at an energy dip at least this many dB below the lower of the peaks on either side
+	DipDb float64 `default:"6" desc:"a sound is split at an energy dip at least this many dB below the lower of the peaks on either side"`
+
+	// [def: 40] minimum duration in milliseconds of a unit -- dips closer than this to the ends of a sound aren't split at, and shorter sounds are silence
+	MinUnitMs float64 `default:"40" desc:"minimum duration in milliseconds of a unit -- dips closer than this to the ends of a sound aren't split at, and shorter sounds are silence"`
+}
+
+// Defaults sets the default params
+func (as *AutoSegParams) Defaults() {
+	as.FrameMs = 10
+	as.SmoothFrames = 3
+	as.SilenceDb = 35
+	as.MinSilenceMs = 60
+	as.DipDb = 6
+	as.MinUnitMs = 40
+}
+
+// FrameEnergy returns the energy in dB of each frame of the signal, smoothed by SmoothFrames
+func (as *AutoSegParams) FrameEnergy(signal []float64, sampleRate int) []float64 {
+	n := MSecToSamples(as.FrameMs, sampleRate)
+	if n <= 0 {
+		return nil
+	}
+	nf := len(signal) / n
+	raw := make([]float64, nf)
+	for f := range raw {
+		e := 0.0
+		for _, v := range signal[f*n : (f+1)*n] {
+			e += v * v
+		}
+		raw[f] = 10 * math.Log10(e/float64(n)+1e-12)
+	}
+	half := as.SmoothFrames / 2
+	db := make([]float64, nf)
+	for f := range db {
+		sum, cnt := 0.0, 0
+		for g := f - half; g <= f+half; g++ {
+			if g >= 0 && g < nf {
+				sum += raw[g]
+				cnt++
+			}
+		}
+		db[f] = sum / float64(cnt)
+	}
+	return db
+}
+
+// AutoSegment splits the signal into pseudo-units at dips in its energy: frames more than SilenceDb below the loudest are
+// silence, ignoring short silences, and each stretch of sound is split in two at its deepest dip that is at least DipDb
+// below the peaks on both sides, recursively. The units cover the whole signal: the sounds are named seg1, seg2 ... and the
+// silences sil, with Silence set, all of Type "auto", times in milliseconds -- for browsing and processing unlabeled
+// recordings unit by unit
+func (as *AutoSegParams) AutoSegment(signal []float64, sampleRate int) []speech.Unit {
+	db := as.FrameEnergy(signal, sampleRate)
+	if len(db) == 0 {
+		return nil
+	}
+	mx := math.Inf(-1)
+	for _, e := range db {
+		mx = math.Max(mx, e)
+	}
+	minSil := int(math.Ceil(as.MinSilenceMs / as.FrameMs))
+	minUnit := int(math.Ceil(as.MinUnitMs / as.FrameMs))
+	snd := make([]bool, len(db))
+	for f, e := range db {
+		snd[f] = e >= mx-as.SilenceDb
+	}
+	fill(snd, false, minSil, true)  // short silences within sound are sound
+	fill(snd, true, minUnit, false) // short sounds are silence
+
+	var bounds [][2]int // frame ranges of the sounds
+	for st := 0; st < len(snd); {
+		ed := st
+		for ed < len(snd) && snd[ed] == snd[st] {
+			ed++
+		}
+		if snd[st] {
+			bounds = append(bounds, as.split(db, st, ed, minUnit)...)
+		}
+		st = ed
+	}
+
+	var units []speech.Unit
+	ms := func(f int) float64 { return float64(f) * as.FrameMs }
+	endMs := SamplesToMSec(len(signal), sampleRate)
+	prv := 0
+	for i, b := range bounds {
+		if b[0] > prv {
+			units = append(units, speech.Unit{Name: "sil", Start: ms(prv), End: ms(b[0]), Silence: true, Type: "auto"})
+		}
+		units = append(units, speech.Unit{Name: fmt.Sprintf("seg%d", i+1), Start: ms(b[0]), End: ms(b[1]), Type: "auto"})
+		prv = b[1]
+	}
+	if n := len(units); n > 0 && endMs-ms(prv) < as.FrameMs {
+		units[n-1].End = endMs // the samples past the last full frame
+	} else {
+		units = append(units, speech.Unit{Name: "sil", Start: ms(prv), End: endMs, Silence: true, Type: "auto"})
+	}
+	return units
+}
+
+// fill sets the runs of val shorter than minLen frames to !val -- if interior is true only the runs with other values on both sides
+func fill(frames []bool, val bool, minLen int, interior bool) {
+	for st := 0; st < len(frames); {
+		ed := st
+		for ed < len(frames) && frames[ed] == frames[st] {
+			ed++
+		}
+		inside := st > 0 && ed < len(frames)
+		if frames[st] == val && ed-st < minLen && (inside || !interior) {
+			for f := st; f < ed; f++ {
+				frames[f] = !val
+			}
+		}
+		st = ed
+	}
+}
+
+// split splits the sound of frames st up to ed at its deepest dip, recursively, returning the frame ranges of the units
+func (as *AutoSegParams) split(db []float64, st, ed, minUnit int) [][2]int {
+	best, depth := -1, as.DipDb
+	for f := st + minUnit; f <= ed-minUnit; f++ {
+		lpk, rpk := math.Inf(-1), math.Inf(-1)
+		for g := st; g < f; g++ {
+			lpk = math.Max(lpk, db[g])
+		}
+		for g := f; g < ed; g++ {
+			rpk = math.Max(rpk, db[g])
+		}
+		if d := math.Min(lpk, rpk) - db[f]; d >= depth {
+			best, depth = f, d
+		}
+	}
+	if best < 0 {
+		return [][2]int{{st, ed}}
+	}
+	return append(as.split(db, st, best, minUnit), as.split(db, best, ed, minUnit)...)
+}
+
+// AutoSegmentFile loads the sound file and returns its pseudo-units, see AutoSegment
+func (as *AutoSegParams) AutoSegmentFile(fn string) ([]speech.Unit, error) {
+	var snd Wave
+	if err := snd.Load(fn); err != nil {
+		return nil, err
+	}
+	var sig etensor.Float64
+	snd.SoundToTensor(&sig)
+	return as.AutoSegment(sig.Values, snd.SampleRate()), nil
+}
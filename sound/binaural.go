@@ -0,0 +1,202 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/emer/auditory/agabor"
+	"github.com/emer/emergent/env"
+	"github.com/emer/etable/etensor"
+)
+
+// CopyParams copies the processing params of src, the params set before Init, so the SndEnv processes a signal
+// exactly as src does -- e.g., the two ears of a BinauralEnv. The names, Sound, Signal and outputs are not copied
+func (se *SndEnv) CopyParams(src *SndEnv) {
+	se.On, se.Seed, se.Workers, se.Float32 = src.On, src.Seed, src.Workers, src.Float32
+	se.Params, se.Calib = src.Params, src.Calib
+	se.DFT = src.DFT
+	se.Whiten = src.Whiten
+	se.Bank, se.Mel, se.Gammatone = src.Bank, src.Mel, src.Gammatone
+	se.BandLo, se.BandHi = src.BandLo, src.BandHi
+	se.MelAffine, se.SpecAug = src.MelAffine, src.SpecAug
+	se.TimePool, se.Splice = src.TimePool, src.Splice
+	se.GaborSpecs = append([]agabor.Filter(nil), src.GaborSpecs...)
+	gf, sf := &se.GaborFilters, &src.GaborFilters
+	gf.SizeX, gf.SizeY, gf.StrideX, gf.StrideY = sf.SizeX, sf.SizeY, sf.StrideX, sf.StrideY
+	gf.Gain, gf.Distribute = sf.Gain, sf.Distribute
+	se.GborOutPoolsX, se.GborOutPoolsY, se.GborOutUnitsX, se.GborOutUnitsY = src.GborOutPoolsX, src.GborOutPoolsY, src.GborOutUnitsX, src.GborOutUnitsY
+	se.NeighInhib, se.Kwta, se.KwtaPool, se.ByTime = src.NeighInhib, src.Kwta, src.KwtaPool, src.ByTime
+	se.Habit, se.GaborCarry = src.Habit, src.GaborCarry
+	se.VAD, se.Pitch, se.Features, se.Envelope = src.VAD, src.Pitch, src.Features, src.Envelope
+}
+
+// BinauralEnv is a SeqEnv for binaural models that processes the left and right ear signals of each sound file with two
+// identical SndEnv pipelines: Snd for the left ear and Right for the right ear, whose params are copied from Snd
+// (see CopyParams) each time a file is loaded. Stereo files give the left ear channel 0 and the right ear channel 1,
+// mono files are spatialized by ITDUs and ILDDb. Both ears have the same number of samples and are processed segment by
+// segment with the same jitter, so the windows of the two ears are always aligned. The left ear states are the SeqEnv
+// states and the right ear states are prefixed with "Right", e.g. "Gabor" and "RightGabor"
+type BinauralEnv struct {
+	SeqEnv
+
+	// the right ear pipeline -- its params are copied from Snd when each sound file is loaded, so set the params through Snd
+	Right SndEnv `desc:"the right ear pipeline -- its params are copied from Snd when each sound file is loaded, so set the params through Snd"`
+
+	// [def: 0] interaural time difference in microseconds applied to mono files, positive delaying the right ear, see ITD
+	ITDUs float64 `default:"0" desc:"interaural time difference in microseconds applied to mono files, positive delaying the right ear, see ITD"`
+
+	// [def: 0] interaural level difference in dB applied to mono files, positive making the left ear louder, see ILD
+	ILDDb float64 `default:"0" desc:"interaural level difference in dB applied to mono files, positive making the left ear louder, see ILD"`
+
+	// [view: -] gabor output of the current right ear segment, post kwta if Kwta is on
+	RightOutput *etensor.Float32 `view:"-" desc:"gabor output of the current right ear segment, post kwta if Kwta is on"`
+}
+
+func (be *BinauralEnv) Validate() error {
+	err := be.SeqEnv.Validate()
+	if err != nil {
+		return err
+	}
+	if be.Snd.Params.ChannelMode != ChannelSingle {
+		return fmt.Errorf("sound.BinauralEnv: %v Snd.Params.ChannelMode must be ChannelSingle, the ears are the channels", be.Nm)
+	}
+	return nil
+}
+
+// LoadEars sets the left and right ear signals of the current sound file, already loaded by Snd, and initializes the
+// processing of both ears, Right with the params of Snd -- the Prefix of Snd is "Left" and of Right "Right" unless set
+func (be *BinauralEnv) LoadEars() error {
+	if be.Snd.Prefix == "" {
+		be.Snd.Prefix = "Left"
+	}
+	snd := &be.Snd.Sound
+	var left, right []float64
+	if snd.Channels() > 1 {
+		var lt, rt etensor.Float64
+		if !snd.SoundToTensorMix(&lt, be.Snd.Params.Mixdown, 0) || !snd.SoundToTensorMix(&rt, be.Snd.Params.Mixdown, 1) {
+			err := fmt.Errorf("sound.BinauralEnv: %v couldn't get the channels of the sound", be.Nm)
+			log.Println(err)
+			return err
+		}
+		left, right = lt.Values, rt.Values
+	} else {
+		var mono etensor.Float64
+		if !snd.SoundToTensorMix(&mono, be.Snd.Params.Mixdown, 0) {
+			err := fmt.Errorf("sound.BinauralEnv: %v couldn't get the signal of the sound", be.Nm)
+			log.Println(err)
+			return err
+		}
+		left, right = mono.Values, mono.Values
+		if be.ITDUs != 0 {
+			left, right = ITD(mono.Values, be.ITDUs, snd.SampleRate())
+		}
+		if be.ILDDb != 0 {
+			left, _ = ILD(left, be.ILDDb)
+			_, right = ILD(right, be.ILDDb)
+		}
+	}
+	if len(right) < len(left) { // the ears are always the same length, so their segments are the same
+		right = append(right, make([]float64, len(left)-len(right))...)
+	} else if len(left) < len(right) {
+		left = append(left, make([]float64, len(right)-len(left))...)
+	}
+	be.Snd.Signal.SetShape([]int{len(left)}, nil, nil)
+	copy(be.Snd.Signal.Values, left)
+	if err := be.Snd.InitSignal(); err != nil {
+		return err
+	}
+
+	pfx := be.Right.Prefix
+	if pfx == "" {
+		pfx = "Right"
+	}
+	be.Right.CopyParams(&be.Snd)
+	be.Right.Prefix = pfx
+	be.Right.Sound = be.Snd.Sound
+	be.Right.Signal.SetShape([]int{len(right)}, nil, nil)
+	copy(be.Right.Signal.Values, right)
+	if err := be.Right.Init(); err != nil {
+		return err
+	}
+	if be.Right.SegCnt != be.Snd.SegCnt {
+		err := fmt.Errorf("sound.BinauralEnv: %v the ears have %v and %v segments", be.Nm, be.Snd.SegCnt, be.Right.SegCnt)
+		log.Println(err)
+		return err
+	}
+	be.Trial.Max = be.Snd.SegCnt
+	return nil
+}
+
+func (be *BinauralEnv) Step() bool {
+	be.Epoch.Same() // good idea to just reset all non-inner-most counters at start
+	be.Seq.Same()
+
+	if be.Seq.Cur < 0 || be.Trial.Incr() { // first step or hit max segments for this file
+		err := be.NextSeq()
+		if err == nil {
+			err = be.LoadEars()
+		}
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+	}
+	be.Jitter = be.NewJitter()
+	be.Snd.ProcessSegment(be.Trial.Cur, be.Jitter)
+	be.Output = be.Snd.ApplyGabor()
+	be.Right.ProcessSegment(be.Trial.Cur, be.Jitter) // same segment and jitter keeps the windows of the ears aligned
+	be.RightOutput = be.Right.ApplyGabor()
+	be.SetLabel()
+	return true
+}
+
+// State returns the named state element -- see SeqEnv.State, for the left ear -- prefix the name with "Right"
+// for the states of the right ear, e.g. "RightGabor"
+func (be *BinauralEnv) State(element string) etensor.Tensor {
+	if !strings.HasPrefix(element, "Right") {
+		return be.SeqEnv.State(element)
+	}
+	switch strings.TrimPrefix(element, "Right") {
+	case "Gabor":
+		return be.RightOutput
+	case "Mel":
+		return &be.Right.MelFBankSegment
+	case "MFCC":
+		return &be.Right.MFCCSegment
+	case "MelPooled":
+		return &be.Right.MelPooled
+	case "MFCCPooled":
+		return &be.Right.MFCCPooled
+	case "MelSpliced":
+		return &be.Right.MelSpliced
+	case "MFCCSpliced":
+		return &be.Right.MFCCSpliced
+	case "Power":
+		return &be.Right.LogPowerSegment
+	}
+	return nil
+}
+
+// Compile-time check that implements Env interface
+var _ env.Env = (*BinauralEnv)(nil)
+
+// States returns the state elements of both ears -- shapes are only valid after the first sound file is loaded
+func (be *BinauralEnv) States() env.Elements {
+	els := be.SeqEnv.States()
+	els = append(els, env.Elements{
+		{"RightGabor", be.Right.GborKwta.Shapes(), nil},
+		{"RightMel", be.Right.MelFBankSegment.Shapes(), nil},
+		{"RightMFCC", be.Right.MFCCSegment.Shapes(), nil},
+		{"RightPower", be.Right.LogPowerSegment.Shapes(), nil},
+		{"RightMelPooled", be.Right.MelPooled.Shapes(), nil},
+		{"RightMFCCPooled", be.Right.MFCCPooled.Shapes(), nil},
+		{"RightMelSpliced", be.Right.MelSpliced.Shapes(), nil},
+		{"RightMFCCSpliced", be.Right.MFCCSpliced.Shapes(), nil},
+	}...)
+	return els
+}
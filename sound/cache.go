@@ -0,0 +1,113 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/emer/auditory/agabor"
+	"github.com/emer/etable/etensor"
+)
+
+// FilterCache keeps the filter bank (mel or gammatone) and gabor filters built by InitProcess, keyed by the params they
+// depend on, e.g., the window samples, sample rate and number of filters, so batch feature extraction over thousands
+// of files with the same params builds them only once. Share one between SndEnvs by setting their Cache -- it is safe
+// for concurrent use, e.g., by the workers of ProcessSegments. The FFTs are kept by the DFT params of each SndEnv,
+// across Init, as they are not safe for concurrent use
+type FilterCache struct {
+
+	// number of filters found in the cache
+	Hits int `inactive:"+" desc:"number of filters found in the cache"`
+
+	// number of filters built and added to the cache
+	Misses int `inactive:"+" desc:"number of filters built and added to the cache"`
+
+	mu     sync.Mutex
+	banks  map[string]*cachedBank
+	gabors map[string]*etensor.Float64
+}
+
+// cachedBank is a filter bank and the params InitFilters computes with it
+type cachedBank struct {
+	filters etensor.Float64
+	binPts  []int32   // mel
+	hzPts   []float64 // mel
+	ctrHz   []float64 // gammatone
+	order   int       // gammatone
+}
+
+// Reset empties the cache
+func (fc *FilterCache) Reset() {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.banks = nil
+	fc.gabors = nil
+	fc.Hits, fc.Misses = 0, 0
+}
+
+// bankKey returns the key of the filter bank of se
+func (se *SndEnv) bankKey() string {
+	sr := se.Sound.SampleRate()
+	if se.Bank == GammatoneBank {
+		gt := &se.Gammatone
+		return fmt.Sprintf("gammatone %d %d %d %g %g %d", se.Params.WinSamples, sr, gt.NFilters, gt.LoHz, gt.HiHz, gt.Order)
+	}
+	return fmt.Sprintf("mel %d %d %+v", se.Params.WinSamples, sr, se.Mel.FBank)
+}
+
+// initFilterBank sets the filter bank of se from the cache, building it and adding it the first time
+func (fc *FilterCache) initFilterBank(se *SndEnv) error {
+	key := se.bankKey()
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if cb, ok := fc.banks[key]; ok {
+		fc.Hits++
+		se.MelFilters.CopyShapeFrom(&cb.filters)
+		se.MelFilters.CopyFrom(&cb.filters)
+		if se.Bank == GammatoneBank {
+			se.Gammatone.CtrHz, se.Gammatone.Order, se.Gammatone.SampleRate = cb.ctrHz, cb.order, se.Sound.SampleRate()
+		} else {
+			se.Mel.BinPts, se.Mel.HzPts = cb.binPts, cb.hzPts
+			se.Mel.FBank.Renorm = false // as InitFilters
+		}
+		return nil
+	}
+	if err := se.buildFilterBank(); err != nil {
+		return err
+	}
+	fc.Misses++
+	cb := &cachedBank{binPts: se.Mel.BinPts, hzPts: se.Mel.HzPts, ctrHz: se.Gammatone.CtrHz, order: se.Gammatone.Order}
+	cb.filters.CopyShapeFrom(&se.MelFilters)
+	cb.filters.CopyFrom(&se.MelFilters)
+	if fc.banks == nil {
+		fc.banks = map[string]*cachedBank{}
+	}
+	fc.banks[key] = cb
+	return nil
+}
+
+// gaborFilters sets the filters of the gabor filter set of the active specs from the cache, building them and adding
+// them the first time
+func (fc *FilterCache) gaborFilters(specs []agabor.Filter, set *agabor.FilterSet) {
+	key := fmt.Sprintf("%d %d %v %+v", set.SizeX, set.SizeY, set.Distribute, specs)
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if flt, ok := fc.gabors[key]; ok {
+		fc.Hits++
+		set.Filters.CopyShapeFrom(flt)
+		set.Filters.CopyFrom(flt)
+		return
+	}
+	agabor.ToTensor(specs, set)
+	fc.Misses++
+	flt := &etensor.Float64{}
+	flt.CopyShapeFrom(&set.Filters)
+	flt.CopyFrom(&set.Filters)
+	if fc.gabors == nil {
+		fc.gabors = map[string]*etensor.Float64{}
+	}
+	fc.gabors[key] = flt
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"errors"
+	"log"
+	"math"
+)
+
+// Calibration maps the digital level of signals, relative to digital full scale, to the sound pressure level in dB SPL
+// they are played at, by a reference tone of known level -- e.g., a 1 kHz full scale sine played at 94 dB SPL, or a
+// recording of a calibrator (see FromRecording). Levels of stimuli for psychoacoustic models are then set and measured
+// in dB SPL (see SPL, ScaleToSPL and Tone) rather than in arbitrary digital units
+type Calibration struct {
+
+	// [def: 1000] frequency in Hz of the reference tone
+	RefHz float64 `default:"1000" desc:"frequency in Hz of the reference tone"`
+
+	// [def: 1] peak amplitude of the reference tone, 1 for a full scale sine
+	RefAmp float64 `default:"1" desc:"peak amplitude of the reference tone, 1 for a full scale sine"`
+
+	// [def: 94] sound pressure level in dB SPL of the reference tone when played -- 94 dB SPL is 1 pascal, the level of most calibrators
+	RefSPL float64 `default:"94" desc:"sound pressure level in dB SPL of the reference tone when played -- 94 dB SPL is 1 pascal, the level of most calibrators"`
+}
+
+// Defaults
+func (cb *Calibration) Defaults() {
+	cb.RefHz = 1000
+	cb.RefAmp = 1
+	cb.RefSPL = 94
+}
+
+// FromRecording sets RefAmp from a recording of the reference tone, e.g., of a calibrator on the microphone, whose level
+// is spl dB SPL -- the amplitude of the tone is taken from its rms, so the recording should hold only the tone
+func (cb *Calibration) FromRecording(tone []float64, spl float64) error {
+	rms := RMS(tone)
+	if rms == 0 {
+		err := errors.New("sound.Calibration: the recording of the reference tone is silent")
+		log.Println(err)
+		return err
+	}
+	cb.RefAmp = rms * math.Sqrt2
+	cb.RefSPL = spl
+	return nil
+}
+
+// FullScaleSPL returns the level in dB SPL of a signal with an rms of 1, the full scale (0 dBFS) -- the offset
+// between levels in dB relative to full scale and in dB SPL
+func (cb *Calibration) FullScaleSPL() float64 {
+	return cb.RefSPL - 20*math.Log10(cb.RefAmp/math.Sqrt2)
+}
+
+// SPL returns the level in dB SPL of the signal, from its rms -- -Inf for silence
+func (cb *Calibration) SPL(signal []float64) float64 {
+	return cb.RMSToSPL(RMS(signal))
+}
+
+// RMSToSPL returns the level in dB SPL of a signal with the rms -- -Inf for 0
+func (cb *Calibration) RMSToSPL(rms float64) float64 {
+	return cb.FullScaleSPL() + 20*math.Log10(rms)
+}
+
+// SPLToRMS returns the rms of a signal with the level in dB SPL
+func (cb *Calibration) SPLToRMS(spl float64) float64 {
+	return math.Pow(10, (spl-cb.FullScaleSPL())/20)
+}
+
+// ScaleToSPL returns the signal scaled to the level in dB SPL, or a copy of it if it is silent
+func (cb *Calibration) ScaleToSPL(signal []float64, spl float64) []float64 {
+	out := append([]float64{}, signal...)
+	rms := RMS(signal)
+	if rms == 0 {
+		return out
+	}
+	gain := cb.SPLToRMS(spl) / rms
+	for i := range out {
+		out[i] *= gain
+	}
+	return out
+}
+
+// Tone returns a sine tone of hz at the level in dB SPL, see the Tone function
+func (cb *Calibration) Tone(hz, spl, phase, durMs float64, sampleRate int) []float64 {
+	return Tone(hz, cb.SPLToRMS(spl)*math.Sqrt2, phase, durMs, sampleRate)
+}
+
+// RefTone returns the reference tone, at RefSPL dB SPL, e.g., to play through the output and adjust the volume
+// until a sound level meter reads RefSPL
+func (cb *Calibration) RefTone(durMs float64, sampleRate int) []float64 {
+	return Tone(cb.RefHz, cb.RefAmp, 0, durMs, sampleRate)
+}
+
+// RMS returns the root mean square of the signal, 0 if it is empty
+func RMS(signal []float64) float64 {
+	if len(signal) == 0 {
+		return 0
+	}
+	s := 0.0
+	for _, v := range signal {
+		s += v * v
+	}
+	return math.Sqrt(s / float64(len(signal)))
+}
+
+// SPL returns the level in dB SPL of the Signal, all channels, by Calib
+func (se *SndEnv) SPL() float64 {
+	return se.Calib.SPL(se.Signal.Values)
+}
+
+// SetSPL scales the Signal, all channels, to the level in dB SPL by Calib -- call before Init, or InitSignal if
+// the signal was already initialized
+func (se *SndEnv) SetSPL(spl float64) {
+	copy(se.Signal.Values, se.Calib.ScaleToSPL(se.Signal.Values, spl))
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+)
+
+// CarrySteps returns the mel steps of MelCarry, the input of the gabor filters if GaborCarry is set: lead steps before
+// the start of the segment's stride, the largest multiple of the gabor StrideX less than the filter SizeX, and width
+// steps in all, so the filters are applied at StrideMs / StepMs / StrideX positions, the first lead steps before the
+// stride start -- for a stride of 10 steps and 6 x 6 filters with a stride of 2 the lead is 4, the width 14 and there
+// are 5 positions
+func (se *SndEnv) CarrySteps() (lead, width int) {
+	sx, stx := se.GaborFilters.SizeX, se.GaborFilters.StrideX
+	if stx <= 0 || se.Params.StepMs <= 0 {
+		return 0, sx
+	}
+	spS := int(math.Round(se.Params.StrideMs / se.Params.StepMs))
+	lead = (sx - 1) / stx * stx
+	n := spS / stx
+	return lead, (n-1)*stx + sx
+}
+
+// checkCarry checks that the params allow the gabor filters to be carried across segments, see GaborCarry
+func (se *SndEnv) checkCarry() error {
+	prm := &se.Params
+	stx := se.GaborFilters.StrideX
+	var err error
+	switch {
+	case prm.ChannelMode == ChannelAll:
+		err = errors.New("sound.SndEnv: GaborCarry is not supported with ChannelAll")
+	case stx <= 0 || prm.StepSamples <= 0 || prm.StrideSamples%prm.StepSamples != 0:
+		err = fmt.Errorf("sound.SndEnv: GaborCarry needs StrideMs %v to be a multiple of StepMs %v", prm.StrideMs, prm.StepMs)
+	case (prm.StrideSamples/prm.StepSamples)%stx != 0:
+		err = fmt.Errorf("sound.SndEnv: GaborCarry needs the %v steps of a stride to be a multiple of the gabor StrideX %v", prm.StrideSamples/prm.StepSamples, stx)
+	case prm.SegmentSteps-prm.StepsBack() < prm.StrideSamples/prm.StepSamples:
+		err = fmt.Errorf("sound.SndEnv: GaborCarry needs the segment to cover its stride, SegmentMs %v is less than StrideMs %v", prm.SegmentMs, prm.StrideMs)
+	}
+	if lead, _ := se.CarrySteps(); err == nil && lead-prm.StepsBack() > prm.StrideSamples/prm.StepSamples {
+		err = fmt.Errorf("sound.SndEnv: GaborCarry needs the %v lead steps of the gabor filters to be within the previous segment", lead)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// carryNeeded returns true if MelCarry of the segment needs steps of the previous segment that are not carried,
+// from processing it
+func (se *SndEnv) carryNeeded(segment, add int) bool {
+	lead, _ := se.CarrySteps()
+	if segment == 0 || lead <= se.Params.StepsBack() {
+		return false
+	}
+	return !se.carryOK || se.carrySeg != segment-1 || se.carryAdd != add
+}
+
+// keepCarry keeps the mel output of the segment for the carry of the next segment
+func (se *SndEnv) keepCarry(segment, add int) {
+	se.carryPrev.CopyShapeFrom(&se.MelFBankSegment)
+	se.carryPrev.CopyFrom(&se.MelFBankSegment)
+	se.carrySeg, se.carryAdd, se.carryOK = segment, add, true
+}
+
+// setCarry sets MelCarry to the lead steps before the stride of the segment, from the segment's border steps or the
+// previous segment, and the steps of the stride, see CarrySteps -- zeros for the steps before the start of the signal
+func (se *SndEnv) setCarry(segment int) {
+	lead, width := se.CarrySteps()
+	back := se.Params.StepsBack()
+	spS := se.Params.StrideSamples / se.Params.StepSamples
+	nf, steps := se.MelFBankSegment.Dim(0), se.MelFBankSegment.Dim(1)
+	se.MelCarry.SetShape([]int{nf, width}, nil, []string{"freq", "time"})
+	for c := 0; c < width; c++ {
+		li := c - lead + back // step of the segment
+		src, si := &se.MelFBankSegment, li
+		if li < 0 {
+			src, si = &se.carryPrev, li+spS
+			if segment == 0 {
+				src = nil
+			}
+		}
+		for f := 0; f < nf; f++ {
+			v := 0.0
+			if src != nil {
+				v = src.Values[f*steps+si]
+			}
+			se.MelCarry.Values[f*width+c] = v
+		}
+	}
+}
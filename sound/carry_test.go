@@ -0,0 +1,69 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math"
+	"testing"
+)
+
+// TestGaborCarry checks that the gabor outputs of consecutive segments with GaborCarry are those of ProcessAll,
+// each position of the filters in exactly one segment
+func TestGaborCarry(t *testing.T) {
+	se := testSndEnv(t, 1000)
+	se.Params.WinMs = 25
+	se.Params.StepMs = 10
+	se.Params.SegmentMs = 100
+	se.Params.StrideMs = 100
+	se.Params.BorderSteps = 2
+	se.GaborFilters.SizeX = 6
+	se.GaborFilters.StrideX = 2
+	se.ByTime = false
+	se.GaborCarry = true
+	se.SetGaborOut2D()
+	se.ToTensor()
+	if err := se.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := se.ProcessAll(); err != nil {
+		t.Fatal(err)
+	}
+	all := se.GaborAll.Clone()
+	nf := se.GaborFilters.Filters.Dim(0)
+	allPos := all.Dim(1) / nf
+	rows := all.Dim(0)
+
+	lead, width := se.CarrySteps()
+	stx := se.GaborFilters.StrideX
+	nPos := (width-se.GaborFilters.SizeX)/stx + 1
+	spS := int(se.Params.StrideMs / se.Params.StepMs)
+	checked := 0
+	for seg := 0; seg < se.SegCnt; seg++ {
+		se.ProcessSegment(seg, 0)
+		out := se.ApplyGabor()
+		if out.Dim(0) != rows || out.Dim(1) != nPos*nf {
+			t.Fatalf("segment %v: gabor output shape %v, want [%v %v]", seg, out.Shapes(), rows, nPos*nf)
+		}
+		for p := 0; p < nPos; p++ {
+			ap := (seg*spS-lead)/stx + p // the position in the whole signal
+			if ap < 0 || ap >= allPos {  // before the signal start or past the last full window
+				continue
+			}
+			for r := 0; r < rows; r++ {
+				for f := 0; f < nf; f++ {
+					got := out.Value([]int{r, p*nf + f})
+					want := all.FloatVal([]int{r, ap*nf + f})
+					if math.Abs(float64(got)-want) > 1e-5 {
+						t.Fatalf("segment %v position %v row %v filter %v: %v, ProcessAll %v", seg, p, r, f, got, want)
+					}
+				}
+			}
+			checked++
+		}
+	}
+	if checked < allPos-nPos {
+		t.Errorf("only %v of the %v positions of ProcessAll checked", checked, allPos)
+	}
+}
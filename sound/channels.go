@@ -0,0 +1,160 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"github.com/emer/auditory/agabor"
+	"github.com/emer/etable/etensor"
+)
+
+// ChannelModes are the ways a SndEnv processes the channels of multichannel sound
+type ChannelModes int32
+
+const (
+	// ChannelSingle processes the one channel Params.Channel, or the Params.Mixdown of the channels if Channel is -1
+	ChannelSingle ChannelModes = iota
+
+	// ChannelMix processes the Params.Mixdown of the channels, whatever Params.Channel is
+	ChannelMix
+
+	// ChannelAll processes every channel: the Signal is [channels, samples], the power, mel and mfcc of each channel
+	// are in PowerChans, MelChans and MFCCChans, and GborOutput and GborKwta have an outer channel dimension. The single
+	// channel outputs (PowerSegment, MelFBankSegment, MFCCSegment etc) are the mean over the channels, so pooling,
+	// splicing and the other users of the mel output work unchanged. The transforms of the Signal (noise vocoding,
+	// filtering, looping etc) treat it as one channel, use them with ChannelSingle or ChannelMix
+	ChannelAll
+)
+
+// NChans returns the number of channels processed, the channels of the Sound for ChannelAll and 1 otherwise
+func (se *SndEnv) NChans() int {
+	if se.Params.ChannelMode != ChannelAll || se.Sound.Buf == nil || se.Sound.Buf.Format == nil {
+		return 1
+	}
+	if n := se.Sound.Channels(); n > 1 {
+		return n
+	}
+	return 1
+}
+
+// NSamples returns the number of samples of the Signal, per channel
+func (se *SndEnv) NSamples() int {
+	nd := se.Signal.NumDims()
+	if nd == 0 {
+		return 0
+	}
+	return se.Signal.Dim(nd - 1)
+}
+
+// ChannelSignal returns the samples of channel ch of the Signal, the whole Signal if it is a single channel
+func (se *SndEnv) ChannelSignal(ch int) []float64 {
+	if se.Signal.NumDims() < 2 {
+		return se.Signal.Values
+	}
+	n := se.NSamples()
+	return se.Signal.Values[ch*n : (ch+1)*n]
+}
+
+// padChannels pads each channel of a [channels, samples] Signal, see Pad
+func (se *SndEnv) padChannels() {
+	nch := se.Signal.Dim(0)
+	chans := make([][]float64, nch)
+	for ch := range chans {
+		chans[ch] = se.Pad(append([]float64{}, se.ChannelSignal(ch)...))
+	}
+	n := len(chans[0])
+	se.Signal.SetShape([]int{nch, n}, nil, []string{"Channel", "Sample"})
+	for ch, sig := range chans {
+		copy(se.Signal.Values[ch*n:], sig)
+	}
+}
+
+// initChans sets the shapes of the per channel outputs for ChannelAll, or empties them
+func (se *SndEnv) initChans() {
+	nch := se.NChans()
+	if se.Params.ChannelMode != ChannelAll {
+		se.PowerChans.SetShape([]int{0}, nil, nil)
+		se.MelChans.SetShape([]int{0}, nil, nil)
+		se.MFCCChans.SetShape([]int{0}, nil, nil)
+		return
+	}
+	se.PowerChans.SetShape(append([]int{nch}, se.PowerSegment.Shp...), nil, nil)
+	se.MelChans.SetShape(append([]int{nch}, se.MelFBankSegment.Shp...), nil, nil)
+	if se.Mel.MFCC {
+		se.MFCCChans.SetShape(append([]int{nch}, se.MFCCSegment.Shp...), nil, nil)
+	} else {
+		se.MFCCChans.SetShape([]int{0}, nil, nil)
+	}
+	inner := append([]int{}, se.GborOutput.Shp...)
+	se.GborOutput.SetShape(append([]int{nch}, inner...), nil, nil)
+	se.GborKwta.CopyShapeFrom(&se.GborOutput)
+}
+
+// processChans processes each channel of the segment (see processChannel) into PowerChans, MelChans and MFCCChans,
+// and sets the single channel outputs to their mean over the channels
+func (se *SndEnv) processChans(segment, add int) {
+	nch := se.NChans()
+	outs := []*etensor.Float64{&se.PowerSegment, &se.LogPowerSegment, &se.Energy, &se.MelFBankSegment}
+	if se.Mel.MFCC {
+		outs = append(outs, &se.MFCCSegment, &se.MFCCDeltas, &se.MFCCDeltaDeltas)
+	}
+	if se.Features.On {
+		outs = append(outs, &se.FeatSegment)
+	}
+	sums := make([][]float64, len(outs))
+	for i, t := range outs {
+		sums[i] = make([]float64, len(t.Values))
+	}
+	for ch := 0; ch < nch; ch++ {
+		se.Chan = ch
+		se.processChannel(segment, add)
+		copy(se.PowerChans.SubSpace([]int{ch}).(*etensor.Float64).Values, se.PowerSegment.Values)
+		copy(se.MelChans.SubSpace([]int{ch}).(*etensor.Float64).Values, se.MelFBankSegment.Values)
+		if se.Mel.MFCC {
+			copy(se.MFCCChans.SubSpace([]int{ch}).(*etensor.Float64).Values, se.MFCCSegment.Values)
+		}
+		for i, t := range outs {
+			for j, v := range t.Values {
+				sums[i][j] += v
+			}
+		}
+	}
+	se.Chan = 0
+	for i, t := range outs {
+		for j := range t.Values {
+			t.Values[j] = sums[i][j] / float64(nch)
+		}
+	}
+}
+
+// applyGaborChans convolves the gabor filters with the mel output of each channel, then applies the habituation, neighborhood
+// inhibition and kwta to each channel, as ApplyGabor does for one channel
+func (se *SndEnv) applyGaborChans() (tsr *etensor.Float32) {
+	for ch := 0; ch < se.GborOutput.Dim(0); ch++ {
+		mel := se.MelChans.SubSpace([]int{ch}).(*etensor.Float64)
+		raw := se.GborOutput.SubSpace([]int{ch}).(*etensor.Float32)
+		agabor.Convolve(se.gaborInputOf(mel), se.GaborFilters, raw, se.ByTime)
+		if se.Habit.On {
+			se.applyHabit(raw, ch)
+		}
+		if se.NeighInhib.On {
+			se.NeighInhib.Inhib4(raw, &se.ExtGi)
+		} else {
+			se.ExtGi.SetZeros()
+		}
+		if se.Kwta.On {
+			kw := se.GborKwta.SubSpace([]int{ch}).(*etensor.Float32)
+			copy(kw.Values, raw.Values)
+			if se.KwtaPool {
+				se.Kwta.KWTAPool(raw, kw, &se.Inhibs, &se.ExtGi)
+			} else {
+				se.Kwta.KWTALayer(raw, kw, &se.ExtGi)
+			}
+		}
+	}
+	if se.Kwta.On {
+		return &se.GborKwta
+	}
+	return &se.GborOutput
+}
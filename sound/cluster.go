@@ -0,0 +1,231 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+
+	"github.com/emer/etable/etensor"
+)
+
+// ClusterMethods are the clustering algorithms of ClusterParams
+type ClusterMethods int32
+
+const (
+	ClusterKMeans ClusterMethods = iota // k-means, started with k-means++
+	ClusterGMM                          // gaussian mixture model with diagonal covariances (see TrainGMM), each point assigned its most probable component
+)
+
+// ClusterParams are the parameters of the clustering of unit features into pseudo-labels, for discovering the
+// units of unlabeled sound, e.g., the segments of AutoSegment
+type ClusterParams struct {
+
+	// clustering algorithm
+	Method ClusterMethods `desc:"clustering algorithm"`
+
+	// [def: 8] number of clusters
+	K int `default:"8" desc:"number of clusters"`
+
+	// [def: 20] number of iterations of k-means or expectation maximization
+	Iters int `default:"20" desc:"number of iterations of k-means or expectation maximization"`
+
+	// [def: mfcc] features of each unit that are clustered -- mel, mfcc or gabor, of the segment centered on the unit (see SndBank.Process)
+	Feature string `default:"mfcc" desc:"features of each unit that are clustered -- mel, mfcc or gabor, of the segment centered on the unit (see SndBank.Process)"`
+
+	// [def: true] standardize each feature (zero mean, unit variance over the units) before clustering, so features with large values don't dominate
+	Standardize bool `default:"true" desc:"standardize each feature (zero mean, unit variance over the units) before clustering, so features with large values don't dominate"`
+}
+
+// Defaults sets the default params
+func (cp *ClusterParams) Defaults() {
+	cp.Method = ClusterKMeans
+	cp.K = 8
+	cp.Iters = 20
+	cp.Feature = "mfcc"
+	cp.Standardize = true
+}
+
+// Vector returns the Feature of the unit features as one vector
+func (cp *ClusterParams) Vector(uf *UnitFeatures) ([]float64, error) {
+	var tsr etensor.Tensor
+	switch cp.Feature {
+	case "mel":
+		tsr = &uf.Mel
+	case "mfcc":
+		tsr = &uf.MFCC
+	case "gabor":
+		tsr = &uf.Gabor
+	default:
+		err := fmt.Errorf("sound.ClusterParams: unknown feature %q, mel, mfcc or gabor", cp.Feature)
+		log.Println(err)
+		return nil, err
+	}
+	if tsr.Len() == 0 {
+		err := fmt.Errorf("sound.ClusterParams: the unit has no %v features, turn them on in the processing", cp.Feature)
+		log.Println(err)
+		return nil, err
+	}
+	vec := make([]float64, tsr.Len())
+	for i := range vec {
+		vec[i] = tsr.FloatVal1D(i)
+	}
+	return vec, nil
+}
+
+// Cluster returns the cluster of each of the points (vectors of the same length), 0 to K-1, and the cluster centers,
+// of the standardized points if Standardize is set
+func (cp *ClusterParams) Cluster(points [][]float64, rnd *rand.Rand) (labels []int, centers [][]float64, err error) {
+	if len(points) < cp.K || cp.K < 1 {
+		err = fmt.Errorf("sound.ClusterParams: %v points can't be put in %v clusters", len(points), cp.K)
+		log.Println(err)
+		return nil, nil, err
+	}
+	if cp.Standardize {
+		points = standardize(points)
+	}
+	if cp.Method == ClusterGMM {
+		g, err := TrainGMM(points, cp.K, cp.Iters, rnd)
+		if err != nil {
+			return nil, nil, err
+		}
+		labels = make([]int, len(points))
+		for i, p := range points {
+			labels[i] = g.Predict(p)
+		}
+		return labels, g.Means, nil
+	}
+	centers, labels = KMeans(points, cp.K, cp.Iters, rnd)
+	return labels, centers, nil
+}
+
+// standardize returns the points with each dimension standardized to zero mean and unit variance
+func standardize(points [][]float64) [][]float64 {
+	var rs RunStats
+	rs.Init(len(points[0]))
+	for _, p := range points {
+		rs.Update(etensor.NewFloat64Shape(etensor.NewShape([]int{1, len(p)}, nil, nil), p))
+	}
+	std := make([][]float64, len(points))
+	for i, p := range points {
+		std[i] = make([]float64, len(p))
+		for j, v := range p {
+			std[i][j] = (v - rs.Mean[j]) / math.Sqrt(rs.Var(j)+1e-8)
+		}
+	}
+	return std
+}
+
+// KMeans clusters the points into k clusters by iters iterations of k-means (Lloyd's algorithm), starting with
+// centers chosen by k-means++ with rnd, and returns the centers and the cluster of each point
+func KMeans(points [][]float64, k, iters int, rnd *rand.Rand) (centers [][]float64, labels []int) {
+	dist := func(a, b []float64) float64 {
+		d := 0.0
+		for i := range a {
+			d += (a[i] - b[i]) * (a[i] - b[i])
+		}
+		return d
+	}
+	centers = append(centers, append([]float64{}, points[rnd.Intn(len(points))]...))
+	near := make([]float64, len(points)) // squared distance to the nearest center
+	for i, p := range points {
+		near[i] = dist(p, centers[0])
+	}
+	for len(centers) < k {
+		sum := 0.0
+		for _, d := range near {
+			sum += d
+		}
+		pick := 0
+		if sum > 0 {
+			r := rnd.Float64() * sum
+			for pick = 0; pick < len(points)-1; pick++ {
+				r -= near[pick]
+				if r <= 0 {
+					break
+				}
+			}
+		} else {
+			pick = rnd.Intn(len(points))
+		}
+		c := append([]float64{}, points[pick]...)
+		centers = append(centers, c)
+		for i, p := range points {
+			near[i] = math.Min(near[i], dist(p, c))
+		}
+	}
+
+	labels = make([]int, len(points))
+	d := len(points[0])
+	for it := 0; it <= iters; it++ {
+		changed := false
+		for i, p := range points {
+			best, bd := 0, math.Inf(1)
+			for c, ctr := range centers {
+				if cd := dist(p, ctr); cd < bd {
+					best, bd = c, cd
+				}
+			}
+			if labels[i] != best || it == 0 {
+				changed = true
+			}
+			labels[i] = best
+		}
+		if !changed || it == iters {
+			break
+		}
+		n := make([]int, k)
+		sums := make([][]float64, k)
+		for c := range sums {
+			sums[c] = make([]float64, d)
+		}
+		for i, p := range points {
+			n[labels[i]]++
+			for j, v := range p {
+				sums[labels[i]][j] += v
+			}
+		}
+		for c := range centers {
+			if n[c] == 0 { // empty cluster keeps its center
+				continue
+			}
+			for j := range centers[c] {
+				centers[c][j] = sums[c][j] / float64(n[c])
+			}
+		}
+	}
+	return centers, labels
+}
+
+// Predict returns the most probable component of the frame
+func (g *GMM) Predict(f []float64) int {
+	post := make([]float64, len(g.Weights))
+	g.Posteriors(f, post)
+	best := 0
+	for c, p := range post {
+		if p > post[best] {
+			best = c
+		}
+	}
+	return best
+}
+
+// Cluster processes each of the units (see Process) and returns its cluster of the features by cp, a pseudo-label
+// for the unit, with the cluster centers
+func (sb *SndBank) Cluster(refs []UnitRef, cp *ClusterParams, rnd *rand.Rand) (labels []int, centers [][]float64, err error) {
+	points := make([][]float64, len(refs))
+	for i, ref := range refs {
+		uf, err := sb.ProcessRef(ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		if points[i], err = cp.Vector(uf); err != nil {
+			return nil, nil, err
+		}
+	}
+	return cp.Cluster(points, rnd)
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+)
+
+// Decoder decodes sound data of a format, e.g., FLAC or MP3, into PCM samples
+type Decoder func(r io.ReadSeeker) (*audio.IntBuffer, error)
+
+// decoders are the registered decoders by lower case file extension
+var decoders = map[string]Decoder{
+	".wav":  DecodeWav,
+	".wave": DecodeWav,
+}
+
+// RegisterDecoder registers the decoder for files with the extension (e.g. ".flac"), replacing any decoder registered
+// for it -- Wave.Load uses the decoder of the file's extension. Decoders for compressed formats can wrap any codec package,
+// see also FFmpegDecoder
+func RegisterDecoder(ext string, dec Decoder) {
+	decoders[strings.ToLower(ext)] = dec
+}
+
+// DecoderFor returns the decoder registered for the extension of the file name (or the extension itself, e.g. ".mp3")
+func DecoderFor(fn string) (Decoder, bool) {
+	ext := strings.ToLower(filepath.Ext(fn))
+	if ext == "" {
+		ext = strings.ToLower(fn)
+	}
+	dec, ok := decoders[ext]
+	return dec, ok
+}
+
+// wavFormatFloat is the audio format of IEEE float wav data, 1 being PCM
+const wavFormatFloat = 3
+
+// DecodeWav decodes wav data: 8, 16, 24 and 32 bit PCM, with the unsigned 8 bit samples made signed, and 32 and 64 bit
+// IEEE float, as written by Praat and Audacity, converted to 32 bit PCM with the samples clipped to -1..1
+func DecodeWav(r io.ReadSeeker) (*audio.IntBuffer, error) {
+	d := wav.NewDecoder(r)
+	if err := d.FwdToPCM(); err != nil {
+		return nil, err
+	}
+	if d.WavAudioFormat == wavFormatFloat {
+		return decodeWavFloat(d)
+	}
+	buf, err := d.FullPCMBuffer()
+	if err != nil {
+		return nil, err
+	}
+	if buf.SourceBitDepth == 8 { // 0..255 around 128
+		for i := range buf.Data {
+			buf.Data[i] -= 128
+		}
+	}
+	return buf, nil
+}
+
+// decodeWavFloat decodes the IEEE float samples of the wav data, positioned at the samples, into 32 bit PCM
+func decodeWavFloat(d *wav.Decoder) (*audio.IntBuffer, error) {
+	bps := int(d.BitDepth) / 8
+	if bps != 4 && bps != 8 {
+		err := fmt.Errorf("sound.DecodeWav: float samples of %v bits, only 32 and 64 are supported", d.BitDepth)
+		log.Println(err)
+		return nil, err
+	}
+	b, err := io.ReadAll(d.PCMChunk)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	buf := &audio.IntBuffer{Format: &audio.Format{NumChannels: int(d.NumChans), SampleRate: int(d.SampleRate)}, SourceBitDepth: 32}
+	buf.Data = make([]int, len(b)/bps)
+	clipped := 0
+	for i := range buf.Data {
+		var v float64
+		if bps == 4 {
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:])))
+		} else {
+			v = math.Float64frombits(binary.LittleEndian.Uint64(b[i*8:]))
+		}
+		if v < -1 || v > 1 {
+			clipped++
+		}
+		buf.Data[i] = floatToPCM(v, 32)
+	}
+	if clipped > 0 {
+		log.Printf("sound.DecodeWav: %v float samples beyond -1..1 were clipped\n", clipped)
+	}
+	return buf, nil
+}
+
+// floatToPCM returns the sample in -1..1, clipped, as a signed integer sample of the bit depth
+func floatToPCM(v float64, bitDepth int) int {
+	mx := float64(int64(1)<<uint(bitDepth-1) - 1)
+	return int(math.Round(math.Max(-1, math.Min(1, v)) * mx))
+}
+
+// DecodeFormat decodes sound data, resampled to TargetRate if set, of the format of the extension (e.g. ".flac", or a file name with the extension), with its registered decoder
+func (snd *Wave) DecodeFormat(r io.ReadSeeker, ext string) error {
+	dec, ok := DecoderFor(ext)
+	if !ok {
+		err := fmt.Errorf("sound.DecodeFormat: no decoder registered for %q, see RegisterDecoder", ext)
+		log.Println(err)
+		return err
+	}
+	buf, err := dec(r)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	snd.Buf = buf
+	snd.Resample(snd.TargetRate)
+	return nil
+}
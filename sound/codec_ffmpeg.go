@@ -0,0 +1,57 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !js
+// +build !js
+
+package sound
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/go-audio/audio"
+)
+
+// FFmpegFormats are the extensions of the compressed formats decoded with FFmpegDecoder by default, until
+// a decoder is registered for them (see RegisterDecoder)
+var FFmpegFormats = []string{".flac", ".mp3", ".ogg", ".opus", ".m4a"}
+
+func init() {
+	for _, ext := range FFmpegFormats {
+		RegisterDecoder(ext, FFmpegDecoder)
+	}
+}
+
+// FFmpegDecoder decodes any format ffmpeg reads (e.g., the FLAC of LibriSpeech and the MP3 of Common Voice) into 16 bit
+// PCM, with the original sample rate and channels, by running the ffmpeg command, which must be on the PATH
+func FFmpegDecoder(r io.ReadSeeker) (*audio.IntBuffer, error) {
+	ff, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("sound.FFmpegDecoder: ffmpeg is not installed, install it or register a decoder for the format: %v", err)
+	}
+	tmp, err := os.CreateTemp("", "auditory-*.wav")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(ff, "-v", "error", "-y", "-i", "pipe:0", "-acodec", "pcm_s16le", tmp.Name())
+	cmd.Stdin = r
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("sound.FFmpegDecoder: %v: %s", err, stderr.Bytes())
+	}
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DecodeWav(f)
+}
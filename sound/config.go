@@ -0,0 +1,164 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/emer/auditory/agabor"
+	"github.com/emer/auditory/dft"
+	"github.com/emer/auditory/gammatone"
+	"github.com/emer/auditory/mel"
+	"github.com/emer/vision/kwta"
+)
+
+// OpenJSON opens the params from a JSON-formatted file, e.g., saved by SaveJSON -- the params not in the file are unchanged
+func (sp *Params) OpenJSON(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	err = json.Unmarshal(b, sp)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// SaveJSON saves the params to a JSON-formatted file
+func (sp *Params) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(sp, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// GaborGeometry is the geometry of a gabor filter set, the params of agabor.FilterSet without its filters
+type GaborGeometry struct {
+	SizeX      int
+	SizeY      int
+	StrideX    int
+	StrideY    int
+	Gain       float64
+	Distribute bool
+}
+
+// Config is the configuration of the processing of a SndEnv, its params set before Init, from the window and
+// step sizes to the gabor filters and kwta -- e.g., saved from the gaborview example with SaveJSON and opened
+// by headless training runs with SndEnv.OpenConfig, so both process sounds exactly the same way. The derived
+// params (e.g., Params.WinSamples) are saved too, but set again by Init
+type Config struct {
+	Params        Params
+	DFT           dft.Params
+	Whiten        WhitenParams
+	Bank          FilterBanks
+	Mel           mel.Params
+	Gammatone     gammatone.Params
+	BandLo        int
+	BandHi        int
+	SpecAug       SpecAugParams
+	TimePool      TimePoolParams
+	Splice        SpliceParams
+	GaborSpecs    []agabor.Filter
+	GaborFilters  GaborGeometry
+	GborOutPoolsX int
+	GborOutPoolsY int
+	GborOutUnitsX int
+	GborOutUnitsY int
+	ByTime        bool
+	GaborCarry    bool
+	NeighInhib    kwta.NeighInhib
+	Kwta          kwta.KWTA
+	KwtaPool      bool
+	Habit         HabitParams
+}
+
+// OpenJSON opens the config from a JSON-formatted file, e.g., saved by SaveJSON -- the params not in the file are
+// unchanged, so a file of only some of the params overrides those of the config
+func (cf *Config) OpenJSON(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	specs := cf.GaborSpecs
+	cf.GaborSpecs = nil // the specs in the file replace these, rather than being decoded over them
+	err = json.Unmarshal(b, cf)
+	if err != nil {
+		log.Println(err)
+	}
+	if cf.GaborSpecs == nil {
+		cf.GaborSpecs = specs
+	}
+	return err
+}
+
+// SaveJSON saves the config to a JSON-formatted file
+func (cf *Config) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(cf, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// Config returns the config of the processing of se
+func (se *SndEnv) Config() *Config {
+	gf := &se.GaborFilters
+	return &Config{
+		Params: se.Params, DFT: se.DFT, Whiten: se.Whiten,
+		Bank: se.Bank, Mel: se.Mel, Gammatone: se.Gammatone, BandLo: se.BandLo, BandHi: se.BandHi,
+		SpecAug: se.SpecAug, TimePool: se.TimePool, Splice: se.Splice,
+		GaborSpecs:    append([]agabor.Filter(nil), se.GaborSpecs...),
+		GaborFilters:  GaborGeometry{SizeX: gf.SizeX, SizeY: gf.SizeY, StrideX: gf.StrideX, StrideY: gf.StrideY, Gain: gf.Gain, Distribute: gf.Distribute},
+		GborOutPoolsX: se.GborOutPoolsX, GborOutPoolsY: se.GborOutPoolsY, GborOutUnitsX: se.GborOutUnitsX, GborOutUnitsY: se.GborOutUnitsY,
+		ByTime: se.ByTime, GaborCarry: se.GaborCarry,
+		NeighInhib: se.NeighInhib, Kwta: se.Kwta, KwtaPool: se.KwtaPool, Habit: se.Habit,
+	}
+}
+
+// SetConfig sets the params of the processing of se to the config -- call Init after
+func (se *SndEnv) SetConfig(cf *Config) {
+	se.Params, se.DFT, se.Whiten = cf.Params, cf.DFT, cf.Whiten
+	se.DFT.ResetCache()
+	se.Bank, se.Mel, se.Gammatone, se.BandLo, se.BandHi = cf.Bank, cf.Mel, cf.Gammatone, cf.BandLo, cf.BandHi
+	se.SpecAug, se.TimePool, se.Splice = cf.SpecAug, cf.TimePool, cf.Splice
+	se.GaborSpecs = append([]agabor.Filter(nil), cf.GaborSpecs...)
+	gf, cg := &se.GaborFilters, &cf.GaborFilters
+	gf.SizeX, gf.SizeY, gf.StrideX, gf.StrideY, gf.Gain, gf.Distribute = cg.SizeX, cg.SizeY, cg.StrideX, cg.StrideY, cg.Gain, cg.Distribute
+	se.GborOutPoolsX, se.GborOutPoolsY, se.GborOutUnitsX, se.GborOutUnitsY = cf.GborOutPoolsX, cf.GborOutPoolsY, cf.GborOutUnitsX, cf.GborOutUnitsY
+	se.ByTime, se.GaborCarry = cf.ByTime, cf.GaborCarry
+	se.NeighInhib, se.Kwta, se.KwtaPool, se.Habit = cf.NeighInhib, cf.Kwta, cf.KwtaPool, cf.Habit
+}
+
+// OpenConfig sets the params of the processing of se to those of the config in the JSON-formatted file, see Config
+// -- the params not in the file are unchanged. Call Init after
+func (se *SndEnv) OpenConfig(filename string) error {
+	cf := se.Config()
+	if err := cf.OpenJSON(filename); err != nil {
+		return err
+	}
+	se.SetConfig(cf)
+	return nil
+}
+
+// SaveConfig saves the config of the processing of se to a JSON-formatted file, see Config
+func (se *SndEnv) SaveConfig(filename string) error {
+	return se.Config().SaveJSON(filename)
+}
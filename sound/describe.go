@@ -0,0 +1,114 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/emer/auditory/agabor"
+)
+
+// Describe returns a human readable summary of the processing: the sound, the window, step, segment and stride in
+// milliseconds and samples, the filterbank and its frequency range, the mfcc, the gabor filters and output geometry,
+// the optional steps that are on and the shape of each of the Outputs -- e.g., for the log of an experiment, so
+// its results document exactly how the audio was processed. Call after Init, for the samples and shapes
+func (se *SndEnv) Describe() string {
+	var b strings.Builder
+	sr := se.Sound.SampleRate()
+	pr := &se.Params
+	fmt.Fprintf(&b, "sound: sample rate %d Hz, %d channels", sr, se.Sound.Channels())
+	switch {
+	case pr.ChannelMode == ChannelAll:
+		fmt.Fprintf(&b, ", every channel processed\n")
+	case pr.ChannelMode == ChannelMix || pr.Channel < 0:
+		fmt.Fprintf(&b, ", channels mixed down\n")
+	default:
+		fmt.Fprintf(&b, ", channel %d processed\n", pr.Channel)
+	}
+	if sr > 0 && len(se.Signal.Values) > 0 {
+		fmt.Fprintf(&b, "signal: %d samples (%.1f ms), %d segments\n", se.NSamples(), float64(se.NSamples())*1000/float64(sr), se.SegCnt)
+	}
+	fmt.Fprintf(&b, "window: %g ms (%d samples), pre-emphasis %g\n", pr.WinMs, pr.WinSamples, pr.PreEmph)
+	fmt.Fprintf(&b, "step: %g ms (%d samples)\n", pr.StepMs, pr.StepSamples)
+	fmt.Fprintf(&b, "segment: %g ms (%d samples, %d steps, %d border steps)\n", pr.SegmentMs, pr.SegmentSamples, pr.SegmentSteps, pr.BorderSteps)
+	fmt.Fprintf(&b, "stride: %g ms (%d samples)\n", pr.StrideMs, pr.StrideSamples)
+
+	if se.Bank == GammatoneBank {
+		gt := &se.Gammatone
+		fmt.Fprintf(&b, "filterbank: gammatone, %d filters, %g to %g Hz, order %d\n", gt.NFilters, gt.LoHz, gt.HiHz, gt.Order)
+	} else {
+		fb := &se.Mel.FBank
+		fmt.Fprintf(&b, "filterbank: mel, %d filters, %g to %g Hz, log min %g\n", fb.NFilters, fb.LoHz, fb.HiHz, fb.LogMin)
+	}
+	if lo, hi := se.MelBand(); se.Cropped() {
+		fmt.Fprintf(&b, "band: filters %d to %d\n", lo, hi)
+	}
+	if se.Mel.MFCC {
+		fmt.Fprintf(&b, "mfcc: %d coefficients, lifter %g", se.Mel.NCoefs, se.Mel.Lifter)
+		if se.Mel.Deltas {
+			fmt.Fprintf(&b, ", deltas over %d steps", se.Mel.DeltaWin)
+		}
+		if se.Mel.CMVN {
+			fmt.Fprintf(&b, ", cmvn window %d", se.Mel.CMVNWindow)
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+
+	if specs := agabor.Active(se.GaborSpecs); len(specs) > 0 {
+		gf := &se.GaborFilters
+		fmt.Fprintf(&b, "gabor: %d filters of %d x %d, stride %d x %d, gain %g\n", len(specs), gf.SizeX, gf.SizeY, gf.StrideX, gf.StrideY, gf.Gain)
+		for _, sp := range specs {
+			fmt.Fprintf(&b, "  orientation %g, wavelength %g, sigma width %g, sigma length %g, phase %g\n", sp.Orientation, sp.WaveLen, sp.SigmaWidth, sp.SigmaLength, sp.PhaseOffset)
+		}
+		fmt.Fprintf(&b, "gabor output: pools %d x %d, units %d x %d", se.GborOutPoolsY, se.GborOutPoolsX, se.GborOutUnitsY, se.GborOutUnitsX)
+		if se.ByTime {
+			fmt.Fprintf(&b, ", by time")
+		}
+		if se.GaborCarry {
+			fmt.Fprintf(&b, ", carried over segments")
+		}
+		fmt.Fprintf(&b, "\n")
+		if se.NeighInhib.On {
+			fmt.Fprintf(&b, "neighborhood inhibition: gi %g\n", se.NeighInhib.Gi)
+		}
+		if se.Kwta.On {
+			fmt.Fprintf(&b, "kwta: layer gi %g, pool gi %g, pooled %v\n", se.Kwta.LayFFFB.Gi, se.Kwta.PoolFFFB.Gi, se.KwtaPool)
+		}
+	}
+
+	var on []string
+	for _, opt := range []struct {
+		name string
+		on   bool
+	}{
+		{"whitening", se.Whiten.On()},
+		{"spec augment", se.SpecAug.On},
+		{"time pooling", se.TimePool.On()},
+		{"splicing", se.Splice.On()},
+		{"habituation", se.Habit.On},
+		{"features", se.Features.On},
+		{"pitch", se.Pitch.On},
+		{"envelope", se.Envelope.On},
+		{"vad", se.VAD.On},
+	} {
+		if opt.on {
+			on = append(on, opt.name)
+		}
+	}
+	if len(on) > 0 {
+		fmt.Fprintf(&b, "also: %s\n", strings.Join(on, ", "))
+	}
+	fmt.Fprintf(&b, "outputs:\n")
+	for _, nm := range se.Outputs() {
+		tsr := se.Output(nm)
+		fmt.Fprintf(&b, "  %s %v", se.Prefix+nm, tsr.Shapes())
+		if dn := tsr.DimNames(); len(dn) > 0 && dn[0] != "" {
+			fmt.Fprintf(&b, " %v", dn)
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+	return b.String()
+}
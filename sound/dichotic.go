@@ -0,0 +1,156 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"errors"
+	"log"
+	"math"
+	"math/cmplx"
+
+	"github.com/go-audio/audio"
+)
+
+// NewWave returns a 16 bit Wave made from the signals of each channel, in -1..1 (values beyond are clipped),
+// interleaving the channels. Channels shorter than the longest are padded with silence
+func NewWave(chans [][]float64, sampleRate int) (*Wave, error) {
+	nc := len(chans)
+	if nc == 0 {
+		err := errors.New("sound.NewWave: no channels")
+		log.Println(err)
+		return nil, err
+	}
+	nfr := 0
+	for _, ch := range chans {
+		if len(ch) > nfr {
+			nfr = len(ch)
+		}
+	}
+	buf := &audio.IntBuffer{Format: &audio.Format{NumChannels: nc, SampleRate: sampleRate}, SourceBitDepth: 16}
+	buf.Data = make([]int, nfr*nc)
+	for c, ch := range chans {
+		for i, v := range ch {
+			v = math.Max(-1, math.Min(1, v))
+			buf.Data[i*nc+c] = int(math.Round(v * 0x7FFF))
+		}
+	}
+	return &Wave{Buf: buf}, nil
+}
+
+// NewStereo returns a 16 bit stereo Wave with the left and right ear signals, e.g., for dichotic stimuli,
+// where each ear gets a different signal
+func NewStereo(left, right []float64, sampleRate int) (*Wave, error) {
+	return NewWave([][]float64{left, right}, sampleRate)
+}
+
+// Tone returns a sine tone of hz with amplitude amp and starting phase (radians), lasting durMs milliseconds
+func Tone(hz, amp, phase, durMs float64, sampleRate int) []float64 {
+	out := make([]float64, MSecToSamples(durMs, sampleRate))
+	w := 2 * math.Pi * hz / float64(sampleRate)
+	for i := range out {
+		out[i] = amp * math.Sin(w*float64(i)+phase)
+	}
+	return out
+}
+
+// BinauralBeat returns a stereo Wave with tones of carrierHz - beatHz / 2 in the left ear and carrierHz + beatHz / 2
+// in the right ear, which are heard as a single tone beating at beatHz although neither ear gets a beating signal
+func BinauralBeat(carrierHz, beatHz, amp, durMs float64, sampleRate int) (*Wave, error) {
+	left := Tone(carrierHz-beatHz/2, amp, 0, durMs, sampleRate)
+	right := Tone(carrierHz+beatHz/2, amp, 0, durMs, sampleRate)
+	return NewStereo(left, right, sampleRate)
+}
+
+// ITD returns the left and right ear signals for an interaural time difference of itdUs microseconds,
+// positive itdUs delaying the right ear, i.e., a source on the left. The delay is fractional, using linear interpolation,
+// and both signals are lengthened by the delay so neither is truncated
+func ITD(signal []float64, itdUs float64, sampleRate int) (left, right []float64) {
+	d := math.Abs(itdUs) * 1e-6 * float64(sampleRate)
+	n := len(signal) + int(math.Ceil(d))
+	lead := make([]float64, n)
+	lag := make([]float64, n)
+	copy(lead, signal)
+	di := int(d)
+	fr := d - float64(di)
+	for i := range lag {
+		j := i - di
+		if j >= 0 && j < len(signal) {
+			lag[i] += (1 - fr) * signal[j]
+		}
+		if j-1 >= 0 && j-1 < len(signal) {
+			lag[i] += fr * signal[j-1]
+		}
+	}
+	if itdUs < 0 {
+		return lag, lead
+	}
+	return lead, lag
+}
+
+// ILD returns the left and right ear signals for an interaural level difference of ildDb dB,
+// positive ildDb making the left ear louder, with the difference split evenly between the ears
+func ILD(signal []float64, ildDb float64) (left, right []float64) {
+	gl := math.Pow(10, ildDb/40)
+	left = make([]float64, len(signal))
+	right = make([]float64, len(signal))
+	for i, v := range signal {
+		left[i] = v * gl
+		right[i] = v / gl
+	}
+	return left, right
+}
+
+// IPD returns the left and right ear signals for an interaural phase difference of ipd radians at all frequencies,
+// positive ipd advancing the phase of the left ear, made by rotating the phase of the analytic signal.
+// Unlike ITD the phase difference is the same at all frequencies, e.g. ipd = math.Pi with a diotic masker
+// gives the classic N0Spi stimulus for binaural masking level differences
+func IPD(signal []float64, ipd float64) (left, right []float64) {
+	an := Analytic(signal)
+	rl := cmplx.Exp(complex(0, ipd/2))
+	rr := cmplx.Exp(complex(0, -ipd/2))
+	left = make([]float64, len(signal))
+	right = make([]float64, len(signal))
+	for i, c := range an {
+		left[i] = real(c * rl)
+		right[i] = real(c * rr)
+	}
+	return left, right
+}
+
+// Dichotic returns a stereo Wave of signal with the interaural time, level and phase differences of
+// ITD, ILD and IPD applied in turn, any of which can be 0
+func Dichotic(signal []float64, itdUs, ildDb, ipd float64, sampleRate int) (*Wave, error) {
+	left, right := signal, signal
+	if ipd != 0 {
+		left, right = IPD(signal, ipd)
+	}
+	if itdUs != 0 {
+		l, _ := ITD(left, itdUs, sampleRate)
+		_, r := ITD(right, itdUs, sampleRate)
+		left, right = l, r
+	}
+	if ildDb != 0 {
+		left, _ = ILD(left, ildDb)
+		_, right = ILD(right, ildDb)
+	}
+	return NewStereo(left, right, sampleRate)
+}
+
+// AddSignals returns the sum of the signals, as long as the longest one, e.g. to add a target to a masker in each ear
+func AddSignals(signals ...[]float64) []float64 {
+	n := 0
+	for _, s := range signals {
+		if len(s) > n {
+			n = len(s)
+		}
+	}
+	out := make([]float64, n)
+	for _, s := range signals {
+		for i, v := range s {
+			out[i] += v
+		}
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/emer/etable/etensor"
+)
+
+// DisplayKinds are the kinds of feature tensors, which determine how they are displayed, see ConfigureForDisplay
+type DisplayKinds int32
+
+const (
+	// DisplaySigned is for values around 0, e.g., gabor output, gabor filters and mfcc -- a diverging color map with a range symmetric about 0
+	DisplaySigned DisplayKinds = iota
+
+	// DisplayLevel is for levels, e.g., mel filter bank output and kwta output -- a sequential color map with the 1st to 99th percentile range
+	DisplayLevel
+
+	// DisplayLogPower is for natural log power, e.g., LogPowerSegment -- a sequential color map with the range the top DisplayDbRange dB
+	DisplayLogPower
+)
+
+// DisplayDbRange is the dynamic range in dB of DisplayLogPower tensors -- values more than this below the maximum are shown at the minimum
+var DisplayDbRange = 60.0
+
+// ColorMap returns the name of the color map (see etview.TensorGrid) for the kind
+func (dk DisplayKinds) ColorMap() string {
+	if dk == DisplaySigned {
+		return "ColdHot"
+	}
+	return "Viridis"
+}
+
+// ConfigureForDisplay sets the display metadata of the tensor for its kind, read by etview.TensorGrid when the tensor
+// is set on the grid: the color map and, if the tensor has any non-zero values, the fixed display range.
+// Tensors without values yet only get the color map, with the range left to the grid
+func ConfigureForDisplay(tsr etensor.Tensor, kind DisplayKinds) {
+	tsr.SetMetaData("colormap", kind.ColorMap())
+	if kind == DisplayLogPower {
+		tsr.SetMetaData("grid-min", "10")
+	}
+	if min, max, ok := DisplayRange(tsr, kind); ok {
+		setRange(tsr, min, max)
+		return
+	}
+	tsr.SetMetaData("fix-min", "false")
+	tsr.SetMetaData("fix-max", "false")
+}
+
+// DisplayRange returns the display range of the tensor for its kind (see DisplayKinds), false if it has no non-zero values
+func DisplayRange(tsr etensor.Tensor, kind DisplayKinds) (min, max float64, ok bool) {
+	mx := 0.0
+	for i := 0; i < tsr.Len(); i++ {
+		mx = math.Max(mx, math.Abs(tsr.FloatVal1D(i)))
+	}
+	if mx == 0 {
+		return 0, 0, false
+	}
+	switch kind {
+	case DisplaySigned:
+		m := mx
+		abs := etensor.NewFloat64([]int{tsr.Len()}, nil, nil)
+		for i := range abs.Values {
+			abs.Values[i] = math.Abs(tsr.FloatVal1D(i))
+		}
+		if q := Quantile(abs, .99); q > 0 {
+			m = q
+		}
+		return -m, m, true
+	case DisplayLevel:
+		min, max = Quantile(tsr, .01), Quantile(tsr, .99)
+		if max <= min { // e.g. all values the same
+			max = min + 1
+		}
+		return min, max, true
+	default:
+		max = math.Inf(-1)
+		for i := 0; i < tsr.Len(); i++ {
+			max = math.Max(max, tsr.FloatVal1D(i))
+		}
+		return max - DisplayDbRange*math.Ln10/10, max, true // dB = 10 log10(power) = 10 / ln(10) ln(power)
+	}
+}
+
+// setRange sets the fixed display range metadata of the tensor
+func setRange(tsr etensor.Tensor, min, max float64) {
+	tsr.SetMetaData("min", strconv.FormatFloat(min, 'g', 4, 64))
+	tsr.SetMetaData("max", strconv.FormatFloat(max, 'g', 4, 64))
+	tsr.SetMetaData("fix-min", "true")
+	tsr.SetMetaData("fix-max", "true")
+}
+
+// ConfigDisplay sets the color maps of the output tensors for their kinds, see ConfigureForDisplay. Called by Init
+func (se *SndEnv) ConfigDisplay() {
+	se.LogPowerSegment.SetMetaData("colormap", DisplayLogPower.ColorMap())
+	se.LogPowerSegment.SetMetaData("grid-min", "10")
+	se.MelFBankSegment.SetMetaData("colormap", DisplayLevel.ColorMap())
+	se.MelBandSegment.SetMetaData("colormap", DisplayLevel.ColorMap())
+	se.MFCCSegment.SetMetaData("colormap", DisplaySigned.ColorMap())
+	se.MFCCDeltas.SetMetaData("colormap", DisplaySigned.ColorMap())
+	se.MFCCDeltaDeltas.SetMetaData("colormap", DisplaySigned.ColorMap())
+	se.GborOutput.SetMetaData("colormap", DisplaySigned.ColorMap())
+	se.GborKwta.SetMetaData("colormap", DisplayLevel.ColorMap())
+	se.GaborFilters.Filters.SetMetaData("colormap", DisplaySigned.ColorMap())
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"errors"
+	"log"
+	"math"
+
+	"github.com/emer/etable/etensor"
+)
+
+// DTWResult is the alignment of two feature sequences by dynamic time warping, see DTW
+type DTWResult struct {
+
+	// sum of the distances of the aligned frames along the path
+	Dist float64 `desc:"sum of the distances of the aligned frames along the path"`
+
+	// Dist divided by the sum of the lengths of the sequences, comparable across sequences of different lengths
+	NormDist float64 `desc:"Dist divided by the sum of the lengths of the sequences, comparable across sequences of different lengths"`
+
+	// the aligned frames, pairs of frame indexes of the first and second sequence, from the first frames to the last
+	Path [][2]int `desc:"the aligned frames, pairs of frame indexes of the first and second sequence, from the first frames to the last"`
+}
+
+// TensorFrames returns the frames of a [features, steps] tensor, e.g., MelFBankSegment or MFCCSegment, one slice of the features per step
+func TensorFrames(tsr *etensor.Float64) [][]float64 {
+	nf, steps := tsr.Dim(0), tsr.Dim(1)
+	frames := make([][]float64, steps)
+	for s := range frames {
+		frames[s] = make([]float64, nf)
+		for i := range frames[s] {
+			frames[s][i] = tsr.Values[i*steps+s]
+		}
+	}
+	return frames
+}
+
+// DTW aligns the [features, steps] tensors a and b (e.g., the mel or mfcc output of two utterances) by dynamic
+// time warping, see DTWFrames
+func DTW(a, b *etensor.Float64, band int) (*DTWResult, error) {
+	return DTWFrames(TensorFrames(a), TensorFrames(b), band)
+}
+
+// DTWFrames aligns the frame sequences a and b (each frame a slice of the same features) by dynamic time warping with
+// the euclidean distance between frames and steps of (1, 0), (0, 1) and (1, 1), from the first frames to the last.
+// If band > 0 the path is limited to the Sakoe-Chiba band of frames within band of the diagonal (scaled for sequences
+// of different lengths), which is faster and keeps the alignment from straying too far -- an error if no path fits the band
+func DTWFrames(a, b [][]float64, band int) (*DTWResult, error) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		err := errors.New("sound.DTW: empty sequence")
+		log.Println(err)
+		return nil, err
+	}
+	inBand := func(i, j int) bool {
+		if band <= 0 {
+			return true
+		}
+		diag := float64(i) * float64(m-1) / math.Max(1, float64(n-1))
+		return math.Abs(float64(j)-diag) <= float64(band)
+	}
+	inf := math.Inf(1)
+	cost := make([][]float64, n)
+	for i := range cost {
+		cost[i] = make([]float64, m)
+		for j := range cost[i] {
+			cost[i][j] = inf
+			if !inBand(i, j) {
+				continue
+			}
+			d := 0.0
+			for k := range a[i] {
+				d += (a[i][k] - b[j][k]) * (a[i][k] - b[j][k])
+			}
+			d = math.Sqrt(d)
+			if i == 0 && j == 0 {
+				cost[i][j] = d
+				continue
+			}
+			best := inf
+			if i > 0 {
+				best = math.Min(best, cost[i-1][j])
+			}
+			if j > 0 {
+				best = math.Min(best, cost[i][j-1])
+			}
+			if i > 0 && j > 0 {
+				best = math.Min(best, cost[i-1][j-1])
+			}
+			cost[i][j] = best + d
+		}
+	}
+	if math.IsInf(cost[n-1][m-1], 1) {
+		err := errors.New("sound.DTW: no path fits the band, make it wider")
+		log.Println(err)
+		return nil, err
+	}
+
+	res := &DTWResult{Dist: cost[n-1][m-1], NormDist: cost[n-1][m-1] / float64(n+m)}
+	i, j := n-1, m-1
+	res.Path = append(res.Path, [2]int{i, j})
+	for i > 0 || j > 0 {
+		switch {
+		case i == 0:
+			j--
+		case j == 0:
+			i--
+		default:
+			d, l, u := cost[i-1][j-1], cost[i][j-1], cost[i-1][j]
+			switch {
+			case d <= l && d <= u:
+				i, j = i-1, j-1
+			case l <= u:
+				j--
+			default:
+				i--
+			}
+		}
+		res.Path = append(res.Path, [2]int{i, j})
+	}
+	for l, r := 0, len(res.Path)-1; l < r; l, r = l+1, r-1 {
+		res.Path[l], res.Path[r] = res.Path[r], res.Path[l]
+	}
+	return res, nil
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/emer/emergent/env"
+	"github.com/emer/etable/etensor"
+)
+
+// DualEnv is a SeqEnv that runs a second SndEnv pipeline, Long, over the same sound with a longer
+// SegmentMs (e.g. 100 ms for Snd and 300 ms for Long) for models with two input pathways.
+// Both pipelines step through the sound with the same stride and the leading (right) edge of
+// each Long segment is kept at the same time point as the leading edge of the Snd segment,
+// i.e. Long also covers the sound that came before the current Snd segment.
+// The Long states are the SeqEnv states prefixed with "Long", e.g. "LongGabor", "LongMel"
+type DualEnv struct {
+	SeqEnv
+
+	// the second pipeline, with a SegmentMs >= Snd.Params.SegmentMs and the same StrideMs -- set the params, gabor specs and output shape before calling Init
+	Long SndEnv `desc:"the second pipeline, with a SegmentMs >= Snd.Params.SegmentMs and the same StrideMs -- set the params, gabor specs and output shape before calling Init"`
+
+	// [view: -] gabor output of the current Long segment, post kwta if Kwta is on
+	LongOutput *etensor.Float32 `view:"-" desc:"gabor output of the current Long segment, post kwta if Kwta is on"`
+}
+
+func (de *DualEnv) Validate() error {
+	err := de.SeqEnv.Validate()
+	if err != nil {
+		return err
+	}
+	if de.Long.Params.StrideMs != de.Snd.Params.StrideMs {
+		return fmt.Errorf("sound.DualEnv: %v Long.Params.StrideMs must equal Snd.Params.StrideMs to keep the segments aligned", de.Nm)
+	}
+	if de.Long.Params.SegmentMs < de.Snd.Params.SegmentMs {
+		return fmt.Errorf("sound.DualEnv: %v Long.Params.SegmentMs must be >= Snd.Params.SegmentMs", de.Nm)
+	}
+	return nil
+}
+
+// AlignMs returns the offset in milliseconds, passed to Long.ProcessSegment, that moves the start
+// of each Long segment back so its leading edge lines up with the leading edge of the Snd segment
+func (de *DualEnv) AlignMs() int {
+	return int(de.Snd.Params.SegmentMs - de.Long.Params.SegmentMs)
+}
+
+// LoadLong shares the signal of the current sound file, already loaded by Snd, with Long
+// and initializes the Long processing for it -- the Prefix of Long is "Long" unless set
+func (de *DualEnv) LoadLong() error {
+	if de.Long.Prefix == "" {
+		de.Long.Prefix = "Long"
+	}
+	de.Long.Sound = de.Snd.Sound
+	de.Long.Signal = de.Snd.Signal
+	return de.Long.Init()
+}
+
+func (de *DualEnv) Step() bool {
+	if !de.SeqEnv.Step() {
+		return false
+	}
+	if de.Seq.Chg { // new sound file
+		err := de.LoadLong()
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+	}
+	de.Long.ProcessSegment(de.Trial.Cur, de.AlignMs()+de.Jitter) // same jitter keeps the leading edges aligned
+	de.LongOutput = de.Long.ApplyGabor()
+	return true
+}
+
+// State returns the named state element -- see SeqEnv.State -- prefix the name with "Long"
+// for the states of the Long pipeline, e.g. "LongGabor"
+func (de *DualEnv) State(element string) etensor.Tensor {
+	if !strings.HasPrefix(element, "Long") {
+		return de.SeqEnv.State(element)
+	}
+	switch strings.TrimPrefix(element, "Long") {
+	case "Gabor":
+		return de.LongOutput
+	case "Mel":
+		return &de.Long.MelFBankSegment
+	case "MFCC":
+		return &de.Long.MFCCSegment
+	case "MelPooled":
+		return &de.Long.MelPooled
+	case "MFCCPooled":
+		return &de.Long.MFCCPooled
+	case "MelSpliced":
+		return &de.Long.MelSpliced
+	case "MFCCSpliced":
+		return &de.Long.MFCCSpliced
+	case "Power":
+		return &de.Long.LogPowerSegment
+	}
+	return nil
+}
+
+// Compile-time check that implements Env interface
+var _ env.Env = (*DualEnv)(nil)
+
+// States returns the state elements of both pipelines -- shapes are only valid after the first sound file is loaded
+func (de *DualEnv) States() env.Elements {
+	els := de.SeqEnv.States()
+	els = append(els, env.Elements{
+		{"LongGabor", de.Long.GborKwta.Shapes(), nil},
+		{"LongMel", de.Long.MelFBankSegment.Shapes(), nil},
+		{"LongMFCC", de.Long.MFCCSegment.Shapes(), nil},
+		{"LongPower", de.Long.LogPowerSegment.Shapes(), nil},
+		{"LongMelPooled", de.Long.MelPooled.Shapes(), nil},
+		{"LongMFCCPooled", de.Long.MFCCPooled.Shapes(), nil},
+		{"LongMelSpliced", de.Long.MelSpliced.Shapes(), nil},
+		{"LongMFCCSpliced", de.Long.MFCCSpliced.Shapes(), nil},
+	}...)
+	return els
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"errors"
+	"log"
+	"math"
+
+	"github.com/emer/etable/etensor"
+)
+
+// SpeakerEmbedder computes a fixed size speaker embedding of an utterance from its frames, e.g., GMMEmbedder,
+// or an adapter for an external i-vector or x-vector extractor
+type SpeakerEmbedder interface {
+
+	// Dim returns the size of the embeddings
+	Dim() int
+
+	// Embed returns the embedding of the frames of an utterance, each a slice of the features
+	Embed(frames [][]float64) ([]float64, error)
+}
+
+// GMMEmbedder is a SpeakerEmbedder computing the GMM mean supervector of an utterance: the means of the universal
+// background model adapted to the frames (maximum a posteriori, with Relevance), as offsets from the model means
+// scaled by the weights and standard deviations (Reynolds et al, 2000; Campbell et al, 2006)
+type GMMEmbedder struct {
+
+	// the universal background model, e.g., from TrainGMM with the frames of many speakers
+	UBM *GMM `desc:"the universal background model, e.g., from TrainGMM with the frames of many speakers"`
+
+	// [def: 16] relevance factor of the adaptation -- the number of frames of a component at which the adapted mean is halfway to the frames' mean
+	Relevance float64 `default:"16" desc:"relevance factor of the adaptation -- the number of frames of a component at which the adapted mean is halfway to the frames' mean"`
+}
+
+// Dim returns the number of components times the number of features
+func (ge *GMMEmbedder) Dim() int {
+	if ge.UBM == nil || len(ge.UBM.Means) == 0 {
+		return 0
+	}
+	return len(ge.UBM.Means) * len(ge.UBM.Means[0])
+}
+
+// Embed returns the mean supervector of the frames
+func (ge *GMMEmbedder) Embed(frames [][]float64) ([]float64, error) {
+	if ge.UBM == nil {
+		err := errors.New("sound.GMMEmbedder: no UBM")
+		log.Println(err)
+		return nil, err
+	}
+	g := ge.UBM
+	k := len(g.Means)
+	d := len(g.Means[0])
+	n := make([]float64, k)
+	sx := make([]float64, k*d)
+	post := make([]float64, k)
+	for _, f := range frames {
+		g.Posteriors(f, post)
+		for c, p := range post {
+			n[c] += p
+			for j, v := range f {
+				sx[c*d+j] += p * v
+			}
+		}
+	}
+	emb := make([]float64, k*d)
+	for c := 0; c < k; c++ {
+		if n[c] == 0 {
+			continue
+		}
+		alpha := n[c] / (n[c] + ge.Relevance)
+		for j := 0; j < d; j++ {
+			adapted := alpha*sx[c*d+j]/n[c] + (1-alpha)*g.Means[c][j]
+			emb[c*d+j] = math.Sqrt(g.Weights[c]) * (adapted - g.Means[c][j]) / math.Sqrt(g.Vars[c][j])
+		}
+	}
+	return emb, nil
+}
+
+// UtteranceFrames returns the mel frames of the whole Signal, each a slice of the filters, from processing every segment
+// and keeping the steps within the segment (not the border steps) -- call after Init
+func (se *SndEnv) UtteranceFrames() [][]float64 {
+	var frames [][]float64
+	bs := se.Params.BorderSteps
+	for s := 0; s < se.SegCnt; s++ {
+		se.ProcessSegment(s, 0)
+		nf, steps := se.MelFBankSegment.Dim(0), se.MelFBankSegment.Dim(1)
+		for st := bs; st < steps-bs; st++ {
+			f := make([]float64, nf)
+			for i := range f {
+				f[i] = se.MelFBankSegment.Values[i*steps+st]
+			}
+			frames = append(frames, f)
+		}
+	}
+	return frames
+}
+
+// SpeakerEmbedding sets tsr to the embedding of the whole Signal by em, see UtteranceFrames -- call after Init
+func (se *SndEnv) SpeakerEmbedding(em SpeakerEmbedder, tsr *etensor.Float32) error {
+	emb, err := em.Embed(se.UtteranceFrames())
+	if err != nil {
+		return err
+	}
+	tsr.SetShape([]int{len(emb)}, nil, nil)
+	for i, v := range emb {
+		tsr.Values[i] = float32(v)
+	}
+	return nil
+}
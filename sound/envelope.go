@@ -0,0 +1,192 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/cmplx"
+)
+
+// EnvelopeParams are the parameters of the hilbert envelopes of the signal, broadband and in frequency bands, for
+// envelope tracking analyses, e.g., correlating the responses of a model with the speech envelope (see LagCorr)
+// as EEG studies do
+type EnvelopeParams struct {
+
+	// compute the envelopes of each step, see SndEnv.EnvSegment
+	On bool `desc:"compute the envelopes of each step, see SndEnv.EnvSegment"`
+
+	// [def: 8] [min: 0] [max: 32] number of frequency bands, logarithmically spaced from LoHz to HiHz, 0 for only the broadband envelope
+	NBands int `default:"8" min:"0" max:"32" desc:"number of frequency bands, logarithmically spaced from LoHz to HiHz, 0 for only the broadband envelope"`
+
+	// [def: 100] [viewif: NBands>0] lower edge of the lowest band in Hz
+	LoHz float64 `viewif:"NBands>0" default:"100" desc:"lower edge of the lowest band in Hz"`
+
+	// [def: 8000] [viewif: NBands>0] upper edge of the highest band in Hz, limited to just below the nyquist frequency
+	HiHz float64 `viewif:"NBands>0" default:"8000" desc:"upper edge of the highest band in Hz, limited to just below the nyquist frequency"`
+
+	// [def: 2] [viewif: NBands>0] number of cascaded biquad sections of the band filters
+	Sections int `viewif:"NBands>0" default:"2" desc:"number of cascaded biquad sections of the band filters"`
+
+	// [def: 30] cutoff of the low-pass filter of the envelopes in Hz, 0 for none -- envelope tracking studies usually keep the modulations below 8 to 30 Hz
+	CutHz float64 `default:"30" desc:"cutoff of the low-pass filter of the envelopes in Hz, 0 for none -- envelope tracking studies usually keep the modulations below 8 to 30 Hz"`
+
+	// [def: 100] milliseconds of signal on either side of a segment included when its envelopes are computed, so the filters have settled within the segment
+	MarginMs float64 `default:"100" desc:"milliseconds of signal on either side of a segment included when its envelopes are computed, so the filters have settled within the segment"`
+}
+
+// Defaults
+func (ep *EnvelopeParams) Defaults() {
+	ep.NBands = 8
+	ep.LoHz = 100
+	ep.HiHz = 8000
+	ep.Sections = 2
+	ep.CutHz = 30
+	ep.MarginMs = 100
+}
+
+// BandEdges returns the NBands + 1 band edges, logarithmically spaced from LoHz to HiHz (limited to .95 of nyquist)
+func (ep *EnvelopeParams) BandEdges(sampleRate int) []float64 {
+	vp := VocoderParams{NBands: ep.NBands, LoHz: ep.LoHz, HiHz: ep.HiHz}
+	return vp.BandEdges(sampleRate)
+}
+
+// Envelope returns the hilbert envelope of the signal, the magnitude of its analytic signal (see Analytic)
+func Envelope(signal []float64) []float64 {
+	an := Analytic(signal)
+	env := make([]float64, len(an))
+	for i, c := range an {
+		env[i] = cmplx.Abs(c)
+	}
+	return env
+}
+
+// Envelopes returns the hilbert envelopes of the signal, low-pass filtered at CutHz without a delay (forward and
+// backward): the broadband envelope first and then the envelope of each of the NBands bands, from low to high
+func Envelopes(signal []float64, sampleRate int, ep *EnvelopeParams) ([][]float64, error) {
+	if ep.NBands < 0 || ep.NBands > 32 {
+		err := fmt.Errorf("sound.Envelopes: NBands %v must be between 0 and 32", ep.NBands)
+		log.Println(err)
+		return nil, err
+	}
+	var edges []float64
+	if ep.NBands > 0 {
+		edges = ep.BandEdges(sampleRate)
+		if edges[0] <= 0 || edges[len(edges)-1] <= edges[0] {
+			err := fmt.Errorf("sound.Envelopes: LoHz %v must be > 0 and below HiHz and the nyquist frequency", ep.LoHz)
+			log.Println(err)
+			return nil, err
+		}
+	}
+	envs := make([][]float64, ep.NBands+1)
+	envs[0] = Envelope(signal)
+	for b := 0; b < ep.NBands; b++ {
+		bf := BandFilter{LoHz: edges[b], HiHz: edges[b+1], Sections: ep.Sections}
+		envs[b+1] = Envelope(bf.Filter(signal, sampleRate))
+	}
+	if ep.CutHz > 0 {
+		lp := NewLowPass(ep.CutHz, 1/math.Sqrt2, sampleRate)
+		for b, env := range envs {
+			envs[b] = filtFilt(&lp, env)
+		}
+	}
+	return envs, nil
+}
+
+// filtFilt returns the signal filtered forward and then backward by the biquad, so without a delay, e.g.,
+// envelopes kept aligned with the responses they are compared with
+func filtFilt(bq *Biquad, signal []float64) []float64 {
+	out := bq.Filter(signal)
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	out = bq.Filter(out)
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// envelopeSegment sets EnvSegment to the average of the envelopes of channel Chan over the window of each step of the
+// segment, the envelopes computed from the samples of the segment and MarginMs on either side
+func (se *SndEnv) envelopeSegment(segment, add int) {
+	sr := se.Sound.SampleRate()
+	prm := &se.Params
+	off := segment*prm.StrideSamples + MSecToSamples(float64(add), sr)
+	sig := se.ChannelSignal(se.Chan)
+	margin := MSecToSamples(se.Envelope.MarginMs, sr)
+	lo := off + prm.Steps[0] - margin
+	hi := off + prm.Steps[prm.SegmentSteps-1] + prm.WinSamples + margin
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > len(sig) {
+		hi = len(sig)
+	}
+	se.EnvSegment.SetZeros()
+	if lo >= hi {
+		return
+	}
+	envs, err := Envelopes(sig[lo:hi], sr, &se.Envelope)
+	if err != nil {
+		return
+	}
+	steps := se.EnvSegment.Dim(1)
+	for b, env := range envs {
+		for s := 0; s < steps && s < prm.SegmentSteps; s++ {
+			st, end := off+prm.Steps[s]-lo, off+prm.Steps[s]+prm.WinSamples-lo
+			if st < 0 {
+				st = 0
+			}
+			if end > len(env) {
+				end = len(env)
+			}
+			if st >= end {
+				continue
+			}
+			sum := 0.0
+			for _, v := range env[st:end] {
+				sum += v
+			}
+			se.EnvSegment.Values[b*steps+s] = sum / float64(end-st)
+		}
+	}
+}
+
+// LagCorr returns the pearson correlation of the response with the envelope (or any two series at the same rate, e.g.,
+// a model response and EnvSegment row over the steps) at each lag from -maxLag to maxLag, index lag + maxLag -- at a
+// positive lag the response follows the envelope, response[t + lag] correlated with env[t], as in envelope tracking
+// studies. Correlations with fewer than 2 overlapping values, or no variance, are 0
+func LagCorr(response, env []float64, maxLag int) []float64 {
+	corrs := make([]float64, 2*maxLag+1)
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		var sx, sy, sxx, syy, sxy float64
+		cnt := 0
+		for t := range env {
+			rt := t + lag
+			if rt < 0 || rt >= len(response) {
+				continue
+			}
+			x, y := response[rt], env[t]
+			sx += x
+			sy += y
+			sxx += x * x
+			syy += y * y
+			sxy += x * y
+			cnt++
+		}
+		if cnt < 2 {
+			continue
+		}
+		c := float64(cnt)
+		vx, vy := sxx-sx*sx/c, syy-sy*sy/c
+		if vx <= 0 || vy <= 0 {
+			continue
+		}
+		corrs[lag+maxLag] = (sxy - sx*sy/c) / math.Sqrt(vx*vy)
+	}
+	return corrs
+}
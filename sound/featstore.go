@@ -0,0 +1,207 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"unsafe"
+
+	"github.com/emer/etable/etensor"
+)
+
+// FeatRecord is the index entry of one feature tensor of a feature store
+type FeatRecord struct {
+
+	// name of the record, e.g., the unit name and source file
+	Name string `desc:"name of the record, e.g., the unit name and source file"`
+
+	// shape of the tensor
+	Shape []int `desc:"shape of the tensor"`
+
+	// offset of the first value in the data file, in values
+	Offset int64 `desc:"offset of the first value in the data file, in values"`
+}
+
+// FeatIndex is the index of a feature store, saved as JSON next to the data file (path + ".json")
+type FeatIndex struct {
+
+	// the records in the order they were written
+	Records []FeatRecord `desc:"the records in the order they were written"`
+}
+
+// FeatWriter writes feature tensors to a feature store: a data file of the values of all the tensors as
+// little-endian float32, one after the other, and a JSON index of their names, shapes and offsets.
+// The store is read with OpenFeatStore, which memory maps the data file, so stores larger than RAM can be used
+type FeatWriter struct {
+	path  string
+	f     *os.File
+	w     *bufio.Writer
+	n     int64
+	index FeatIndex
+	buf   [4]byte
+}
+
+// CreateFeatStore creates the data file at path, and the index at path + ".json" when the writer is closed
+func CreateFeatStore(path string) (*FeatWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &FeatWriter{path: path, f: f, w: bufio.NewWriterSize(f, 1<<20)}, nil
+}
+
+// Write appends the values of the tensor, as float32, and adds its record to the index
+func (fw *FeatWriter) Write(name string, tsr etensor.Tensor) error {
+	shp := append([]int{}, tsr.Shapes()...)
+	fw.index.Records = append(fw.index.Records, FeatRecord{Name: name, Shape: shp, Offset: fw.n})
+	for i := 0; i < tsr.Len(); i++ {
+		binary.LittleEndian.PutUint32(fw.buf[:], math.Float32bits(float32(tsr.FloatVal1D(i))))
+		if _, err := fw.w.Write(fw.buf[:]); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	fw.n += int64(tsr.Len())
+	return nil
+}
+
+// Close flushes and closes the data file and writes the index
+func (fw *FeatWriter) Close() error {
+	if err := fw.w.Flush(); err != nil {
+		log.Println(err)
+		fw.f.Close()
+		return err
+	}
+	if err := fw.f.Close(); err != nil {
+		log.Println(err)
+		return err
+	}
+	b, err := json.Marshal(&fw.index)
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(fw.path+".json", b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// FeatStore is a feature store opened for reading, see FeatWriter. The data file is memory mapped (read into memory
+// on systems without mmap) and the tensors returned by Tensor are views of the mapped values, without copying --
+// they are read only and only valid until Close. The values are read in the native byte order, which must be little-endian
+type FeatStore struct {
+
+	// the index of the records
+	Index FeatIndex `desc:"the index of the records"`
+
+	data   []byte
+	vals   []float32
+	byName map[string]int
+}
+
+// OpenFeatStore opens the feature store written at path by a FeatWriter
+func OpenFeatStore(path string) (*FeatStore, error) {
+	fs := &FeatStore{}
+	b, err := os.ReadFile(path + ".json")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &fs.Index); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	fs.data, err = mmapFile(path)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if len(fs.data) > 0 {
+		fs.vals = unsafe.Slice((*float32)(unsafe.Pointer(&fs.data[0])), len(fs.data)/4)
+	}
+	fs.byName = make(map[string]int, len(fs.Index.Records))
+	for i, r := range fs.Index.Records {
+		nvals := int64(len(fs.vals))
+		bad := r.Offset < 0
+		for _, d := range r.Shape {
+			bad = bad || d < 0
+		}
+		if bad {
+			fs.Close()
+			err := fmt.Errorf("sound.OpenFeatStore: record %v %q has a negative offset or dimension in %v", i, r.Name, path)
+			log.Println(err)
+			return nil, err
+		}
+		n := int64(1)
+		for _, d := range r.Shape {
+			if d == 0 { // no values, only the offset is checked
+				n = 0
+			}
+		}
+		past := r.Offset > nvals
+		for _, d := range r.Shape {
+			if past || n == 0 {
+				break
+			}
+			if n > (nvals-r.Offset)/int64(d) { // checked before the multiply, so it can't overflow
+				past = true
+				break
+			}
+			n *= int64(d)
+		}
+		if past || n > nvals-r.Offset {
+			fs.Close()
+			err := fmt.Errorf("sound.OpenFeatStore: record %v %q is past the end of %v", i, r.Name, path)
+			log.Println(err)
+			return nil, err
+		}
+		if _, has := fs.byName[r.Name]; !has {
+			fs.byName[r.Name] = i
+		}
+	}
+	return fs, nil
+}
+
+// Len returns the number of records
+func (fs *FeatStore) Len() int {
+	return len(fs.Index.Records)
+}
+
+// Find returns the index of the first record with the name
+func (fs *FeatStore) Find(name string) (int, bool) {
+	i, ok := fs.byName[name]
+	return i, ok
+}
+
+// Tensor returns a read only view of the values of record i, without copying, see FeatStore
+func (fs *FeatStore) Tensor(i int) *etensor.Float32 {
+	r := &fs.Index.Records[i]
+	shp := etensor.NewShape(r.Shape, nil, nil)
+	n := int64(shp.Len())
+	return etensor.NewFloat32Shape(shp, fs.vals[r.Offset:r.Offset+n:r.Offset+n])
+}
+
+// Close unmaps the data file, after which the tensors returned by Tensor must not be used
+func (fs *FeatStore) Close() error {
+	data := fs.data
+	fs.data, fs.vals = nil, nil
+	if data == nil {
+		return nil
+	}
+	err := munmapFile(data)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
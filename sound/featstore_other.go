@@ -0,0 +1,22 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package sound
+
+import (
+	"os"
+)
+
+// mmapFile reads the file into memory, on systems without mmap
+func mmapFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+// munmapFile is a no-op on systems without mmap
+func munmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/emer/etable/etensor"
+)
+
+func TestFeatStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feats")
+	fw, err := CreateFeatStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := etensor.NewFloat32([]int{2, 3}, nil, nil)
+	for i := range a.Values {
+		a.Values[i] = float32(i) - 2.5
+	}
+	b := etensor.NewFloat64([]int{4}, nil, nil)
+	b.Values = []float64{10, 11, 12, 13}
+	if err := fw.Write("a", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Write("b", b); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := OpenFeatStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if fs.Len() != 2 {
+		t.Fatalf("Len %v, want 2", fs.Len())
+	}
+	i, ok := fs.Find("b")
+	if !ok || i != 1 {
+		t.Errorf("Find b got %v %v, want 1 true", i, ok)
+	}
+	ta := fs.Tensor(0)
+	if ta.Dim(0) != 2 || ta.Dim(1) != 3 {
+		t.Errorf("record a shape %v, want [2 3]", ta.Shapes())
+	}
+	for i, v := range a.Values {
+		if ta.Values[i] != v {
+			t.Errorf("record a value %v: %v, want %v", i, ta.Values[i], v)
+		}
+	}
+	if tb := fs.Tensor(1); tb.Values[3] != 13 {
+		t.Errorf("record b values %v, want %v", tb.Values, b.Values)
+	}
+}
+
+// TestFeatStoreBadIndex checks that OpenFeatStore rejects the records of an index that don't fit the data file,
+// so Tensor can't go out of range
+func TestFeatStoreBadIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feats")
+	if err := os.WriteFile(path, make([]byte, 40), 0644); err != nil { // 10 values
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		rec FeatRecord
+		ok  bool
+	}{
+		{FeatRecord{Name: "all", Shape: []int{2, 5}}, true},
+		{FeatRecord{Name: "last", Shape: []int{1}, Offset: 9}, true},
+		{FeatRecord{Name: "empty", Shape: []int{4611686018427387904, 0}, Offset: 10}, true},
+		{FeatRecord{Name: "long", Shape: []int{11}}, false},
+		{FeatRecord{Name: "offset", Shape: []int{2}, Offset: 9}, false},
+		{FeatRecord{Name: "empty past", Shape: []int{0}, Offset: 11}, false},
+		{FeatRecord{Name: "negative offset", Shape: []int{1}, Offset: -1}, false},
+		{FeatRecord{Name: "negative dim", Shape: []int{-2, -3}}, false},
+		{FeatRecord{Name: "overflow", Shape: []int{2, 4611686018427387904}}, false},
+		{FeatRecord{Name: "overflow first", Shape: []int{4611686018427387904, 2}}, false},
+		{FeatRecord{Name: "overflow 3", Shape: []int{3037000500, 3037000500, 2}}, false},
+		{FeatRecord{Name: "max offset", Shape: []int{1}, Offset: 9223372036854775807}, false},
+	} {
+		bs, err := json.Marshal(&FeatIndex{Records: []FeatRecord{c.rec}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path+".json", bs, 0644); err != nil {
+			t.Fatal(err)
+		}
+		fs, err := OpenFeatStore(path)
+		if (err == nil) != c.ok {
+			t.Errorf("%v: OpenFeatStore error %v, want ok %v", c.rec.Name, err, c.ok)
+		}
+		if err == nil {
+			if tsr := fs.Tensor(0); tsr.Len() > 10 {
+				t.Errorf("%v: %v values", c.rec.Name, tsr.Len())
+			}
+			fs.Close()
+		}
+	}
+}
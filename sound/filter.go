@@ -0,0 +1,298 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math"
+	"math/cmplx"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// Biquad is a second order IIR filter section, normalized so a0 is 1, using the coefficient formulas of
+// the Audio EQ Cookbook (R. Bristow-Johnson) -- see NewBandPass and NewBandStop
+type Biquad struct {
+	B0, B1, B2 float64
+	A1, A2     float64
+}
+
+// NewBandPass returns a band-pass biquad with 0 dB peak gain at centerHz and bandwidth centerHz / q
+// (the -3 dB points), e.g. q = 1 for a band about 1.4 octaves wide
+func NewBandPass(centerHz, q float64, sampleRate int) Biquad {
+	w0 := 2 * math.Pi * centerHz / float64(sampleRate)
+	alpha := math.Sin(w0) / (2 * q)
+	a0 := 1 + alpha
+	return Biquad{B0: alpha / a0, B1: 0, B2: -alpha / a0, A1: -2 * math.Cos(w0) / a0, A2: (1 - alpha) / a0}
+}
+
+// NewBandStop returns a band-stop (notch) biquad with no output at centerHz and bandwidth centerHz / q (the -3 dB points)
+func NewBandStop(centerHz, q float64, sampleRate int) Biquad {
+	w0 := 2 * math.Pi * centerHz / float64(sampleRate)
+	alpha := math.Sin(w0) / (2 * q)
+	a0 := 1 + alpha
+	return Biquad{B0: 1 / a0, B1: -2 * math.Cos(w0) / a0, B2: 1 / a0, A1: -2 * math.Cos(w0) / a0, A2: (1 - alpha) / a0}
+}
+
+// NewLowPass returns a low-pass biquad with cutoff cutHz, q = 1/sqrt(2) giving a butterworth response
+func NewLowPass(cutHz, q float64, sampleRate int) Biquad {
+	w0 := 2 * math.Pi * cutHz / float64(sampleRate)
+	alpha := math.Sin(w0) / (2 * q)
+	a0 := 1 + alpha
+	cw := math.Cos(w0)
+	return Biquad{B0: (1 - cw) / 2 / a0, B1: (1 - cw) / a0, B2: (1 - cw) / 2 / a0, A1: -2 * cw / a0, A2: (1 - alpha) / a0}
+}
+
+// Filter returns the signal filtered by the biquad, starting from a zero state
+func (bq *Biquad) Filter(signal []float64) []float64 {
+	out := make([]float64, len(signal))
+	var x1, x2, y1, y2 float64
+	for i, x := range signal {
+		y := bq.B0*x + bq.B1*x1 + bq.B2*x2 - bq.A1*y1 - bq.A2*y2
+		x2, x1 = x1, x
+		y2, y1 = y1, y
+		out[i] = y
+	}
+	return out
+}
+
+// BandFilter is a parametric band-pass or band-stop filter made of a cascade of identical biquads,
+// for stimulus manipulation, e.g. notching out the F2 region of speech with LoHz 1000 and HiHz 2500.
+// More sections make the attenuation outside (band-pass) or inside (band-stop) the band deeper
+type BandFilter struct {
+
+	// remove the band (band-stop), otherwise keep only the band (band-pass)
+	Stop bool `desc:"remove the band (band-stop), otherwise keep only the band (band-pass)"`
+
+	// lower edge of the band in Hz
+	LoHz float64 `desc:"lower edge of the band in Hz"`
+
+	// upper edge of the band in Hz
+	HiHz float64 `desc:"upper edge of the band in Hz"`
+
+	// [def: 2] number of cascaded biquad sections
+	Sections int `default:"2" desc:"number of cascaded biquad sections"`
+}
+
+// Defaults
+func (bf *BandFilter) Defaults() {
+	bf.Sections = 2
+}
+
+// Biquad returns the biquad section for the band, centered at the geometric mean of the band edges
+func (bf *BandFilter) Biquad(sampleRate int) Biquad {
+	ctr := math.Sqrt(bf.LoHz * bf.HiHz)
+	q := ctr / (bf.HiHz - bf.LoHz)
+	if bf.Stop {
+		return NewBandStop(ctr, q, sampleRate)
+	}
+	return NewBandPass(ctr, q, sampleRate)
+}
+
+// Filter returns the filtered signal
+func (bf *BandFilter) Filter(signal []float64, sampleRate int) []float64 {
+	bq := bf.Biquad(sampleRate)
+	out := signal
+	sections := bf.Sections
+	if sections < 1 {
+		sections = 1
+	}
+	for s := 0; s < sections; s++ {
+		out = bq.Filter(out)
+	}
+	return out
+}
+
+// FreqShift shifts all the frequencies of the signal up (shiftHz > 0) or down (shiftHz < 0) by shiftHz using
+// single sideband heterodyning, i.e., multiplying the analytic signal (computed with the fft) by a complex
+// exponential and keeping the real part. Unlike pitch shifting this does not keep harmonic relations.
+// Frequencies shifted below 0 or above the nyquist frequency fold over
+func FreqShift(signal []float64, shiftHz float64, sampleRate int) []float64 {
+	an := Analytic(signal)
+	out := make([]float64, len(an))
+	w := 2 * math.Pi * shiftHz / float64(sampleRate)
+	for i, c := range an {
+		out[i] = real(c * cmplx.Exp(complex(0, w*float64(i))))
+	}
+	return out
+}
+
+// Analytic returns the analytic signal of the signal, computed with the fft, whose real part is the signal
+// and imaginary part its hilbert transform, so its magnitude is the envelope and its angle the instantaneous phase
+func Analytic(signal []float64) []complex128 {
+	n := len(signal)
+	if n == 0 {
+		return nil
+	}
+	fft := fourier.NewCmplxFFT(n)
+	coefs := make([]complex128, n)
+	for i, v := range signal {
+		coefs[i] = complex(v, 0)
+	}
+	fft.Coefficients(coefs, coefs)
+	// double the positive frequencies, zero the negative ones
+	for k := 1; k < n; k++ {
+		switch {
+		case k < (n+1)/2:
+			coefs[k] *= 2
+		case n%2 == 0 && k == n/2: // nyquist bin is kept as is
+		default:
+			coefs[k] = 0
+		}
+	}
+	fft.Sequence(coefs, coefs)
+	for i := range coefs {
+		coefs[i] /= complex(float64(n), 0)
+	}
+	return coefs
+}
+
+// FilterSignal applies the band filter to the Signal, e.g. before Init
+func (se *SndEnv) FilterSignal(bf *BandFilter) {
+	copy(se.Signal.Values, bf.Filter(se.Signal.Values, se.Sound.SampleRate()))
+}
+
+// ShiftSignal shifts the frequencies of the Signal by shiftHz, see FreqShift
+func (se *SndEnv) ShiftSignal(shiftHz float64) {
+	copy(se.Signal.Values, FreqShift(se.Signal.Values, shiftHz, se.Sound.SampleRate()))
+}
+
+// FIRFilter returns the signal filtered by the FIR filter taps, i.e., convolved with taps and truncated to the length of
+// the signal. The output is delayed by the group delay of the filter, (len(taps) - 1) / 2 samples for linear phase taps
+func FIRFilter(signal, taps []float64) []float64 {
+	out := make([]float64, len(signal))
+	for i := range out {
+		sum := 0.0
+		for k, t := range taps {
+			if i-k < 0 {
+				break
+			}
+			sum += t * signal[i-k]
+		}
+		out[i] = sum
+	}
+	return out
+}
+
+// EQTypes are the types of equalizer bands
+type EQTypes int32
+
+const (
+	EQPeak      EQTypes = iota // boost or cut around Hz with bandwidth set by Q
+	EQLowShelf                 // boost or cut below Hz
+	EQHighShelf                // boost or cut above Hz
+	EQTilt                     // Db per octave relative to Hz, e.g., -6 dB / octave for a spectral tilt
+)
+
+// EQBand is one band of an EQ
+type EQBand struct {
+	Type EQTypes
+	Hz   float64
+	Db   float64
+	Q    float64
+}
+
+// EQ is a simple parametric equalizer, built by adding bands, that is applied as a linear phase FIR filter
+// so the spectral manipulation of stimuli is exactly reproducible, e.g.
+//
+//	eq := sound.NewEQ(255).Tilt(-3, 1000).Peak(2000, 6, 2)
+//	out := eq.Filter(signal, sampleRate)
+type EQ struct {
+
+	// bands of the equalizer, the gains in dB of all bands are summed
+	Bands []EQBand `desc:"bands of the equalizer, the gains in dB of all bands are summed"`
+
+	// [def: 255] number of FIR taps, made odd -- more taps give a more accurate response at low frequencies
+	NTaps int `default:"255" desc:"number of FIR taps, made odd -- more taps give a more accurate response at low frequencies"`
+}
+
+// NewEQ returns a flat EQ for FIR filters of ntaps
+func NewEQ(ntaps int) *EQ {
+	return &EQ{NTaps: ntaps}
+}
+
+// Peak adds a peaking band of gain db at hz, with bandwidth hz / q
+func (eq *EQ) Peak(hz, db, q float64) *EQ {
+	eq.Bands = append(eq.Bands, EQBand{Type: EQPeak, Hz: hz, Db: db, Q: q})
+	return eq
+}
+
+// LowShelf adds a gain of db below hz
+func (eq *EQ) LowShelf(hz, db float64) *EQ {
+	eq.Bands = append(eq.Bands, EQBand{Type: EQLowShelf, Hz: hz, Db: db})
+	return eq
+}
+
+// HighShelf adds a gain of db above hz
+func (eq *EQ) HighShelf(hz, db float64) *EQ {
+	eq.Bands = append(eq.Bands, EQBand{Type: EQHighShelf, Hz: hz, Db: db})
+	return eq
+}
+
+// Tilt adds a spectral tilt of dbPerOct dB per octave, 0 dB at refHz
+func (eq *EQ) Tilt(dbPerOct, refHz float64) *EQ {
+	eq.Bands = append(eq.Bands, EQBand{Type: EQTilt, Hz: refHz, Db: dbPerOct})
+	return eq
+}
+
+// Db returns the gain in dB of the EQ at frequency hz (> 0)
+func (eq *EQ) Db(hz float64) float64 {
+	db := 0.0
+	for _, b := range eq.Bands {
+		oct := math.Log2(hz / b.Hz)
+		switch b.Type {
+		case EQPeak:
+			bw := 2 * math.Asinh(1/(2*b.Q)) / math.Ln2 // bandwidth in octaves
+			sd := bw / 2.355                           // gaussian with full width at half max of bw
+			db += b.Db * math.Exp(-0.5*oct*oct/(sd*sd))
+		case EQLowShelf:
+			db += b.Db * 0.5 * (1 - math.Tanh(2*oct))
+		case EQHighShelf:
+			db += b.Db * 0.5 * (1 + math.Tanh(2*oct))
+		case EQTilt:
+			db += b.Db * oct
+		}
+	}
+	return db
+}
+
+// Taps returns the linear phase FIR filter taps for the EQ, designed by frequency sampling with a hann window
+func (eq *EQ) Taps(sampleRate int) []float64 {
+	ntaps := eq.NTaps
+	if ntaps < 1 {
+		ntaps = 255
+	}
+	ntaps |= 1 // odd for a whole sample delay
+	nfft := 1
+	for nfft < 4*ntaps {
+		nfft *= 2
+	}
+	fft := fourier.NewFFT(nfft)
+	coefs := make([]complex128, nfft/2+1)
+	for k := range coefs {
+		hz := float64(k) * float64(sampleRate) / float64(nfft)
+		if k == 0 {
+			hz = 0.5 * float64(sampleRate) / float64(nfft) // avoid log of 0 for tilt and shelves
+		}
+		coefs[k] = complex(math.Pow(10, eq.Db(hz)/20), 0)
+	}
+	imp := fft.Sequence(nil, coefs) // zero phase impulse response, centered on sample 0
+	half := ntaps / 2
+	taps := make([]float64, ntaps)
+	for i := range taps {
+		j := (i - half + nfft) % nfft
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i+1)/float64(ntaps+1)) // hann
+		taps[i] = w * imp[j] / float64(nfft)
+	}
+	return taps
+}
+
+// Filter returns the signal filtered by the EQ, compensated for the delay of the filter so the output is aligned with the input
+func (eq *EQ) Filter(signal []float64, sampleRate int) []float64 {
+	taps := eq.Taps(sampleRate)
+	half := len(taps) / 2
+	padded := append(append([]float64{}, signal...), make([]float64, half)...)
+	out := FIRFilter(padded, taps)
+	return out[half:]
+}
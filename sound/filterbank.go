@@ -0,0 +1,67 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+// FilterBanks are the filterbanks that can produce the MelFBankSegment output of a SndEnv
+type FilterBanks int32
+
+const (
+	// MelBank is the mel filterbank of the Mel params
+	MelBank FilterBanks = iota
+
+	// GammatoneBank is the ERB spaced gammatone filterbank of the Gammatone params, producing a cochleagram
+	// in MelFBankSegment, with the same [filters, steps] layout so gabor filtering, mfcc, pooling and splicing work unchanged
+	GammatoneBank
+)
+
+// NFilters returns the number of filters of the filterbank (see Bank), the number of rows of MelFBankSegment
+func (se *SndEnv) NFilters() int {
+	if se.Bank == GammatoneBank {
+		return se.Gammatone.NFilters
+	}
+	return se.Mel.FBank.NFilters
+}
+
+// FilterCenterFreqs returns the center frequency in Hz of each filter of the filterbank (see Bank) -- call after Init
+func (se *SndEnv) FilterCenterFreqs() []float64 {
+	if se.Bank == GammatoneBank {
+		return se.Gammatone.CenterFreqs()
+	}
+	return se.Mel.CenterFreqs()
+}
+
+// initFilterBank initializes the filters of the filterbank (see Bank) for the window size and sample rate
+func (se *SndEnv) initFilterBank() error {
+	if se.Cache != nil {
+		return se.Cache.initFilterBank(se)
+	}
+	return se.buildFilterBank()
+}
+
+// buildFilterBank computes the filters of the filter bank (see Bank) for the window samples and sample rate
+func (se *SndEnv) buildFilterBank() error {
+	if se.Bank == GammatoneBank {
+		return se.Gammatone.InitFilters(se.Params.WinSamples, se.Sound.SampleRate(), &se.MelFilters)
+	}
+	return se.Mel.InitFilters(se.Params.WinSamples, se.Sound.SampleRate(), &se.MelFilters) // call after non-default values are set!
+}
+
+// filterStep applies the filterbank (see Bank) to the window and its dft power, whitened if Whiten is on, into
+// MelFBank and column step of MelFBankSegment
+func (se *SndEnv) filterStep(step int) {
+	pow := &se.Power
+	if se.Whiten.On() {
+		se.whitenStep()
+		pow = &se.PowerWhite
+	}
+	switch {
+	case se.Bank == GammatoneBank && se.Gammatone.TimeDomain:
+		se.Gammatone.FilterWindow(step, &se.Window, &se.MelFBankSegment, &se.MelFBank)
+	case se.Bank == GammatoneBank:
+		se.Gammatone.FilterDft(step, pow, &se.MelFBankSegment, &se.MelFBank, &se.MelFilters)
+	default:
+		se.Mel.FilterDft(step, pow, &se.MelFBankSegment, &se.MelFBank, &se.MelFilters)
+	}
+}
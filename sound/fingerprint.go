@@ -0,0 +1,176 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// Fingerprint is a spectral hash of a sound, one sub-fingerprint per frame whose bits are the signs of the
+// differences in energy between neighboring mel filters, from one frame to the next (Haitsma & Kalker, 2002).
+// The fingerprints of copies of a recording, re-encoded, rescaled or with a little added noise, have few
+// differing bits, see BitErrorRate
+type Fingerprint []uint32
+
+// FingerprintFrames returns the fingerprint of the frames, each a slice of the filters (at most 33 are used),
+// e.g., from UtteranceFrames -- there is one sub-fingerprint per frame after the first
+func FingerprintFrames(frames [][]float64) Fingerprint {
+	if len(frames) < 2 {
+		return nil
+	}
+	nb := len(frames[0]) - 1
+	if nb > 32 {
+		nb = 32
+	}
+	fp := make(Fingerprint, len(frames)-1)
+	for n := 1; n < len(frames); n++ {
+		cur, prv := frames[n], frames[n-1]
+		var sf uint32
+		for m := 0; m < nb; m++ {
+			if (cur[m]-cur[m+1])-(prv[m]-prv[m+1]) > 0 {
+				sf |= 1 << uint(m)
+			}
+		}
+		fp[n-1] = sf
+	}
+	return fp
+}
+
+// Fingerprint returns the fingerprint of the whole Signal, see UtteranceFrames -- the frames are consecutive
+// when Params.StrideMs equals Params.SegmentMs, as by default -- call after Init
+func (se *SndEnv) Fingerprint() Fingerprint {
+	return FingerprintFrames(se.UtteranceFrames())
+}
+
+// BitErrorRate returns the proportion of differing bits of the sub-fingerprints of a and b that overlap
+// with b shifted later by shift frames (a[i] is compared with b[i-shift]), and the number of overlapping
+// frames -- 1 if they don't overlap
+func BitErrorRate(a, b Fingerprint, shift int) (ber float64, n int) {
+	st := 0
+	if shift > 0 {
+		st = shift
+	}
+	diff := 0
+	for i := st; i < len(a) && i-shift < len(b); i++ {
+		diff += bits.OnesCount32(a[i] ^ b[i-shift])
+		n++
+	}
+	if n == 0 {
+		return 1, 0
+	}
+	return float64(diff) / float64(32*n), n
+}
+
+// DupPair is a pair of fingerprints found to be duplicates by FingerprintIndex.Duplicates
+type DupPair struct {
+
+	// index of the first fingerprint, the lower one
+	A int `desc:"index of the first fingerprint, the lower one"`
+
+	// index of the second fingerprint
+	B int `desc:"index of the second fingerprint"`
+
+	// frames B is shifted later relative to A, see BitErrorRate
+	Shift int `desc:"frames B is shifted later relative to A, see BitErrorRate"`
+
+	// number of identical sub-fingerprints at Shift
+	Hits int `desc:"number of identical sub-fingerprints at Shift"`
+
+	// bit error rate of the overlapping frames at Shift
+	BER float64 `desc:"bit error rate of the overlapping frames at Shift"`
+
+	// number of overlapping frames at Shift
+	Overlap int `desc:"number of overlapping frames at Shift"`
+}
+
+// fpLoc is a frame of a fingerprint in a FingerprintIndex
+type fpLoc struct {
+	fp, frame int
+}
+
+// FingerprintIndex finds the duplicate and near-duplicate sounds among many by their fingerprints: candidate pairs
+// share identical sub-fingerprints at a consistent shift, and are confirmed by the bit error rate at that shift
+type FingerprintIndex struct {
+
+	// the fingerprints, in order of Add
+	Prints []Fingerprint `desc:"the fingerprints, in order of Add"`
+
+	// [def: 200] sub-fingerprints shared by more fingerprints than this are too common to find candidates (e.g., from silence) and are skipped
+	MaxBucket int `default:"200" desc:"sub-fingerprints shared by more fingerprints than this are too common to find candidates (e.g., from silence) and are skipped"`
+
+	// the frames of each sub-fingerprint
+	index map[uint32][]fpLoc
+}
+
+// Add adds the fingerprint to the index and returns its index
+func (fi *FingerprintIndex) Add(fp Fingerprint) int {
+	if fi.index == nil {
+		fi.index = map[uint32][]fpLoc{}
+	}
+	if fi.MaxBucket == 0 {
+		fi.MaxBucket = 200
+	}
+	idx := len(fi.Prints)
+	fi.Prints = append(fi.Prints, fp)
+	for f, sf := range fp {
+		if sf == 0 || sf == ^uint32(0) { // no change, e.g., digital silence
+			continue
+		}
+		fi.index[sf] = append(fi.index[sf], fpLoc{idx, f})
+	}
+	return idx
+}
+
+// Duplicates returns the pairs of fingerprints with at least minHits identical sub-fingerprints at the same shift
+// and a bit error rate at that shift of at most maxBER (e.g., 0.35 for near-duplicates, 0.05 for copies), by increasing bit error rate
+func (fi *FingerprintIndex) Duplicates(minHits int, maxBER float64) []DupPair {
+	type pairShift struct {
+		a, b, shift int
+	}
+	hits := map[pairShift]int{}
+	for _, locs := range fi.index {
+		if len(locs) > fi.MaxBucket {
+			continue
+		}
+		for i, la := range locs {
+			for _, lb := range locs[i+1:] {
+				if la.fp == lb.fp {
+					continue
+				}
+				a, b := la, lb
+				if b.fp < a.fp {
+					a, b = b, a
+				}
+				hits[pairShift{a.fp, b.fp, a.frame - b.frame}]++
+			}
+		}
+	}
+	best := map[[2]int]DupPair{}
+	for ps, h := range hits {
+		key := [2]int{ps.a, ps.b}
+		if bp, ok := best[key]; h < minHits || (ok && bp.Hits >= h) {
+			continue
+		}
+		best[key] = DupPair{A: ps.a, B: ps.b, Shift: ps.shift, Hits: h}
+	}
+	var dups []DupPair
+	for _, dp := range best {
+		dp.BER, dp.Overlap = BitErrorRate(fi.Prints[dp.A], fi.Prints[dp.B], dp.Shift)
+		if dp.BER <= maxBER {
+			dups = append(dups, dp)
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		if dups[i].BER != dups[j].BER {
+			return dups[i].BER < dups[j].BER
+		}
+		if dups[i].A != dups[j].A {
+			return dups[i].A < dups[j].A
+		}
+		return dups[i].B < dups[j].B
+	})
+	return dups
+}
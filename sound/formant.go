@@ -0,0 +1,228 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+
+	"github.com/emer/etable/etensor"
+	"gonum.org/v1/gonum/mat"
+)
+
+// FormantParams are the parameters of the LPC formant tracker, see Formants
+type FormantParams struct {
+
+	// [def: 0] order of the linear prediction, 0 for the usual 2 + sample rate in kHz
+	Order int `default:"0" desc:"order of the linear prediction, 0 for the usual 2 + sample rate in kHz"`
+
+	// [def: 10] step between frames in milliseconds
+	StepMs float64 `default:"10" desc:"step between frames in milliseconds"`
+
+	// [def: 25] length of the hamming window of each frame in milliseconds
+	WinMs float64 `default:"25" desc:"length of the hamming window of each frame in milliseconds"`
+
+	// [def: 0.97] pre-emphasis coefficient, flattening the spectral tilt of voiced speech before the analysis
+	PreEmph float64 `default:"0.97" desc:"pre-emphasis coefficient, flattening the spectral tilt of voiced speech before the analysis"`
+
+	// [def: 4] number of formants to track
+	NFormants int `default:"4" desc:"number of formants to track"`
+
+	// [def: 90] lowest formant frequency in Hz, lower poles are ignored
+	MinHz float64 `default:"90" desc:"lowest formant frequency in Hz, lower poles are ignored"`
+
+	// [def: 400] largest formant bandwidth in Hz, broader poles are ignored
+	MaxBwHz float64 `default:"400" desc:"largest formant bandwidth in Hz, broader poles are ignored"`
+
+	// [def: 0.001] frames with an rms below this are silent, their formant amplitudes are 0 and frequencies held from the previous frame
+	SilenceRms float64 `default:"0.001" desc:"frames with an rms below this are silent, their formant amplitudes are 0 and frequencies held from the previous frame"`
+}
+
+// Defaults
+func (fp *FormantParams) Defaults() {
+	fp.Order = 0
+	fp.StepMs = 10
+	fp.WinMs = 25
+	fp.PreEmph = 0.97
+	fp.NFormants = 4
+	fp.MinHz = 90
+	fp.MaxBwHz = 400
+	fp.SilenceRms = 0.001
+}
+
+// FormantTrack holds the formant frequencies and amplitudes of each frame of a signal,
+// frame i being centered at i * StepMs milliseconds
+type FormantTrack struct {
+
+	// step between frames in milliseconds
+	StepMs float64 `desc:"step between frames in milliseconds"`
+
+	// [view: no-inline] formant frequencies in Hz, shape [frames, formants]
+	Hz etensor.Float64 `view:"no-inline" desc:"formant frequencies in Hz, shape [frames, formants]"`
+
+	// [view: no-inline] formant amplitudes, shape [frames, formants] -- the sum of the power of the formants is the power of the frame
+	Amp etensor.Float64 `view:"no-inline" desc:"formant amplitudes, shape [frames, formants] -- the sum of the power of the formants is the power of the frame"`
+}
+
+// NFrames returns the number of frames of the track
+func (ft *FormantTrack) NFrames() int {
+	if ft.Hz.NumDims() == 0 {
+		return 0
+	}
+	return ft.Hz.Dim(0)
+}
+
+// LPC returns the linear prediction coefficients a[0..order] (a[0] = 1) of the frame, using the autocorrelation
+// method and the Levinson-Durbin recursion, and the prediction error power
+func LPC(frame []float64, order int) (a []float64, errPow float64) {
+	r := make([]float64, order+1)
+	for lag := range r {
+		for i := lag; i < len(frame); i++ {
+			r[lag] += frame[i] * frame[i-lag]
+		}
+	}
+	a = make([]float64, order+1)
+	a[0] = 1
+	errPow = r[0]
+	if errPow <= 0 {
+		return a, 0
+	}
+	tmp := make([]float64, order+1)
+	for i := 1; i <= order; i++ {
+		acc := r[i]
+		for j := 1; j < i; j++ {
+			acc += a[j] * r[i-j]
+		}
+		k := -acc / errPow
+		copy(tmp, a)
+		for j := 1; j < i; j++ {
+			a[j] = tmp[j] + k*tmp[i-j]
+		}
+		a[i] = k
+		errPow *= 1 - k*k
+		if errPow <= 0 {
+			break
+		}
+	}
+	return a, errPow
+}
+
+// lpcRoots returns the roots of the prediction polynomial a, as the eigenvalues of its companion matrix
+func lpcRoots(a []float64) []complex128 {
+	n := len(a) - 1
+	if n < 1 {
+		return nil
+	}
+	cm := mat.NewDense(n, n, nil)
+	for j := 0; j < n; j++ {
+		cm.Set(0, j, -a[j+1])
+	}
+	for i := 1; i < n; i++ {
+		cm.Set(i, i-1, 1)
+	}
+	var eig mat.Eigen
+	if !eig.Factorize(cm, mat.EigenNone) {
+		return nil
+	}
+	return eig.Values(nil)
+}
+
+// Formants tracks the formants of the signal by LPC analysis of each frame, taking the frequencies of the
+// narrowest poles above MinHz as the formants, in increasing order. Formants not found in a frame are held from
+// the previous frame with amplitude 0. The amplitudes are the LPC envelope at each formant, scaled so their power
+// adds up to the power of the frame
+func Formants(signal []float64, sampleRate int, fp *FormantParams) *FormantTrack {
+	sr := float64(sampleRate)
+	order := fp.Order
+	if order <= 0 {
+		order = 2 + sampleRate/1000
+	}
+	nf := fp.NFormants
+	step := MSecToSamples(fp.StepMs, sampleRate)
+	win := MSecToSamples(fp.WinMs, sampleRate)
+	nfr := 0
+	if step > 0 {
+		nfr = len(signal)/step + 1
+	}
+	ft := &FormantTrack{StepMs: fp.StepMs}
+	ft.Hz.SetShape([]int{nfr, nf}, nil, []string{"frame", "formant"})
+	ft.Amp.SetShape([]int{nfr, nf}, nil, []string{"frame", "formant"})
+
+	emph := make([]float64, len(signal))
+	for i, v := range signal {
+		emph[i] = v
+		if i > 0 {
+			emph[i] -= fp.PreEmph * signal[i-1]
+		}
+	}
+	frame := make([]float64, win)
+	raw := make([]float64, win)
+	prev := make([]float64, nf)
+	for f := 0; f < nfr; f++ {
+		st := f*step - win/2 // centered on the frame time
+		pow := 0.0
+		for i := range frame {
+			frame[i], raw[i] = 0, 0
+			if j := st + i; j >= 0 && j < len(signal) {
+				w := 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(win-1))
+				frame[i] = w * emph[j]
+				raw[i] = signal[j]
+				pow += raw[i] * raw[i]
+			}
+		}
+		pow /= float64(win)
+		hz := append([]float64{}, prev...)
+		amp := make([]float64, nf)
+		if math.Sqrt(pow) >= fp.SilenceRms {
+			a, _ := LPC(frame, order)
+			var cands []float64
+			for _, r := range lpcRoots(a) {
+				if imag(r) <= 0 {
+					continue
+				}
+				fhz := cmplx.Phase(r) * sr / (2 * math.Pi)
+				bw := -math.Log(cmplx.Abs(r)) * sr / math.Pi
+				if fhz >= fp.MinHz && bw <= fp.MaxBwHz && fhz < sr/2 {
+					cands = append(cands, fhz)
+				}
+			}
+			sort.Float64s(cands)
+			sum := 0.0
+			for i := 0; i < nf && i < len(cands); i++ {
+				hz[i] = cands[i]
+				amp[i] = lpcEnvelope(a, cands[i]/sr)
+				// undo the pre-emphasis so the amplitudes follow the spectrum of the signal
+				amp[i] /= cmplx.Abs(1 - complex(fp.PreEmph, 0)*cmplx.Exp(complex(0, -2*math.Pi*cands[i]/sr)))
+				sum += amp[i] * amp[i]
+			}
+			if sum > 0 {
+				g := math.Sqrt(2 * pow / sum) // a sinusoid of amplitude a has power a^2 / 2
+				for i := range amp {
+					amp[i] *= g
+				}
+			}
+		}
+		for i := 0; i < nf; i++ {
+			ft.Hz.Set([]int{f, i}, hz[i])
+			ft.Amp.Set([]int{f, i}, amp[i])
+		}
+		copy(prev, hz)
+	}
+	return ft
+}
+
+// lpcEnvelope returns the magnitude of the LPC envelope 1 / |A| at the normalized frequency (cycles per sample)
+func lpcEnvelope(a []float64, freq float64) float64 {
+	var s complex128
+	for k, v := range a {
+		s += complex(v, 0) * cmplx.Exp(complex(0, -2*math.Pi*freq*float64(k)))
+	}
+	m := cmplx.Abs(s)
+	if m == 0 {
+		return 0
+	}
+	return 1 / m
+}
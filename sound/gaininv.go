@@ -0,0 +1,119 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"errors"
+	"log"
+	"math"
+
+	"github.com/emer/auditory/agabor"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// GainInvariance processes the current Signal at each of the gains in dB (e.g. -20, -10, 0, 10, 20) and reports,
+// for each feature (mel, mfcc if on and, if there are active gabor specs, gabor -- post kwta if Kwta is on), how much the feature
+// values change with the gain: VarGain is the variance across gains of each value, averaged over all values of all
+// segments, VarFeature is the variance of the values themselves, and Ratio is VarGain / VarFeature --
+// 0 for features that are perfectly level-invariant, around 1 or more for features dominated by the level.
+// Call after ToTensor, with the params set as for Init. The Signal is restored afterwards
+func (se *SndEnv) GainInvariance(gainsDb []float64) (*etable.Table, error) {
+	if len(gainsDb) < 2 {
+		err := errors.New("sound.GainInvariance: at least 2 gains are needed")
+		log.Println(err)
+		return nil, err
+	}
+	orig := append([]float64{}, se.Signal.Values...)
+	defer func() {
+		se.Signal.SetShape([]int{len(orig)}, nil, nil)
+		copy(se.Signal.Values, orig)
+	}()
+
+	feats := []string{"mel"}
+	if se.Mel.MFCC {
+		feats = append(feats, "mfcc")
+	}
+	if len(agabor.Active(se.GaborSpecs)) > 0 {
+		feats = append(feats, "gabor")
+	}
+	vals := make([][][]float64, len(feats)) // [feature][gain][value]
+	for f := range vals {
+		vals[f] = make([][]float64, len(gainsDb))
+	}
+	for g, db := range gainsDb {
+		se.Signal.SetShape([]int{len(orig)}, nil, nil)
+		gain := math.Pow(10, db/20)
+		for i, v := range orig {
+			se.Signal.Values[i] = gain * v
+		}
+		if err := se.Init(); err != nil {
+			return nil, err
+		}
+		for s := 0; s < se.SegCnt; s++ {
+			se.ProcessSegment(s, 0)
+			for f, ft := range feats {
+				var tsr etensor.Tensor
+				switch ft {
+				case "mel":
+					tsr = &se.MelFBankSegment
+				case "mfcc":
+					tsr = &se.MFCCSegment
+				case "gabor":
+					tsr = se.ApplyGabor()
+				}
+				for i := 0; i < tsr.Len(); i++ {
+					vals[f][g] = append(vals[f][g], tsr.FloatVal1D(i))
+				}
+			}
+		}
+	}
+
+	dt := &etable.Table{}
+	dt.SetMetaData("name", "GainInvariance")
+	dt.SetMetaData("desc", "variance of the features across gains relative to the variance of the features")
+	sch := etable.Schema{
+		{"Feature", etensor.STRING, nil, nil},
+		{"VarGain", etensor.FLOAT64, nil, nil},
+		{"VarFeature", etensor.FLOAT64, nil, nil},
+		{"Ratio", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, len(feats))
+	ng := float64(len(gainsDb))
+	for f, ft := range feats {
+		n := len(vals[f][0])
+		varGain := 0.0
+		sum, ss := 0.0, 0.0
+		for i := 0; i < n; i++ {
+			m := 0.0
+			for g := range gainsDb {
+				m += vals[f][g][i]
+			}
+			m /= ng
+			for g := range gainsDb {
+				d := vals[f][g][i] - m
+				varGain += d * d
+				sum += vals[f][g][i]
+				ss += vals[f][g][i] * vals[f][g][i]
+			}
+		}
+		cnt := float64(n) * ng
+		varFeat := 0.0
+		if n > 0 {
+			varGain /= cnt
+			mean := sum / cnt
+			varFeat = ss/cnt - mean*mean
+		}
+		ratio := 0.0
+		if varFeat > 0 {
+			ratio = varGain / varFeat
+		}
+		dt.SetCellString("Feature", f, ft)
+		dt.SetCellFloat("VarGain", f, varGain)
+		dt.SetCellFloat("VarFeature", f, varFeat)
+		dt.SetCellFloat("Ratio", f, ratio)
+	}
+	return dt, nil
+}
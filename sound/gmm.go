@@ -0,0 +1,151 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+)
+
+// GMM is a gaussian mixture model with diagonal covariances, e.g., a universal background model of the mel frames
+// of many speakers for GMMEmbedder
+type GMM struct {
+
+	// the weight of each component
+	Weights []float64 `desc:"the weight of each component"`
+
+	// the mean of each component, one slice of the features per component
+	Means [][]float64 `desc:"the mean of each component, one slice of the features per component"`
+
+	// the variance of each feature of each component
+	Vars [][]float64 `desc:"the variance of each feature of each component"`
+}
+
+// gmmVarFloor is the smallest variance, as a proportion of the variance of all the frames
+const gmmVarFloor = 0.01
+
+// TrainGMM fits a GMM of k components to the frames (each a slice of the features) by iters iterations of
+// expectation maximization, starting with the means at k frames chosen with rnd and the variances of all the frames
+func TrainGMM(frames [][]float64, k, iters int, rnd *rand.Rand) (*GMM, error) {
+	if len(frames) < k || k < 1 {
+		err := errors.New("sound.TrainGMM: fewer frames than components")
+		log.Println(err)
+		return nil, err
+	}
+	d := len(frames[0])
+	mean, vr := make([]float64, d), make([]float64, d)
+	for _, f := range frames {
+		for j, v := range f {
+			mean[j] += v
+		}
+	}
+	for j := range mean {
+		mean[j] /= float64(len(frames))
+	}
+	for _, f := range frames {
+		for j, v := range f {
+			vr[j] += (v - mean[j]) * (v - mean[j])
+		}
+	}
+	floor := make([]float64, d)
+	for j := range vr {
+		vr[j] = math.Max(vr[j]/float64(len(frames)), 1e-6)
+		floor[j] = gmmVarFloor * vr[j]
+	}
+	g := &GMM{Weights: make([]float64, k), Means: make([][]float64, k), Vars: make([][]float64, k)}
+	for c, fi := range rnd.Perm(len(frames))[:k] {
+		g.Weights[c] = 1 / float64(k)
+		g.Means[c] = append([]float64{}, frames[fi]...)
+		g.Vars[c] = append([]float64{}, vr...)
+	}
+	post := make([]float64, k)
+	for it := 0; it < iters; it++ {
+		n := make([]float64, k)
+		sx := make([][]float64, k)
+		sxx := make([][]float64, k)
+		for c := range sx {
+			sx[c], sxx[c] = make([]float64, d), make([]float64, d)
+		}
+		for _, f := range frames {
+			g.Posteriors(f, post)
+			for c, p := range post {
+				n[c] += p
+				for j, v := range f {
+					sx[c][j] += p * v
+					sxx[c][j] += p * v * v
+				}
+			}
+		}
+		for c := 0; c < k; c++ {
+			if n[c] < 1e-6 { // dead component, keep its parameters
+				continue
+			}
+			g.Weights[c] = n[c] / float64(len(frames))
+			for j := 0; j < d; j++ {
+				m := sx[c][j] / n[c]
+				g.Means[c][j] = m
+				g.Vars[c][j] = math.Max(sxx[c][j]/n[c]-m*m, floor[j])
+			}
+		}
+	}
+	return g, nil
+}
+
+// logGauss returns the log likelihood of the frame under component c, without the weight
+func (g *GMM) logGauss(c int, f []float64) float64 {
+	ll := 0.0
+	for j, v := range f {
+		d := v - g.Means[c][j]
+		ll -= 0.5 * (math.Log(2*math.Pi*g.Vars[c][j]) + d*d/g.Vars[c][j])
+	}
+	return ll
+}
+
+// Posteriors sets post to the posterior probability of each component for the frame and returns the log likelihood of the frame
+func (g *GMM) Posteriors(f []float64, post []float64) float64 {
+	mx := math.Inf(-1)
+	for c := range g.Weights {
+		post[c] = math.Log(g.Weights[c]+1e-300) + g.logGauss(c, f)
+		mx = math.Max(mx, post[c])
+	}
+	sum := 0.0
+	for c := range post {
+		post[c] = math.Exp(post[c] - mx)
+		sum += post[c]
+	}
+	for c := range post {
+		post[c] /= sum
+	}
+	return mx + math.Log(sum)
+}
+
+// OpenJSON opens the GMM from a JSON-formatted file
+func (g *GMM) OpenJSON(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	*g = GMM{}
+	return json.Unmarshal(b, g)
+}
+
+// SaveJSON saves the GMM to a JSON-formatted file
+func (g *GMM) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math"
+
+	"github.com/emer/etable/etensor"
+)
+
+// HabitParams are the params of the habituation of the gabor output, a simple model of the repetition suppression
+// (habituation) of auditory neurons, e.g., for oddball and mismatch negativity simulations. Each unit of GborOutput has
+// a resource r, from 0 to 1, that scales its output and is depleted by the unit's activity and recovers without it:
+// dr/dt = (1 - r) / TauRecMs - |x| r / TauHabMs, integrated exactly over each segment's StrideMs for the segment's output x.
+// A unit driven again and again is suppressed, while a unit driven rarely, e.g., by the deviant sound, is not. The
+// resources carry over from segment to segment and from sound to sound, until ResetHabit
+type HabitParams struct {
+
+	// apply the habituation to the gabor output, before the neighborhood inhibition and kwta
+	On bool `desc:"apply the habituation to the gabor output, before the neighborhood inhibition and kwta"`
+
+	// [def: 300] [viewif: On] time constant in milliseconds of the depletion of the resource of a unit with an output of 1 -- larger outputs deplete it faster
+	TauHabMs float64 `viewif:"On" default:"300" desc:"time constant in milliseconds of the depletion of the resource of a unit with an output of 1 -- larger outputs deplete it faster"`
+
+	// [def: 2000] [viewif: On] time constant in milliseconds of the recovery of the resource
+	TauRecMs float64 `viewif:"On" default:"2000" desc:"time constant in milliseconds of the recovery of the resource"`
+}
+
+// Defaults
+func (hp *HabitParams) Defaults() {
+	hp.On = false
+	hp.TauHabMs = 300
+	hp.TauRecMs = 2000
+}
+
+// Habituate scales the outputs by their resources, one per output, then updates the resources for dtMs of the outputs
+func (hp *HabitParams) Habituate(out, res []float32, dtMs float64) {
+	rec := 0.0
+	if hp.TauRecMs > 0 {
+		rec = 1 / hp.TauRecMs
+	}
+	hab := 0.0
+	if hp.TauHabMs > 0 {
+		hab = 1 / hp.TauHabMs
+	}
+	for i, x := range out {
+		r := float64(res[i])
+		out[i] = x * float32(r)
+		k := rec + math.Abs(float64(x))*hab
+		if k <= 0 {
+			continue
+		}
+		eq := rec / k // the resource the unit settles at with this output
+		res[i] = float32(eq + (r-eq)*math.Exp(-k*dtMs))
+	}
+}
+
+// ResetHabit sets the resources of the habituation of the gabor output (see HabitParams) back to full, e.g., at the
+// start of a new sequence of sounds
+func (se *SndEnv) ResetHabit() {
+	se.HabitRes.SetShape([]int{0}, nil, nil)
+}
+
+// applyHabit habituates the raw gabor output, of channel ch for ChannelAll, see HabitParams
+func (se *SndEnv) applyHabit(raw *etensor.Float32, ch int) {
+	if !se.HabitRes.Shape.IsEqual(&se.GborOutput.Shape) { // full resources for a new output shape
+		se.HabitRes.CopyShapeFrom(&se.GborOutput)
+		for i := range se.HabitRes.Values {
+			se.HabitRes.Values[i] = 1
+		}
+	}
+	res := se.HabitRes.Values
+	if raw != &se.GborOutput {
+		res = res[ch*raw.Len() : (ch+1)*raw.Len()]
+	}
+	se.Habit.Habituate(raw.Values, res, se.Params.StrideMs)
+}
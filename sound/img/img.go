@@ -0,0 +1,153 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package img renders tensors of sound.SndEnv, e.g., spectrograms and gabor outputs, to images with the color maps of
+the gui tensor grids, and saves them as PNG files. It is a package of its own so the sound package doesn't import
+the GoGi color maps
+*/
+package img
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"math"
+	"os"
+
+	"github.com/emer/auditory/sound"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/colormap"
+)
+
+// Params are the params of rendering a tensor to an image, see TensorToImage
+type Params struct {
+
+	// the kind of the tensor, which sets the default color map and display range, see sound.ConfigureForDisplay
+	Kind sound.DisplayKinds `desc:"the kind of the tensor, which sets the default color map and display range, see sound.ConfigureForDisplay"`
+
+	// name of the color map (see colormap.AvailMaps), e.g., Viridis, ColdHot, Jet or DarkLight for grey -- empty for the color map of the Kind
+	ColorMap string `desc:"name of the color map (see colormap.AvailMaps), e.g., Viridis, ColdHot, Jet or DarkLight for grey -- empty for the color map of the Kind"`
+
+	// the values are power, e.g., PowerSegment, shown in dB, 10 log10(power) -- with the range the top DbRange dB, unless FixRange
+	Db bool `desc:"the values are power, e.g., PowerSegment, shown in dB, 10 log10(power) -- with the range the top DbRange dB, unless FixRange"`
+
+	// [def: 60] [viewif: Db] dynamic range in dB of Db images -- values more than this below the maximum are shown at the minimum
+	DbRange float64 `viewif:"Db" default:"60" desc:"dynamic range in dB of Db images -- values more than this below the maximum are shown at the minimum"`
+
+	// use the fixed range Min to Max, in dB for Db, rather than the range of the Kind for the values
+	FixRange bool `desc:"use the fixed range Min to Max, in dB for Db, rather than the range of the Kind for the values"`
+
+	// [viewif: FixRange] the value shown at the bottom of the color map
+	Min float64 `viewif:"FixRange" desc:"the value shown at the bottom of the color map"`
+
+	// [viewif: FixRange] the value shown at the top of the color map
+	Max float64 `viewif:"FixRange" desc:"the value shown at the top of the color map"`
+
+	// row 0 is at the top of the image, rather than the bottom as in the gui tensor grids -- e.g., for spectrograms, false puts the low frequencies at the bottom
+	TopZero bool `desc:"row 0 is at the top of the image, rather than the bottom as in the gui tensor grids -- e.g., for spectrograms, false puts the low frequencies at the bottom"`
+
+	// [def: 4] [min: 1] size in pixels of the square of each value
+	Scale int `default:"4" min:"1" desc:"size in pixels of the square of each value"`
+}
+
+// Defaults initializes the image params, for sound.DisplayLevel tensors, e.g., mel spectrograms -- set Kind after for others
+func (ip *Params) Defaults() {
+	ip.Kind = sound.DisplayLevel
+	ip.ColorMap = ""
+	ip.Db = false
+	ip.DbRange = 60
+	ip.FixRange = false
+	ip.TopZero = false
+	ip.Scale = 4
+}
+
+// TensorToImage renders the tensor to an image, each value a Scale x Scale square of the color of the color map for
+// its position in the display range, e.g., mel spectrograms [filters, steps] (sound.DisplayLevel), log power
+// spectrograms (sound.DisplayLogPower, or sound.DisplayLevel with Db for PowerSegment) and gabor outputs
+// (sound.DisplaySigned). Tensors of more than 2 dimensions are laid out as by the gui tensor grids (see
+// etensor.Prjn2DShape), e.g., the pools of gabor outputs [poolsY, poolsX, unitsY, unitsX] side by side, and the
+// segments of the Outputs of sound.SndEnv.ProcessSegments one above the other. Values that are NaN are shown in the
+// no color of the color map
+func TensorToImage(tsr etensor.Tensor, ip *Params) (*image.RGBA, error) {
+	cmn := ip.ColorMap
+	if cmn == "" {
+		cmn = ip.Kind.ColorMap()
+	}
+	cm, ok := colormap.AvailMaps[cmn]
+	if !ok {
+		err := fmt.Errorf("img.TensorToImage: color map %q not found, see colormap.AvailMaps", cmn)
+		log.Println(err)
+		return nil, err
+	}
+	if ip.Db {
+		db := etensor.NewFloat64(tsr.Shapes(), nil, nil)
+		for i := range db.Values {
+			db.Values[i] = 10 * math.Log10(math.Max(tsr.FloatVal1D(i), 1e-20))
+		}
+		tsr = db
+	}
+	min, max := ip.Min, ip.Max
+	if !ip.FixRange {
+		ok := true
+		if ip.Db {
+			max = math.Inf(-1)
+			for i := 0; i < tsr.Len(); i++ {
+				max = math.Max(max, tsr.FloatVal1D(i))
+			}
+			min = max - ip.DbRange
+		} else {
+			min, max, ok = sound.DisplayRange(tsr, ip.Kind)
+		}
+		if !ok { // all 0
+			min, max = 0, 1
+		}
+	}
+	if max <= min {
+		max = min + 1
+	}
+	sc := ip.Scale
+	if sc < 1 {
+		sc = 1
+	}
+	rows, cols, _, _ := etensor.Prjn2DShape(tsr.ShapeObj(), true)
+	img := image.NewRGBA(image.Rect(0, 0, cols*sc, rows*sc))
+	for r := 0; r < rows; r++ {
+		y := r
+		if !ip.TopZero {
+			y = rows - 1 - r
+		}
+		for c := 0; c < cols; c++ {
+			clr := cm.Map((etensor.Prjn2DVal(tsr, true, r, c) - min) / (max - min))
+			for py := y * sc; py < (y+1)*sc; py++ {
+				for px := c * sc; px < (c+1)*sc; px++ {
+					img.Set(px, py, clr)
+				}
+			}
+		}
+	}
+	return img, nil
+}
+
+// SaveTensorPNG renders the tensor to an image (see TensorToImage) and saves it to the file in the PNG format
+func SaveTensorPNG(tsr etensor.Tensor, ip *Params, filename string) error {
+	img, err := TensorToImage(tsr, ip)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
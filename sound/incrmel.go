@@ -0,0 +1,180 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"errors"
+
+	"github.com/emer/auditory/dft"
+	"github.com/emer/auditory/mel"
+	"github.com/emer/etable/etensor"
+)
+
+// IncrMel computes the power, mel filter bank and MFCC outputs incrementally, one step at a time
+// as new samples arrive (e.g. from a microphone), for interactive displays of live features.
+// Each step only processes one window of samples. The outputs for the most recent NSteps steps are
+// kept in ring buffers, column Head being the most recent step -- use Ordered to get them in time order.
+type IncrMel struct {
+
+	// [def: 25] input window -- number of milliseconds worth of sound to filter at a time
+	WinMs float64 `default:"25" desc:"input window -- number of milliseconds worth of sound to filter at a time"`
+
+	// [def: 10] input step -- number of milliseconds worth of sound that the input is stepped along to obtain the next window sample
+	StepMs float64 `default:"10" desc:"input step -- number of milliseconds worth of sound that the input is stepped along to obtain the next window sample"`
+
+	// [def: 100] number of steps of output to keep in the ring buffers
+	NSteps int `default:"100" desc:"number of steps of output to keep in the ring buffers"`
+
+	// sample rate of the incoming sound
+	SampleRate int `inactive:"+" desc:"sample rate of the incoming sound"`
+
+	// number of samples to process each step
+	WinSamples int `inactive:"+" desc:"number of samples to process each step"`
+
+	// number of samples to step input by
+	StepSamples int `inactive:"+" desc:"number of samples to step input by"`
+
+	DFT dft.Params
+
+	// [view: no-inline]
+	Mel mel.Params `view:"no-inline"`
+
+	// column of the ring buffers holding the most recent step, -1 if no step processed yet
+	Head int `inactive:"+" desc:"column of the ring buffers holding the most recent step, -1 if no step processed yet"`
+
+	// total number of steps processed since Init
+	NProcessed int `inactive:"+" desc:"total number of steps processed since Init"`
+
+	// [view: -] samples received but not yet fully processed -- always less than WinSamples after Push
+	Pending []float64 `view:"-" desc:"samples received but not yet fully processed -- always less than WinSamples after Push"`
+
+	// [view: -] the window of samples currently being processed
+	Window etensor.Float64 `view:"-" desc:"the window of samples currently being processed"`
+
+	// [view: -] power of the dft for the current step
+	Power etensor.Float64 `view:"-" desc:"power of the dft for the current step"`
+
+	// [view: -] log power of the dft for the current step
+	LogPower etensor.Float64 `view:"-" desc:"log power of the dft for the current step"`
+
+	// [view: no-inline] ring buffer of power of the dft
+	PowerRing etensor.Float64 `view:"no-inline" desc:"ring buffer of power of the dft"`
+
+	// [view: no-inline] ring buffer of log power of the dft
+	LogPowerRing etensor.Float64 `view:"no-inline" desc:"ring buffer of log power of the dft"`
+
+	// [view: -] mel filter bank output for the current step
+	MelFBank etensor.Float64 `view:"-" desc:"mel filter bank output for the current step"`
+
+	// [view: no-inline] ring buffer of mel filter bank output
+	MelFBankRing etensor.Float64 `view:"no-inline" desc:"ring buffer of mel filter bank output"`
+
+	// [view: no-inline] the actual filters
+	MelFilters etensor.Float64 `view:"no-inline" desc:"the actual filters"`
+
+	// [view: -] discrete cosine transform of the mel filter bank output for the current step
+	MFCCDCT etensor.Float64 `view:"-" desc:"discrete cosine transform of the mel filter bank output for the current step"`
+
+	// [view: no-inline] ring buffer of mel frequency cepstral coefficients
+	MFCCRing etensor.Float64 `view:"no-inline" desc:"ring buffer of mel frequency cepstral coefficients"`
+}
+
+// Defaults
+func (im *IncrMel) Defaults() {
+	im.WinMs = 25.0
+	im.StepMs = 10.0
+	im.NSteps = 100
+	im.DFT.Defaults()
+	im.Mel.Defaults()
+}
+
+// Init initializes the processing for sound at the given sample rate, clearing all buffers -- call after setting
+// any non-default params
+func (im *IncrMel) Init(sampleRate int) error {
+	if sampleRate <= 0 {
+		return errors.New("IncrMel.Init: sample rate <= 0")
+	}
+	im.SampleRate = sampleRate
+	im.WinSamples = MSecToSamples(im.WinMs, sampleRate)
+	im.StepSamples = MSecToSamples(im.StepMs, sampleRate)
+	if im.StepSamples <= 0 || im.NSteps <= 0 {
+		return errors.New("IncrMel.Init: StepMs and NSteps must be > 0")
+	}
+	winSamplesHalf := im.WinSamples/2 + 1
+	if err := im.Mel.InitFilters(im.WinSamples, sampleRate, &im.MelFilters); err != nil {
+		return err
+	}
+	im.Window.SetShape([]int{im.WinSamples}, nil, nil)
+	im.Power.SetShape([]int{winSamplesHalf}, nil, nil)
+	im.LogPower.CopyShapeFrom(&im.Power)
+	im.PowerRing.SetShape([]int{winSamplesHalf, im.NSteps}, nil, nil)
+	im.LogPowerRing.CopyShapeFrom(&im.PowerRing)
+	im.MelFBank.SetShape([]int{im.Mel.FBank.NFilters}, nil, nil)
+	im.MelFBankRing.SetShape([]int{im.Mel.FBank.NFilters, im.NSteps}, nil, nil)
+	if im.Mel.MFCC {
+		im.MFCCDCT.SetShape([]int{im.Mel.FBank.NFilters}, nil, nil)
+		im.MFCCRing.SetShape([]int{im.Mel.NCoefs, im.NSteps}, nil, nil)
+	}
+	im.PowerRing.SetZeros()
+	im.LogPowerRing.SetZeros()
+	im.MelFBankRing.SetZeros()
+	im.MFCCRing.SetZeros()
+	im.Pending = im.Pending[:0]
+	im.Head = -1
+	im.NProcessed = 0
+	return nil
+}
+
+// Push adds new samples and processes every complete window now available,
+// returning the number of new steps processed
+func (im *IncrMel) Push(samples []float64) int {
+	im.Pending = append(im.Pending, samples...)
+	n := 0
+	st := 0
+	for len(im.Pending)-st >= im.WinSamples {
+		im.ProcessWindow(im.Pending[st : st+im.WinSamples])
+		st += im.StepSamples
+		n++
+	}
+	if st > 0 { // drop the samples no longer needed, reusing the buffer
+		if st > len(im.Pending) {
+			st = len(im.Pending)
+		}
+		rem := copy(im.Pending, im.Pending[st:])
+		im.Pending = im.Pending[:rem]
+	}
+	return n
+}
+
+// ProcessWindow processes one window of samples into the next column of the ring buffers
+func (im *IncrMel) ProcessWindow(win []float64) {
+	im.Head = (im.Head + 1) % im.NSteps
+	im.Window.Values = win
+	col := im.Head
+	im.DFT.Filter(col, &im.Window, im.WinSamples, &im.Power, &im.LogPower, &im.PowerRing, &im.LogPowerRing)
+	im.Mel.FilterDft(col, &im.Power, &im.MelFBankRing, &im.MelFBank, &im.MelFilters)
+	if im.Mel.MFCC {
+		im.Mel.CepstrumDct(col, &im.MelFBank, &im.MFCCRing, &im.MFCCDCT)
+		e := 0.0 // energy replaces the first coefficient as in SndEnv
+		for _, lp := range im.LogPower.Values {
+			e += lp
+		}
+		im.MFCCRing.SetFloat([]int{0, col}, e)
+	}
+	im.NProcessed++
+}
+
+// Ordered copies the ring buffer into dst with the columns in time order, oldest first, most recent last.
+// Columns for steps not yet processed are zero. dst is reshaped as needed.
+func (im *IncrMel) Ordered(ring *etensor.Float64, dst *etensor.Float64) {
+	dst.CopyShapeFrom(ring)
+	rows := ring.Dim(0)
+	for c := 0; c < im.NSteps; c++ {
+		src := (im.Head + 1 + c) % im.NSteps
+		for r := 0; r < rows; r++ {
+			dst.Set([]int{r, c}, ring.Value([]int{r, src}))
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Loop returns a signal of total samples made by repeating the loop region start..end (end exclusive) of the signal,
+// e.g. the steady-state part of a vowel or tone, so long steady inputs can be generated from short recordings.
+// The samples before start (e.g. the onset) are kept as is. Each repetition overlaps the previous one by xfade
+// samples with a linear crossfade so the loop is seamless -- 0 for no crossfade, in which case the loop points
+// should be at zero crossings of the same direction (see ZeroCrossing) to avoid clicks. The loop period is end - start - xfade samples
+func Loop(signal []float64, start, end, total, xfade int) ([]float64, error) {
+	if start < 0 || end > len(signal) || end <= start {
+		return nil, fmt.Errorf("sound.Loop: loop region %v..%v not within signal of %v samples", start, end, len(signal))
+	}
+	rlen := end - start
+	if xfade < 0 || xfade >= rlen {
+		return nil, errors.New("sound.Loop: xfade must be >= 0 and less than the loop length")
+	}
+	out := make([]float64, total)
+	pos := copy(out, signal[:start])
+	for rep := 0; pos < total; rep++ {
+		for i := 0; i < rlen && pos+i < total; i++ {
+			g := 1.0
+			if rep > 0 && i < xfade { // fade in over the tail of the previous repetition
+				g = float64(i+1) / float64(xfade+1)
+			} else if i >= rlen-xfade { // fade out under the head of the next repetition
+				g = float64(rlen-i) / float64(xfade+1)
+			}
+			out[pos+i] += g * signal[start+i]
+		}
+		pos += rlen - xfade
+	}
+	return out, nil
+}
+
+// ZeroCrossing returns the index of the upward (negative to non-negative) zero crossing of the signal nearest to idx,
+// for choosing loop points, or idx if there is none
+func ZeroCrossing(signal []float64, idx int) int {
+	for d := 0; d < len(signal); d++ {
+		for _, i := range []int{idx - d, idx + d} {
+			if i > 0 && i < len(signal) && signal[i-1] < 0 && signal[i] >= 0 {
+				return i
+			}
+		}
+	}
+	return idx
+}
+
+// LoopSignal replaces the Signal with the loop region startMs..endMs of the signal repeated out to durMs milliseconds,
+// with a crossfade of xfadeMs (see Loop). If snap is true the loop points are moved to the nearest upward zero crossings.
+// Call Init afterwards to update the segment count
+func (se *SndEnv) LoopSignal(startMs, endMs, durMs, xfadeMs float64, snap bool) error {
+	sr := se.Sound.SampleRate()
+	sig := se.Signal.Values
+	start := MSecToSamples(startMs, sr)
+	end := MSecToSamples(endMs, sr)
+	if snap {
+		start = ZeroCrossing(sig, start)
+		end = ZeroCrossing(sig, end)
+	}
+	looped, err := Loop(sig, start, end, MSecToSamples(durMs, sr), MSecToSamples(xfadeMs, sr))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	se.Signal.SetShape([]int{len(looped)}, nil, nil)
+	copy(se.Signal.Values, looped)
+	return nil
+}
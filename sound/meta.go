@@ -0,0 +1,228 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emer/auditory/mel"
+	"github.com/emer/etable/etensor"
+)
+
+// FloatsToMetaData formats the values as a comma separated list for storing as tensor metadata
+func FloatsToMetaData(vals []float64, prec int) string {
+	strs := make([]string, len(vals))
+	for i, v := range vals {
+		strs[i] = strconv.FormatFloat(v, 'f', prec, 64)
+	}
+	return strings.Join(strs, ",")
+}
+
+// MetaDataFloats parses the comma separated list of values stored in the tensor metadata for key, e.g. "row-hz"
+func MetaDataFloats(tsr etensor.Tensor, key string) ([]float64, bool) {
+	md, ok := tsr.MetaData(key)
+	if !ok || md == "" {
+		return nil, false
+	}
+	strs := strings.Split(md, ",")
+	vals := make([]float64, len(strs))
+	for i, s := range strs {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, false
+		}
+		vals[i] = v
+	}
+	return vals, true
+}
+
+// SetFreqMetaData sets "row-hz" and "row-mel" metadata, the center frequency of each row in Hz and mels,
+// on the power, mel and gabor output tensors so the frequency axis can be labeled correctly.
+// The power rows are linearly spaced dft bins, the mel rows are the filter centers and
+// the gabor rows are the centers of the mel filters (of the mel band, see MelBand) spanned by each filter position
+// (the 2D gabor output has an on-center and off-center row for each position). Called by Init.
+func (se *SndEnv) SetFreqMetaData() {
+	sr := float64(se.Sound.SampleRate())
+	nbins := se.Params.WinSamples/2 + 1
+	binHz := make([]float64, nbins)
+	binMel := make([]float64, nbins)
+	for k := range binHz {
+		binHz[k] = float64(k) * sr / float64(se.Params.WinSamples)
+		binMel[k] = mel.FreqToMel(binHz[k])
+	}
+	hz := FloatsToMetaData(binHz, 1)
+	mels := FloatsToMetaData(binMel, 1)
+	se.PowerSegment.SetMetaData("row-hz", hz)
+	se.PowerSegment.SetMetaData("row-mel", mels)
+	se.LogPowerSegment.SetMetaData("row-hz", hz)
+	se.LogPowerSegment.SetMetaData("row-mel", mels)
+
+	ctrs := se.FilterCenterFreqs()
+	ctrMels := make([]float64, len(ctrs))
+	for i, c := range ctrs {
+		ctrMels[i] = mel.FreqToMel(c)
+	}
+	se.MelFBankSegment.SetMetaData("row-hz", FloatsToMetaData(ctrs, 1))
+	se.MelFBankSegment.SetMetaData("row-mel", FloatsToMetaData(ctrMels, 1))
+	lo, hi := se.MelBand()
+	if se.Cropped() && lo < hi && hi <= len(ctrs) {
+		ctrs = ctrs[lo:hi]
+		ctrMels = ctrMels[lo:hi]
+		se.MelBandSegment.SetMetaData("row-hz", FloatsToMetaData(ctrs, 1))
+		se.MelBandSegment.SetMetaData("row-mel", FloatsToMetaData(ctrMels, 1))
+	}
+
+	if se.GborOutput.NumDims() < 2 || len(ctrs) == 0 {
+		return
+	}
+	rows := se.GborOutput.Dim(0)
+	perPos := 1
+	if se.GborOutput.NumDims() == 2 {
+		perPos = 2 // on-center and off-center rows
+	}
+	gHz := make([]float64, rows)
+	gMel := make([]float64, rows)
+	for r := 0; r < rows; r++ {
+		pos := r / perPos
+		c := float64(pos*se.GaborFilters.StrideY) + float64(se.GaborFilters.SizeY-1)/2
+		lo := int(math.Floor(c))
+		if lo > len(ctrs)-1 {
+			lo = len(ctrs) - 1
+		}
+		hi := lo + 1
+		if hi > len(ctrs)-1 {
+			hi = len(ctrs) - 1
+		}
+		frac := c - float64(lo)
+		gMel[r] = (1-frac)*ctrMels[lo] + frac*ctrMels[hi]
+		gHz[r] = mel.MelToFreq(gMel[r])
+	}
+	se.GborOutput.SetMetaData("row-hz", FloatsToMetaData(gHz, 1))
+	se.GborOutput.SetMetaData("row-mel", FloatsToMetaData(gMel, 1))
+	se.GborKwta.CopyMetaData(&se.GborOutput)
+}
+
+// SetTimeMetaData sets "col-ms" metadata, the time in milliseconds from the start of the signal of each column,
+// on the power, mel, mfcc, pitch, spectral feature, envelope and gabor output tensors for the given segment and add offset (see ProcessSegment).
+// The time of a power, mel or mfcc column is the start of the window processed for that step, which is
+// negative for the border steps of the first segment. The time of a gabor column is the start of
+// the step at the center of the filter position, in MelCarry if GaborCarry is set. Called by ProcessSegment.
+func (se *SndEnv) SetTimeMetaData(segment, add int) {
+	sr := se.Sound.SampleRate()
+	start := segment*se.Params.StrideSamples + MSecToSamples(float64(add), sr)
+	steps := make([]float64, se.Params.SegmentSteps)
+	for s := range steps {
+		steps[s] = SamplesToMSec(start+se.Params.Steps[s], sr)
+	}
+	ms := FloatsToMetaData(steps, 1)
+	se.PowerSegment.SetMetaData("col-ms", ms)
+	se.LogPowerSegment.SetMetaData("col-ms", ms)
+	se.MelFBankSegment.SetMetaData("col-ms", ms)
+	if se.Cropped() {
+		se.MelBandSegment.SetMetaData("col-ms", ms)
+	}
+	if se.Pitch.On {
+		se.PitchSegment.SetMetaData("col-ms", ms)
+	}
+	if se.Features.On {
+		se.FeatSegment.SetMetaData("col-ms", ms)
+	}
+	if se.Envelope.On {
+		se.EnvSegment.SetMetaData("col-ms", ms)
+	}
+	if se.Mel.MFCC {
+		se.MFCCSegment.SetMetaData("col-ms", ms)
+		se.MFCCDeltas.SetMetaData("col-ms", ms)
+		se.MFCCDeltaDeltas.SetMetaData("col-ms", ms)
+	}
+
+	if se.GborOutput.NumDims() < 2 || se.GaborFilters.StrideX <= 0 {
+		return
+	}
+	nf := se.GaborFilters.Filters.Dim(0)
+	nStrides := (se.Params.SegmentSteps-se.GaborFilters.SizeX)/se.GaborFilters.StrideX + 1
+	// the step of the segment of the first input column of the filters -- with GaborCarry, of MelCarry,
+	// starting lead steps before the stride
+	first := 0.0
+	if se.GaborCarry {
+		lead, width := se.CarrySteps()
+		nStrides = (width-se.GaborFilters.SizeX)/se.GaborFilters.StrideX + 1
+		first = float64(se.Params.StepsBack() - lead)
+	}
+	cols := se.GborOutput.Dim(1)
+	gms := make([]float64, cols)
+	for x := range gms {
+		tIdx := x // 4D -- one pool per filter position
+		if se.GborOutput.NumDims() == 2 {
+			if se.ByTime {
+				tIdx = x % nStrides
+			} else {
+				tIdx = x / nf
+			}
+		}
+		ctr := float64(tIdx*se.GaborFilters.StrideX) + float64(se.GaborFilters.SizeX-1)/2
+		gms[x] = steps[0] + (first+ctr)*se.Params.StepMs
+	}
+	gm := FloatsToMetaData(gms, 1)
+	se.GborOutput.SetMetaData("col-ms", gm)
+	se.GborKwta.SetMetaData("col-ms", gm)
+}
+
+// Quantile returns the value at quantile q (0-1) of the values of the tensor, ignoring NaN values
+// and interpolating linearly between the closest values
+func Quantile(tsr etensor.Tensor, q float64) float64 {
+	vals := make([]float64, 0, tsr.Len())
+	for i := 0; i < tsr.Len(); i++ {
+		v := tsr.FloatVal1D(i)
+		if !math.IsNaN(v) {
+			vals = append(vals, v)
+		}
+	}
+	if len(vals) == 0 {
+		return 0
+	}
+	sort.Float64s(vals)
+	pos := q * float64(len(vals)-1)
+	lo := int(math.Floor(pos))
+	if lo < 0 {
+		return vals[0]
+	}
+	if lo >= len(vals)-1 {
+		return vals[len(vals)-1]
+	}
+	frac := pos - float64(lo)
+	return (1-frac)*vals[lo] + frac*vals[lo+1]
+}
+
+// SetQuantileRange sets the "min" and "max" display range metadata of the tensor to the loQ and hiQ
+// quantiles of its values (e.g. .01 and .99), and fixes the range, so a few extreme values don't wash out
+// the display. The range is read by etview.TensorGrid when the tensor is set on the grid.
+func SetQuantileRange(tsr etensor.Tensor, loQ, hiQ float64) (min, max float64) {
+	min = Quantile(tsr, loQ)
+	max = Quantile(tsr, hiQ)
+	if max <= min { // e.g. all values the same
+		max = min + 1
+	}
+	tsr.SetMetaData("min", strconv.FormatFloat(min, 'g', 4, 64))
+	tsr.SetMetaData("max", strconv.FormatFloat(max, 'g', 4, 64))
+	tsr.SetMetaData("fix-min", "true")
+	tsr.SetMetaData("fix-max", "true")
+	return
+}
+
+// SetDisplayRanges sets the display metadata of the log power, mel, mfcc and gabor output tensors
+// for the current segment's values, see ConfigureForDisplay -- call after processing the segment
+func (se *SndEnv) SetDisplayRanges() {
+	ConfigureForDisplay(&se.LogPowerSegment, DisplayLogPower)
+	ConfigureForDisplay(&se.MelFBankSegment, DisplayLevel)
+	if se.Mel.MFCC {
+		ConfigureForDisplay(&se.MFCCSegment, DisplaySigned)
+	}
+	ConfigureForDisplay(&se.GborOutput, DisplaySigned)
+	ConfigureForDisplay(&se.GborKwta, DisplayLevel)
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// OutputNames are the names of the outputs of a SndEnv, as named by Outputs, ToTable and the "name" metadata of the
+// output tensors -- the SeqEnv state names, plus MelDeltas, MelDeltaDeltas, GaborKwta, MelAll, MFCCAll and GaborAll
+var OutputNames = []string{"Power", "Mel", "MFCC", "MelPooled", "MFCCPooled", "MelSpliced", "MFCCSpliced", "MelDeltas", "MelDeltaDeltas", "Pitch", "Features", "Envelope", "Gabor", "GaborKwta", "VAD", "MelAll", "MFCCAll", "GaborAll"}
+
+// Output returns the output tensor of the name, see OutputNames, without the Prefix -- nil for an unknown name
+func (se *SndEnv) Output(name string) etensor.Tensor {
+	switch name {
+	case "Power":
+		return &se.LogPowerSegment
+	case "Mel":
+		return &se.MelFBankSegment
+	case "MFCC":
+		return &se.MFCCSegment
+	case "MelPooled":
+		return &se.MelPooled
+	case "MFCCPooled":
+		return &se.MFCCPooled
+	case "MelSpliced":
+		return &se.MelSpliced
+	case "MFCCSpliced":
+		return &se.MFCCSpliced
+	case "MelDeltas":
+		return &se.MelDeltas
+	case "MelDeltaDeltas":
+		return &se.MelDeltaDeltas
+	case "Gabor":
+		return &se.GborOutput
+	case "Pitch":
+		return &se.PitchSegment
+	case "Features":
+		return &se.FeatSegment
+	case "Envelope":
+		return &se.EnvSegment
+	case "GaborKwta":
+		return &se.GborKwta
+	case "VAD":
+		return &se.VADMask
+	case "MelAll":
+		return &se.MelAll
+	case "MFCCAll":
+		return &se.MFCCAll
+	case "GaborAll":
+		return &se.GaborAll
+	}
+	return nil
+}
+
+// Outputs returns the names, without the Prefix, of the outputs that are computed with the current params and
+// have values, e.g., MFCC only if Mel.MFCC is set, GaborKwta only if Kwta is on and VAD only if VAD is on
+func (se *SndEnv) Outputs() []string {
+	var names []string
+	for _, nm := range OutputNames {
+		switch {
+		case nm == "GaborKwta" && !se.Kwta.On:
+			continue
+		case nm == "VAD" && !se.VAD.On:
+			continue
+		case nm == "Pitch" && !se.Pitch.On:
+			continue
+		case nm == "Features" && !se.Features.On:
+			continue
+		case nm == "Envelope" && !se.Envelope.On:
+			continue
+		case (nm == "MelDeltas" || nm == "MelDeltaDeltas") && !se.Mel.FBankDeltas:
+			continue
+		case (nm == "MFCC" || nm == "MFCCPooled" || nm == "MFCCSpliced" || nm == "MFCCAll") && !se.Mel.MFCC:
+			continue
+		}
+		if tsr := se.Output(nm); tsr.NumDims() > 0 && tsr.Len() > 0 {
+			names = append(names, nm)
+		}
+	}
+	return names
+}
+
+// SetNameMetaData sets the "name" metadata of each output tensor to its name prefixed with Prefix, e.g., "LongMel", so
+// the outputs of several SndEnvs feeding one model are told apart in logs and views. Called by InitProcess,
+// ProcessSegment and ProcessAll, as the pooled, spliced and whole signal outputs copy the metadata of the mel output
+func (se *SndEnv) SetNameMetaData() {
+	for _, nm := range OutputNames {
+		se.Output(nm).SetMetaData("name", se.Prefix+nm)
+	}
+}
+
+// ToTable sets row of the table to the current outputs (see Outputs), one column per output named with the Prefix,
+// e.g., "LongMel", adding the columns that are missing and the rows up to row. Log the outputs of several SndEnvs to one
+// table by giving each a different Prefix. An error if a column of the name already has a different cell shape
+func (se *SndEnv) ToTable(dt *etable.Table, row int) error {
+	if dt.Rows <= row {
+		dt.SetNumRows(row + 1)
+	}
+	for _, nm := range se.Outputs() {
+		tsr := se.Output(nm)
+		cnm := se.Prefix + nm
+		col, err := dt.ColByNameTry(cnm)
+		if err != nil {
+			shp := append([]int{dt.Rows}, tsr.Shapes()...)
+			var nms []string
+			if dn := tsr.DimNames(); len(dn) == tsr.NumDims() {
+				nms = append([]string{"Row"}, dn...)
+			}
+			col = etensor.New(tsr.DataType(), shp, nil, nms)
+			if err = dt.AddCol(col, cnm); err != nil {
+				log.Println(err)
+				return err
+			}
+		}
+		if _, csz := col.RowCellSize(); csz != tsr.Len() {
+			err = fmt.Errorf("sound.SndEnv: %v column %v has cells of %v values, the output has %v -- use a different Prefix or table", se.Nm, cnm, csz, tsr.Len())
+			log.Println(err)
+			return err
+		}
+		if err = dt.SetCellTensorTry(cnm, row, tsr); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	return nil
+}
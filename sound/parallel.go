@@ -0,0 +1,117 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/emer/etable/etensor"
+)
+
+// ProcessSegments processes all SegCnt segments of the Signal, with no added offset, by Workers goroutines at once, each
+// with its own copy of the SndEnv, and returns the named segment outputs (see OutputNames, e.g., "Mel", "MFCC" and
+// "Gabor") stacked over the segments, [segments, ...] -- e.g., [segments, filters, steps] for Mel and 5D for 4D gabor
+// output -- for precomputing the features of large corpora. Gabor and GaborKwta are computed as by ApplyGabor.
+// Segments depend on the segments before them with GaborCarry, Habit or VAD, which are then processed by one worker
+// in order, as they are with SpecAug, so its masks are drawn from one random source in segment order. The outputs
+// are float32 if Float32 is set. The outputs of se itself are unchanged. Call after Init
+func (se *SndEnv) ProcessSegments(names []string) (map[string]etensor.Tensor, error) {
+	gabor := false
+	for _, nm := range names {
+		if se.Output(nm) == nil || strings.HasSuffix(nm, "All") {
+			err := fmt.Errorf("sound.SndEnv.ProcessSegments: %q is not a segment output, see OutputNames", nm)
+			log.Println(err)
+			return nil, err
+		}
+		if strings.HasPrefix(nm, "Gabor") {
+			gabor = true
+		}
+	}
+	if se.SegCnt <= 0 {
+		err := fmt.Errorf("sound.SndEnv.ProcessSegments: %v has no segments, call Init", se.Nm)
+		log.Println(err)
+		return nil, err
+	}
+	nw := se.Workers
+	if nw <= 0 {
+		nw = runtime.NumCPU()
+	}
+	if se.GaborCarry || se.Habit.On || se.VAD.On || se.SpecAug.On {
+		nw = 1
+	}
+	if nw > se.SegCnt {
+		nw = se.SegCnt
+	}
+	cache := se.Cache
+	if cache == nil {
+		cache = &FilterCache{} // the workers build the filters once
+	}
+	envs := make([]*SndEnv, nw)
+	for i := range envs {
+		w := &SndEnv{}
+		w.CopyParams(se)
+		w.DFT.ResetCache() // the FFT isn't shared
+		w.Nm, w.Prefix = se.Nm, se.Prefix
+		w.Sound = se.Sound
+		w.Cache = cache
+		w.Signal = se.Signal // shared, only read
+		if err := w.Init(); err != nil {
+			return nil, err
+		}
+		envs[i] = w
+	}
+
+	process := func(w *SndEnv, seg int) {
+		w.ProcessSegment(seg, 0)
+		if gabor {
+			w.ApplyGabor()
+		}
+	}
+	process(envs[0], 0) // shapes the outputs
+	outs := map[string]etensor.Tensor{}
+	for _, nm := range names {
+		tsr := envs[0].Output(nm)
+		var dnms []string
+		if dn := tsr.DimNames(); len(dn) == tsr.NumDims() {
+			dnms = append([]string{"Segment"}, dn...)
+		}
+		dt := tsr.DataType()
+		if se.Float32 && dt == etensor.FLOAT64 {
+			dt = etensor.FLOAT32
+		}
+		out := etensor.New(dt, append([]int{se.SegCnt}, tsr.Shapes()...), nil, dnms)
+		out.CopyMetaData(tsr)
+		out.SubSpace([]int{0}).CopyFrom(tsr)
+		outs[nm] = out
+	}
+	if se.SegCnt == 1 {
+		return outs, nil
+	}
+
+	segs := make(chan int)
+	var wg sync.WaitGroup
+	for _, w := range envs {
+		wg.Add(1)
+		go func(w *SndEnv) {
+			defer wg.Done()
+			for seg := range segs {
+				process(w, seg)
+				for _, nm := range names {
+					outs[nm].SubSpace([]int{seg}).CopyFrom(w.Output(nm))
+				}
+			}
+		}(w)
+	}
+	for seg := 1; seg < se.SegCnt; seg++ {
+		segs <- seg
+	}
+	close(segs)
+	wg.Wait()
+	return outs, nil
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/emer/etable/etensor"
+)
+
+// Pipeline runs the whole processing of the gaborview example -- mel, mfcc with energy and deltas, and gabor
+// filtering -- on one segment of a sound file, from any start to end time, without a GUI, so batch jobs and tests
+// use the same code path as the app. Set the params of Snd, e.g., with Defaults, then for each segment call Setup,
+// Process and ApplyGabor:
+//
+//	pl := &sound.Pipeline{}
+//	pl.Defaults()
+//	err := pl.Setup("sa1.wav", 120, 380)
+//	if err == nil {
+//		err = pl.Process()
+//	}
+//	gabor, err := pl.ApplyGabor()
+type Pipeline struct {
+
+	// the sound processing, its params and outputs -- the segment params (SegmentMs and StrideMs) and gabor output shape are set by Setup
+	Snd SndEnv `desc:"the sound processing, its params and outputs -- the segment params (SegmentMs and StrideMs) and gabor output shape are set by Setup"`
+
+	// lengthen the segment, a bit before and a bit after, to fit a whole number of gabor filter strides, see ResizeSegment
+	Resize bool `desc:"lengthen the segment, a bit before and a bit after, to fit a whole number of gabor filter strides, see ResizeSegment"`
+
+	// the sound file loaded
+	File string `inactive:"+" desc:"the sound file loaded"`
+
+	// start of the segment processed in milliseconds, after any Resize
+	StartMs float64 `inactive:"+" desc:"start of the segment processed in milliseconds, after any Resize"`
+
+	// end of the segment processed in milliseconds, after any Resize
+	EndMs float64 `inactive:"+" desc:"end of the segment processed in milliseconds, after any Resize"`
+}
+
+// Defaults sets the params of the gaborview example: mfcc with deltas, the standard gabor filters (see
+// SndEnv.GaborDefaults) with 2D output and no kwta, no border steps, and short sounds padded
+func (pl *Pipeline) Defaults() {
+	pl.Snd.Defaults()
+	pl.Snd.Params.BorderSteps = 0
+	pl.Snd.Params.PadShort = true
+	pl.Snd.Mel.MFCC = true
+	pl.Snd.Mel.Deltas = true
+	pl.Snd.GaborDefaults()
+	pl.Snd.Kwta.On = false
+	pl.Snd.NeighInhib.On = false
+	pl.Resize = true
+}
+
+// ResizeSegment returns the start and end of a segment lengthened to fit the gabor filters: to one filter width of
+// sizeX steps if it is shorter, otherwise to the end of the next stride of strideX steps. Half the added time is
+// before the segment and half after, unless that would start before 0, in which case it is all added after
+func ResizeSegment(startMs, endMs, stepMs float64, sizeX, strideX int) (float64, float64) {
+	duration := endMs - startMs
+	sizeXMs := float64(sizeX) * stepMs
+	strideXMs := float64(strideX) * stepMs
+	add := 0.0
+	if duration < sizeXMs {
+		add = sizeXMs - duration
+	} else if int(strideXMs) > 0 { // duration is longer than one filter so find the next stride end
+		rem := float64(int(duration-sizeXMs) % int(strideXMs))
+		if rem > 0 {
+			add = strideXMs - rem
+		}
+	}
+	if startMs-add < 0 {
+		return startMs, endMs + add
+	}
+	return startMs - add/2, endMs + add/2
+}
+
+// Setup loads the sound file, unless it is already loaded, and sets up the processing of the segment from startMs to endMs,
+// resized if Resize is set -- the segment is rounded up to whole steps and its start to whole milliseconds
+func (pl *Pipeline) Setup(file string, startMs, endMs float64) error {
+	se := &pl.Snd
+	if endMs <= startMs || startMs < 0 {
+		err := fmt.Errorf("sound.Pipeline: segment %v..%v ms is empty, the end must be greater than the start", startMs, endMs)
+		log.Println(err)
+		return err
+	}
+	if se.Params.StepMs <= 0 {
+		err := errors.New("sound.Pipeline: Params.StepMs must be > 0")
+		log.Println(err)
+		return err
+	}
+	if file != pl.File || se.Sound.Buf == nil {
+		pl.File = ""
+		if err := se.Sound.Load(file); err != nil {
+			return err
+		}
+		if !se.ToTensor() {
+			err := fmt.Errorf("sound.Pipeline: couldn't convert %v to a signal", file)
+			log.Println(err)
+			return err
+		}
+		pl.File = file
+	}
+	if pl.Resize {
+		startMs, endMs = ResizeSegment(startMs, endMs, se.Params.StepMs, se.GaborFilters.SizeX, se.GaborFilters.StrideX)
+	}
+	pl.StartMs = math.Round(startMs)
+	steps := math.Ceil((endMs-startMs)/se.Params.StepMs - 1e-9)
+	se.Params.SegmentMs = steps * se.Params.StepMs
+	se.Params.StrideMs = se.Params.SegmentMs
+	pl.EndMs = pl.StartMs + se.Params.SegmentMs
+	se.SetGaborOut2D()
+	if err := se.InitProcess(); err != nil {
+		return err
+	}
+	// the windows of the segment must be within the signal, see SndToWindow
+	if need := MSecToSamples(pl.StartMs, se.Sound.SampleRate()) + se.SegmentEnd(); se.NSamples() < need {
+		pl.padTo(need)
+	}
+	return se.InitSignal()
+}
+
+// padTo pads the end of each channel of the signal with Params.PadValue to n samples
+func (pl *Pipeline) padTo(n int) {
+	se := &pl.Snd
+	ns := se.NSamples()
+	nch := 1
+	if se.Signal.NumDims() > 1 {
+		nch = se.Signal.Dim(0)
+	}
+	vals := make([]float64, nch*n)
+	for ch := 0; ch < nch; ch++ {
+		copy(vals[ch*n:], se.ChannelSignal(ch))
+		for i := ns; i < n; i++ {
+			vals[ch*n+i] = se.Params.PadValue
+		}
+	}
+	if nch > 1 {
+		se.Signal.SetShape([]int{nch, n}, nil, se.Signal.DimNames())
+	} else {
+		se.Signal.SetShape([]int{n}, nil, nil)
+	}
+	copy(se.Signal.Values, vals)
+}
+
+// Process processes the segment set up by Setup into the mel, mfcc (with energy and deltas if Mel.Deltas) and other outputs of Snd
+func (pl *Pipeline) Process() error {
+	se := &pl.Snd
+	if pl.File == "" || len(se.Params.Steps) == 0 {
+		err := errors.New("sound.Pipeline: call Setup before Process")
+		log.Println(err)
+		return err
+	}
+	se.ProcessSegment(0, int(pl.StartMs))
+	return nil
+}
+
+// ApplyGabor convolves the gabor filters with the mel output of the segment and returns the gabor output,
+// post kwta if Kwta is on -- an error if the filters don't fit in the mel output or their stride is larger than their size
+func (pl *Pipeline) ApplyGabor() (*etensor.Float32, error) {
+	se := &pl.Snd
+	gf := &se.GaborFilters
+	if gf.StrideX > gf.SizeX || gf.StrideY > gf.SizeY {
+		err := fmt.Errorf("sound.Pipeline: gabor filter stride %v x %v is larger than the filter size %v x %v", gf.StrideX, gf.StrideY, gf.SizeX, gf.SizeY)
+		log.Println(err)
+		return nil, err
+	}
+	lo, hi := se.MelBand()
+	if steps := se.MelFBankSegment.Dim(1); steps < gf.SizeX || hi-lo < gf.SizeY {
+		err := fmt.Errorf("sound.Pipeline: gabor filters of %v x %v don't fit in the mel output of %v steps by %v filters, lengthen the segment or set Resize", gf.SizeX, gf.SizeY, steps, hi-lo)
+		log.Println(err)
+		return nil, err
+	}
+	return se.ApplyGabor(), nil
+}
+
+// Describe returns a human readable summary of the processing of the segment set up by Setup, see SndEnv.Describe
+func (pl *Pipeline) Describe() string {
+	return fmt.Sprintf("file: %s, %g to %g ms\n", pl.File, pl.StartMs, pl.EndMs) + pl.Snd.Describe()
+}
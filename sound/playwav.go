@@ -2,8 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-//go:build !server
-// +build !server
+//go:build !server && !js
+// +build !server,!js
 
 package sound
 
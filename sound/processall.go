@@ -0,0 +1,74 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+)
+
+// AllSteps returns the number of steps of ProcessAll, the windows that fit within the Signal starting at its first sample
+func (se *SndEnv) AllSteps() int {
+	sr := se.Sound.SampleRate()
+	win, step := MSecToSamples(se.Params.WinMs, sr), MSecToSamples(se.Params.StepMs, sr)
+	if step <= 0 || se.NSamples() < win {
+		return 0
+	}
+	return (se.NSamples()-win)/step + 1
+}
+
+// ProcessAll processes the whole Signal at once, without segments, into MelAll, MFCCAll (if Mel.MFCC) and GaborAll,
+// one column per step from the start of the signal (see AllSteps) -- for analysis rather than training, memory permitting.
+// The gabor output is 2D (see SetGaborOut2D), post kwta over the whole layer if Kwta is on, and without neighborhood
+// inhibition, which needs pools, or habituation. The params and segment outputs are restored
+// afterwards, so segment processing can go on as before. Call after Init
+func (se *SndEnv) ProcessAll() error {
+	steps := se.AllSteps()
+	if steps == 0 {
+		err := fmt.Errorf("sound.SndEnv: %v signal of %v samples is shorter than one window", se.Nm, se.NSamples())
+		log.Println(err)
+		return err
+	}
+	prm := se.Params
+	px, py, ux, uy := se.GborOutPoolsX, se.GborOutPoolsY, se.GborOutUnitsX, se.GborOutUnitsY
+	carry, kpool, ni, habit := se.GaborCarry, se.KwtaPool, se.NeighInhib.On, se.Habit.On
+	defer func() {
+		se.Params = prm
+		se.GborOutPoolsX, se.GborOutPoolsY, se.GborOutUnitsX, se.GborOutUnitsY = px, py, ux, uy
+		se.GaborCarry, se.KwtaPool, se.NeighInhib.On, se.Habit.On = carry, kpool, ni, habit
+		if se.InitProcess() == nil {
+			se.InitSignal()
+		}
+	}()
+
+	se.Params.SegmentMs = float64(steps) * se.Params.StepMs
+	se.Params.StrideMs = se.Params.SegmentMs
+	se.Params.BorderSteps = 0
+	se.Params.Align = AlignStart
+	se.GaborCarry, se.KwtaPool, se.NeighInhib.On, se.Habit.On = false, false, false, false
+	se.SetGaborOut2D()
+	if err := se.InitProcess(); err != nil {
+		return err
+	}
+	se.ProcessSegment(0, 0)
+	se.MelAll.CopyShapeFrom(&se.MelFBankSegment)
+	se.MelAll.CopyFrom(&se.MelFBankSegment)
+	se.MelAll.CopyMetaData(&se.MelFBankSegment)
+	if se.Mel.MFCC {
+		se.MFCCAll.CopyShapeFrom(&se.MFCCSegment)
+		se.MFCCAll.CopyFrom(&se.MFCCSegment)
+		se.MFCCAll.CopyMetaData(&se.MFCCSegment)
+	}
+	lo, hi := se.MelBand()
+	if steps < se.GaborFilters.SizeX || hi-lo < se.GaborFilters.SizeY || len(se.GaborSpecs) == 0 {
+		se.GaborAll.SetShape([]int{0}, nil, nil) // no gabor filters or they don't fit
+		return nil
+	}
+	g := se.ApplyGabor()
+	se.GaborAll.CopyShapeFrom(g)
+	se.GaborAll.CopyFrom(g)
+	se.GaborAll.CopyMetaData(g)
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// PhoneProto is the prototype of a phone category, the average of the features of its units
+type PhoneProto struct {
+
+	// the phone category
+	Name string `desc:"the phone category"`
+
+	// number of units averaged
+	N int `desc:"number of units averaged"`
+
+	// average mel filter bank output of the units
+	Mel etensor.Float64 `desc:"average mel filter bank output of the units"`
+
+	// average mfcc of the units, if they had them
+	MFCC etensor.Float64 `desc:"average mfcc of the units, if they had them"`
+
+	// average gabor output of the units, if they had it
+	Gabor etensor.Float32 `desc:"average gabor output of the units, if they had it"`
+}
+
+// PhoneProtos accumulates the prototypes of the phone categories of a corpus, the average features of the units of
+// each category (see Add and SndBank.Prototypes) -- e.g., as fixed target patterns of a model or for checking that
+// the features tell the phones apart. View them with Table and save them with SaveJSON
+type PhoneProtos struct {
+
+	// [view: -] optional map of the unit names to their categories, e.g., the folding of TIMIT phones -- units of names not in the map are their own category, and units mapped to "" are skipped
+	Fold map[string]string `view:"-" desc:"optional map of the unit names to their categories, e.g., the folding of TIMIT phones -- units of names not in the map are their own category, and units mapped to \"\" are skipped"`
+
+	// the prototypes, sorted by name
+	Protos []*PhoneProto `desc:"the prototypes, sorted by name"`
+}
+
+// Reset removes the prototypes
+func (pp *PhoneProtos) Reset() {
+	pp.Protos = nil
+}
+
+// Category returns the category of the unit name, by Fold
+func (pp *PhoneProtos) Category(name string) string {
+	if cat, ok := pp.Fold[name]; ok {
+		return cat
+	}
+	return name
+}
+
+// Proto returns the prototype of the category
+func (pp *PhoneProtos) Proto(cat string) (*PhoneProto, bool) {
+	i := sort.Search(len(pp.Protos), func(i int) bool { return pp.Protos[i].Name >= cat })
+	if i < len(pp.Protos) && pp.Protos[i].Name == cat {
+		return pp.Protos[i], true
+	}
+	return nil, false
+}
+
+// Add adds the features of the unit to the average of its category, creating the prototype of the category the first
+// time -- an error if the features have a different shape than those already averaged
+func (pp *PhoneProtos) Add(uf *UnitFeatures) error {
+	cat := pp.Category(uf.Name)
+	if cat == "" {
+		return nil
+	}
+	pr, ok := pp.Proto(cat)
+	if !ok {
+		pr = &PhoneProto{Name: cat}
+		pr.Mel.CopyShapeFrom(&uf.Mel)
+		pr.MFCC.CopyShapeFrom(&uf.MFCC)
+		pr.Gabor.CopyShapeFrom(&uf.Gabor)
+		i := sort.Search(len(pp.Protos), func(i int) bool { return pp.Protos[i].Name >= cat })
+		pp.Protos = append(pp.Protos, nil)
+		copy(pp.Protos[i+1:], pp.Protos[i:])
+		pp.Protos[i] = pr
+	}
+	if pr.Mel.Len() != uf.Mel.Len() || pr.MFCC.Len() != uf.MFCC.Len() || pr.Gabor.Len() != uf.Gabor.Len() {
+		err := fmt.Errorf("sound.PhoneProtos: features of unit %v have a different shape than those of %v averaged", uf.Name, cat)
+		log.Println(err)
+		return err
+	}
+	pr.N++
+	n := float64(pr.N)
+	for i, v := range uf.Mel.Values {
+		pr.Mel.Values[i] += (v - pr.Mel.Values[i]) / n
+	}
+	for i, v := range uf.MFCC.Values {
+		pr.MFCC.Values[i] += (v - pr.MFCC.Values[i]) / n
+	}
+	for i, v := range uf.Gabor.Values {
+		pr.Gabor.Values[i] += (v - pr.Gabor.Values[i]) / float32(n)
+	}
+	return nil
+}
+
+// Table returns a table of the prototypes, one row per category, with the Name, the N of units averaged and a tensor
+// column for each of the features the units had -- for viewing and exporting, e.g., with SaveCSV
+func (pp *PhoneProtos) Table() *etable.Table {
+	dt := &etable.Table{}
+	dt.SetMetaData("name", "PhoneProtos")
+	dt.SetMetaData("desc", "average features of the units of each phone category")
+	sch := etable.Schema{
+		{"Name", etensor.STRING, nil, nil},
+		{"N", etensor.INT64, nil, nil},
+	}
+	if len(pp.Protos) > 0 {
+		pr := pp.Protos[0]
+		if pr.Mel.Len() > 0 {
+			sch = append(sch, etable.Column{"Mel", etensor.FLOAT64, pr.Mel.Shapes(), pr.Mel.DimNames()})
+		}
+		if pr.MFCC.Len() > 0 {
+			sch = append(sch, etable.Column{"MFCC", etensor.FLOAT64, pr.MFCC.Shapes(), pr.MFCC.DimNames()})
+		}
+		if pr.Gabor.Len() > 0 {
+			sch = append(sch, etable.Column{"Gabor", etensor.FLOAT32, pr.Gabor.Shapes(), pr.Gabor.DimNames()})
+		}
+	}
+	dt.SetFromSchema(sch, len(pp.Protos))
+	for r, pr := range pp.Protos {
+		dt.SetCellString("Name", r, pr.Name)
+		dt.SetCellFloat("N", r, float64(pr.N))
+		if pr.Mel.Len() > 0 {
+			dt.SetCellTensor("Mel", r, &pr.Mel)
+		}
+		if pr.MFCC.Len() > 0 {
+			dt.SetCellTensor("MFCC", r, &pr.MFCC)
+		}
+		if pr.Gabor.Len() > 0 {
+			dt.SetCellTensor("Gabor", r, &pr.Gabor)
+		}
+	}
+	return dt
+}
+
+// OpenJSON opens the prototypes from a JSON-formatted file
+func (pp *PhoneProtos) OpenJSON(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	pp.Protos = nil
+	return json.Unmarshal(b, &pp.Protos)
+}
+
+// SaveJSON saves the prototypes to a JSON-formatted file
+func (pp *PhoneProtos) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(pp.Protos, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// Prototypes processes each of the units (see Process) and adds its features to the prototypes of pp, see
+// PhoneProtos.Add -- e.g., all the Units of the bank for the prototypes of the corpus
+func (sb *SndBank) Prototypes(refs []UnitRef, pp *PhoneProtos) error {
+	for _, ref := range refs {
+		if ref.File >= 0 && ref.File < len(sb.Seqs) && ref.Unit >= 0 && ref.Unit < len(sb.Seqs[ref.File].Units) {
+			if pp.Category(sb.Seqs[ref.File].Units[ref.Unit].Name) == "" {
+				continue // skipped without processing
+			}
+		}
+		uf, err := sb.ProcessRef(ref)
+		if err != nil {
+			return err
+		}
+		if err = pp.Add(uf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// Random numbers: every stochastic component takes an explicit *rand.Rand or seed rather than using the global
+// math/rand source, so runs are reproducible. Components of a SndEnv (and the envs built on it) derive their
+// sources from the master SndEnv.Seed with NewRand, each with its own name, so that adding or removing
+// one stochastic step does not change the random numbers of the others
+
+// NewRand returns a random number source seeded with seed
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// SubSeed returns the seed of the named component derived from the master seed
+func SubSeed(master int64, name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return master ^ int64(h.Sum64())
+}
+
+// Permute randomly permutes ins using the random source rnd
+func Permute(rnd *rand.Rand, ins []int) {
+	rnd.Shuffle(len(ins), func(i, j int) { ins[i], ins[j] = ins[j], ins[i] })
+}
+
+// NewRand returns a random number source for the named component, derived from the master Seed --
+// add the run number, or other index, to the name for different but reproducible sources per run
+func (se *SndEnv) NewRand(name string) *rand.Rand {
+	return NewRand(SubSeed(se.Seed, name))
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/emer/auditory/agabor"
+	"github.com/emer/etable/etensor"
+)
+
+// ProjectGabor sets out to the weights over the gabor output, of the shape of GborOutput (e.g., the weights of a unit of
+// a model receiving the gabor output, laid out as the sending layer), projected back onto the mel frequency by time of
+// the gabor input (see agabor.Project), so the receptive fields learned by the model can be seen as spectrograms --
+// [filters, steps] of the band of MelBand, or [channels, filters, steps] for ChannelAll. Call after Init, with the
+// same gabor filters and output shape as the model was trained with. The out tensor is configured for DisplaySigned
+func (se *SndEnv) ProjectGabor(weights etensor.Tensor, out *etensor.Float64) error {
+	if weights.Len() != se.GborOutput.Len() || weights.NumDims() != se.GborOutput.NumDims() {
+		err := fmt.Errorf("sound.SndEnv.ProjectGabor: weights of shape %v, the gabor output is %v", weights.Shapes(), se.GborOutput.Shapes())
+		log.Println(err)
+		return err
+	}
+	lo, hi := se.MelBand()
+	steps := se.Params.SegmentSteps
+	if se.GaborCarry {
+		steps = se.MelCarry.Dim(1)
+	}
+	melShp := []int{hi - lo, steps}
+	if se.Params.ChannelMode != ChannelAll {
+		if err := agabor.Project(weights, melShp, se.GaborFilters, se.ByTime, out); err != nil {
+			return err
+		}
+		ConfigureForDisplay(out, DisplaySigned)
+		return nil
+	}
+	nch := weights.Dim(0)
+	out.SetShape([]int{nch, melShp[0], melShp[1]}, nil, []string{"chan", "freq", "time"})
+	var ch etensor.Float64
+	for c := 0; c < nch; c++ {
+		if err := agabor.Project(weights.SubSpace([]int{c}), melShp, se.GaborFilters, se.ByTime, &ch); err != nil {
+			return err
+		}
+		copy(out.Values[c*ch.Len():], ch.Values)
+	}
+	ConfigureForDisplay(out, DisplaySigned)
+	return nil
+}
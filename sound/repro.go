@@ -0,0 +1,218 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"time"
+)
+
+// ModulePath is the module path of this package, for looking up its version in the build info
+const ModulePath = "github.com/emer/auditory"
+
+// FileSum is the checksum of a file used to build a feature set
+type FileSum struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// BuildManifest records everything a feature set was built from -- the package version, the parameters
+// with their hashes, the random seeds and the checksums of the input files -- so a published experiment
+// can be reproduced from it, and Diff tells whether two builds used the same inputs
+type BuildManifest struct {
+
+	// version of this package the features were built with, from the build info, "(devel)" for a local build
+	Version string `desc:"version of this package the features were built with, from the build info, \"(devel)\" for a local build"`
+
+	// version of go the features were built with
+	GoVersion string `desc:"version of go the features were built with"`
+
+	// time the manifest was created
+	Created time.Time `desc:"time the manifest was created"`
+
+	// the parameters by name, as JSON
+	Params map[string]json.RawMessage `desc:"the parameters by name, as JSON"`
+
+	// SHA-256 of the JSON of each of the Params
+	ParamHashes map[string]string `desc:"SHA-256 of the JSON of each of the Params"`
+
+	// the random seeds by name, e.g., of each augmentation
+	Seeds map[string]int64 `desc:"the random seeds by name, e.g., of each augmentation"`
+
+	// checksums of the input files, in order of AddFile
+	Files []FileSum `desc:"checksums of the input files, in order of AddFile"`
+}
+
+// NewBuildManifest returns a manifest with the package and go versions and the creation time
+func NewBuildManifest() *BuildManifest {
+	bm := &BuildManifest{Version: "unknown", GoVersion: runtime.Version(), Created: time.Now().UTC()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if bi.Main.Path == ModulePath {
+			bm.Version = bi.Main.Version
+		}
+		for _, dep := range bi.Deps {
+			if dep.Path == ModulePath {
+				bm.Version = dep.Version
+			}
+		}
+	}
+	bm.Params = map[string]json.RawMessage{}
+	bm.ParamHashes = map[string]string{}
+	bm.Seeds = map[string]int64{}
+	return bm
+}
+
+// AddParams records the parameters (any JSON encodable value, typically a params struct) under the name, with the hash of their JSON
+func (bm *BuildManifest) AddParams(name string, params interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	sum := sha256.Sum256(b)
+	bm.Params[name] = b
+	bm.ParamHashes[name] = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// AddSeed records the random seed under the name
+func (bm *BuildManifest) AddSeed(name string, seed int64) {
+	bm.Seeds[name] = seed
+}
+
+// AddFile records the size and SHA-256 checksum of the file
+func (bm *BuildManifest) AddFile(path string) error {
+	fp, err := os.Open(path)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer fp.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, fp)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	bm.Files = append(bm.Files, FileSum{Path: path, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))})
+	return nil
+}
+
+// AddSndEnv records the processing parameters of the SndEnv, each under prefix plus its field name
+// (Params, DFT, Mel, Band, TimePool, Splice, GaborSpecs, NeighInhib, Kwta and MelAffine, if set), and its Seed --
+// add them before Init, which sets derived fields that would change the hashes
+func (bm *BuildManifest) AddSndEnv(prefix string, se *SndEnv) error {
+	ps := map[string]interface{}{
+		"Params":     &se.Params,
+		"DFT":        &se.DFT,
+		"Mel":        &se.Mel,
+		"Band":       []int{se.BandLo, se.BandHi},
+		"TimePool":   &se.TimePool,
+		"Splice":     &se.Splice,
+		"GaborSpecs": se.GaborSpecs,
+		"NeighInhib": &se.NeighInhib,
+		"Kwta":       map[string]interface{}{"Kwta": &se.Kwta, "KwtaPool": se.KwtaPool},
+	}
+	if se.MelAffine != nil {
+		ps["MelAffine"] = se.MelAffine
+	}
+	for nm, p := range ps {
+		if err := bm.AddParams(prefix+nm, p); err != nil {
+			return err
+		}
+	}
+	bm.AddSeed(prefix+"Seed", se.Seed)
+	return nil
+}
+
+// Diff returns the differences of the other manifest from this one that would make their features differ --
+// the version, parameter hashes, seeds and files -- empty if they match
+func (bm *BuildManifest) Diff(other *BuildManifest) []string {
+	var diffs []string
+	if bm.Version != other.Version {
+		diffs = append(diffs, fmt.Sprintf("version %v != %v", bm.Version, other.Version))
+	}
+	names := map[string]bool{}
+	for nm := range bm.ParamHashes {
+		names[nm] = true
+	}
+	for nm := range other.ParamHashes {
+		names[nm] = true
+	}
+	for _, nm := range sortedKeys(names) {
+		if bm.ParamHashes[nm] != other.ParamHashes[nm] {
+			diffs = append(diffs, fmt.Sprintf("params %v differ", nm))
+		}
+	}
+	names = map[string]bool{}
+	for nm := range bm.Seeds {
+		names[nm] = true
+	}
+	for nm := range other.Seeds {
+		names[nm] = true
+	}
+	for _, nm := range sortedKeys(names) {
+		s, ok := bm.Seeds[nm]
+		so, sok := other.Seeds[nm]
+		if s != so || ok != sok {
+			diffs = append(diffs, fmt.Sprintf("seed %v differs", nm))
+		}
+	}
+	if len(bm.Files) != len(other.Files) {
+		diffs = append(diffs, fmt.Sprintf("%v files != %v files", len(bm.Files), len(other.Files)))
+		return diffs
+	}
+	for i, f := range bm.Files {
+		if f != other.Files[i] {
+			diffs = append(diffs, fmt.Sprintf("file %v: %v differs", i, f.Path))
+		}
+	}
+	return diffs
+}
+
+// sortedKeys returns the keys of the set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// OpenJSON opens the manifest from a JSON-formatted file
+func (bm *BuildManifest) OpenJSON(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	*bm = BuildManifest{}
+	return json.Unmarshal(b, bm)
+}
+
+// SaveJSON saves the manifest to a JSON-formatted file
+func (bm *BuildManifest) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(bm, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math"
+
+	"github.com/emer/etable/etensor"
+)
+
+// ResampleZeros is the number of zero crossings on each side of the windowed sinc interpolation of Resample
+const ResampleZeros = 16
+
+// Resample resamples the signal from fromRate to toRate (samples per second), in place, by band limited interpolation
+// with a Hann windowed sinc that, when downsampling, also low pass filters the signal below the new nyquist frequency
+func Resample(sig *etensor.Float64, fromRate, toRate int) {
+	out := ResampleSlice(sig.Values, fromRate, toRate)
+	sig.SetShape([]int{len(out)}, nil, nil)
+	copy(sig.Values, out)
+}
+
+// ResampleSlice returns the samples resampled from fromRate to toRate, see Resample -- the samples themselves if the rates are the same
+func ResampleSlice(in []float64, fromRate, toRate int) []float64 {
+	if fromRate == toRate || fromRate <= 0 || toRate <= 0 || len(in) == 0 {
+		return in
+	}
+	ratio := float64(toRate) / float64(fromRate)
+	cutoff := math.Min(1, ratio)            // of the input nyquist frequency
+	half := float64(ResampleZeros) / cutoff // half width of the filter in input samples
+	n := int(math.Round(float64(len(in)) * ratio))
+	out := make([]float64, n)
+	for i := range out {
+		t := float64(i) / ratio // time of the output sample in input samples
+		lo := int(math.Ceil(t - half))
+		hi := int(math.Floor(t + half))
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(in)-1 {
+			hi = len(in) - 1
+		}
+		sum := 0.0
+		for j := lo; j <= hi; j++ {
+			x := t - float64(j)
+			w := 0.5 + 0.5*math.Cos(math.Pi*x/half) // Hann window
+			sum += in[j] * w * cutoff * sinc(cutoff*x)
+		}
+		out[i] = sum
+	}
+	return out
+}
+
+// sinc returns sin(pi x) / (pi x)
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// Resample resamples every channel of the sound to the rate, e.g., 16000, so sounds of different rates give the same
+// number of samples per window and step -- the samples are clipped to the range of the bit depth
+func (snd *Wave) Resample(rate int) {
+	if snd.Buf == nil || rate <= 0 || snd.SampleRate() == rate {
+		return
+	}
+	sr := snd.SampleRate()
+	nch := snd.Channels()
+	if nch < 1 {
+		nch = 1
+	}
+	nfr := snd.Buf.NumFrames()
+	mx := float64(int64(1)<<uint(snd.Buf.SourceBitDepth-1) - 1)
+	var data []int
+	for c := 0; c < nch; c++ {
+		ch := make([]float64, nfr)
+		for i := range ch {
+			ch[i] = float64(snd.Buf.Data[i*nch+c])
+		}
+		rs := ResampleSlice(ch, sr, rate)
+		if data == nil {
+			data = make([]int, len(rs)*nch)
+		}
+		for i, v := range rs {
+			data[i*nch+c] = int(math.Round(math.Max(-mx-1, math.Min(mx, v))))
+		}
+	}
+	snd.Buf.Data = data
+	snd.Buf.Format.SampleRate = rate
+}
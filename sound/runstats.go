@@ -0,0 +1,149 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+
+	"github.com/emer/etable/etensor"
+)
+
+// RunStats keeps the running mean and variance (Welford) of each of a number of features and standardizes
+// feature tensors on the fly, e.g., the mel filter bank output over a long training run, as an alternative to
+// normalization statistics computed beforehand. By default each row of a tensor (the values of all but the outermost
+// dimension) is one observation of the features. With ByCol the features are the outermost dimension and each
+// column is an observation, e.g., the filters of MelFBankSegment [filters, steps], with one observation per step.
+// Freeze the statistics once they are stable, e.g., for testing, and save them with SaveJSON
+type RunStats struct {
+
+	// number of observations
+	N int64 `inactive:"+" desc:"number of observations"`
+
+	// running mean of each feature
+	Mean []float64 `view:"-" desc:"running mean of each feature"`
+
+	// running sum of the squared differences from the mean of each feature
+	M2 []float64 `view:"-" desc:"running sum of the squared differences from the mean of each feature"`
+
+	// if true the statistics are not updated, Standardize only uses them
+	Frozen bool `desc:"if true the statistics are not updated, Standardize only uses them"`
+
+	// the features are the outermost dimension of the tensors and each column (index of the other dimensions) is an observation, e.g., for MelFBankSegment [filters, steps]
+	ByCol bool `desc:"the features are the outermost dimension of the tensors and each column (index of the other dimensions) is an observation, e.g., for MelFBankSegment [filters, steps]"`
+
+	// [def: 1e-8] added to the variance before dividing, so features with no variance are not blown up
+	Eps float64 `default:"1e-8" desc:"added to the variance before dividing, so features with no variance are not blown up"`
+}
+
+// Init resets the statistics for nfeat features
+func (rs *RunStats) Init(nfeat int) {
+	rs.N = 0
+	rs.Mean = make([]float64, nfeat)
+	rs.M2 = make([]float64, nfeat)
+	rs.Frozen = false
+	if rs.Eps == 0 {
+		rs.Eps = 1e-8
+	}
+}
+
+// Var returns the variance of feature i, 0 until there are 2 observations
+func (rs *RunStats) Var(i int) float64 {
+	if rs.N < 2 {
+		return 0
+	}
+	return rs.M2[i] / float64(rs.N)
+}
+
+// rows checks the tensor against the number of features, initializing the statistics if they are empty, and returns the number of observations
+func (rs *RunStats) rows(tsr etensor.Tensor) (int, error) {
+	if len(rs.Mean) == 0 && rs.N == 0 {
+		if rs.ByCol {
+			rs.Init(tsr.Dim(0))
+		} else {
+			rs.Init(tsr.Len() / tsr.Dim(0))
+		}
+	}
+	nf := len(rs.Mean)
+	if nf == 0 || tsr.Len()%nf != 0 {
+		err := fmt.Errorf("sound.RunStats: tensor of %v values is not rows of %v features", tsr.Len(), nf)
+		log.Println(err)
+		return 0, err
+	}
+	return tsr.Len() / nf, nil
+}
+
+// idx returns the index of the value of feature i of observation r in a tensor of nr observations
+func (rs *RunStats) idx(r, i, nr int) int {
+	if rs.ByCol {
+		return i*nr + r
+	}
+	return r*len(rs.Mean) + i
+}
+
+// Update adds each observation (row, or column with ByCol) of the tensor to the statistics, unless Frozen
+func (rs *RunStats) Update(tsr etensor.Tensor) error {
+	nr, err := rs.rows(tsr)
+	if err != nil || rs.Frozen {
+		return err
+	}
+	nf := len(rs.Mean)
+	for r := 0; r < nr; r++ {
+		rs.N++
+		for i := 0; i < nf; i++ {
+			v := tsr.FloatVal1D(rs.idx(r, i, nr))
+			d := v - rs.Mean[i]
+			rs.Mean[i] += d / float64(rs.N)
+			rs.M2[i] += d * (v - rs.Mean[i])
+		}
+	}
+	return nil
+}
+
+// Standardize updates the statistics with the tensor (see Update) and then sets each value to its
+// difference from the feature mean divided by the feature standard deviation
+func (rs *RunStats) Standardize(tsr etensor.Tensor) error {
+	if err := rs.Update(tsr); err != nil {
+		return err
+	}
+	nr, _ := rs.rows(tsr)
+	nf := len(rs.Mean)
+	for i := 0; i < nf; i++ {
+		sd := math.Sqrt(rs.Var(i) + rs.Eps)
+		for r := 0; r < nr; r++ {
+			idx := rs.idx(r, i, nr)
+			tsr.SetFloat1D(idx, (tsr.FloatVal1D(idx)-rs.Mean[i])/sd)
+		}
+	}
+	return nil
+}
+
+// OpenJSON opens the statistics from a JSON-formatted file
+func (rs *RunStats) OpenJSON(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	*rs = RunStats{}
+	return json.Unmarshal(b, rs)
+}
+
+// SaveJSON saves the statistics to a JSON-formatted file
+func (rs *RunStats) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
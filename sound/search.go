@@ -0,0 +1,203 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"errors"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/emer/etable/etensor"
+)
+
+// SubseqDTWFrames finds the part of the frame sequence doc that best matches the whole query sequence by subsequence
+// dynamic time warping: as DTWFrames, but the path can start and end at any frame of doc. The Path of the result
+// pairs query frames with doc frames, and start and end (exclusive) are the doc frames matched
+func SubseqDTWFrames(query, doc [][]float64) (res *DTWResult, start, end int, err error) {
+	n, m := len(query), len(doc)
+	if n == 0 || m == 0 {
+		err = errors.New("sound.SubseqDTW: empty sequence")
+		log.Println(err)
+		return nil, 0, 0, err
+	}
+	cost := make([][]float64, n)
+	for i := range cost {
+		cost[i] = make([]float64, m)
+		for j := range cost[i] {
+			d := 0.0
+			for k := range query[i] {
+				d += (query[i][k] - doc[j][k]) * (query[i][k] - doc[j][k])
+			}
+			d = math.Sqrt(d)
+			if i == 0 { // the match can start at any doc frame
+				cost[i][j] = d
+				continue
+			}
+			best := cost[i-1][j]
+			if j > 0 {
+				best = math.Min(best, math.Min(cost[i][j-1], cost[i-1][j-1]))
+			}
+			cost[i][j] = best + d
+		}
+	}
+	end = 0
+	for j := 1; j < m; j++ {
+		if cost[n-1][j] < cost[n-1][end] {
+			end = j
+		}
+	}
+
+	res = &DTWResult{Dist: cost[n-1][end]}
+	i, j := n-1, end
+	res.Path = append(res.Path, [2]int{i, j})
+	for i > 0 {
+		switch {
+		case j == 0:
+			i--
+		default:
+			d, l, u := cost[i-1][j-1], cost[i][j-1], cost[i-1][j]
+			switch {
+			case d <= l && d <= u:
+				i, j = i-1, j-1
+			case l <= u:
+				j--
+			default:
+				i--
+			}
+		}
+		res.Path = append(res.Path, [2]int{i, j})
+	}
+	for l, r := 0, len(res.Path)-1; l < r; l, r = l+1, r-1 {
+		res.Path[l], res.Path[r] = res.Path[r], res.Path[l]
+	}
+	start = j
+	end++
+	res.NormDist = res.Dist / float64(n+end-start)
+	return res, start, end, nil
+}
+
+// SearchDoc is a frame sequence of a corpus searched by a SearchIndex, e.g., the mel output of a unit
+type SearchDoc struct {
+
+	// name of the sequence, e.g., the feature store record name
+	Name string `desc:"name of the sequence, e.g., the feature store record name"`
+
+	// the frames of the sequence, each a slice of the features
+	Frames [][]float64 `view:"-" desc:"the frames of the sequence, each a slice of the features"`
+
+	// mean of the frames, for the Prefilter
+	mean []float64
+}
+
+// SearchHit is a match of a query in a document of a SearchIndex
+type SearchHit struct {
+
+	// index of the document in the SearchIndex
+	Doc int `desc:"index of the document in the SearchIndex"`
+
+	// name of the document
+	Name string `desc:"name of the document"`
+
+	// first frame of the document matched
+	Start int `desc:"first frame of the document matched"`
+
+	// end (last + 1) frame of the document matched
+	End int `desc:"end (last + 1) frame of the document matched"`
+
+	// distance of the match, see SubseqDTWFrames
+	Dist float64 `desc:"distance of the match, see SubseqDTWFrames"`
+
+	// distance divided by the number of query and matched frames, the hits are ranked by it
+	NormDist float64 `desc:"distance divided by the number of query and matched frames, the hits are ranked by it"`
+}
+
+// SearchIndex is query by example search over a processed corpus: Search aligns a short query, e.g., the mel frames
+// of a word, with each document by subsequence dynamic time warping (see SubseqDTWFrames) and returns the best matches,
+// with the frames of the documents they are in. The documents are the frame sequences of the corpus, e.g., the records
+// of the feature stores of featbuild (see AddStore)
+type SearchIndex struct {
+
+	// the documents of the corpus
+	Docs []SearchDoc `desc:"the documents of the corpus"`
+
+	// [def: 0] if > 0 only the Prefilter documents whose mean frame is closest to the mean frame of the query are aligned,
+	// which is much faster for large corpora but can miss matches in long documents
+	Prefilter int `default:"0" desc:"if > 0 only the Prefilter documents whose mean frame is closest to the mean frame of the query are aligned, which is much faster for large corpora but can miss matches in long documents"`
+}
+
+// meanFrame returns the mean of the frames
+func meanFrame(frames [][]float64) []float64 {
+	if len(frames) == 0 {
+		return nil
+	}
+	mean := make([]float64, len(frames[0]))
+	for _, f := range frames {
+		for i, v := range f {
+			mean[i] += v
+		}
+	}
+	for i := range mean {
+		mean[i] /= float64(len(frames))
+	}
+	return mean
+}
+
+// Add adds a document of frames, each a slice of the same features as the queries
+func (si *SearchIndex) Add(name string, frames [][]float64) {
+	si.Docs = append(si.Docs, SearchDoc{Name: name, Frames: frames, mean: meanFrame(frames)})
+}
+
+// AddTensor adds a document of a [features, steps] tensor, e.g., MelFBankSegment or a feature store record
+func (si *SearchIndex) AddTensor(name string, tsr etensor.Tensor) {
+	nf, steps := tsr.Dim(0), tsr.Dim(1)
+	frames := make([][]float64, steps)
+	for s := range frames {
+		frames[s] = make([]float64, nf)
+		for i := range frames[s] {
+			frames[s][i] = tsr.FloatVal1D(i*steps + s)
+		}
+	}
+	si.Add(name, frames)
+}
+
+// AddStore adds every record of the feature store, e.g., of the mel feature, as a document named prefix + the record name
+func (si *SearchIndex) AddStore(fs *FeatStore, prefix string) {
+	for i := 0; i < fs.Len(); i++ {
+		si.AddTensor(prefix+fs.Index.Records[i].Name, fs.Tensor(i))
+	}
+}
+
+// Search returns the k best matches of the query frames in the documents, at most one per document, ranked by NormDist
+func (si *SearchIndex) Search(query [][]float64, k int) []SearchHit {
+	docs := make([]int, len(si.Docs))
+	for i := range docs {
+		docs[i] = i
+	}
+	if si.Prefilter > 0 && si.Prefilter < len(docs) {
+		qm := meanFrame(query)
+		dist := make([]float64, len(si.Docs))
+		for i := range si.Docs {
+			for j, v := range si.Docs[i].mean {
+				dist[i] += (v - qm[j]) * (v - qm[j])
+			}
+		}
+		sort.SliceStable(docs, func(a, b int) bool { return dist[docs[a]] < dist[docs[b]] })
+		docs = docs[:si.Prefilter]
+	}
+	var hits []SearchHit
+	for _, d := range docs {
+		res, st, ed, err := SubseqDTWFrames(query, si.Docs[d].Frames)
+		if err != nil {
+			continue
+		}
+		hits = append(hits, SearchHit{Doc: d, Name: si.Docs[d].Name, Start: st, End: ed, Dist: res.Dist, NormDist: res.NormDist})
+	}
+	sort.SliceStable(hits, func(a, b int) bool { return hits[a].NormDist < hits[b].NormDist })
+	if k > 0 && len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
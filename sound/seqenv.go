@@ -0,0 +1,366 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+
+	"github.com/emer/auditory/speech"
+	"github.com/emer/emergent/env"
+	"github.com/emer/etable/etensor"
+)
+
+// SeqEnv is an env.Env that steps through the segments of a set of labeled sound files,
+// processing each segment with SndEnv. Each trial is one segment of the current file and
+// the states are the feature tensors for that segment plus a one-hot label state
+// for the unit (phone, CV, word...) at the center of the segment.
+type SeqEnv struct {
+
+	// name of this environment
+	Nm string `desc:"name of this environment"`
+
+	// description of this environment
+	Dsc string `desc:"description of this environment"`
+
+	// the sound processing pipeline -- set the params, gabor specs and output shape before calling Init
+	Snd SndEnv `desc:"the sound processing pipeline -- set the params, gabor specs and output shape before calling Init"`
+
+	// [view: no-inline] the labeled sound files, one sequence per file
+	Seqs speech.Sequences `view:"no-inline" desc:"the labeled sound files, one sequence per file"`
+
+	// the label categories, e.g., timit.PhoneCats41 -- the Label state is a one-hot tensor over these
+	Labels []string `desc:"the label categories, e.g., timit.PhoneCats41 -- the Label state is a one-hot tensor over these"`
+
+	// [view: -] optional function mapping a unit name to a label index (e.g. timit.IdxFmSnd for collapsing phone sets) -- if nil the index of the name in Labels is used
+	LabelFunc func(name string) (idx int, ok bool) `view:"-" desc:"optional function mapping a unit name to a label index (e.g. timit.IdxFmSnd for collapsing phone sets) -- if nil the index of the name in Labels is used"`
+
+	// soft labels near the boundaries of the units, the Label state mixing the labels of the units on either side of a boundary -- hard labels by default
+	Soft speech.SoftLabels `desc:"soft labels near the boundaries of the units, the Label state mixing the labels of the units on either side of a boundary -- hard labels by default"`
+
+	// [view: -] optional function augmenting the Signal of Snd after each sound file is loaded, before Init, e.g., the Augment method of augment.Params for noise, reverberation and gain -- called each time a file is loaded, so each presentation can differ
+	Augment func(snd *SndEnv) error `view:"-" desc:"optional function augmenting the Signal of Snd after each sound file is loaded, before Init, e.g., the Augment method of augment.Params for noise, reverberation and gain -- called each time a file is loaded, so each presentation can differ"`
+
+	// [view: -] speaker adaptation transforms of the mel output by speaker (see speech.Sequence.Speaker) -- if set, the transform of the speaker of each sound file is applied (see SndEnv.MelAffine), none for speakers without one
+	Adapt SpeakerAffines `view:"-" desc:"speaker adaptation transforms of the mel output by speaker (see speech.Sequence.Speaker) -- if set, the transform of the speaker of each sound file is applied (see SndEnv.MelAffine), none for speakers without one"`
+
+	// [view: -] speaker embedder, e.g., a GMMEmbedder -- if set, the Speaker state is the embedding of each sound file, computed when it is loaded
+	Embedder SpeakerEmbedder `view:"-" desc:"speaker embedder, e.g., a GMMEmbedder -- if set, the Speaker state is the embedding of each sound file, computed when it is loaded"`
+
+	// speaker embedding of the current sound file, if Embedder is set
+	Speaker etensor.Float32 `desc:"speaker embedding of the current sound file, if Embedder is set"`
+
+	// present the sound files in sequential order, otherwise permuted random order
+	Sequential bool `desc:"present the sound files in sequential order, otherwise permuted random order"`
+
+	// permuted order of sound files to present if not sequential -- updated every epoch
+	Order []int `desc:"permuted order of sound files to present if not sequential -- updated every epoch"`
+
+	// [def: 0] jitter the start of each segment by a random number of milliseconds in -JitterMs..+JitterMs, for translation augmentation in time -- the jitter is passed to SndEnv.ProcessSegment as the add offset so segments near the start of the sound are padded as for the border steps, and is limited so segments don't run past the end of the sound
+	JitterMs int `default:"0" desc:"jitter the start of each segment by a random number of milliseconds in -JitterMs..+JitterMs, for translation augmentation in time -- the jitter is passed to SndEnv.ProcessSegment as the add offset so segments near the start of the sound are padded as for the border steps, and is limited so segments don't run past the end of the sound"`
+
+	// [def: 0] segments with a smaller proportion of speech steps than this, by the voice activity detection of Snd (see SndEnv.VAD and SpeechFrac), are skipped by Step so silent segments don't become trials -- 0 to skip none, and only if Snd.VAD is on
+	MinSpeech float64 `default:"0" desc:"segments with a smaller proportion of speech steps than this, by the voice activity detection of Snd (see SndEnv.VAD and SpeechFrac), are skipped by Step so silent segments don't become trials -- 0 to skip none, and only if Snd.VAD is on"`
+
+	// [view: -] random number source for the jitter, derived from Snd.Seed and the run so each run is different but reproducible
+	JitterRand *rand.Rand `view:"-" desc:"random number source for the jitter, derived from Snd.Seed and the run so each run is different but reproducible"`
+
+	// [view: -] random number source for the order of the sound files, derived from Snd.Seed and the run
+	OrderRand *rand.Rand `view:"-" desc:"random number source for the order of the sound files, derived from Snd.Seed and the run"`
+
+	// jitter in milliseconds of the current segment
+	Jitter int `inactive:"+" desc:"jitter in milliseconds of the current segment"`
+
+	// [view: inline] current run of model as provided during Init
+	Run env.Ctr `view:"inline" desc:"current run of model as provided during Init"`
+
+	// [view: inline] number of times through the entire set of sound files
+	Epoch env.Ctr `view:"inline" desc:"number of times through the entire set of sound files"`
+
+	// [view: inline] current sound file -- index into Order if not Sequential
+	Seq env.Ctr `view:"inline" desc:"current sound file -- index into Order if not Sequential"`
+
+	// [view: inline] current segment of the current sound file
+	Trial env.Ctr `view:"inline" desc:"current segment of the current sound file"`
+
+	// name of the unit at the center of the current segment
+	Unit env.CurPrvString `desc:"name of the unit at the center of the current segment"`
+
+	// one-hot label of the unit at the center of the current segment, or the soft label mixing it with an adjacent unit (see Soft)
+	Label etensor.Float32 `desc:"one-hot label of the unit at the center of the current segment, or the soft label mixing it with an adjacent unit (see Soft)"`
+
+	// [view: -] gabor output of the current segment, post kwta if Kwta is on
+	Output *etensor.Float32 `view:"-" desc:"gabor output of the current segment, post kwta if Kwta is on"`
+}
+
+func (se *SeqEnv) Name() string { return se.Nm }
+func (se *SeqEnv) Desc() string { return se.Dsc }
+
+func (se *SeqEnv) Validate() error {
+	if len(se.Seqs) == 0 {
+		return fmt.Errorf("sound.SeqEnv: %v has no sequences (sound files) set", se.Nm)
+	}
+	if len(se.Labels) == 0 {
+		return fmt.Errorf("sound.SeqEnv: %v has no Labels set", se.Nm)
+	}
+	return nil
+}
+
+func (se *SeqEnv) Init(run int) {
+	se.Run.Scale = env.Run
+	se.Epoch.Scale = env.Epoch
+	se.Seq.Scale = env.Sequence
+	se.Trial.Scale = env.Trial
+	se.Run.Init()
+	se.Epoch.Init()
+	se.Seq.Init()
+	se.Trial.Init()
+	se.Run.Cur = run
+	se.OrderRand = se.Snd.NewRand(fmt.Sprintf("order%d", run))
+	se.Order = se.OrderRand.Perm(len(se.Seqs))
+	se.Seq.Max = len(se.Seqs)
+	se.Seq.Cur = -1 // init state -- key so that first Step() loads the first sound file
+	se.Trial.Cur = -1
+	se.Label.SetShape([]int{len(se.Labels)}, nil, nil)
+	se.JitterRand = se.Snd.NewRand(fmt.Sprintf("jitter%d", run))
+	se.Jitter = 0
+}
+
+// CurSeq returns the sequence (sound file) currently being processed
+func (se *SeqEnv) CurSeq() *speech.Sequence {
+	if se.Seq.Cur < 0 {
+		return nil
+	}
+	if se.Sequential {
+		return &se.Seqs[se.Seq.Cur]
+	}
+	return &se.Seqs[se.Order[se.Seq.Cur]]
+}
+
+// LoadSeq loads the sound file of the current sequence and initializes the processing for it
+func (se *SeqEnv) LoadSeq() error {
+	seq := se.CurSeq()
+	err := se.Snd.Sound.Load(seq.File)
+	if err != nil {
+		return err
+	}
+	se.Snd.ToTensor()
+	if se.Augment != nil {
+		if err = se.Augment(&se.Snd); err != nil {
+			return err
+		}
+	}
+	err = se.Snd.Init()
+	if err != nil {
+		return err
+	}
+	se.Snd.MelAffine = nil
+	if se.Adapt != nil {
+		se.Snd.MelAffine = se.Adapt[seq.Speaker()]
+	}
+	if se.Embedder != nil {
+		err = se.Snd.SpeakerEmbedding(se.Embedder, &se.Speaker)
+		if err != nil {
+			return err
+		}
+	}
+	se.Trial.Max = se.Snd.SegCnt
+	return nil
+}
+
+// NextSeq moves on to the next sound file, permuting the order at the end of each epoch
+func (se *SeqEnv) NextSeq() error {
+	if se.Seq.Incr() { // if true, hit max, reset to 0
+		Permute(se.OrderRand, se.Order)
+		se.Epoch.Incr()
+	}
+	se.Trial.Set(0)
+	return se.LoadSeq()
+}
+
+// SetLabel sets the Unit name and the one-hot Label state for the current segment, or the soft label near a boundary (see Soft)
+func (se *SeqEnv) SetLabel() {
+	se.Label.SetZeros()
+	sr := se.Snd.Sound.SampleRate()
+	ms := SamplesToMSec(se.Trial.Cur*se.Snd.Params.StrideSamples, sr) + se.Snd.Params.SegmentMs/2 + float64(se.Jitter)
+	seq := se.CurSeq()
+	uis, wts := se.Soft.Weights(seq.Units, ms)
+	if len(uis) == 0 {
+		se.Unit.Set("")
+		return
+	}
+	se.Unit.Set(seq.Units[uis[0]].Name)
+	for i, ui := range uis {
+		idx, ok := se.LabelIdx(seq.Units[ui].Name)
+		if ok && idx >= 0 && idx < len(se.Labels) {
+			se.Label.Values[idx] += float32(wts[i])
+		}
+	}
+}
+
+// LabelIdx returns the index of the label of the unit name, by LabelFunc if set, otherwise the index of the name in Labels
+func (se *SeqEnv) LabelIdx(nm string) (int, bool) {
+	if se.LabelFunc != nil {
+		return se.LabelFunc(nm)
+	}
+	for i, l := range se.Labels {
+		if l == nm {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// NewJitter returns a random jitter in milliseconds for the current segment, within -JitterMs..+JitterMs
+// but no later than the last start that keeps the segment within the sound
+func (se *SeqEnv) NewJitter() int {
+	if se.JitterMs <= 0 {
+		return 0
+	}
+	if se.JitterRand == nil {
+		se.JitterRand = se.Snd.NewRand(fmt.Sprintf("jitter%d", se.Run.Cur))
+	}
+	jit := se.JitterRand.Intn(2*se.JitterMs+1) - se.JitterMs
+	prm := &se.Snd.Params
+	last := se.Trial.Cur*prm.StrideSamples + prm.Steps[prm.SegmentSteps-1] + prm.WinSamples
+	maxMs := int(SamplesToMSec(se.Snd.NSamples()-last, se.Snd.Sound.SampleRate()))
+	if jit > maxMs {
+		jit = maxMs
+	}
+	if jit < -se.JitterMs { // segment already runs past the end without jitter
+		jit = -se.JitterMs
+	}
+	return jit
+}
+
+// Step processes the next segment, loading the next sound file at the end of the current one -- files that fail to
+// load or Init, e.g., signals shorter than one segment without Snd.Params.PadShort, are logged and skipped, and Step
+// returns false only if none of the files load
+func (se *SeqEnv) Step() bool {
+	se.Epoch.Same() // good idea to just reset all non-inner-most counters at start
+	se.Seq.Same()
+
+	failed := false
+	for loads := 0; ; {
+		if se.Seq.Cur < 0 || failed || se.Trial.Incr() { // first step, last file failed or hit max segments for this file
+			err := se.NextSeq()
+			loads++
+			failed = err != nil
+			if failed { // e.g., too short without Snd.Params.PadShort -- skip the file
+				log.Printf("sound.SeqEnv: skipping %v: %v\n", se.CurSeq().File, err)
+				if loads > len(se.Seqs) { // none of the files load
+					return false
+				}
+				continue
+			}
+		}
+		se.Jitter = se.NewJitter()
+		se.Snd.ProcessSegment(se.Trial.Cur, se.Jitter)
+		if !se.Silent() || loads > len(se.Seqs) { // all the files silent, don't skip forever
+			break
+		}
+	}
+	se.Output = se.Snd.ApplyGabor()
+	se.SetLabel()
+	return true
+}
+
+// Silent returns true if the current segment is to be skipped by Step as it has too few speech steps, see MinSpeech
+func (se *SeqEnv) Silent() bool {
+	return se.Snd.VAD.On && se.MinSpeech > 0 && se.Snd.SpeechFrac() < se.MinSpeech
+}
+
+func (se *SeqEnv) Counter(scale env.TimeScales) (cur, prv int, chg bool) {
+	switch scale {
+	case env.Run:
+		return se.Run.Query()
+	case env.Epoch:
+		return se.Epoch.Query()
+	case env.Sequence:
+		return se.Seq.Query()
+	case env.Trial:
+		return se.Trial.Query()
+	}
+	return -1, -1, false
+}
+
+// State returns the named state element -- "Gabor" (post kwta if on), "Mel", "MFCC", "Power", "Label",
+// "MelPooled" and "MFCCPooled", the mel and mfcc output pooled over time if Snd.TimePool is on,
+// "MelSpliced" and "MFCCSpliced", the mel and mfcc frames stacked with their neighbors if Snd.Splice is on,
+// "Pitch", the pitch of each step if Snd.Pitch is on, "Features", the spectral features of each step if
+// Snd.Features is on, "Envelope", the hilbert envelopes of each step if Snd.Envelope is on, "VAD", the speech
+// steps of the segment if Snd.VAD is on, or "Speaker", the speaker embedding of the sound file if Embedder is set
+func (se *SeqEnv) State(element string) etensor.Tensor {
+	switch element {
+	case "Gabor":
+		return se.Output
+	case "Mel":
+		return &se.Snd.MelFBankSegment
+	case "MFCC":
+		return &se.Snd.MFCCSegment
+	case "MelPooled":
+		return &se.Snd.MelPooled
+	case "MFCCPooled":
+		return &se.Snd.MFCCPooled
+	case "MelSpliced":
+		return &se.Snd.MelSpliced
+	case "MFCCSpliced":
+		return &se.Snd.MFCCSpliced
+	case "Power":
+		return &se.Snd.LogPowerSegment
+	case "Label":
+		return &se.Label
+	case "Pitch":
+		return &se.Snd.PitchSegment
+	case "Features":
+		return &se.Snd.FeatSegment
+	case "Envelope":
+		return &se.Snd.EnvSegment
+	case "VAD":
+		return &se.Snd.VADMask
+	case "Speaker":
+		return &se.Speaker
+	}
+	return nil
+}
+
+func (se *SeqEnv) Action(element string, input etensor.Tensor) {
+	// nop
+}
+
+// Compile-time check that implements Env interface
+var _ env.Env = (*SeqEnv)(nil)
+
+/////////////////////////////////////////////////////
+// EnvDesc -- optional but implemented here
+
+func (se *SeqEnv) Counters() []env.TimeScales {
+	return []env.TimeScales{env.Run, env.Epoch, env.Sequence, env.Trial}
+}
+
+// States returns the state elements -- shapes are only valid after the first sound file is loaded
+func (se *SeqEnv) States() env.Elements {
+	return env.Elements{
+		{"Gabor", se.Snd.GborKwta.Shapes(), nil},
+		{"Mel", se.Snd.MelFBankSegment.Shapes(), nil},
+		{"MFCC", se.Snd.MFCCSegment.Shapes(), nil},
+		{"Power", se.Snd.LogPowerSegment.Shapes(), nil},
+		{"Label", []int{len(se.Labels)}, nil},
+		{"MelPooled", se.Snd.MelPooled.Shapes(), nil},
+		{"MFCCPooled", se.Snd.MFCCPooled.Shapes(), nil},
+		{"MelSpliced", se.Snd.MelSpliced.Shapes(), nil},
+		{"MFCCSpliced", se.Snd.MFCCSpliced.Shapes(), nil},
+		{"Pitch", se.Snd.PitchSegment.Shapes(), nil},
+		{"Features", se.Snd.FeatSegment.Shapes(), nil},
+		{"Envelope", se.Snd.EnvSegment.Shapes(), nil},
+		{"VAD", se.Snd.VADMask.Shapes(), nil},
+		{"Speaker", se.Speaker.Shapes(), nil},
+	}
+}
+
+func (se *SeqEnv) Actions() env.Elements {
+	return nil
+}
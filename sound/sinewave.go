@@ -0,0 +1,57 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math"
+)
+
+// SinewaveSpeech synthesizes sinewave speech (Remez et al, 1981) from the formant track, one time-varying sinusoid per formant
+// following the frequency and amplitude of the formant, linearly interpolated between frames with continuous phase.
+// nsamples is the length of the output, e.g. the length of the original signal
+func SinewaveSpeech(ft *FormantTrack, sampleRate, nsamples int) []float64 {
+	out := make([]float64, nsamples)
+	nfr := ft.NFrames()
+	if nfr == 0 {
+		return out
+	}
+	nf := ft.Hz.Dim(1)
+	step := ft.StepMs * float64(sampleRate) / 1000
+	phase := make([]float64, nf)
+	for i := range out {
+		pos := float64(i) / step
+		f0 := int(pos)
+		if f0 >= nfr-1 {
+			f0 = nfr - 1
+		}
+		f1 := f0 + 1
+		if f1 >= nfr {
+			f1 = nfr - 1
+		}
+		fr := pos - float64(f0)
+		if fr > 1 {
+			fr = 1
+		}
+		for k := 0; k < nf; k++ {
+			hz := (1-fr)*ft.Hz.Value([]int{f0, k}) + fr*ft.Hz.Value([]int{f1, k})
+			amp := (1-fr)*ft.Amp.Value([]int{f0, k}) + fr*ft.Amp.Value([]int{f1, k})
+			phase[k] = math.Mod(phase[k]+2*math.Pi*hz/float64(sampleRate), 2*math.Pi)
+			out[i] += amp * math.Sin(phase[k])
+		}
+	}
+	return out
+}
+
+// SinewaveReplica returns a sinewave speech replica of the signal, e.g. an utterance, tracking its formants
+// with fp (see Formants) and synthesizing a sinusoid for each
+func SinewaveReplica(signal []float64, sampleRate int, fp *FormantParams) []float64 {
+	ft := Formants(signal, sampleRate, fp)
+	return SinewaveSpeech(ft, sampleRate, len(signal))
+}
+
+// SinewaveSignal replaces the Signal with its sinewave speech replica, see SinewaveReplica
+func (se *SndEnv) SinewaveSignal(fp *FormantParams) {
+	copy(se.Signal.Values, SinewaveReplica(se.Signal.Values, se.Sound.SampleRate(), fp))
+}
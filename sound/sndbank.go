@@ -0,0 +1,196 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+
+	"github.com/emer/auditory/agabor"
+	"github.com/emer/auditory/speech"
+	"github.com/emer/etable/etensor"
+)
+
+// UnitRef identifies a unit by the index of its sequence (sound file) and its index in the sequence
+type UnitRef struct {
+	File int
+	Unit int
+}
+
+// UnitFeatures are the features of the segment centered on a unit, see SndBank.Process
+type UnitFeatures struct {
+
+	// unit the features are for
+	Ref UnitRef `desc:"unit the features are for"`
+
+	// name of the unit
+	Name string `desc:"name of the unit"`
+
+	// mel filter bank output of the segment
+	Mel etensor.Float64 `desc:"mel filter bank output of the segment"`
+
+	// mfcc of the segment, if Mel.MFCC is on
+	MFCC etensor.Float64 `desc:"mfcc of the segment, if Mel.MFCC is on"`
+
+	// gabor output of the segment (post kwta if Kwta is on), if there are gabor specs
+	Gabor etensor.Float32 `desc:"gabor output of the segment (post kwta if Kwta is on), if there are gabor specs"`
+}
+
+// bankSnd is a loaded sound file of a SndBank
+type bankSnd struct {
+	file   int
+	sound  Wave
+	signal []float64
+}
+
+// SndBank owns a set of labeled sound files and processes any unit of any file on request, with the segment
+// centered on the unit, independent of any gui or table -- e.g., for shuffled per-unit training.
+// The signals of the most recently used files are kept so units of the same file are processed without reloading
+type SndBank struct {
+
+	// the sound processing pipeline -- set the params, gabor specs and output shape before processing
+	Snd SndEnv `desc:"the sound processing pipeline -- set the params, gabor specs and output shape before processing"`
+
+	// [view: no-inline] the labeled sound files, one sequence per file
+	Seqs speech.Sequences `view:"no-inline" desc:"the labeled sound files, one sequence per file"`
+
+	// [def: 8] maximum number of loaded sound files kept, the least recently used is dropped when full
+	MaxFiles int `default:"8" desc:"maximum number of loaded sound files kept, the least recently used is dropped when full"`
+
+	// loaded sound files, most recently used last
+	snds []*bankSnd
+
+	// index + 1 of the file whose signal is in Snd and initialized, 0 if none
+	cur int
+}
+
+// Defaults sets the Snd defaults and the cache size
+func (sb *SndBank) Defaults() {
+	sb.Snd.Defaults()
+	sb.MaxFiles = 8
+	sb.cur = 0
+}
+
+// Reset drops the loaded sound files, e.g., after changing the Snd params
+func (sb *SndBank) Reset() {
+	sb.snds = nil
+	sb.cur = 0
+}
+
+// FileIdx returns the index of the sequence whose File or ID is file
+func (sb *SndBank) FileIdx(file string) (int, bool) {
+	for i := range sb.Seqs {
+		if sb.Seqs[i].File == file || (sb.Seqs[i].ID != "" && sb.Seqs[i].ID == file) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// Units returns all the units of all the files, in order
+func (sb *SndBank) Units() []UnitRef {
+	var refs []UnitRef
+	for f := range sb.Seqs {
+		for u := range sb.Seqs[f].Units {
+			refs = append(refs, UnitRef{File: f, Unit: u})
+		}
+	}
+	return refs
+}
+
+// Shuffled returns all the units of all the files in a random order from rnd, e.g. from Snd.NewRand("bank")
+func (sb *SndBank) Shuffled(rnd *rand.Rand) []UnitRef {
+	refs := sb.Units()
+	rnd.Shuffle(len(refs), func(i, j int) { refs[i], refs[j] = refs[j], refs[i] })
+	return refs
+}
+
+// load makes the file's signal the Snd signal and initializes Snd for it, loading the file if not held
+func (sb *SndBank) load(file int) error {
+	if sb.cur == file+1 {
+		return nil
+	}
+	var bs *bankSnd
+	for i, s := range sb.snds {
+		if s.file == file {
+			bs = s
+			sb.snds = append(append(sb.snds[:i:i], sb.snds[i+1:]...), s)
+			break
+		}
+	}
+	if bs == nil {
+		bs = &bankSnd{file: file}
+		if err := bs.sound.Load(sb.Seqs[file].File); err != nil {
+			return err
+		}
+		sb.Snd.Sound = bs.sound
+		sb.Snd.ToTensor()
+		bs.signal = append([]float64{}, sb.Snd.Signal.Values...)
+		if sb.MaxFiles > 0 {
+			if len(sb.snds) >= sb.MaxFiles {
+				sb.snds = sb.snds[1:]
+			}
+			sb.snds = append(sb.snds, bs)
+		}
+	}
+	sb.Snd.Sound = bs.sound
+	sb.Snd.Signal.SetShape([]int{len(bs.signal)}, nil, nil)
+	copy(sb.Snd.Signal.Values, bs.signal)
+	sb.cur = 0
+	if err := sb.Snd.Init(); err != nil {
+		return err
+	}
+	sb.cur = file + 1
+	return nil
+}
+
+// Process returns the features of the segment centered on the unit of the file (sequence index). The segment start
+// is limited so the segment ends within the sound, and starts before the sound are padded as for the border steps
+func (sb *SndBank) Process(file, unit int) (*UnitFeatures, error) {
+	if file < 0 || file >= len(sb.Seqs) {
+		err := fmt.Errorf("sound.SndBank.Process: file index %v out of range, %v files", file, len(sb.Seqs))
+		log.Println(err)
+		return nil, err
+	}
+	seq := &sb.Seqs[file]
+	if unit < 0 || unit >= len(seq.Units) {
+		err := fmt.Errorf("sound.SndBank.Process: unit index %v out of range, %v units in %v", unit, len(seq.Units), seq.File)
+		log.Println(err)
+		return nil, err
+	}
+	if err := sb.load(file); err != nil {
+		return nil, err
+	}
+	se := &sb.Snd
+	sr := se.Sound.SampleRate()
+	u := seq.Units[unit]
+	startMs := (u.Start+u.End)/2 - se.Params.SegmentMs/2
+	maxMs := SamplesToMSec(se.NSamples()-se.SegmentEnd(), sr)
+	if startMs > maxMs {
+		startMs = maxMs
+	}
+	se.ProcessSegment(0, int(math.Floor(startMs)))
+
+	uf := &UnitFeatures{Ref: UnitRef{File: file, Unit: unit}, Name: u.Name}
+	uf.Mel.CopyShapeFrom(&se.MelFBankSegment)
+	uf.Mel.CopyFrom(&se.MelFBankSegment)
+	if se.Mel.MFCC {
+		uf.MFCC.CopyShapeFrom(&se.MFCCSegment)
+		uf.MFCC.CopyFrom(&se.MFCCSegment)
+	}
+	if len(agabor.Active(se.GaborSpecs)) > 0 {
+		gb := se.ApplyGabor()
+		uf.Gabor.CopyShapeFrom(gb)
+		uf.Gabor.CopyFrom(gb)
+	}
+	return uf, nil
+}
+
+// ProcessRef returns the features of the unit, see Process
+func (sb *SndBank) ProcessRef(ref UnitRef) (*UnitFeatures, error) {
+	return sb.Process(ref.File, ref.Unit)
+}
@@ -9,11 +9,16 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"math/rand"
 	"runtime"
 
 	"github.com/emer/auditory/agabor"
 	"github.com/emer/auditory/dft"
+	"github.com/emer/auditory/features"
+	"github.com/emer/auditory/gammatone"
 	"github.com/emer/auditory/mel"
+	"github.com/emer/auditory/pitch"
+	"github.com/emer/auditory/vad"
 	"github.com/emer/etable/etable"
 	"github.com/emer/etable/etensor"
 	"github.com/emer/leabra/fffb"
@@ -26,6 +31,10 @@ type Params struct {
 	// [def: 25] input window -- number of milliseconds worth of sound to filter at a time
 	WinMs float64 `default:"25" desc:"input window -- number of milliseconds worth of sound to filter at a time"`
 
+	// [def: 0.97] pre-emphasis coefficient, the raw signal x is filtered as x[t] - PreEmph * x[t-1] before the windows are
+	// transformed, boosting the high frequencies to flatten the spectral tilt of speech -- 0 to disable
+	PreEmph float64 `default:"0.97" desc:"pre-emphasis coefficient, the raw signal x is filtered as x[t] - PreEmph * x[t-1] before the windows are transformed, boosting the high frequencies to flatten the spectral tilt of speech -- 0 to disable"`
+
 	// [def: 5,10,12.5] input step -- number of milliseconds worth of sound that the input is stepped along to obtain the next window sample
 	StepMs float64 `default:"5,10,12.5" desc:"input step -- number of milliseconds worth of sound that the input is stepped along to obtain the next window sample"`
 
@@ -38,8 +47,26 @@ type Params struct {
 	// [def: 6] [view: +] overlap with previous and next segment
 	BorderSteps int `default:"6" view:"+" desc:"overlap with previous and next segment"`
 
-	// [viewif: Channels=1] specific channel to process, if input has multiple channels, and we only process one of them (-1 = process all)
-	Channel int `viewif:"Channels=1" desc:"specific channel to process, if input has multiple channels, and we only process one of them (-1 = process all)"`
+	// where the steps of each segment are, AlignStart, or AlignStrides for the alignment of the old processspeech example -- see AlignStart
+	Align StepAligns `desc:"where the steps of each segment are, AlignStart, or AlignStrides for the alignment of the old processspeech example -- see AlignStart"`
+
+	// which channels are processed -- one channel (Channel), the mix of the channels (Mixdown) or every channel
+	ChannelMode ChannelModes `desc:"which channels are processed -- one channel (Channel), the mix of the channels (Mixdown) or every channel"`
+
+	// [viewif: Channels=1] specific channel to process, if input has multiple channels, and we only process one of them (-1 = mix the channels down as set by Mixdown)
+	Channel int `viewif:"Channels=1" desc:"specific channel to process, if input has multiple channels, and we only process one of them (-1 = mix the channels down as set by Mixdown)"`
+
+	// [viewif: Channel=-1] how to mix multichannel input down to the one channel processed when Channel is -1 -- mean of all channels, left, right, mid (L+R)/2 or side (L-R)/2
+	Mixdown MixModes `viewif:"Channel=-1" desc:"how to mix multichannel input down to the one channel processed when Channel is -1 -- mean of all channels, left, right, mid (L+R)/2 or side (L-R)/2"`
+
+	// how Pad extends the signal -- with PadValue, by repeating the last sample or by reflecting the signal at its end
+	PadMode PadModes `desc:"how Pad extends the signal -- with PadValue, by repeating the last sample or by reflecting the signal at its end"`
+
+	// [viewif: PadMode=PadConstant] value used to pad the signal if PadMode is PadConstant, typically 0 (silence)
+	PadValue float64 `viewif:"PadMode=PadConstant" desc:"value used to pad the signal if PadMode is PadConstant, typically 0 (silence)"`
+
+	// if true Init pads signals shorter than one segment, including the border steps, out to one segment -- otherwise Init returns an error for them, so they can't be processed, and SeqEnv logs and skips their files
+	PadShort bool `desc:"if true Init pads signals shorter than one segment, including the border steps, out to one segment -- otherwise Init returns an error for them, so they can't be processed, and SeqEnv logs and skips their files"`
 
 	// number of samples to process each step
 	WinSamples int `inactive:"+" desc:"number of samples to process each step"`
@@ -60,9 +87,24 @@ type Params struct {
 	Steps []int `inactive:"+" desc:"pre-calculated start position for each step"`
 }
 
+// StepsBack returns how many steps before the segment start the first step of a segment starts, see StepsBack
+func (p *Params) StepsBack() int {
+	return StepsBack(p.Align, p.SegmentMs, p.StrideMs, p.StepMs, p.BorderSteps)
+}
+
+// PadModes are the ways of extending a signal, see SndEnv.Pad
+type PadModes int32
+
+const (
+	PadConstant PadModes = iota // pad with Params.PadValue
+	PadEdge                     // repeat the last sample
+	PadReflect                  // mirror the signal at its end, without repeating the last sample
+)
+
 // ParamDefaults initializes the Input
 func (se *SndEnv) ParamDefaults() {
 	se.Params.WinMs = 25.0
+	se.Params.PreEmph = 0.97
 	se.Params.StepMs = 10.0
 	se.Params.SegmentMs = 100.0
 	se.Params.Channel = 0
@@ -78,63 +120,171 @@ type SndEnv struct {
 	// description of this environment
 	Dsc string `desc:"description of this environment"`
 
+	// prefix of the names of the outputs, in the "name" metadata of the output tensors and the columns of ToTable, e.g., "Long" -- give each SndEnv feeding one model a different prefix so their logged outputs don't collide
+	Prefix string `desc:"prefix of the names of the outputs, in the \"name\" metadata of the output tensors and the columns of ToTable, e.g., \"Long\" -- give each SndEnv feeding one model a different prefix so their logged outputs don't collide"`
+
 	// false turns off processing of this sound
 	On bool `desc:"false turns off processing of this sound"`
 
+	// master random seed -- every stochastic step of the processing and augmentation (e.g. noise vocoding, jitter, presentation order) derives its random numbers from this seed, see NewRand, so entire runs are reproducible
+	Seed int64 `desc:"master random seed -- every stochastic step of the processing and augmentation (e.g. noise vocoding, jitter, presentation order) derives its random numbers from this seed, see NewRand, so entire runs are reproducible"`
+
 	// specifications of the raw sensory input
 	Sound  Wave `desc:"specifications of the raw sensory input"`
 	Params Params
 
+	// calibration of the digital level of the Signal to dB SPL, for setting and measuring stimulus levels, see SPL and SetSPL
+	Calib Calibration `desc:"calibration of the digital level of the Signal to dB SPL, for setting and measuring stimulus levels, see SPL and SetSPL"`
+
 	// [view: no-inline]  the full sound input
 	Signal etensor.Float64 `view:"no-inline" desc:" the full sound input"`
 
+	// [view: -] the channel of the Signal being processed, for ChannelAll
+	Chan int `view:"-" desc:"the channel of the Signal being processed, for ChannelAll"`
+
 	// the number of segments in this sound file (based on current segment size)
 	SegCnt int `desc:"the number of segments in this sound file (based on current segment size)"`
 
+	// [view: -] cache of the filter bank and gabor filters built by InitProcess, shared by SndEnvs with the same params over many files -- nil for none
+	Cache *FilterCache `view:"-" desc:"cache of the filter bank and gabor filters built by InitProcess, shared by SndEnvs with the same params over many files -- nil for none"`
+
+	// [def: 0] number of goroutines of ProcessSegments, each processing segments with its own copy of the SndEnv, 0 for the number of cpus
+	Workers int `default:"0" desc:"number of goroutines of ProcessSegments, each processing segments with its own copy of the SndEnv, 0 for the number of cpus"`
+
+	// stack the float64 outputs of ProcessSegments (Power, Mel, MFCC etc) as float32, as the gabor output is, halving the memory of the features of large corpora -- each segment is processed in float64 in either case, its working tensors are small
+	Float32 bool `desc:"stack the float64 outputs of ProcessSegments (Power, Mel, MFCC etc) as float32, as the gabor output is, halving the memory of the features of large corpora -- each segment is processed in float64 in either case, its working tensors are small"`
+
 	//  [Input.WinSamples] the raw sound input, one channel at a time
 	Window etensor.Float64 `inactive:"+" desc:" [Input.WinSamples] the raw sound input, one channel at a time"`
 
 	DFT dft.Params
 
+	// whitening of the power spectrum before the filter bank, by the long-term average of each frequency bin or cepstral high-pass filtering
+	Whiten WhitenParams `desc:"whitening of the power spectrum before the filter bank, by the long-term average of each frequency bin or cepstral high-pass filtering"`
+
+	// [view: no-inline] the long-term average power of each frequency bin of each channel of the Signal, [channels, bins], for Whiten.Mode WhitenAvg -- set by InitSignal
+	WhitenAvg etensor.Float64 `view:"no-inline" inactive:"+" desc:"the long-term average power of each frequency bin of each channel of the Signal, [channels, bins], for Whiten.Mode WhitenAvg -- set by InitSignal"`
+
 	// [view: -]  power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)
 	Power etensor.Float64 `view:"-" desc:" power of the dft, up to the nyquist limit frequency (1/2 input.WinSamples)"`
 
 	// [view: -]  log power of the dft, up to the nyquist liit frequency (1/2 input.WinSamples)
 	LogPower etensor.Float64 `view:"-" desc:" log power of the dft, up to the nyquist liit frequency (1/2 input.WinSamples)"`
 
+	// [view: -] the power of the dft whitened, that the filter bank is applied to, if Whiten is on
+	PowerWhite etensor.Float64 `view:"-" desc:"the power of the dft whitened, that the filter bank is applied to, if Whiten is on"`
+
 	// [view: no-inline]  full segment's worth of power of the dft, up to the nyquist limit frequency (1/2 input.win_samples)
 	PowerSegment etensor.Float64 `view:"no-inline" desc:" full segment's worth of power of the dft, up to the nyquist limit frequency (1/2 input.win_samples)"`
 
 	// [view: no-inline]  full segment's worth of log power of the dft, up to the nyquist limit frequency (1/2 input.win_samples)
 	LogPowerSegment etensor.Float64 `view:"no-inline" desc:" full segment's worth of log power of the dft, up to the nyquist limit frequency (1/2 input.win_samples)"`
 
+	// the filterbank that produces MelFBankSegment, mel or gammatone -- the mel field names are kept for either
+	Bank FilterBanks `desc:"the filterbank that produces MelFBankSegment, mel or gammatone -- the mel field names are kept for either"`
+
+	// [view: no-inline] full segment's worth of power of the dft of each channel [channels, freqs, steps], if Params.ChannelMode is ChannelAll
+	PowerChans etensor.Float64 `view:"no-inline" desc:"full segment's worth of power of the dft of each channel [channels, freqs, steps], if Params.ChannelMode is ChannelAll"`
+
 	// [view: no-inline]
 	Mel mel.Params `view:"no-inline"`
 
+	// [view: no-inline] gammatone filterbank parameters, used if Bank is GammatoneBank
+	Gammatone gammatone.Params `view:"no-inline" desc:"gammatone filterbank parameters, used if Bank is GammatoneBank"`
+
 	// [view: no-inline]  mel scale transformation of dft_power, resulting in the mel filterbank output -- the natural log of this is typically applied
 	MelFBank etensor.Float64 `view:"no-inline" desc:" mel scale transformation of dft_power, resulting in the mel filterbank output -- the natural log of this is typically applied"`
 
 	// [view: no-inline]  full segment's worth of mel feature-bank output
 	MelFBankSegment etensor.Float64 `view:"no-inline" desc:" full segment's worth of mel feature-bank output"`
 
+	// [view: no-inline] full segment's worth of mel feature-bank output of each channel [channels, filters, steps], if Params.ChannelMode is ChannelAll
+	MelChans etensor.Float64 `view:"no-inline" desc:"full segment's worth of mel feature-bank output of each channel [channels, filters, steps], if Params.ChannelMode is ChannelAll"`
+
 	// [view: no-inline]  the actual filters
 	MelFilters etensor.Float64 `view:"no-inline" desc:" the actual filters"`
 
+	// [def: 0] first mel filter of the band of the mel output that is gabor filtered -- crops the mel output so models of a frequency region don't waste input units, see MelBand
+	BandLo int `default:"0" desc:"first mel filter of the band of the mel output that is gabor filtered -- crops the mel output so models of a frequency region don't waste input units, see MelBand"`
+
+	// [def: 0] end (last + 1) of the band of mel filters that is gabor filtered, 0 for all the filters above BandLo, e.g., BandLo 4 and BandHi 29 keep filters 4 through 28
+	BandHi int `default:"0" desc:"end (last + 1) of the band of mel filters that is gabor filtered, 0 for all the filters above BandLo, e.g., BandLo 4 and BandHi 29 keep filters 4 through 28"`
+
+	// [view: no-inline] the band of the mel output that is gabor filtered, if BandLo or BandHi crop it
+	MelBandSegment etensor.Float64 `view:"no-inline" desc:"the band of the mel output that is gabor filtered, if BandLo or BandHi crop it"`
+
 	// [view: no-inline]  sum of log power per segment step
 	Energy etensor.Float64 `view:"no-inline" desc:" sum of log power per segment step"`
 
+	// spectral features of each step, see FeatSegment
+	Features features.Params `desc:"spectral features of each step, see FeatSegment"`
+
+	// [view: no-inline] full segment's worth of spectral features [features, steps] (see features.Features), aligned with MelFBankSegment, if Features is on
+	FeatSegment etensor.Float64 `view:"no-inline" desc:"full segment's worth of spectral features [features, steps] (see features.Features), aligned with MelFBankSegment, if Features is on"`
+
+	// fundamental frequency (pitch) estimation of each step, see PitchSegment
+	Pitch pitch.Params `desc:"fundamental frequency (pitch) estimation of each step, see PitchSegment"`
+
+	// [view: no-inline] full segment's worth of pitch [2, steps], aligned with MelFBankSegment, if Pitch is on: the fundamental frequency in Hz of each step (0 if unvoiced) in row 0 and its periodicity, 0..1, in row 1 -- of the first channel for ChannelAll
+	PitchSegment etensor.Float64 `view:"no-inline" desc:"full segment's worth of pitch [2, steps], aligned with MelFBankSegment, if Pitch is on: the fundamental frequency in Hz of each step (0 if unvoiced) in row 0 and its periodicity, 0..1, in row 1 -- of the first channel for ChannelAll"`
+
+	// hilbert envelopes of each step, broadband and in frequency bands, see EnvSegment
+	Envelope EnvelopeParams `desc:"hilbert envelopes of each step, broadband and in frequency bands, see EnvSegment"`
+
+	// [view: no-inline] full segment's worth of hilbert envelopes [1 + bands, steps], aligned with MelFBankSegment, if Envelope is on: the broadband envelope in row 0 and the envelope of each band, from low to high, in the rows after it, each averaged over the window of the step -- of the first channel for ChannelAll
+	EnvSegment etensor.Float64 `view:"no-inline" desc:"full segment's worth of hilbert envelopes [1 + bands, steps], aligned with MelFBankSegment, if Envelope is on: the broadband envelope in row 0 and the envelope of each band, from low to high, in the rows after it, each averaged over the window of the step -- of the first channel for ChannelAll"`
+
+	// voice activity detection of each step, see VADMask
+	VAD vad.Params `desc:"voice activity detection of each step, see VADMask"`
+
+	// [view: no-inline] 1 for the steps of the segment that are speech, 0 for the others, if VAD is on -- see SpeechFrac
+	VADMask etensor.Float32 `view:"no-inline" desc:"1 for the steps of the segment that are speech, 0 for the others, if VAD is on -- see SpeechFrac"`
+
 	// [view: no-inline]  discrete cosine transform of the log_mel_filter_out values, producing the final mel-frequency cepstral coefficients
 	MFCCDCT etensor.Float64 `view:"no-inline" desc:" discrete cosine transform of the log_mel_filter_out values, producing the final mel-frequency cepstral coefficients"`
 
 	// [view: no-inline]  full segment's worth of discrete cosine transform of log_mel_filter_out values, producing the final mel-frequency cepstral coefficients
 	MFCCSegment etensor.Float64 `view:"no-inline" desc:" full segment's worth of discrete cosine transform of log_mel_filter_out values, producing the final mel-frequency cepstral coefficients"`
 
+	// [view: no-inline] full segment's worth of mfcc of each channel [channels, coefs, steps], if Params.ChannelMode is ChannelAll and Mel.MFCC
+	MFCCChans etensor.Float64 `view:"no-inline" desc:"full segment's worth of mfcc of each channel [channels, coefs, steps], if Params.ChannelMode is ChannelAll and Mel.MFCC"`
+
 	// [view: no-inline]  MFCC deltas are the differences over time of the MFC coefficeints
 	MFCCDeltas etensor.Float64 `view:"no-inline" desc:" MFCC deltas are the differences over time of the MFC coefficeints"`
 
 	// [view: no-inline] MFCC delta deltas are the differences over time of the MFCC deltas
 	MFCCDeltaDeltas etensor.Float64 `view:"no-inline" desc:"MFCC delta deltas are the differences over time of the MFCC deltas"`
 
+	// [view: no-inline] the deltas over time of the mel output, if Mel.FBankDeltas is set
+	MelDeltas etensor.Float64 `view:"no-inline" desc:"the deltas over time of the mel output, if Mel.FBankDeltas is set"`
+
+	// [view: no-inline] the deltas over time of MelDeltas, if Mel.FBankDeltas is set
+	MelDeltaDeltas etensor.Float64 `view:"no-inline" desc:"the deltas over time of MelDeltas, if Mel.FBankDeltas is set"`
+
+	// [view: -] affine transform applied to each frame of the mel output, e.g., a speaker adaptation transform, nil for none -- applied before gabor filtering, pooling and splicing, the mfcc are computed from the untransformed output
+	MelAffine *Affine `view:"-" desc:"affine transform applied to each frame of the mel output, e.g., a speaker adaptation transform, nil for none -- applied before gabor filtering, pooling and splicing, the mfcc are computed from the untransformed output"`
+
+	// SpecAugment style masking of the mel output of each segment, random bands of filters and runs of steps, for training -- applied after MelAffine, so the mel deltas, gabor output, pooling and splicing are of the masked output, the mfcc are computed from the unmasked output -- with ChannelAll, the mel output of each channel is masked at the same positions
+	SpecAug SpecAugParams `desc:"SpecAugment style masking of the mel output of each segment, random bands of filters and runs of steps, for training -- applied after MelAffine, so the mel deltas, gabor output, pooling and splicing are of the masked output, the mfcc are computed from the unmasked output -- with ChannelAll, the mel output of each channel is masked at the same positions"`
+
+	// pooling of the mel and mfcc frames over time, for a lower frame rate than StepMs
+	TimePool TimePoolParams `desc:"pooling of the mel and mfcc frames over time, for a lower frame rate than StepMs"`
+
+	// [view: no-inline] the mel output pooled over time, if TimePool.N > 1
+	MelPooled etensor.Float64 `view:"no-inline" desc:"the mel output pooled over time, if TimePool.N > 1"`
+
+	// [view: no-inline] the mfcc output pooled over time, if TimePool.N > 1 and Mel.MFCC
+	MFCCPooled etensor.Float64 `view:"no-inline" desc:"the mfcc output pooled over time, if TimePool.N > 1 and Mel.MFCC"`
+
+	// splicing of each mel and mfcc frame with its neighboring frames (after TimePool)
+	Splice SpliceParams `desc:"splicing of each mel and mfcc frame with its neighboring frames (after TimePool)"`
+
+	// [view: no-inline] the mel output with each frame stacked with its neighbors, if Splice.N > 0
+	MelSpliced etensor.Float64 `view:"no-inline" desc:"the mel output with each frame stacked with its neighbors, if Splice.N > 0"`
+
+	// [view: no-inline] the mfcc output with each frame stacked with its neighbors, if Splice.N > 0 and Mel.MFCC
+	MFCCSpliced etensor.Float64 `view:"no-inline" desc:"the mfcc output with each frame stacked with its neighbors, if Splice.N > 0 and Mel.MFCC"`
+
 	// [view: no-inline]  a set of gabor filter specifications, one spec per filter'
 	GaborSpecs []agabor.Filter `view:"no-inline" desc:" a set of gabor filter specifications, one spec per filter'"`
 
@@ -174,25 +324,76 @@ type SndEnv struct {
 	// kwta parameters, using FFFB form
 	Kwta kwta.KWTA `desc:"kwta parameters, using FFFB form"`
 
+	// habituation of the gabor output across segments, before the neighborhood inhibition and kwta
+	Habit HabitParams `desc:"habituation of the gabor output across segments, before the neighborhood inhibition and kwta"`
+
+	// [view: no-inline] the resources of the units of the gabor output, that scale their output, if Habit is on -- see HabitParams
+	HabitRes etensor.Float32 `view:"no-inline" inactive:"+" desc:"the resources of the units of the gabor output, that scale their output, if Habit is on -- see HabitParams"`
+
 	// if Kwta.On == true, call KwtaPool (true) or KwtaLayer (false)
 	KwtaPool bool `desc:"if Kwta.On == true, call KwtaPool (true) or KwtaLayer (false)"`
 
 	// display the gabor filtering result by time and then by filter, default is to order by filter and then time
 	ByTime bool `desc:"display the gabor filtering result by time and then by filter, default is to order by filter and then time"`
+
+	// [view: no-inline] mel output of the whole signal [filters, steps], from ProcessAll
+	MelAll etensor.Float64 `view:"no-inline" desc:"mel output of the whole signal [filters, steps], from ProcessAll"`
+
+	// [view: no-inline] mfcc of the whole signal [coefs, steps], from ProcessAll if Mel.MFCC
+	MFCCAll etensor.Float64 `view:"no-inline" desc:"mfcc of the whole signal [coefs, steps], from ProcessAll if Mel.MFCC"`
+
+	// [view: no-inline] 2D gabor output of the whole signal, post kwta if Kwta is on, from ProcessAll
+	GaborAll etensor.Float32 `view:"no-inline" desc:"2D gabor output of the whole signal, post kwta if Kwta is on, from ProcessAll"`
+
+	// apply the gabor filters to the steps of each segment's stride plus the steps just before it, carried from the previous segment if the border steps don't reach back far enough (see CarrySteps), instead of to the whole segment -- the gabor outputs of consecutive segments are then those of the whole signal at once, each position of the filters in exactly one segment
+	GaborCarry bool `desc:"apply the gabor filters to the steps of each segment's stride plus the steps just before it, carried from the previous segment if the border steps don't reach back far enough (see CarrySteps), instead of to the whole segment -- the gabor outputs of consecutive segments are then those of the whole signal at once, each position of the filters in exactly one segment"`
+
+	// [view: no-inline] the mel output the gabor filters are applied to if GaborCarry is set, see CarrySteps
+	MelCarry etensor.Float64 `view:"no-inline" desc:"the mel output the gabor filters are applied to if GaborCarry is set, see CarrySteps"`
+
+	carryPrev etensor.Float64 // mel output of the previous segment, for the carry
+	carrySeg  int             // segment of carryPrev
+	carryAdd  int             // add of carryPrev
+	carryOK   bool            // carryPrev is set
+	emph      []float64       // the pre-emphasized window, see SndToWindow
+	vadDet    vad.Detector    // the noise floor of the voice activity detection
+	specRand  *rand.Rand      // random numbers of SpecAug
 }
 
 // Defaults
 func (se *SndEnv) Defaults() {
 	se.ParamDefaults()
 	se.On = true
+	se.Calib.Defaults()
+	se.DFT.Defaults()
+	se.Whiten.Defaults()
 	se.Mel.Defaults() // calls melfbank defaults
+	se.Gammatone.Defaults()
 	se.Kwta.Defaults()
+	se.SpecAug.Defaults()
+	se.TimePool.Defaults()
+	se.Splice.Defaults()
 	se.KwtaPool = true
+	se.Habit.Defaults()
+	se.Features.Defaults()
+	se.Pitch.Defaults()
+	se.Envelope.Defaults()
+	se.VAD.Defaults()
 	se.ByTime = false
 }
 
-// Init sets various sound processing params based on default params and user overrides
+// Init sets various sound processing params based on default params and user overrides, and initializes the
+// processing of the Signal (see InitSignal)
 func (se *SndEnv) Init() (err error) {
+	if err = se.InitProcess(); err != nil {
+		return err
+	}
+	return se.InitSignal()
+}
+
+// InitProcess sets the processing params, filters and output tensors for the sample rate of Sound -- the part of Init
+// that doesn't depend on the Signal
+func (se *SndEnv) InitProcess() (err error) {
 	sr := se.Sound.SampleRate()
 	if sr <= 0 {
 		fmt.Println("sample rate <= 0")
@@ -202,14 +403,23 @@ func (se *SndEnv) Init() (err error) {
 	se.Params.WinSamples = MSecToSamples(se.Params.WinMs, sr)
 	se.Params.StepSamples = MSecToSamples(se.Params.StepMs, sr)
 	se.Params.SegmentSamples = MSecToSamples(se.Params.SegmentMs, sr)
-	steps := int(math.Round(se.Params.SegmentMs / se.Params.StepMs))
-	se.Params.SegmentSteps = steps + 2*se.Params.BorderSteps
+	se.Params.SegmentSteps = SegmentSteps(se.Params.SegmentMs, se.Params.StepMs, se.Params.BorderSteps)
 	se.Params.StrideSamples = MSecToSamples(se.Params.StrideMs, sr)
+	if se.Params.WinSamples < 1 || se.Params.StepSamples < 1 || se.Params.StrideSamples < 1 {
+		err = fmt.Errorf("sound.SndEnv: WinMs %v, StepMs %v and StrideMs %v must each be at least one sample at sample rate %v",
+			se.Params.WinMs, se.Params.StepMs, se.Params.StrideMs, sr)
+		log.Println(err)
+		return err
+	}
 
 	specs := agabor.Active(se.GaborSpecs)
 	nfilters := len(specs)
 	se.GaborFilters.Filters.SetShape([]int{nfilters, se.GaborFilters.SizeY, se.GaborFilters.SizeX}, nil, nil)
-	agabor.ToTensor(specs, &se.GaborFilters)
+	if se.Cache != nil {
+		se.Cache.gaborFilters(specs, &se.GaborFilters)
+	} else {
+		agabor.ToTensor(specs, &se.GaborFilters)
+	}
 	se.GaborFilters.ToTable(se.GaborFilters, &se.GaborTab) // note: view only, testing
 	if se.GborOutPoolsX == 0 && se.GborOutPoolsY == 0 {    // 2D
 		se.GborOutput.SetShape([]int{se.GborOutUnitsY, se.GborOutUnitsX}, nil, nil)
@@ -225,10 +435,14 @@ func (se *SndEnv) Init() (err error) {
 	se.GborOutput.SetMetaData("grid-fill", ".9")
 	se.GborKwta.CopyShapeFrom(&se.GborOutput)
 	se.GborKwta.CopyMetaData(&se.GborOutput)
+	se.ConfigDisplay()
 
 	winSamplesHalf := se.Params.WinSamples/2 + 1
-	se.DFT.Defaults()
-	se.Mel.InitFilters(se.Params.WinSamples, se.Sound.SampleRate(), &se.MelFilters) // call after non-default values are set!
+	se.DFT.CurSmooth = 1.0 - se.DFT.PrevSmooth // the DFT params are set by Defaults, so WindowType etc can be changed
+	err = se.initFilterBank()
+	if err != nil {
+		return err
+	}
 	se.Window.SetShape([]int{se.Params.WinSamples}, nil, nil)
 	se.Power.SetShape([]int{winSamplesHalf}, nil, nil)
 	se.LogPower.CopyShapeFrom(&se.Power)
@@ -237,35 +451,186 @@ func (se *SndEnv) Init() (err error) {
 		se.LogPowerSegment.CopyShapeFrom(&se.PowerSegment)
 	}
 
-	// 2 reasons for this code
-	// 1 - the amount of signal handed to the fft has a "border" (some extra signal) to avoid edge effects.
-	// On the first step there is no signal to act as the "border" so we pad the data handed on the front.
-	// 2 - signals needs to be aligned when the number when multiple signals are input (e.g. 100 and 300 ms)
-	// so that the leading edge (right edge) is the same time point.
-	// This code does this by generating negative offsets for the start of the processing.
-	// Also see SndToWindow for the use of the step values
-	stepsBack := se.Params.BorderSteps
-	se.Params.Steps = make([]int, se.Params.SegmentSteps)
-	for i := 0; i < se.Params.SegmentSteps; i++ {
-		se.Params.Steps[i] = se.Params.StepSamples * (i - stepsBack)
-	}
+	// the steps start BorderSteps before the segment start, padded with zeros before the signal start (see SndToWindow),
+	// or as in the old processspeech example if Params.Align is AlignStrides -- see AlignStart for the model
+	se.Params.Steps = StepOffsets(se.Params.StepsBack(), se.Params.StepSamples, se.Params.SegmentSteps)
 
-	se.MelFBank.SetShape([]int{se.Mel.FBank.NFilters}, nil, nil)
-	se.MelFBankSegment.SetShape([]int{se.Mel.FBank.NFilters, se.Params.SegmentSteps}, nil, nil)
+	se.MelFBank.SetShape([]int{se.NFilters()}, nil, nil)
+	se.MelFBankSegment.SetShape([]int{se.NFilters(), se.Params.SegmentSteps}, nil, nil)
+	lo, hi := se.MelBand()
+	if lo >= hi {
+		err = fmt.Errorf("sound.SndEnv: mel band %v..%v is empty, there are %v mel filters", se.BandLo, se.BandHi, se.NFilters())
+		log.Println(err)
+		return err
+	}
+	if se.Cropped() {
+		se.MelBandSegment.SetShape([]int{hi - lo, se.Params.SegmentSteps}, nil, nil)
+	}
 	se.Energy.SetShape([]int{se.Params.SegmentSteps}, nil, nil)
+	if se.Features.On {
+		se.FeatSegment.SetShape([]int{int(features.FeaturesN), se.Params.SegmentSteps}, nil, []string{"Feature", "Time"})
+	}
+	if se.Pitch.On {
+		se.PitchSegment.SetShape([]int{2, se.Params.SegmentSteps}, nil, []string{"Pitch", "Time"})
+	}
+	if se.Envelope.On {
+		se.EnvSegment.SetShape([]int{se.Envelope.NBands + 1, se.Params.SegmentSteps}, nil, []string{"Band", "Time"})
+	}
+	if se.VAD.On {
+		se.VADMask.SetShape([]int{se.Params.SegmentSteps}, nil, nil)
+	}
+	se.vadDet.Reset()
 	if se.Mel.MFCC {
-		se.MFCCDCT.SetShape([]int{se.Mel.FBank.NFilters}, nil, nil)
+		se.MFCCDCT.SetShape([]int{se.NFilters()}, nil, nil)
 		se.MFCCSegment.SetShape([]int{se.Mel.NCoefs, se.Params.SegmentSteps}, nil, nil)
 		se.MFCCDeltas.SetShape([]int{se.Mel.NCoefs, se.Params.SegmentSteps}, nil, nil)
 		se.MFCCDeltaDeltas.SetShape([]int{se.Mel.NCoefs, se.Params.SegmentSteps}, nil, nil)
 	}
+	if se.Mel.FBankDeltas {
+		se.MelDeltas.CopyShapeFrom(&se.MelFBankSegment)
+		se.MelDeltaDeltas.CopyShapeFrom(&se.MelFBankSegment)
+	}
+	if se.TimePool.On() {
+		nf := se.TimePool.Frames(se.Params.SegmentSteps)
+		se.MelPooled.SetShape([]int{se.NFilters(), nf}, nil, nil)
+		if se.Mel.MFCC {
+			se.MFCCPooled.SetShape([]int{se.Mel.NCoefs, nf}, nil, nil)
+		}
+	}
+	if se.Splice.On() {
+		nf := se.TimePool.Frames(se.Params.SegmentSteps)
+		nc := 2*se.Splice.N + 1
+		se.MelSpliced.SetShape([]int{nc * se.NFilters(), nf}, nil, nil)
+		if se.Mel.MFCC {
+			se.MFCCSpliced.SetShape([]int{nc * se.Mel.NCoefs, nf}, nil, nil)
+		}
+	}
+	se.initChans()
+	se.carryOK = false
+	if se.GaborCarry {
+		if err = se.checkCarry(); err != nil {
+			return err
+		}
+	}
+	se.SetFreqMetaData()
+	se.SetNameMetaData()
+	return nil
+}
+
+// InitSignal pads the Signal if it is shorter than one segment and Params.PadShort is set, counts its segments, and
+// sets WhitenAvg if Whiten.Mode is WhitenAvg -- called by Init, and again after changing the Signal with the same params, e.g., as a Stream does
+func (se *SndEnv) InitSignal() (err error) {
+	segEnd := se.SegmentEnd()
+	if se.NSamples() < segEnd {
+		if !se.Params.PadShort {
+			err = fmt.Errorf("sound.SndEnv: %v signal of %v samples is shorter than one segment of %v samples, including the border steps -- set Params.PadShort to pad it", se.Nm, se.NSamples(), segEnd)
+			log.Println(err)
+			return err
+		}
+		if se.Signal.NumDims() > 1 { // [channels, samples], see ToTensor
+			se.padChannels()
+		} else {
+			se.Signal.Values = se.Pad(se.Signal.Values)
+			se.Signal.SetShape([]int{len(se.Signal.Values)}, nil, nil)
+		}
+	}
 
-	siglen := len(se.Signal.Values) - se.Params.SegmentSamples*se.Sound.Channels()
-	siglen = siglen / se.Sound.Channels()
+	// only count the segments whose last window ends within the signal -- Pad the signal to include the tail
+	siglen := se.NSamples() - segEnd
 	se.SegCnt = siglen/se.Params.StrideSamples + 1 // add back the first segment subtracted at from siglen calculation
+	if se.Whiten.Mode == WhitenAvg {
+		se.initWhitenAvg()
+	}
+	se.vadDet.Reset()
 	return nil
 }
 
+// SegmentEnd returns the number of samples from the start of a segment to the end of the last window processed
+// for the segment, which includes the border steps after the segment and the window length -- call after Init
+func (se *SndEnv) SegmentEnd() int {
+	end := se.Params.SegmentSamples
+	if n := len(se.Params.Steps); n > 0 {
+		if e := se.Params.Steps[n-1] + se.Params.WinSamples; e > end {
+			end = e
+		}
+	}
+	return end
+}
+
+// MelBand returns the band of mel filters, lo up to but not including hi, that is gabor filtered -- BandLo and BandHi
+// limited to the filters
+func (se *SndEnv) MelBand() (lo, hi int) {
+	nf := se.NFilters()
+	lo, hi = se.BandLo, se.BandHi
+	if lo < 0 {
+		lo = 0
+	}
+	if hi <= 0 || hi > nf {
+		hi = nf
+	}
+	return lo, hi
+}
+
+// Cropped returns true if the mel band that is gabor filtered is not all the mel filters, see MelBand
+func (se *SndEnv) Cropped() bool {
+	lo, hi := se.MelBand()
+	return lo > 0 || hi < se.NFilters()
+}
+
+// GaborInput returns the mel output that is gabor filtered -- MelFBankSegment, or its band MelBandSegment,
+// copied from MelFBankSegment, if BandLo or BandHi crop it
+func (se *SndEnv) GaborInput() *etensor.Float64 {
+	return se.gaborInputOf(&se.MelFBankSegment)
+}
+
+// gaborInputOf returns the mel output that is gabor filtered of the mel output of a segment, mel itself or its band
+// copied into MelBandSegment
+func (se *SndEnv) gaborInputOf(mel *etensor.Float64) *etensor.Float64 {
+	if !se.Cropped() {
+		return mel
+	}
+	lo, hi := se.MelBand()
+	steps := mel.Dim(1)
+	if se.MelBandSegment.Len() != (hi-lo)*steps {
+		se.MelBandSegment.SetShape([]int{hi - lo, steps}, nil, nil)
+	}
+	copy(se.MelBandSegment.Values, mel.Values[lo*steps:hi*steps])
+	return &se.MelBandSegment
+}
+
+// GaborDefaults sets a standard gabor filter set, 6 x 6 filters at 4 orientations with a stride of 3,
+// for uses that don't need to tune the filters, e.g., feature extraction for code outside of a sim
+func (se *SndEnv) GaborDefaults() {
+	se.GaborFilters.SizeX = 6
+	se.GaborFilters.SizeY = 6
+	se.GaborFilters.StrideX = 3
+	se.GaborFilters.StrideY = 3
+	se.GaborFilters.Gain = 1.5
+	se.GaborFilters.Distribute = false
+	se.GaborSpecs = nil // in case there are some specs already
+	for _, or := range []float64{0, 45, 90, 135} {
+		spec := agabor.Filter{WaveLen: 2.0, Orientation: or, SigmaWidth: 0.5, SigmaLength: 0.5, PhaseOffset: 0, CircleEdge: true}
+		se.GaborSpecs = append(se.GaborSpecs, spec)
+	}
+}
+
+// SetGaborOut2D sets GborOutUnitsX and GborOutUnitsY for 2D gabor output (no pools) to fit the
+// convolution of the active gabor filters with a full segment of mel output, or MelCarry if GaborCarry is set -- 2 rows (on-center and off-center)
+// per filter position in frequency and one column per filter per position in time. Only the mel band (see MelBand)
+// is gabor filtered. Call before Init
+func (se *SndEnv) SetGaborOut2D() {
+	steps := SegmentSteps(se.Params.SegmentMs, se.Params.StepMs, se.Params.BorderSteps)
+	if se.GaborCarry {
+		_, steps = se.CarrySteps()
+	}
+	nf := len(agabor.Active(se.GaborSpecs))
+	se.GborOutPoolsX = 0
+	se.GborOutPoolsY = 0
+	se.GborOutUnitsX = ((steps-se.GaborFilters.SizeX)/se.GaborFilters.StrideX + 1) * nf
+	lo, hi := se.MelBand()
+	se.GborOutUnitsY = ((hi-lo-se.GaborFilters.SizeY)/se.GaborFilters.StrideY + 1) * 2
+}
+
 // AdjustForSilence trims or adds silence
 // add is the amount of random silence that should precede the start of the sequence.
 // existing is the amount of silence preexisting at start of sound.
@@ -293,10 +658,16 @@ func (se *SndEnv) AdjustForSilence(add, existing float64) (offset int) {
 	return offset
 }
 
-// ToTensor
+// ToTensor converts the sound to the Signal as set by Params.ChannelMode: Params.Channel or, if Channel is -1, the
+// Params.Mixdown of all channels, the Mixdown for ChannelMix, or every channel, [channels, samples], for ChannelAll
 func (se *SndEnv) ToTensor() bool {
-	se.Sound.SoundToTensor(&se.Signal)
-	return true
+	switch se.Params.ChannelMode {
+	case ChannelMix:
+		return se.Sound.SoundToTensorMix(&se.Signal, se.Params.Mixdown, -1)
+	case ChannelAll:
+		return se.Sound.SoundToTensorChannels(&se.Signal)
+	}
+	return se.Sound.SoundToTensorMix(&se.Signal, se.Params.Mixdown, se.Params.Channel)
 }
 
 // ApplyNeighInhib - each unit gets inhibition from same feature in nearest orthogonal neighbors
@@ -340,12 +711,65 @@ func (se *SndEnv) ApplyKwta() {
 // of the network. For example, durations of 80 and 120 ms. Add half the difference (e.g. 20 ms) so the sounds are
 // centered on the same moment of sound
 func (se *SndEnv) ProcessSegment(segment, add int) {
+	if se.GaborCarry && se.carryNeeded(segment, add) {
+		se.processChannel(segment-1, add)
+		if se.MelAffine != nil {
+			se.MelAffine.Apply(&se.MelFBankSegment)
+		}
+		se.keepCarry(segment-1, add)
+	}
+	se.SetTimeMetaData(segment, add)
+	if se.VAD.On {
+		se.VADMask.SetZeros() // a step of any channel that is speech is speech, see ProcessStep
+	}
+	if se.Params.ChannelMode == ChannelAll {
+		se.processChans(segment, add)
+	} else {
+		se.processChannel(segment, add)
+	}
+	if se.VAD.On {
+		vad.Hangover(se.VADMask.Values, se.VAD.HangSteps)
+	}
+	if se.MelAffine != nil {
+		se.MelAffine.Apply(&se.MelFBankSegment)
+		if se.Params.ChannelMode == ChannelAll {
+			for ch := 0; ch < se.MelChans.Dim(0); ch++ {
+				se.MelAffine.Apply(se.MelChans.SubSpace([]int{ch}).(*etensor.Float64))
+			}
+		}
+	}
+	if se.SpecAug.On {
+		se.specAugment()
+	}
+	if se.Mel.FBankDeltas {
+		mel.Deltas(&se.MelFBankSegment, &se.MelDeltas, se.Mel.DeltaWin)
+		mel.Deltas(&se.MelDeltas, &se.MelDeltaDeltas, se.Mel.DeltaWin)
+	}
+	if se.GaborCarry {
+		se.setCarry(segment)
+		se.keepCarry(segment, add)
+	}
+	if se.TimePool.On() {
+		se.PoolTime()
+	}
+	if se.Splice.On() {
+		se.SpliceFrames()
+	}
+	se.SetNameMetaData()
+}
+
+// processChannel processes the segment of the channel Chan of the Signal, the steps (see ProcessStep) and then the
+// energy and mfcc deltas of the segment
+func (se *SndEnv) processChannel(segment, add int) {
 	se.Power.SetZeros()
 	se.LogPower.SetZeros()
 	se.PowerSegment.SetZeros()
 	se.LogPowerSegment.SetZeros()
 	se.Energy.SetZeros()
 	se.MelFBankSegment.SetZeros()
+	if se.Pitch.On && se.Chan == 0 {
+		se.PitchSegment.SetZeros()
+	}
 	if se.Mel.MFCC == true {
 		se.MFCCSegment.SetZeros()
 	}
@@ -357,6 +781,12 @@ func (se *SndEnv) ProcessSegment(segment, add int) {
 			break
 		}
 	}
+	if se.Envelope.On && se.Chan == 0 {
+		se.envelopeSegment(segment, add)
+	}
+	if se.Features.On {
+		se.Features.Segment(&se.PowerSegment, se.Sound.SampleRate(), se.Params.WinSamples, &se.FeatSegment)
+	}
 	for s := 0; s < se.Params.SegmentSteps; s++ {
 		e := 0.0
 		for f := 0; f < se.LogPowerSegment.Shape.Dim(1); f++ {
@@ -369,66 +799,14 @@ func (se *SndEnv) ProcessSegment(segment, add int) {
 		for s := 0; s < se.Params.SegmentSteps; s++ {
 			se.MFCCSegment.SetFloatRowCell(0, s, se.Energy.FloatVal1D(s))
 		}
+		if se.Mel.CMVN {
+			se.Mel.NormalizeCMVN(&se.MFCCSegment) // before the deltas, which are of the normalized mfcc
+		}
 	}
-	//calculate the MFCC deltas (change in MFCC coeficient over time - basically first derivative)
-	//One source of the equation - https://privacycanada.net/mel-frequency-cepstral-coefficient/#Mel-filterbank-Computation
-
-	//denominator = 2 * sum([i**2 for i in range(1, N+1)])
-	// npn: For each frame, calculate delta features based on preceding and following npn frames
-	npn := 2
+	// the deltas and delta-deltas over time, see mel.Deltas
 	if se.Mel.MFCC && se.Mel.Deltas {
-		for s := 0; s < int(se.Params.SegmentSteps); s++ {
-			prv := 0.0
-			nxt := 0.0
-			for i := 0; i < se.Mel.NCoefs; i++ {
-				nume := 0.0
-				for n := 1; n <= npn; n++ {
-					sprv := s - n
-					snxt := s + n
-					if sprv < 0 {
-						sprv = 0
-					}
-					if snxt > se.Params.SegmentSteps-1 {
-						snxt = se.Params.SegmentSteps - 1
-					}
-					prv += se.MFCCSegment.FloatValRowCell(i, sprv)
-					nxt += se.MFCCSegment.FloatValRowCell(i, snxt)
-					nume += float64(n) * (nxt - prv)
-
-					denom := float64(2 * n * n)
-					d := nume / denom
-					// ToDo: better to have separate matrix and combine if using one layer as input
-					se.MFCCDeltas.SetFloatRowCell(i, s, d)
-				}
-			}
-		}
-
-		// now the delta deltas
-		for s := 0; s < int(se.Params.SegmentSteps); s++ {
-			prv := 0.0
-			nxt := 0.0
-			for i := 0; i < se.Mel.NCoefs; i++ {
-				nume := 0.0
-				for n := 1; n <= npn; n++ {
-					sprv := s - n
-					snxt := s + n
-					if sprv < 0 {
-						sprv = 0
-					}
-					if snxt > se.Params.SegmentSteps-1 {
-						snxt = se.Params.SegmentSteps - 1
-					}
-					prv += se.MFCCDeltas.FloatValRowCell(i, sprv)
-					nxt += se.MFCCDeltas.FloatValRowCell(i, snxt)
-					nume += float64(n) * (nxt - prv)
-
-					denom := float64(2 * n * n)
-					d := nume / denom
-					// ToDo: better to have separate matrix and combine if using one layer as input
-					se.MFCCDeltaDeltas.SetFloatRowCell(i, s, d)
-				}
-			}
-		}
+		mel.Deltas(&se.MFCCSegment, &se.MFCCDeltas, se.Mel.DeltaWin)
+		mel.Deltas(&se.MFCCDeltas, &se.MFCCDeltaDeltas, se.Mel.DeltaWin)
 	}
 }
 
@@ -443,7 +821,16 @@ func (se *SndEnv) ProcessStep(segment, step, add int) error {
 	if err == nil {
 		//gparams.Fft.Reset(wparams.WinSamples)
 		se.DFT.Filter(step, &se.Window, se.Params.WinSamples, &se.Power, &se.LogPower, &se.PowerSegment, &se.LogPowerSegment)
-		se.Mel.FilterDft(step, &se.Power, &se.MelFBankSegment, &se.MelFBank, &se.MelFilters)
+		se.filterStep(step)
+		if se.Features.On && step < se.FeatSegment.Dim(1) {
+			se.FeatSegment.Values[int(features.ZCR)*se.FeatSegment.Dim(1)+step] = vad.ZeroCrossRate(se.stepSamples(start))
+		}
+		if se.Pitch.On && se.Chan == 0 {
+			se.pitchStep(step, start)
+		}
+		if se.VAD.On && step < se.VADMask.Len() && se.vadDet.Step(&se.VAD, se.stepSamples(start), se.Power.Values) {
+			se.VADMask.Values[step] = 1
+		}
 		if se.Mel.MFCC {
 			se.Mel.CepstrumDct(step, &se.MelFBank, &se.MFCCSegment, &se.MFCCDCT)
 		}
@@ -451,35 +838,105 @@ func (se *SndEnv) ProcessStep(segment, step, add int) error {
 	return err
 }
 
-// SndToWindow gets sound from the signal (i.e. the slice of input values) at given position
+// SndToWindow gets sound from the signal (i.e. the slice of input values) at given position, from channel Chan
+// if the signal has multiple channels (see ToTensor), pre-emphasized if Params.PreEmph is not 0
 func (se *SndEnv) SndToWindow(start int) error {
-	if se.Signal.NumDims() == 1 {
-		end := start + se.Params.WinSamples
-		if end > len(se.Signal.Values) {
-			return errors.New("SndToWindow: end beyond signal length!!")
-		}
-		var pad []float64
-		if start < 0 && end <= 0 {
-			pad = make([]float64, end-start)
-			se.Window.Values = pad[0:]
-		} else if start < 0 && end > 0 {
-			pad = make([]float64, 0-start)
-			se.Window.Values = pad[0:]
-			se.Window.Values = append(se.Window.Values, se.Signal.Values[0:end]...)
-		} else {
-			se.Window.Values = se.Signal.Values[start:end]
-		}
-		//fmt.Println("start / end in samples:", start, end)
+	sig := se.ChannelSignal(se.Chan)
+	end := start + se.Params.WinSamples
+	if end > len(sig) {
+		return errors.New("SndToWindow: end beyond signal length!!")
+	}
+	var pad []float64
+	if start < 0 && end <= 0 {
+		pad = make([]float64, end-start)
+		se.Window.Values = pad[0:]
+	} else if start < 0 && end > 0 {
+		pad = make([]float64, 0-start)
+		se.Window.Values = pad[0:]
+		se.Window.Values = append(se.Window.Values, sig[0:end]...)
 	} else {
-		// ToDo: implement
-		fmt.Printf("SndToWindow: else case not implemented - please report this issue")
+		se.Window.Values = sig[start:end]
+	}
+	if se.Params.PreEmph != 0 {
+		if cap(se.emph) < end-start {
+			se.emph = make([]float64, end-start)
+		}
+		se.emph = se.emph[:end-start]
+		PreEmphasize(se.emph, sig, start, se.Params.PreEmph)
+		se.Window.Values = se.emph
 	}
+	//fmt.Println("start / end in samples:", start, end)
 	return nil
 }
 
-// ApplyGabor convolves the gabor filters with the mel output
+// stepSamples returns the samples of the window from start, of channel Chan, not pre-emphasized or tapered and cropped to
+// the signal -- the samples voice activity detection is done on
+func (se *SndEnv) stepSamples(start int) []float64 {
+	sig := se.ChannelSignal(se.Chan)
+	end := start + se.Params.WinSamples
+	if start < 0 {
+		start = 0
+	}
+	if end > len(sig) {
+		end = len(sig)
+	}
+	if start >= end {
+		return nil
+	}
+	return sig[start:end]
+}
+
+// pitchStep sets the pitch of the step, from the frame of Pitch.FrameSamples samples of channel Chan centered on
+// the window from start, not pre-emphasized or tapered
+func (se *SndEnv) pitchStep(step, start int) {
+	sr := se.Sound.SampleRate()
+	steps := se.PitchSegment.Dim(1)
+	if step >= steps {
+		return
+	}
+	frame := pitch.Frame(se.ChannelSignal(se.Chan), start+se.Params.WinSamples/2, se.Pitch.FrameSamples(sr))
+	hz, v := se.Pitch.Estimate(frame, sr)
+	se.PitchSegment.Values[step] = hz
+	se.PitchSegment.Values[steps+step] = v
+}
+
+// SpeechFrac returns the proportion of the steps of the current segment that are speech, see VAD -- 1 if VAD is off
+func (se *SndEnv) SpeechFrac() float64 {
+	if !se.VAD.On {
+		return 1
+	}
+	return vad.Frac(se.VADMask.Values)
+}
+
+// PreEmphasize sets dst to the len(dst) samples of the signal from start, filtered by the pre-emphasis filter
+// x[t] - coef * x[t-1], the same whatever window of the signal is taken -- samples outside the signal are zero
+func PreEmphasize(dst, signal []float64, start int, coef float64) {
+	for i := range dst {
+		t := start + i
+		if t < 0 || t >= len(signal) {
+			dst[i] = 0
+			continue
+		}
+		dst[i] = signal[t]
+		if t > 0 {
+			dst[i] -= coef * signal[t-1]
+		}
+	}
+}
+
+// ApplyGabor convolves the gabor filters with the mel output, or its band if cropped (see MelBand)
 func (se *SndEnv) ApplyGabor() (tsr *etensor.Float32) {
-	agabor.Convolve(&se.MelFBankSegment, se.GaborFilters, &se.GborOutput, se.ByTime)
+	if se.Params.ChannelMode == ChannelAll {
+		return se.applyGaborChans()
+	}
+	in := se.GaborInput()
+	if se.GaborCarry {
+		in = se.gaborInputOf(&se.MelCarry)
+	}
+	agabor.Convolve(in, se.GaborFilters, &se.GborOutput, se.ByTime)
+	if se.Habit.On {
+		se.applyHabit(&se.GborOutput, 0)
+	}
 
 	if se.NeighInhib.On {
 		se.ApplyNeighInhib()
@@ -499,20 +956,51 @@ func (se *SndEnv) ApplyGabor() (tsr *etensor.Float32) {
 func (se *SndEnv) Name() string { return se.Nm }
 func (se *SndEnv) Desc() string { return se.Dsc }
 
-// Tail returns the number of samples that remain beyond the last full stride
+// Tail returns the number of samples that remain beyond the last full stride, i.e., beyond the end of the last
+// segment that fits in the signal, including the segment's border steps -- all of the signal if it is shorter than one segment.
+// Call after Init
 func (se *SndEnv) Tail(signal []float64) int {
-	temp := len(signal) - se.Params.SegmentSamples
-	tail := temp % se.Params.StrideSamples
-	return tail
+	segEnd := se.SegmentEnd()
+	if len(signal) < segEnd {
+		return len(signal)
+	}
+	return (len(signal) - segEnd) % se.Params.StrideSamples
 }
 
-// Pad pads the signal so that the length of signal divided by stride has no remainder
-func (se *SndEnv) Pad(signal []float64, value float64) (padded []float64) {
-	tail := se.Tail(signal)
-	padLen := se.Params.SegmentSamples - se.Params.StepSamples - tail%se.Params.StepSamples
+// PadLen returns the number of samples to add to a signal of n samples so the last segment covers the end of the
+// signal, including the segment's border steps, or so there is one full segment if the signal is shorter -- call after Init
+func (se *SndEnv) PadLen(n int) int {
+	segEnd := se.SegmentEnd()
+	if n <= segEnd {
+		return segEnd - n
+	}
+	tail := (n - segEnd) % se.Params.StrideSamples
+	if tail == 0 {
+		return 0
+	}
+	return se.Params.StrideSamples - tail
+}
+
+// Pad pads the end of the signal, as set by Params.PadMode, so that the last segment covers the end of the signal
+// (see PadLen) -- call after Init, and as SegCnt is computed by Init call Init again after padding Signal
+func (se *SndEnv) Pad(signal []float64) (padded []float64) {
+	padLen := se.PadLen(len(signal))
+	n := len(signal)
 	pad := make([]float64, padLen)
 	for i := range pad {
-		pad[i] = value
+		switch {
+		case n == 0 || se.Params.PadMode == PadConstant:
+			pad[i] = se.Params.PadValue
+		case se.Params.PadMode == PadEdge || n == 1:
+			pad[i] = signal[n-1]
+		default: // PadReflect -- reflect back and forth for pads longer than the signal
+			period := 2 * (n - 1)
+			j := (n + i) % period
+			if j >= n {
+				j = period - j
+			}
+			pad[i] = signal[j]
+		}
 	}
 	padded = append(signal, pad...)
 	return padded
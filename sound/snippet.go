@@ -0,0 +1,90 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/auditory/speech"
+	"github.com/go-audio/audio"
+)
+
+// Snippet returns a new Wave with the part of the sound from startMs to endMs, limited to the sound,
+// with the same format and bit depth
+func (snd *Wave) Snippet(startMs, endMs float64) (*Wave, error) {
+	if snd.Buf == nil {
+		err := fmt.Errorf("sound.Snippet: no sound loaded")
+		log.Println(err)
+		return nil, err
+	}
+	sr := snd.SampleRate()
+	nc := snd.Channels()
+	nfr := snd.Buf.NumFrames()
+	st := MSecToSamples(startMs, sr)
+	ed := MSecToSamples(endMs, sr)
+	if st < 0 {
+		st = 0
+	}
+	if ed > nfr {
+		ed = nfr
+	}
+	if ed <= st {
+		err := fmt.Errorf("sound.Snippet: %v..%v ms is not within the sound of %v ms", startMs, endMs, SamplesToMSec(nfr, sr))
+		log.Println(err)
+		return nil, err
+	}
+	format := *snd.Buf.Format
+	buf := &audio.IntBuffer{Format: &format, SourceBitDepth: snd.Buf.SourceBitDepth}
+	buf.Data = append([]int{}, snd.Buf.Data[st*nc:ed*nc]...)
+	return &Wave{Buf: buf}, nil
+}
+
+// SnippetName returns the file name of the snippet of a unit, label_source_idx.wav, with source the file name
+// of the sound without the extension. Characters other than letters, digits, - and . are replaced by _
+// (e.g. TIMIT "h#" becomes "h_")
+func SnippetName(label, source string, idx int) string {
+	src := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	return fmt.Sprintf("%v_%v_%v.wav", fileSafe(label), fileSafe(src), idx)
+}
+
+func fileSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// ExportUnits writes each unit of the sequence, from preMs before its start to postMs after its end, as a wav file
+// in dir named by SnippetName, e.g., to make a data set of phone or word exemplars. It returns the files written
+func ExportUnits(seq *speech.Sequence, dir string, preMs, postMs float64) ([]string, error) {
+	var snd Wave
+	if err := snd.Load(seq.File); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	var files []string
+	for i, u := range seq.Units {
+		sn, err := snd.Snippet(u.Start-preMs, u.End+postMs)
+		if err != nil {
+			return files, err
+		}
+		fn := filepath.Join(dir, SnippetName(u.Name, seq.File, i))
+		if err := sn.WriteWave(fn); err != nil {
+			return files, err
+		}
+		files = append(files, fn)
+	}
+	return files, nil
+}
@@ -5,7 +5,10 @@
 package sound
 
 import (
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 
 	"github.com/emer/etable/etensor"
@@ -31,9 +34,13 @@ const (
 
 type Wave struct {
 	Buf *audio.IntBuffer `inactive:"+"`
+
+	// [def: 0] sample rate that sounds are resampled to when they are loaded or decoded (see Resample), e.g., 16000 so corpora recorded at different rates have the same number of samples per window and step -- 0 keeps the rate of each sound
+	TargetRate int `default:"0" desc:"sample rate that sounds are resampled to when they are loaded or decoded (see Resample), e.g., 16000 so corpora recorded at different rates have the same number of samples per window and step -- 0 keeps the rate of each sound"`
 }
 
-// Load loads the sound file and decodes it
+// Load loads the sound file and decodes it with the decoder of its extension -- wav, or compressed formats such as
+// FLAC, MP3 and OGG through ffmpeg or the decoders registered for them (see RegisterDecoder)
 func (snd *Wave) Load(fn string) error {
 	f, err := os.Open(fn)
 	if err != nil {
@@ -41,28 +48,73 @@ func (snd *Wave) Load(fn string) error {
 		return err
 	}
 	defer f.Close()
-	d := wav.NewDecoder(f)
-	snd.Buf, err = d.FullPCMBuffer()
-	if err != nil {
-		log.Fatal(err)
+	if _, ok := DecoderFor(fn); !ok { // e.g. no extension, try wav as before
+		return snd.Decode(f)
 	}
+	return snd.DecodeFormat(f, fn)
+}
 
-	return err
+// Decode decodes wav data, e.g., from a file or from bytes received over the network (use bytes.NewReader),
+// resampled to TargetRate if set -- see DecodeFormat for other formats
+func (snd *Wave) Decode(r io.ReadSeeker) error {
+	var err error
+	snd.Buf, err = DecodeWav(r)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	snd.Resample(snd.TargetRate)
+	return nil
 }
 
 // WriteWave encodes the signal data and writes it to file using the sample rate and
 // other values of the buf object
 func (snd *Wave) WriteWave(fn string) error {
+	return snd.WriteWaveFormat(fn, snd.Buf.SourceBitDepth, false)
+}
+
+// WriteWaveFormat writes the sound to the wav file as PCM samples of the bit depth, 8, 16, 24 or 32, or if float
+// is set as 32 bit IEEE float samples (bitDepth is ignored) -- the samples are rescaled from the bit depth of the
+// sound, e.g., to write 24 bit or float files for tools such as Praat and Audacity
+func (snd *Wave) WriteWaveFormat(fn string, bitDepth int, float bool) error {
+	format := 1 // PCM
+	if float {
+		format = wavFormatFloat
+		bitDepth = 32
+	}
+	switch bitDepth {
+	case 8, 16, 24, 32:
+	default:
+		err := fmt.Errorf("sound.WriteWaveFormat: bit depth %v, must be 8, 16, 24 or 32", bitDepth)
+		log.Println(err)
+		return err
+	}
+	buf := &audio.IntBuffer{Format: snd.Buf.Format, SourceBitDepth: bitDepth, Data: make([]int, len(snd.Buf.Data))}
+	for i := range buf.Data {
+		v := snd.GetFloatAtIdx(snd.Buf, i)
+		switch {
+		case float:
+			buf.Data[i] = int(int32(math.Float32bits(float32(v)))) // the encoder writes the bits as they are
+		case bitDepth == snd.Buf.SourceBitDepth:
+			buf.Data[i] = snd.Buf.Data[i]
+		default:
+			buf.Data[i] = floatToPCM(v, bitDepth)
+		}
+		if !float && bitDepth == 8 { // 8 bit wav samples are unsigned, see DecodeWav
+			buf.Data[i] += 128
+		}
+	}
+
 	out, err := os.Create(fn)
 	if err != nil {
 		log.Printf("unable to create %s: %v", fn, err)
 		return err
 	}
 
-	PCM := 1
-	e := wav.NewEncoder(out, snd.SampleRate(), snd.Buf.SourceBitDepth, snd.Channels(), PCM)
-	if err = e.Write(snd.Buf); err != nil {
+	e := wav.NewEncoder(out, snd.SampleRate(), bitDepth, snd.Channels(), format)
+	if err = e.Write(buf); err != nil {
 		log.Printf("Encoding failed on write: %v", err)
+		out.Close()
 		return err
 	}
 
@@ -108,20 +160,86 @@ func (snd *Wave) SampleType() SoundSampleType {
 	return SignedInt
 }
 
+// MixModes are the ways of combining the channels of multichannel sound into the single channel that is processed
+type MixModes int32
+
+const (
+	MixMono  MixModes = iota // mean of all channels (i.e. sum scaled by 1 / number of channels)
+	MixLeft                  // channel 0
+	MixRight                 // channel 1, channel 0 if there is only one
+	MixMid                   // (left + right) / 2 -- same as MixMono for stereo but only uses channels 0 and 1
+	MixSide                  // (left - right) / 2, the difference between the channels, 0 for mono
+)
+
 // SoundToTensor converts sound data to floating point etensor with normalized -1..1 values (unless sound is stored as a
 // float natively, in which case it is not guaranteed to be normalized) -- for use in signal processing routines --
-// can optionally select a specific channel (formats sound_data as a single-dimensional matrix of frames size),
-// and -1 gets all available channels (formats sound_data as two-dimensional matrix with outer dimension as
-// channels and inner dimension frames
+// formats sound_data as a single-dimensional matrix of frames size. Multichannel sound is mixed down to one channel
+// with MixMono -- use SoundToTensorMix to select a channel or another mix
 func (snd *Wave) SoundToTensor(samples *etensor.Float64) bool {
+	return snd.SoundToTensorMix(samples, MixMono, -1)
+}
+
+// SoundToTensorMix converts sound data to a single-dimensional floating point etensor of frames size, as SoundToTensor,
+// using the given channel if channel >= 0 or combining the channels with mode if channel is -1
+func (snd *Wave) SoundToTensorMix(samples *etensor.Float64, mode MixModes, channel int) bool {
 	nFrames := snd.Buf.NumFrames()
+	nch := snd.Channels()
+	if nch < 1 {
+		nch = 1
+	}
+	if channel >= nch {
+		log.Printf("sound.SoundToTensorMix: channel %v out of range, sound has %v channels\n", channel, nch)
+		return false
+	}
 
 	shape := make([]int, 1)
 	shape[0] = nFrames
 	samples.SetShape(shape, nil, nil)
 
+	left := 0
+	right := 1
+	if nch == 1 {
+		right = 0
+	}
+	for i := 0; i < nFrames; i++ {
+		fr := i * nch // data is interleaved by frame
+		var v float64
+		switch {
+		case channel >= 0:
+			v = snd.GetFloatAtIdx(snd.Buf, fr+channel)
+		case mode == MixLeft:
+			v = snd.GetFloatAtIdx(snd.Buf, fr+left)
+		case mode == MixRight:
+			v = snd.GetFloatAtIdx(snd.Buf, fr+right)
+		case mode == MixMid:
+			v = 0.5 * (snd.GetFloatAtIdx(snd.Buf, fr+left) + snd.GetFloatAtIdx(snd.Buf, fr+right))
+		case mode == MixSide:
+			v = 0.5 * (snd.GetFloatAtIdx(snd.Buf, fr+left) - snd.GetFloatAtIdx(snd.Buf, fr+right))
+		default: // MixMono
+			for c := 0; c < nch; c++ {
+				v += snd.GetFloatAtIdx(snd.Buf, fr+c)
+			}
+			v /= float64(nch)
+		}
+		samples.SetFloat1D(i, v)
+	}
+	return true
+}
+
+// SoundToTensorChannels converts sound data to a [channels, frames] floating point etensor, each channel's
+// samples normalized as SoundToTensor
+func (snd *Wave) SoundToTensorChannels(samples *etensor.Float64) bool {
+	nFrames := snd.Buf.NumFrames()
+	nch := snd.Channels()
+	if nch < 1 {
+		nch = 1
+	}
+	samples.SetShape([]int{nch, nFrames}, nil, []string{"Channel", "Sample"})
 	for i := 0; i < nFrames; i++ {
-		samples.SetFloat1D(i, float64(snd.GetFloatAtIdx(snd.Buf, i)))
+		fr := i * nch // data is interleaved by frame
+		for c := 0; c < nch; c++ {
+			samples.Values[c*nFrames+i] = snd.GetFloatAtIdx(snd.Buf, fr+c)
+		}
 	}
 	return true
 }
@@ -0,0 +1,120 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math/rand"
+
+	"github.com/emer/etable/etensor"
+)
+
+// SpecAugParams are the params of SpecAugment style masking of the mel output of each segment (Park et al.,
+// "SpecAugment: A Simple Data Augmentation Method for Automatic Speech Recognition", 2019): random bands of filters
+// and runs of steps are set to a fill value, so a model trained on them doesn't rely on any one part of the spectrum
+// or moment of the sound. Turn it off for testing
+type SpecAugParams struct {
+
+	// mask the mel output of each segment, see SndEnv.SpecAug
+	On bool `desc:"mask the mel output of each segment, see SndEnv.SpecAug"`
+
+	// [def: 2] [viewif: On] number of frequency masks, bands of filters, per segment
+	FreqMasks int `viewif:"On" default:"2" desc:"number of frequency masks, bands of filters, per segment"`
+
+	// [def: 6] [viewif: On] greatest width of a frequency mask in filters, each drawn uniformly from 0 to FreqWidth
+	FreqWidth int `viewif:"On" default:"6" desc:"greatest width of a frequency mask in filters, each drawn uniformly from 0 to FreqWidth"`
+
+	// [def: 1] [viewif: On] number of time masks, runs of steps, per segment
+	TimeMasks int `viewif:"On" default:"1" desc:"number of time masks, runs of steps, per segment"`
+
+	// [def: 3] [viewif: On] greatest width of a time mask in steps, each drawn uniformly from 0 to TimeWidth
+	TimeWidth int `viewif:"On" default:"3" desc:"greatest width of a time mask in steps, each drawn uniformly from 0 to TimeWidth"`
+
+	// [def: true] [viewif: On] fill the masks with the mean of the segment's output, otherwise with 0 -- 0 is the mean of standardized output
+	FillMean bool `viewif:"On" default:"true" desc:"fill the masks with the mean of the segment's output, otherwise with 0 -- 0 is the mean of standardized output"`
+}
+
+// Defaults
+func (sp *SpecAugParams) Defaults() {
+	sp.FreqMasks = 2
+	sp.FreqWidth = 6
+	sp.TimeMasks = 1
+	sp.TimeWidth = 3
+	sp.FillMean = true
+}
+
+// Mask fills FreqMasks bands of rows and TimeMasks runs of columns of tsr [freqs, steps] at random positions from rnd
+func (sp *SpecAugParams) Mask(tsr *etensor.Float64, rnd *rand.Rand) {
+	sp.MaskAll([]*etensor.Float64{tsr}, rnd)
+}
+
+// MaskAll fills the same bands of rows and runs of columns of each of tsrs, all [freqs, steps] of the same shape, e.g.
+// the mel output of each channel, as Mask does for one -- with FillMean, each is filled with its own mean
+func (sp *SpecAugParams) MaskAll(tsrs []*etensor.Float64, rnd *rand.Rand) {
+	if len(tsrs) == 0 {
+		return
+	}
+	nf, steps := tsrs[0].Dim(0), tsrs[0].Dim(1)
+	if nf == 0 || steps == 0 {
+		return
+	}
+	fills := make([]float64, len(tsrs))
+	if sp.FillMean {
+		for i, tsr := range tsrs {
+			for _, v := range tsr.Values {
+				fills[i] += v
+			}
+			fills[i] /= float64(len(tsr.Values))
+		}
+	}
+	for m := 0; m < sp.FreqMasks; m++ {
+		w, st := maskSpan(nf, sp.FreqWidth, rnd)
+		for i, tsr := range tsrs {
+			for f := st; f < st+w; f++ {
+				for s := 0; s < steps; s++ {
+					tsr.Values[f*steps+s] = fills[i]
+				}
+			}
+		}
+	}
+	for m := 0; m < sp.TimeMasks; m++ {
+		w, st := maskSpan(steps, sp.TimeWidth, rnd)
+		for i, tsr := range tsrs {
+			for f := 0; f < nf; f++ {
+				for s := st; s < st+w; s++ {
+					tsr.Values[f*steps+s] = fills[i]
+				}
+			}
+		}
+	}
+}
+
+// maskSpan returns the width, 0 to maxWidth (limited to n), and start of a mask within n rows or columns
+func maskSpan(n, maxWidth int, rnd *rand.Rand) (w, st int) {
+	if maxWidth > n {
+		maxWidth = n
+	}
+	if maxWidth <= 0 {
+		return 0, 0
+	}
+	w = rnd.Intn(maxWidth + 1)
+	st = rnd.Intn(n - w + 1)
+	return w, st
+}
+
+// specAugment masks the mel output of the segment by SpecAug, with random numbers derived from the Seed the first time --
+// with ChannelAll, the mel output of each channel is masked at the same positions, so the gabor output of the channels
+// is of the masked output too
+func (se *SndEnv) specAugment() {
+	if se.specRand == nil {
+		se.specRand = se.NewRand("specaug")
+	}
+	tsrs := []*etensor.Float64{&se.MelFBankSegment}
+	if se.Params.ChannelMode == ChannelAll {
+		for ch := 0; ch < se.MelChans.Dim(0); ch++ {
+			tsrs = append(tsrs, se.MelChans.SubSpace([]int{ch}).(*etensor.Float64))
+		}
+	}
+	se.SpecAug.MaskAll(tsrs, se.specRand)
+}
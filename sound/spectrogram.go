@@ -0,0 +1,41 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"github.com/emer/auditory/dft"
+	"github.com/emer/etable/etensor"
+)
+
+// Spectrogram returns the log power of the dft of the whole signal, [freqs, steps] as LogPowerSegment, a step every
+// stepMs of a window of winMs of samples, computed by the dft params (with CompLogPow on) -- e.g., for viewing a whole
+// sound file rather than a segment, see img.SaveTensorPNG with DisplayLogPower. The steps are those of the windows that
+// fit in the signal, with the time of step s at s * stepMs
+func Spectrogram(signal []float64, sampleRate int, winMs, stepMs float64, dp *dft.Params) *etensor.Float64 {
+	winSamples := MSecToSamples(winMs, sampleRate)
+	stepSamples := MSecToSamples(stepMs, sampleRate)
+	nf := winSamples/2 + 1
+	steps := 0
+	if winSamples > 0 && stepSamples > 0 && len(signal) >= winSamples {
+		steps = (len(signal)-winSamples)/stepSamples + 1
+	}
+	spec := etensor.NewFloat64([]int{nf, steps}, nil, []string{"freq", "step"})
+	if steps == 0 {
+		return spec
+	}
+	d := *dp
+	d.CompLogPow = true
+	d.ResetCache() // not shared with the caller's params
+	power := etensor.NewFloat64([]int{nf}, nil, nil)
+	logPower := etensor.NewFloat64([]int{nf}, nil, nil)
+	powerSeg := etensor.NewFloat64([]int{nf, steps}, nil, nil)
+	window := &etensor.Float64{}
+	window.SetShape([]int{winSamples}, nil, nil)
+	for s := 0; s < steps; s++ {
+		window.Values = signal[s*stepSamples : s*stepSamples+winSamples]
+		d.Filter(s, window, winSamples, power, logPower, powerSeg, spec)
+	}
+	return spec
+}
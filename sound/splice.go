@@ -0,0 +1,64 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"github.com/emer/etable/etensor"
+)
+
+// SpliceParams stacks each mel and mfcc frame with its N neighboring frames on each side (splicing, as in ASR front ends),
+// so each frame has its context for feedforward models without recurrence
+type SpliceParams struct {
+
+	// [def: 0] number of neighboring frames on each side stacked with each frame, 0 for no splicing -- frames before the first and after the last frame repeat the first and last frame
+	N int `default:"0" desc:"number of neighboring frames on each side stacked with each frame, 0 for no splicing -- frames before the first and after the last frame repeat the first and last frame"`
+}
+
+// Defaults
+func (sp *SpliceParams) Defaults() {
+	sp.N = 0
+}
+
+// On returns true if frames are spliced
+func (sp *SpliceParams) On() bool {
+	return sp.N > 0
+}
+
+// Splice stacks the frames, the columns, of in [rows, steps] with their neighbors into out [(2N+1) * rows, steps] --
+// the rows of frame s - N first, then those of s - N + 1, etc up to s + N
+func (sp *SpliceParams) Splice(in, out *etensor.Float64) {
+	rows, steps := in.Dim(0), in.Dim(1)
+	nc := 2*sp.N + 1
+	out.SetShape([]int{nc * rows, steps}, nil, in.DimNames())
+	for s := 0; s < steps; s++ {
+		for c := 0; c < nc; c++ {
+			src := s + c - sp.N
+			if src < 0 {
+				src = 0
+			}
+			if src > steps-1 {
+				src = steps - 1
+			}
+			for r := 0; r < rows; r++ {
+				out.Values[(c*rows+r)*steps+s] = in.Values[r*steps+src]
+			}
+		}
+	}
+}
+
+// SpliceFrames splices the mel output, and the mfcc output if Mel.MFCC, into MelSpliced and MFCCSpliced --
+// the outputs pooled over time (MelPooled, MFCCPooled) if TimePool is on. Called by ProcessSegment if Splice.On()
+func (se *SndEnv) SpliceFrames() {
+	mel, mfcc := &se.MelFBankSegment, &se.MFCCSegment
+	if se.TimePool.On() {
+		mel, mfcc = &se.MelPooled, &se.MFCCPooled
+	}
+	se.Splice.Splice(mel, &se.MelSpliced)
+	se.MelSpliced.CopyMetaData(mel)
+	if se.Mel.MFCC {
+		se.Splice.Splice(mfcc, &se.MFCCSpliced)
+		se.MFCCSpliced.CopyMetaData(mfcc)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import "math"
+
+// StepAligns are the models of where the steps of a segment are in the signal, see StepsBack
+type StepAligns int32
+
+const (
+	// AlignStart is the step alignment model: segment s covers the signal from s * StrideMs for SegmentMs, and the
+	// BorderSteps on each side extend it into the previous and next segments (padded with zeros before the signal start).
+	// Step i of the segment starts at s * StrideSamples + (i - BorderSteps) * StepSamples, so the first step within the border
+	// is at the segment start and the times of the segment, its steps and the SetTimeMetaData metadata all agree
+	AlignStart StepAligns = iota
+
+	// AlignStrides is the alignment of the old processspeech example, kept for compatibility: the steps start
+	// StepMs/StrideMs steps per stride times one less than the int(SegmentMs / StrideMs) strides before AlignStart, so that
+	// when SegmentMs is a multiple of StrideMs each segment ends at the end of its stride, (s + 1) * StrideMs. The segment
+	// times no longer agree with the step times, and when SegmentMs is not a multiple of StrideMs the ends aren't aligned
+	AlignStrides
+)
+
+// StepsBack returns how many steps before the segment start (segment * stride) the first step of a segment starts, for the alignment model
+func StepsBack(align StepAligns, segmentMs, strideMs, stepMs float64, borderSteps int) int {
+	if align == AlignStrides {
+		strides := int(segmentMs / strideMs)
+		stepsPerStride := int(strideMs / stepMs)
+		return stepsPerStride*(strides-1) + borderSteps
+	}
+	return borderSteps
+}
+
+// StepOffsets returns the offset in samples from the segment start of the start of each of the segmentSteps steps,
+// negative for the steps before it (see StepsBack)
+func StepOffsets(stepsBack, stepSamples, segmentSteps int) []int {
+	steps := make([]int, segmentSteps)
+	for i := range steps {
+		steps[i] = stepSamples * (i - stepsBack)
+	}
+	return steps
+}
+
+// SegmentSteps returns the number of steps of a segment, including the border steps on both sides
+func SegmentSteps(segmentMs, stepMs float64, borderSteps int) int {
+	return int(math.Round(segmentMs/stepMs)) + 2*borderSteps
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"reflect"
+	"testing"
+)
+
+// oldProcessSpeechSteps are the step offsets of the processspeech example before StepsBack, as it computed them
+func oldProcessSpeechSteps(segmentMs, strideMs, stepMs float32, borderSteps, stepSamples, segmentSteps int) []int {
+	strides := int(segmentMs / strideMs)
+	stepsPerStride := int(strideMs / stepMs)
+	stepsBack := stepsPerStride*(strides-1) + borderSteps
+	steps := make([]int, segmentSteps)
+	for i := 0; i < segmentSteps; i++ {
+		steps[i] = stepSamples * (i - stepsBack)
+	}
+	return steps
+}
+
+func TestAlignStrides(t *testing.T) {
+	for _, c := range []struct {
+		segmentMs, strideMs, stepMs float32
+		border, back                int
+	}{
+		{100, 100, 10, 2, 2}, // the processspeech defaults
+		{300, 100, 10, 2, 22},
+		{100, 20, 5, 6, 22},
+		{250, 100, 12.5, 0, 8}, // segment not a multiple of the stride
+	} {
+		back := StepsBack(AlignStrides, float64(c.segmentMs), float64(c.strideMs), float64(c.stepMs), c.border)
+		if back != c.back {
+			t.Errorf("%+v: StepsBack got %v, want %v", c, back, c.back)
+		}
+		stepSamples := MSecToSamples(float64(c.stepMs), 16000)
+		n := SegmentSteps(float64(c.segmentMs), float64(c.stepMs), c.border)
+		got := StepOffsets(back, stepSamples, n)
+		want := oldProcessSpeechSteps(c.segmentMs, c.strideMs, c.stepMs, c.border, stepSamples, n)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: StepOffsets got %v, want %v", c, got, want)
+		}
+	}
+}
+
+// testSndEnv returns a SndEnv initialized with a 16 kHz signal of durMs milliseconds of two tones, with the gabor
+// filters and without kwta or neighbor inhibition
+func testSndEnv(t *testing.T, durMs float64) *SndEnv {
+	t.Helper()
+	sig := Tone(440, 0.3, 0, durMs, 16000)
+	for i, v := range Tone(1900, 0.2, 1, durMs, 16000) {
+		sig[i] += v
+	}
+	wv, err := NewWave([][]float64{sig}, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	se := &SndEnv{}
+	se.Defaults()
+	se.GaborDefaults()
+	se.Kwta.On = false
+	se.NeighInhib.On = false
+	se.Sound = *wv
+	return se
+}
+
+func TestAlignStartTimes(t *testing.T) {
+	se := testSndEnv(t, 1000)
+	se.Params.WinMs = 25
+	se.Params.StepMs = 10
+	se.Params.SegmentMs = 100
+	se.Params.StrideMs = 50
+	se.Params.BorderSteps = 3
+	se.SetGaborOut2D()
+	se.ToTensor()
+	if err := se.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, sa := range [][2]int{{0, 0}, {1, 0}, {4, 0}, {3, 20}} {
+		seg, add := sa[0], sa[1]
+		se.ProcessSegment(seg, add)
+		ms, ok := MetaDataFloats(&se.MelFBankSegment, "col-ms")
+		if !ok || len(ms) != se.Params.SegmentSteps {
+			t.Fatalf("segment %v: col-ms %v", seg, ms)
+		}
+		segStart := float64(seg)*se.Params.StrideMs + float64(add) // where the first step within the border starts
+		for i, got := range ms {
+			want := segStart + float64(i-se.Params.BorderSteps)*se.Params.StepMs
+			if got != want {
+				t.Errorf("segment %v add %v step %v: col-ms %v, want %v", seg, add, i, got, want)
+			}
+		}
+	}
+}
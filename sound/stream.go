@@ -0,0 +1,155 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+
+	"github.com/emer/etable/etensor"
+	"github.com/go-audio/audio"
+)
+
+// Stream feeds a SndEnv incrementally, e.g., from a microphone callback or an io.Reader of live audio, instead of
+// a whole sound file: the samples pushed are appended to Snd.Signal and every segment whose samples, including the
+// border steps, have all arrived is processed, one per stride, and passed to OnSegment. For interactive displays
+// of individual steps see IncrMel
+type Stream struct {
+
+	// the sound processing -- set its params, gabor specs and output shape before calling Init
+	Snd *SndEnv `desc:"the sound processing -- set its params, gabor specs and output shape before calling Init"`
+
+	// apply the gabor filters to each segment, passing the result to OnSegment
+	Gabor bool `desc:"apply the gabor filters to each segment, passing the result to OnSegment"`
+
+	// [def: 0] milliseconds of signal to keep before the next segment, 0 to keep all of it -- older samples are dropped, whole strides at a time, so a long stream doesn't grow without bound. With dropped samples the col-ms metadata of the outputs are relative to the kept signal, see StartMs
+	KeepMs float64 `default:"0" desc:"milliseconds of signal to keep before the next segment, 0 to keep all of it -- older samples are dropped, whole strides at a time, so a long stream doesn't grow without bound. With dropped samples the col-ms metadata of the outputs are relative to the kept signal, see StartMs"`
+
+	// [view: -] called after each segment is processed, with the segment number since Init, the SndEnv holding the outputs (e.g., MelFBankSegment) and the gabor output if Gabor is set, otherwise nil
+	OnSegment func(seg int, se *SndEnv, gabor *etensor.Float32) `view:"-" desc:"called after each segment is processed, with the segment number since Init, the SndEnv holding the outputs (e.g., MelFBankSegment) and the gabor output if Gabor is set, otherwise nil"`
+
+	// the next segment to process, counting from Init
+	Seg int `inactive:"+" desc:"the next segment to process, counting from Init"`
+
+	// number of strides of samples dropped from the start of Snd.Signal
+	Dropped int `inactive:"+" desc:"number of strides of samples dropped from the start of Snd.Signal"`
+}
+
+// Init initializes the processing of Snd for mono sound at the sample rate, with an empty signal
+func (st *Stream) Init(sampleRate int) error {
+	if st.Snd == nil {
+		err := errors.New("sound.Stream: Snd is nil")
+		log.Println(err)
+		return err
+	}
+	se := st.Snd
+	se.Sound.Buf = &audio.IntBuffer{Format: &audio.Format{NumChannels: 1, SampleRate: sampleRate}}
+	if err := se.InitProcess(); err != nil {
+		return err
+	}
+	se.Signal.SetShape([]int{0}, nil, nil)
+	se.SegCnt = 0
+	st.Seg = 0
+	st.Dropped = 0
+	return nil
+}
+
+// StartMs returns the time in milliseconds since Init of the start of the segment, see AlignStart
+func (st *Stream) StartMs(seg int) float64 {
+	return SamplesToMSec(seg*st.Snd.Params.StrideSamples, st.Snd.Sound.SampleRate())
+}
+
+// Push appends the samples to the signal and processes the segments that are complete, returning the number processed --
+// it can be used directly as the callback of a microphone
+func (st *Stream) Push(samples []float64) int {
+	se := st.Snd
+	se.Signal.Values = append(se.Signal.Values, samples...)
+	se.Signal.Shp[0] = len(se.Signal.Values)
+	stride := se.Params.StrideSamples
+	segEnd := se.SegmentEnd()
+	n := 0
+	for (st.Seg-st.Dropped)*stride+segEnd <= len(se.Signal.Values) {
+		rel := st.Seg - st.Dropped
+		se.SegCnt = rel + 1
+		se.ProcessSegment(rel, 0)
+		var gb *etensor.Float32
+		if st.Gabor {
+			gb = se.ApplyGabor()
+		}
+		if st.OnSegment != nil {
+			st.OnSegment(st.Seg, se, gb)
+		}
+		st.Seg++
+		n++
+	}
+	st.drop()
+	return n
+}
+
+// drop drops the strides of samples before KeepMs before the next segment, keeping the samples of the steps before its start
+func (st *Stream) drop() {
+	se := st.Snd
+	if st.KeepMs <= 0 {
+		return
+	}
+	stride := se.Params.StrideSamples
+	keep := MSecToSamples(st.KeepMs, se.Sound.SampleRate())
+	if back := -se.Params.Steps[0]; back > keep {
+		keep = back
+	}
+	start := (st.Seg-st.Dropped)*stride - keep // first sample to keep
+	if start < stride {
+		return
+	}
+	k := start / stride
+	rem := copy(se.Signal.Values, se.Signal.Values[k*stride:])
+	se.Signal.Values = se.Signal.Values[:rem]
+	se.Signal.Shp[0] = rem
+	st.Dropped += k
+}
+
+// Flush pads the end of the signal (see SndEnv.Pad) so the last segment covers the samples pushed, and processes the remaining segments
+func (st *Stream) Flush() int {
+	se := st.Snd
+	if len(se.Signal.Values) == 0 {
+		return 0
+	}
+	pad := se.Pad(se.Signal.Values)
+	return st.Push(pad[len(se.Signal.Values):])
+}
+
+// ReadPCM16 pushes 16 bit little endian PCM samples from r, interleaved for the number of channels and mixed down to mono,
+// a stride of samples at a time, until r returns io.EOF (then Flush is called) or another error, which is returned
+func (st *Stream) ReadPCM16(r io.Reader, channels int) error {
+	if channels < 1 {
+		channels = 1
+	}
+	n := st.Snd.Params.StrideSamples
+	buf := make([]byte, 2*channels*n)
+	samples := make([]float64, 0, n)
+	for {
+		nb, err := io.ReadFull(r, buf)
+		frames := nb / (2 * channels)
+		samples = samples[:0]
+		for f := 0; f < frames; f++ {
+			sum := 0.0
+			for c := 0; c < channels; c++ {
+				sum += float64(int16(binary.LittleEndian.Uint16(buf[2*(f*channels+c):]))) / 32768
+			}
+			samples = append(samples, sum/float64(channels))
+		}
+		st.Push(samples)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			st.Flush()
+			return nil
+		}
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math"
+	"math/cmplx"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// StretchWinMs is the length in milliseconds of the windows of the phase vocoder of TimeStretch and PitchShift,
+// rounded up to a power of 2 samples -- long enough to resolve the harmonics of speech
+const StretchWinMs = 40
+
+// StretchOverlap is the number of windows of the phase vocoder overlapping each sample
+const StretchOverlap = 4
+
+// TimeStretch returns the signal stretched in time by factor, keeping its pitch, by a phase vocoder -- the output is
+// factor times as long, e.g., 1.1 for 10% slower and 0.9 for 10% faster. The signal itself is returned for a
+// factor of 1 or less than or equal to 0
+func TimeStretch(signal []float64, factor float64, sampleRate int) []float64 {
+	if factor == 1 || factor <= 0 || len(signal) == 0 {
+		return signal
+	}
+	win := 1
+	for win < MSecToSamples(StretchWinMs, sampleRate) {
+		win *= 2
+	}
+	hs := win / StretchOverlap // synthesis hop
+	ha := float64(hs) / factor // analysis hop, in fractional samples
+	nout := int(math.Round(float64(len(signal)) * factor))
+	nfr := int(math.Ceil(float64(nout)/float64(hs))) + 1
+
+	hann := make([]float64, win)
+	for i := range hann {
+		hann[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(win))
+	}
+	fft := fourier.NewFFT(win)
+	nb := win/2 + 1
+	frame := make([]float64, win)
+	var spec []complex128
+	prev := make([]float64, nb)  // analysis phases of the previous frame
+	phase := make([]float64, nb) // synthesis phases
+	out := make([]float64, nout+win)
+	norm := make([]float64, nout+win)
+	lastPos := 0
+	for f := 0; f < nfr; f++ {
+		pos := int(math.Round(float64(f)*ha)) - win/2 // frames centered on the analysis times
+		for i := range frame {
+			frame[i] = 0
+			if t := pos + i; t >= 0 && t < len(signal) {
+				frame[i] = signal[t] * hann[i]
+			}
+		}
+		spec = fft.Coefficients(spec, frame)
+		hop := float64(pos - lastPos) // actual analysis hop, after rounding
+		for k, c := range spec {
+			ph := cmplx.Phase(c)
+			if f == 0 {
+				phase[k] = ph
+			} else {
+				omega := 2 * math.Pi * float64(k) / float64(win) // bin frequency in radians per sample
+				dev := ph - prev[k] - omega*hop
+				dev -= 2 * math.Pi * math.Round(dev/(2*math.Pi))
+				freq := omega
+				if hop > 0 {
+					freq += dev / hop // true frequency of the bin
+				}
+				phase[k] += freq * float64(hs)
+			}
+			prev[k] = ph
+			spec[k] = cmplx.Rect(cmplx.Abs(c), phase[k])
+		}
+		lastPos = pos
+		frame = fft.Sequence(frame, spec)
+		st := f*hs - win/2
+		for i, v := range frame {
+			t := st + i
+			if t < 0 || t >= len(out) {
+				continue
+			}
+			out[t] += v * hann[i] / float64(win)
+			norm[t] += hann[i] * hann[i]
+		}
+	}
+	out = out[:nout]
+	for i := range out {
+		if norm[i] > 1e-3 {
+			out[i] /= norm[i]
+		}
+	}
+	return out
+}
+
+// PitchShift returns the signal with its pitch, and all its frequencies, shifted by factor keeping its duration,
+// e.g., 1.1 for 10% higher and 0.9 for 10% lower -- time stretched by factor (see TimeStretch) and then resampled
+// back to the length of the signal. The signal itself is returned for a factor of 1 or less than or equal to 0
+func PitchShift(signal []float64, factor float64, sampleRate int) []float64 {
+	if factor == 1 || factor <= 0 || len(signal) == 0 {
+		return signal
+	}
+	st := TimeStretch(signal, factor, sampleRate)
+	rs := ResampleSlice(st, int(math.Round(float64(sampleRate)*factor)), sampleRate)
+	out := make([]float64, len(signal))
+	copy(out, rs)
+	return out
+}
+
+// SemitonesToFactor returns the frequency factor of a shift of the semitones, e.g., for PitchShift
+func SemitonesToFactor(semitones float64) float64 {
+	return math.Pow(2, semitones/12)
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math"
+
+	"github.com/emer/etable/etensor"
+)
+
+// TimePoolOps are the ways of pooling feature frames over time, see TimePoolParams
+type TimePoolOps int32
+
+const (
+	TimePoolAvg  TimePoolOps = iota // average of the frames of each pool
+	TimePoolMax                     // maximum of the frames of each pool
+	TimePoolSkip                    // first frame of each pool, i.e., keep every Nth frame
+)
+
+// TimePoolParams pools the mel and mfcc frames (steps) of a segment over time, for a lower frame rate
+// without changing StepMs and the window math derived from it
+type TimePoolParams struct {
+
+	// [def: 1] number of frames pooled into one, 1 for no pooling -- a last partial pool is pooled over the frames it has
+	N int `default:"1" desc:"number of frames pooled into one, 1 for no pooling -- a last partial pool is pooled over the frames it has"`
+
+	// [def: TimePoolAvg] how the frames of a pool are combined
+	Op TimePoolOps `default:"TimePoolAvg" desc:"how the frames of a pool are combined"`
+}
+
+// Defaults
+func (tp *TimePoolParams) Defaults() {
+	tp.N = 1
+	tp.Op = TimePoolAvg
+}
+
+// On returns true if frames are pooled
+func (tp *TimePoolParams) On() bool {
+	return tp.N > 1
+}
+
+// Frames returns the number of pooled frames of steps frames
+func (tp *TimePoolParams) Frames(steps int) int {
+	if tp.N <= 1 {
+		return steps
+	}
+	return (steps + tp.N - 1) / tp.N
+}
+
+// Pool pools the frames, the columns, of in [rows, steps] into out [rows, Frames(steps)]
+func (tp *TimePoolParams) Pool(in, out *etensor.Float64) {
+	rows, steps := in.Dim(0), in.Dim(1)
+	nf := tp.Frames(steps)
+	n := tp.N
+	if n < 1 {
+		n = 1
+	}
+	out.SetShape([]int{rows, nf}, nil, in.DimNames())
+	for r := 0; r < rows; r++ {
+		for f := 0; f < nf; f++ {
+			st := f * n
+			ed := st + n
+			if ed > steps {
+				ed = steps
+			}
+			v := in.Values[r*steps+st]
+			switch tp.Op {
+			case TimePoolAvg:
+				for s := st + 1; s < ed; s++ {
+					v += in.Values[r*steps+s]
+				}
+				v /= float64(ed - st)
+			case TimePoolMax:
+				for s := st + 1; s < ed; s++ {
+					v = math.Max(v, in.Values[r*steps+s])
+				}
+			}
+			out.Values[r*nf+f] = v
+		}
+	}
+}
+
+// PoolTime pools the frames of the mel output, and the mfcc output if Mel.MFCC, into MelPooled and MFCCPooled,
+// with "col-ms" metadata of the time of the first frame of each pool. Called by ProcessSegment if TimePool.On()
+func (se *SndEnv) PoolTime() {
+	se.TimePool.Pool(&se.MelFBankSegment, &se.MelPooled)
+	se.MelPooled.CopyMetaData(&se.MelFBankSegment)
+	if se.Mel.MFCC {
+		se.TimePool.Pool(&se.MFCCSegment, &se.MFCCPooled)
+		se.MFCCPooled.CopyMetaData(&se.MFCCSegment)
+	}
+	if ms, ok := MetaDataFloats(&se.MelFBankSegment, "col-ms"); ok {
+		pms := make([]float64, 0, len(ms))
+		for s := 0; s < len(ms); s += se.TimePool.N {
+			pms = append(pms, ms[s])
+		}
+		md := FloatsToMetaData(pms, 1)
+		se.MelPooled.SetMetaData("col-ms", md)
+		if se.Mel.MFCC {
+			se.MFCCPooled.SetMetaData("col-ms", md)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+)
+
+// VocoderParams are the parameters of the noise vocoder (Shannon et al, 1995), which replaces the fine structure
+// of each frequency band with noise modulated by the band's envelope, degrading intelligibility in a
+// controlled way -- fewer bands and lower envelope cutoffs are less intelligible
+type VocoderParams struct {
+
+	// [def: 8] [min: 1] [max: 32] number of frequency bands, from 1 to 32
+	NBands int `default:"8" min:"1" max:"32" desc:"number of frequency bands, from 1 to 32"`
+
+	// [def: 100] lower edge of the lowest band in Hz
+	LoHz float64 `default:"100" desc:"lower edge of the lowest band in Hz"`
+
+	// [def: 8000] upper edge of the highest band in Hz, limited to just below the nyquist frequency
+	HiHz float64 `default:"8000" desc:"upper edge of the highest band in Hz, limited to just below the nyquist frequency"`
+
+	// [def: 30] cutoff of the low-pass filter of the envelopes in Hz -- 16 to 50 Hz keeps only the slow amplitude modulations, 160 Hz or more also keeps periodicity cues
+	EnvCutHz float64 `default:"30" desc:"cutoff of the low-pass filter of the envelopes in Hz -- 16 to 50 Hz keeps only the slow amplitude modulations, 160 Hz or more also keeps periodicity cues"`
+
+	// [def: 2] number of cascaded biquad sections of the band filters
+	Sections int `default:"2" desc:"number of cascaded biquad sections of the band filters"`
+
+	// [def: 1] seed of the noise carriers when Vocode is not given a random source, so the same stimulus is produced each time
+	Seed int64 `default:"1" desc:"seed of the noise carriers when Vocode is not given a random source, so the same stimulus is produced each time"`
+}
+
+// Defaults
+func (vp *VocoderParams) Defaults() {
+	vp.NBands = 8
+	vp.LoHz = 100
+	vp.HiHz = 8000
+	vp.EnvCutHz = 30
+	vp.Sections = 2
+	vp.Seed = 1
+}
+
+// BandEdges returns the NBands + 1 band edges, logarithmically spaced from LoHz to HiHz (limited to .95 of nyquist)
+func (vp *VocoderParams) BandEdges(sampleRate int) []float64 {
+	hi := math.Min(vp.HiHz, 0.95*float64(sampleRate)/2)
+	edges := make([]float64, vp.NBands+1)
+	for i := range edges {
+		edges[i] = vp.LoHz * math.Pow(hi/vp.LoHz, float64(i)/float64(vp.NBands))
+	}
+	return edges
+}
+
+// Vocode returns the noise vocoded signal: for each band the envelope (full wave rectified and low-pass filtered
+// at EnvCutHz) of the band filtered signal modulates band filtered noise, which is filtered again and scaled
+// to the rms of the band of the signal. The bands are summed. The noise comes from rnd, or from a source seeded with Seed if rnd is nil
+func Vocode(signal []float64, sampleRate int, vp *VocoderParams, rnd *rand.Rand) ([]float64, error) {
+	if vp.NBands < 1 || vp.NBands > 32 {
+		err := fmt.Errorf("sound.Vocode: NBands %v must be between 1 and 32", vp.NBands)
+		log.Println(err)
+		return nil, err
+	}
+	edges := vp.BandEdges(sampleRate)
+	if edges[0] <= 0 || edges[len(edges)-1] <= edges[0] {
+		err := fmt.Errorf("sound.Vocode: LoHz %v must be > 0 and below HiHz and the nyquist frequency", vp.LoHz)
+		log.Println(err)
+		return nil, err
+	}
+	if rnd == nil {
+		rnd = NewRand(vp.Seed)
+	}
+	noise := make([]float64, len(signal))
+	for i := range noise {
+		noise[i] = 2*rnd.Float64() - 1
+	}
+	lp := NewLowPass(vp.EnvCutHz, 1/math.Sqrt2, sampleRate)
+	out := make([]float64, len(signal))
+	for b := 0; b < vp.NBands; b++ {
+		bf := BandFilter{LoHz: edges[b], HiHz: edges[b+1], Sections: vp.Sections}
+		band := bf.Filter(signal, sampleRate)
+		env := make([]float64, len(band))
+		for i, v := range band {
+			env[i] = math.Abs(v)
+		}
+		env = lp.Filter(lp.Filter(env))
+		carrier := bf.Filter(noise, sampleRate)
+		for i := range carrier {
+			carrier[i] *= math.Max(env[i], 0)
+		}
+		carrier = bf.Filter(carrier, sampleRate)
+		g := 0.0
+		if cr := RMS(carrier); cr > 0 {
+			g = RMS(band) / cr
+		}
+		for i, v := range carrier {
+			out[i] += g * v
+		}
+	}
+	return out, nil
+}
+
+// VocodeSignal replaces the Signal with its noise vocoded version, see Vocode, with noise derived from the master Seed
+func (se *SndEnv) VocodeSignal(vp *VocoderParams) error {
+	voc, err := Vocode(se.Signal.Values, se.Sound.SampleRate(), vp, se.NewRand("vocode"))
+	if err != nil {
+		return err
+	}
+	copy(se.Signal.Values, voc)
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/emer/etable/etensor"
+	"github.com/go-audio/audio"
+)
+
+// testWave32 returns a 32 bit stereo Wave of 44.1 kHz of a tone in the left channel and a ramp in the right,
+// including full scale samples of both signs
+func testWave32() (*Wave, [][]float64) {
+	const n = 1000
+	chans := [][]float64{Tone(440, 0.9, 0.3, float64(n)/44.1, 44100), make([]float64, n)}
+	for i := range chans[1] {
+		chans[1][i] = -1 + 2*float64(i)/float64(n-1)
+	}
+	buf := &audio.IntBuffer{Format: &audio.Format{NumChannels: 2, SampleRate: 44100}, SourceBitDepth: 32}
+	buf.Data = make([]int, 2*n)
+	for c, ch := range chans {
+		for i, v := range ch {
+			buf.Data[i*2+c] = floatToPCM(v, 32)
+		}
+	}
+	return &Wave{Buf: buf}, chans
+}
+
+func TestWaveFormatRoundTrip(t *testing.T) {
+	wv, chans := testWave32()
+	dir := t.TempDir()
+	for _, c := range []struct {
+		bits  int
+		float bool
+		tol   float64 // the quantization of the bit depth, or of float32
+	}{
+		{8, false, 1.0 / 127},
+		{16, false, 1.0 / 0x7FFF},
+		{24, false, 1.0 / 0x7FFFFF},
+		{32, false, 1e-9},
+		{0, true, 1e-7},
+	} {
+		name := fmt.Sprintf("pcm%v", c.bits)
+		if c.float {
+			name = "float32"
+		}
+		fn := filepath.Join(dir, name+".wav")
+		if err := wv.WriteWaveFormat(fn, c.bits, c.float); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		var rd Wave
+		if err := rd.Load(fn); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if rd.SampleRate() != 44100 || rd.Channels() != 2 {
+			t.Errorf("%v: read %v Hz, %v channels, want 44100 Hz, 2 channels", name, rd.SampleRate(), rd.Channels())
+		}
+		var got etensor.Float64
+		rd.SoundToTensorChannels(&got)
+		if got.Dim(0) != 2 || got.Dim(1) != len(chans[0]) {
+			t.Fatalf("%v: read shape %v, want [2 %v]", name, got.Shapes(), len(chans[0]))
+		}
+		mx := 0.0
+		for ch := range chans {
+			for i, v := range chans[ch] {
+				mx = math.Max(mx, math.Abs(got.Values[ch*len(chans[ch])+i]-v))
+			}
+		}
+		if mx > c.tol {
+			t.Errorf("%v: largest difference %v, more than the quantization %v", name, mx, c.tol)
+		}
+	}
+	if err := wv.WriteWaveFormat(filepath.Join(dir, "bad.wav"), 12, false); err == nil {
+		t.Error("no error for a bit depth of 12")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sound
+
+import (
+	"math"
+
+	"github.com/emer/etable/etensor"
+)
+
+// WhitenModes are the ways of whitening the power spectrum before the mel (or gammatone) filters, see WhitenParams
+type WhitenModes int32
+
+const (
+	// WhitenNone leaves the power spectrum as it is
+	WhitenNone WhitenModes = iota
+
+	// WhitenAvg divides the power of each frequency bin by its long-term average, the average power of the bin over all
+	// the windows of the signal (WhitenAvg of SndEnv), removing the fixed coloring of the channel, e.g., the microphone
+	WhitenAvg
+
+	// WhitenCepstral high-pass filters the cepstrum of each window, removing the NCut lowest cepstral coefficients
+	// (after the 0th, the level) which hold the smooth spectral envelope, leaving the fine structure, e.g., the harmonics
+	WhitenCepstral
+)
+
+// WhitenParams are the params of the whitening of the power spectrum of each window before the filter bank, for channel
+// robust features and front ends that model the adaptation of the auditory nerve. The power and log power outputs of
+// the dft (PowerSegment etc) are not whitened, only the power the filter bank is applied to, so the time domain
+// gammatone filters (Gammatone.TimeDomain), which filter the samples, are not whitened
+type WhitenParams struct {
+
+	// how the power spectrum is whitened, WhitenNone for not at all
+	Mode WhitenModes `desc:"how the power spectrum is whitened, WhitenNone for not at all"`
+
+	// [def: 1e-10] [viewif: Mode!=WhitenNone] added to the power before it is divided by the average or its log is taken, so silent bins stay finite
+	Floor float64 `viewif:"Mode!=WhitenNone" default:"1e-10" desc:"added to the power before it is divided by the average or its log is taken, so silent bins stay finite"`
+
+	// [def: 20] [viewif: Mode=WhitenCepstral] number of the lowest cepstral coefficients, after the 0th, removed by WhitenCepstral
+	NCut int `viewif:"Mode=WhitenCepstral" default:"20" desc:"number of the lowest cepstral coefficients, after the 0th, removed by WhitenCepstral"`
+
+	// the cosines of the dct, for reuse while the number of bins is the same
+	dct []float64
+}
+
+// Defaults
+func (wp *WhitenParams) Defaults() {
+	wp.Mode = WhitenNone
+	wp.Floor = 1e-10
+	wp.NCut = 20
+}
+
+// On returns true if the power spectrum is whitened
+func (wp *WhitenParams) On() bool {
+	return wp.Mode != WhitenNone
+}
+
+// Cepstral whitens the power spectrum in place by removing the NCut lowest coefficients, after the 0th, of the dct of
+// its log -- the 0th coefficient is kept, so the mean log power, the overall level, is unchanged
+func (wp *WhitenParams) Cepstral(power []float64) {
+	n := len(power)
+	if n == 0 || wp.NCut <= 0 {
+		return
+	}
+	if len(wp.dct) != n*n {
+		wp.dct = make([]float64, n*n)
+		for q := 0; q < n; q++ {
+			for k := 0; k < n; k++ {
+				wp.dct[q*n+k] = math.Cos(math.Pi / float64(n) * (float64(k) + 0.5) * float64(q))
+			}
+		}
+	}
+	logp := make([]float64, n)
+	for k, p := range power {
+		logp[k] = math.Log(p + wp.Floor)
+	}
+	cep := make([]float64, n)
+	for q := range cep {
+		s := 0.0
+		for k, l := range logp {
+			s += wp.dct[q*n+k] * l
+		}
+		cep[q] = s
+	}
+	for q := 1; q <= wp.NCut && q < n; q++ {
+		cep[q] = 0
+	}
+	for k := range power { // inverse of the dct-II, the dct-III scaled by 2 / n
+		s := cep[0] / 2
+		for q := 1; q < n; q++ {
+			s += cep[q] * wp.dct[q*n+k]
+		}
+		power[k] = math.Exp(s * 2 / float64(n))
+	}
+}
+
+// initWhitenAvg sets WhitenAvg to the average power of each frequency bin over the windows of each channel of the
+// Signal, one window every step, pre-emphasized and tapered as in ProcessStep -- called by InitSignal for WhitenAvg
+func (se *SndEnv) initWhitenAvg() {
+	nch := 1
+	if se.Signal.NumDims() > 1 {
+		nch = se.Signal.Dim(0)
+	}
+	win := se.Params.WinSamples
+	nb := win/2 + 1
+	se.WhitenAvg.SetShape([]int{nch, nb}, nil, []string{"Channel", "Freq"})
+	if win <= 0 || se.Params.StepSamples <= 0 {
+		return
+	}
+	var wt etensor.Float64
+	wt.SetShape([]int{win}, nil, nil)
+	coefs := make([]complex128, win)
+	for ch := 0; ch < nch; ch++ {
+		sig := se.ChannelSignal(ch)
+		avg := se.WhitenAvg.Values[ch*nb : (ch+1)*nb]
+		n := 0
+		for st := 0; st+win <= len(sig); st += se.Params.StepSamples {
+			if se.Params.PreEmph != 0 {
+				PreEmphasize(wt.Values, sig, st, se.Params.PreEmph)
+			} else {
+				copy(wt.Values, sig[st:st+win])
+			}
+			se.DFT.FftReal(coefs, &wt)
+			coefs = se.DFT.FFT(win).Coefficients(coefs, coefs)
+			for k := range avg {
+				avg[k] += real(coefs[k])*real(coefs[k]) + imag(coefs[k])*imag(coefs[k])
+			}
+			n++
+		}
+		for k := range avg {
+			if n == 0 {
+				avg[k] = 1
+			} else {
+				avg[k] /= float64(n)
+			}
+		}
+	}
+}
+
+// whitenStep sets PowerWhite to the power of the current step whitened, see WhitenParams -- Power itself is left as
+// it is, as the dft smooths it with the power of the previous step (DFT.PrevSmooth)
+func (se *SndEnv) whitenStep() {
+	se.PowerWhite.CopyShapeFrom(&se.Power)
+	copy(se.PowerWhite.Values, se.Power.Values)
+	switch se.Whiten.Mode {
+	case WhitenAvg:
+		nb := se.Power.Len()
+		if se.WhitenAvg.Len() < (se.Chan+1)*nb {
+			return
+		}
+		avg := se.WhitenAvg.Values[se.Chan*nb : (se.Chan+1)*nb]
+		for k, p := range se.PowerWhite.Values {
+			se.PowerWhite.Values[k] = (p + se.Whiten.Floor) / (avg[k] + se.Whiten.Floor)
+		}
+	case WhitenCepstral:
+		se.Whiten.Cepstral(se.PowerWhite.Values)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package speech
+
+import (
+	"fmt"
+	"log"
+)
+
+// AlignIssue reports a unit whose timing is inconsistent with the audio or with the previous unit, see Sequence.CheckAlignment
+type AlignIssue struct {
+
+	// the sound file of the sequence
+	File string `desc:"the sound file of the sequence"`
+
+	// index of the unit in the sequence
+	Idx int `desc:"index of the unit in the sequence"`
+
+	// name of the unit
+	Name string `desc:"name of the unit"`
+
+	// the kind of issue -- negative start, empty, past end, out of order, overlap, or no audio (sound file not readable, Idx -1)
+	Kind string `desc:"the kind of issue -- negative start, empty, past end, out of order, overlap, or no audio (sound file not readable, Idx -1)"`
+
+	// start time of the unit in milliseconds
+	Start float64 `desc:"start time of the unit in milliseconds"`
+
+	// end time of the unit in milliseconds
+	End float64 `desc:"end time of the unit in milliseconds"`
+
+	// the time the unit is checked against in milliseconds -- the audio duration for past end, the previous unit's start for out of order or its end for overlap
+	Ref float64 `desc:"the time the unit is checked against in milliseconds -- the audio duration for past end, the previous unit's start for out of order or its end for overlap"`
+}
+
+func (ai AlignIssue) String() string {
+	return fmt.Sprintf("unit %v %q %.1f..%.1f ms %v (%.1f ms)", ai.Idx, ai.Name, ai.Start, ai.End, ai.Kind, ai.Ref)
+}
+
+// CheckAlignment checks that the unit times are consistent with the audio of durMs milliseconds (not checked if durMs <= 0)
+// and with each other: each unit must start at or after 0, end after it starts and end by durMs,
+// and the units must be in order of start time and not overlap the previous unit by more than tolMs.
+// The units are not changed -- a warning is logged for each issue and the list of issues is returned as a report
+func (seq *Sequence) CheckAlignment(durMs, tolMs float64) []AlignIssue {
+	var report []AlignIssue
+	add := func(i int, u *Unit, kind string, ref float64) {
+		ai := AlignIssue{File: seq.File, Idx: i, Name: u.Name, Kind: kind, Start: u.Start, End: u.End, Ref: ref}
+		log.Printf("speech.CheckAlignment: %v: %v\n", seq.File, ai)
+		report = append(report, ai)
+	}
+	for i := range seq.Units {
+		u := &seq.Units[i]
+		if u.Start < 0 {
+			add(i, u, "negative start", 0)
+		}
+		if u.End <= u.Start {
+			add(i, u, "empty", u.Start)
+		}
+		if durMs > 0 && u.End > durMs+tolMs {
+			add(i, u, "past end", durMs)
+		}
+		if i == 0 {
+			continue
+		}
+		pu := &seq.Units[i-1]
+		switch {
+		case u.Start < pu.Start:
+			add(i, u, "out of order", pu.Start)
+		case u.Start < pu.End-tolMs:
+			add(i, u, "overlap", pu.End)
+		}
+	}
+	return report
+}
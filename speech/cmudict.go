@@ -0,0 +1,158 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package speech
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"strings"
+)
+
+// Stress levels of syllables, as marked on the vowels of the CMU pronouncing dictionary
+const (
+	StressNone      = 0
+	StressPrimary   = 1
+	StressSecondary = 2
+)
+
+// Dict is a pronouncing dictionary, mapping lower case words to their pronunciations, each a list of
+// lower case ARPAbet phones with the vowels carrying their stress digit (e.g. "hello" -> hh ah0 l ow1)
+type Dict map[string][][]string
+
+// OpenCMUDict opens a CMU pronouncing dictionary file (e.g. cmudict-0.7b or cmudict.dict), lines of a word
+// followed by its phones -- comment lines starting with ;;; or # are skipped and alternate pronunciations,
+// marked "WORD(2)", are added to the word
+func OpenCMUDict(filename string) (Dict, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer fp.Close()
+
+	dict := Dict{}
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		t := scanner.Text()
+		if t == "" || strings.HasPrefix(t, ";;;") || strings.HasPrefix(t, "#") {
+			continue
+		}
+		if i := strings.Index(t, "#"); i > 0 { // trailing comment of cmudict.dict
+			t = t[:i]
+		}
+		flds := strings.Fields(strings.ToLower(t))
+		if len(flds) < 2 {
+			continue
+		}
+		w := flds[0]
+		if i := strings.Index(w, "("); i > 0 {
+			w = w[:i]
+		}
+		dict[w] = append(dict[w], flds[1:])
+	}
+	if err := scanner.Err(); err != nil {
+		log.Println(err)
+		return dict, err
+	}
+	return dict, nil
+}
+
+// Stresses returns the stress of each vowel (syllable) of the pronunciation of the word, StressNone,
+// StressPrimary or StressSecondary, using the first pronunciation with nsyl syllables, or the first
+// pronunciation if nsyl is 0. ok is false if the word is not in the dictionary or has no such pronunciation
+func (dict Dict) Stresses(word string, nsyl int) (stress []int, ok bool) {
+	for _, pr := range dict[strings.ToLower(word)] {
+		stress = stress[:0]
+		for _, ph := range pr {
+			switch ph[len(ph)-1] {
+			case '0':
+				stress = append(stress, StressNone)
+			case '1':
+				stress = append(stress, StressPrimary)
+			case '2':
+				stress = append(stress, StressSecondary)
+			}
+		}
+		if nsyl == 0 || len(stress) == nsyl {
+			return stress, true
+		}
+	}
+	return nil, false
+}
+
+// Lookup returns the first pronunciation of the word in lower case ARPAbet with stress digits. ok is false if the word is not in the dictionary
+func (dict Dict) Lookup(word string) (phones []string, ok bool) {
+	prs := dict[strings.ToLower(word)]
+	if len(prs) == 0 {
+		return nil, false
+	}
+	return prs[0], true
+}
+
+// Transcribe returns the pronunciation of each of the words, see Lookup, as TIMIT phones if timit is true (see ArpabetToTIMIT)
+// or as ARPAbet phones with stress digits, and the words not in the dictionary, whose pronunciations are nil
+func (dict Dict) Transcribe(words []string, timit bool) (prons [][]string, missing []string) {
+	prons = make([][]string, len(words))
+	for i, w := range words {
+		pr, ok := dict.Lookup(w)
+		if !ok {
+			missing = append(missing, w)
+			continue
+		}
+		if timit {
+			pr = ArpabetToTIMIT(pr)
+		}
+		prons[i] = pr
+	}
+	return prons, missing
+}
+
+// StripStress returns the phone without its stress digit, e.g. "ah0" -> "ah"
+func StripStress(phone string) string {
+	return strings.TrimRight(phone, "012")
+}
+
+// ArpabetToTIMIT maps CMU dictionary phones (with or without stress digits, any case) to TIMIT phones.
+// The 39 CMU phones are all TIMIT phones, except that the unstressed ah and er are reduced to TIMIT ax and axr
+func ArpabetToTIMIT(phones []string) []string {
+	tp := make([]string, len(phones))
+	for i, ph := range phones {
+		ph = strings.ToLower(ph)
+		p := StripStress(ph)
+		switch {
+		case ph == "ah0":
+			p = "ax"
+		case ph == "er0":
+			p = "axr"
+		}
+		tp[i] = p
+	}
+	return tp
+}
+
+// TIMITToArpabet folds TIMIT phones onto the 39 phones of the CMU dictionary (without stress): the reduced
+// and fronted vowels onto their full vowels, the syllabic consonants onto ah plus the consonant, nx and hv onto n and hh,
+// the flap onto t, and the closures, glottal stop and silences (h#, pau, epi) are removed
+func TIMITToArpabet(phones []string) []string {
+	var ap []string
+	for _, p := range phones {
+		if m, ok := TIMITArpabet[p]; ok {
+			ap = append(ap, m...)
+			continue
+		}
+		ap = append(ap, p)
+	}
+	return ap
+}
+
+// TIMITArpabet maps the TIMIT phones that aren't CMU dictionary phones to their CMU phones, see TIMITToArpabet
+var TIMITArpabet = map[string][]string{
+	"ax": {"ah"}, "ax-h": {"ah"}, "ix": {"ih"}, "ux": {"uw"}, "axr": {"er"},
+	"el": {"ah", "l"}, "em": {"ah", "m"}, "en": {"ah", "n"}, "eng": {"ih", "ng"},
+	"nx": {"n"}, "hv": {"hh"}, "dx": {"t"}, "q": nil,
+	"pcl": nil, "tcl": nil, "kcl": nil, "bcl": nil, "dcl": nil, "gcl": nil,
+	"h#": nil, "pau": nil, "epi": nil,
+}
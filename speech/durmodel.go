@@ -0,0 +1,231 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package speech
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// PhoneDur is the duration distribution of one phone (or other unit name), see DurModel
+type PhoneDur struct {
+
+	// the phone (unit name)
+	Name string `desc:"the phone (unit name)"`
+
+	// number of units the statistics are from
+	N int `desc:"number of units the statistics are from"`
+
+	// mean duration in milliseconds
+	Mean float64 `desc:"mean duration in milliseconds"`
+
+	// standard deviation of the duration in milliseconds
+	SD float64 `desc:"standard deviation of the duration in milliseconds"`
+
+	// shortest duration in milliseconds
+	Min float64 `desc:"shortest duration in milliseconds"`
+
+	// longest duration in milliseconds
+	Max float64 `desc:"longest duration in milliseconds"`
+
+	// shape (k) of the gamma distribution fit to the durations, 0 if there were too few units or no variance
+	Shape float64 `desc:"shape (k) of the gamma distribution fit to the durations, 0 if there were too few units or no variance"`
+
+	// scale (theta) of the gamma distribution fit to the durations, Shape * Scale is the mean
+	Scale float64 `desc:"scale (theta) of the gamma distribution fit to the durations, Shape * Scale is the mean"`
+}
+
+// DurModel is a model of phone durations, with the duration statistics and a gamma distribution fit for each phone (unit name),
+// e.g., to time the units of synthesized sequences or to make duration targets for recognition
+type DurModel struct {
+
+	// the phone duration distributions by phone (unit name)
+	Phones map[string]*PhoneDur `desc:"the phone duration distributions by phone (unit name)"`
+
+	// [def: 5] shortest duration in milliseconds returned by Sample
+	MinMs float64 `default:"5" desc:"shortest duration in milliseconds returned by Sample"`
+}
+
+// FitDurations fits the duration model to the units of all the sequences. Units with no duration are ignored.
+// The gamma distributions are maximum likelihood fits, which need at least 2 units of different durations --
+// Sample returns the mean for other phones
+func FitDurations(seqs Sequences) *DurModel {
+	durs := map[string][]float64{}
+	for i := range seqs {
+		for _, u := range seqs[i].Units {
+			if d := u.End - u.Start; d > 0 {
+				durs[u.Name] = append(durs[u.Name], d)
+			}
+		}
+	}
+	dm := &DurModel{Phones: map[string]*PhoneDur{}, MinMs: 5}
+	for nm, ds := range durs {
+		dm.Phones[nm] = FitPhoneDur(nm, ds)
+	}
+	return dm
+}
+
+// FitPhoneDur returns the statistics and gamma fit of the durations (all > 0) of one phone
+func FitPhoneDur(name string, durs []float64) *PhoneDur {
+	pd := &PhoneDur{Name: name, N: len(durs)}
+	if len(durs) == 0 {
+		return pd
+	}
+	pd.Min, pd.Max = durs[0], durs[0]
+	sum, lsum := 0.0, 0.0
+	for _, d := range durs {
+		sum += d
+		lsum += math.Log(d)
+		pd.Min = math.Min(pd.Min, d)
+		pd.Max = math.Max(pd.Max, d)
+	}
+	n := float64(len(durs))
+	pd.Mean = sum / n
+	ss := 0.0
+	for _, d := range durs {
+		ss += (d - pd.Mean) * (d - pd.Mean)
+	}
+	pd.SD = math.Sqrt(ss / n)
+	// gamma maximum likelihood: solve log(k) - digamma(k) = s, with Minka's starting point and Newton steps
+	s := math.Log(pd.Mean) - lsum/n
+	if len(durs) < 2 || s <= 0 {
+		return pd
+	}
+	k := (3 - s + math.Sqrt((s-3)*(s-3)+24*s)) / (12 * s)
+	for i := 0; i < 5; i++ {
+		f := math.Log(k) - digamma(k) - s
+		df := 1/k - trigamma(k)
+		k -= f / df
+	}
+	pd.Shape = k
+	pd.Scale = pd.Mean / k
+	return pd
+}
+
+// Sample returns a random duration in milliseconds of the phone from its gamma distribution, using rnd,
+// or its mean if there is no gamma fit, at least MinMs. ok is false if the phone is not in the model
+func (dm *DurModel) Sample(name string, rnd *rand.Rand) (ms float64, ok bool) {
+	pd, ok := dm.Phones[name]
+	if !ok {
+		return 0, false
+	}
+	ms = pd.Mean
+	if pd.Shape > 0 {
+		ms = GammaRand(pd.Shape, pd.Scale, rnd)
+	}
+	return math.Max(ms, dm.MinMs), true
+}
+
+// GammaRand returns a random value from the gamma distribution with the shape and scale, using rnd (Marsaglia and Tsang, 2000)
+func GammaRand(shape, scale float64, rnd *rand.Rand) float64 {
+	if shape < 1 { // boost, see Marsaglia and Tsang
+		u := rnd.Float64()
+		return GammaRand(shape+1, scale, rnd) * math.Pow(u, 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rnd.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rnd.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v * scale
+		}
+	}
+}
+
+// digamma returns the digamma function of x > 0, by recurrence to x >= 6 and the asymptotic series
+func digamma(x float64) float64 {
+	r := 0.0
+	for ; x < 6; x++ {
+		r -= 1 / x
+	}
+	f := 1 / (x * x)
+	return r + math.Log(x) - 0.5/x - f*(1.0/12-f*(1.0/120-f*(1.0/252-f*(1.0/240-f/132))))
+}
+
+// trigamma returns the trigamma function of x > 0, by recurrence to x >= 6 and the asymptotic series
+func trigamma(x float64) float64 {
+	r := 0.0
+	for ; x < 6; x++ {
+		r += 1 / (x * x)
+	}
+	f := 1 / (x * x)
+	return r + 1/x + f/2 + f/x*(1.0/6-f*(1.0/30-f*(1.0/42-f/30)))
+}
+
+// Names returns the phones of the model in sorted order
+func (dm *DurModel) Names() []string {
+	names := make([]string, 0, len(dm.Phones))
+	for nm := range dm.Phones {
+		names = append(names, nm)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Table returns the model as a table with a row per phone, in sorted order, e.g., for viewing or saving as csv
+func (dm *DurModel) Table() *etable.Table {
+	names := dm.Names()
+	dt := etable.New(etable.Schema{
+		{"Phone", etensor.STRING, nil, nil},
+		{"N", etensor.INT64, nil, nil},
+		{"MeanMs", etensor.FLOAT64, nil, nil},
+		{"SDMs", etensor.FLOAT64, nil, nil},
+		{"MinMs", etensor.FLOAT64, nil, nil},
+		{"MaxMs", etensor.FLOAT64, nil, nil},
+		{"Shape", etensor.FLOAT64, nil, nil},
+		{"Scale", etensor.FLOAT64, nil, nil},
+	}, len(names))
+	dt.SetMetaData("name", "DurModel")
+	for r, nm := range names {
+		pd := dm.Phones[nm]
+		dt.SetCellString("Phone", r, nm)
+		dt.SetCellFloat("N", r, float64(pd.N))
+		dt.SetCellFloat("MeanMs", r, pd.Mean)
+		dt.SetCellFloat("SDMs", r, pd.SD)
+		dt.SetCellFloat("MinMs", r, pd.Min)
+		dt.SetCellFloat("MaxMs", r, pd.Max)
+		dt.SetCellFloat("Shape", r, pd.Shape)
+		dt.SetCellFloat("Scale", r, pd.Scale)
+	}
+	return dt
+}
+
+// OpenJSON opens a duration model from a JSON-formatted file
+func (dm *DurModel) OpenJSON(filename string) error {
+	*dm = DurModel{} // reset
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return json.Unmarshal(b, dm)
+}
+
+// SaveJSON saves the duration model to a JSON-formatted file
+func (dm *DurModel) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(dm, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package speech
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+)
+
+// Sequences is a collection of sequences, typically one per sound file of a corpus
+type Sequences []Sequence
+
+// OpenJSON opens a sequence, including its units, from a JSON-formatted file
+func (seq *Sequence) OpenJSON(filename string) error {
+	*seq = Sequence{} // reset
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return json.Unmarshal(b, seq)
+}
+
+// SaveJSON saves the sequence, including its units, to a JSON-formatted file
+func (seq *Sequence) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(seq, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// OpenJSON opens a set of sequences from a JSON-formatted file, e.g., alignment data for a whole corpus
+// that was cached by SaveJSON so the corpus specific transcription and timing files need not be parsed again
+func (seqs *Sequences) OpenJSON(filename string) error {
+	*seqs = Sequences{} // reset
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return json.Unmarshal(b, seqs)
+}
+
+// SaveJSON saves the set of sequences to a JSON-formatted file
+func (seqs *Sequences) SaveJSON(filename string) error {
+	b, err := json.MarshalIndent(seqs, "", "  ")
+	if err != nil {
+		log.Println(err) // unlikely
+		return err
+	}
+	err = os.WriteFile(filename, b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package speech
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testSequence(file string) Sequence {
+	return Sequence{File: file, ID: "dr1", Sequence: "h# b ah g h#", Text: "bug", Silence: 12.5, Start: 40, Stop: 380,
+		Offset: -3, CurTime: 100, NextTime: 200,
+		Units: []Unit{
+			{Name: "h#", Start: 0, End: 40, AStart: 2, AEnd: 42, Silence: true, Type: "phone"},
+			{Name: "b", Start: 40, End: 95.5, AStart: 42, AEnd: 97.5, Type: "phone"},
+			{Name: "ah", Start: 95.5, End: 210.25, AStart: 97.5, AEnd: 212.25, Type: "phone"},
+		}}
+}
+
+func TestSequenceJSON(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "seq.json")
+	seq := testSequence("sa1.wav")
+	if err := seq.SaveJSON(fn); err != nil {
+		t.Fatal(err)
+	}
+	got := Sequence{File: "stale", Units: []Unit{{Name: "x"}, {Name: "y"}, {Name: "z"}, {Name: "w"}}}
+	if err := got.OpenJSON(fn); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, seq) {
+		t.Errorf("OpenJSON got %+v, saved %+v", got, seq)
+	}
+}
+
+func TestSequencesJSON(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "seqs.json")
+	seqs := Sequences{testSequence("sa1.wav"), testSequence("sa2.wav"), {File: "empty.wav"}}
+	if err := seqs.SaveJSON(fn); err != nil {
+		t.Fatal(err)
+	}
+	got := Sequences{testSequence("stale.wav")}
+	if err := got.OpenJSON(fn); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, seqs) {
+		t.Errorf("OpenJSON got %+v, saved %+v", got, seqs)
+	}
+	if err := got.OpenJSON(filepath.Join(t.TempDir(), "none.json")); err == nil {
+		t.Errorf("OpenJSON of a missing file: no error")
+	}
+}
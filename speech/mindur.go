@@ -0,0 +1,117 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package speech
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+// ShortPolicies are the ways of handling units shorter than a minimum duration, see Sequence.CheckDurations
+type ShortPolicies int32
+
+const (
+	ShortPad   ShortPolicies = iota // extend the unit to the minimum duration, as far as its neighbors and the sound allow
+	ShortSkip                       // remove the unit
+	ShortMerge                      // merge the unit into the shorter neighbor, which keeps its name
+)
+
+// ShortUnit reports a unit shorter than the minimum duration and what was done with it
+type ShortUnit struct {
+
+	// index of the unit in the sequence before any were removed
+	Idx int `desc:"index of the unit in the sequence before any were removed"`
+
+	// name of the unit
+	Name string `desc:"name of the unit"`
+
+	// duration of the unit in milliseconds
+	DurMs float64 `desc:"duration of the unit in milliseconds"`
+
+	// what was done -- padded, skipped or merged
+	Action string `desc:"what was done -- padded, skipped or merged"`
+
+	// name of the neighbor the unit was merged into, if merged
+	MergedInto string `desc:"name of the neighbor the unit was merged into, if merged"`
+}
+
+func (su ShortUnit) String() string {
+	s := fmt.Sprintf("unit %v %q of %.1f ms %v", su.Idx, su.Name, su.DurMs, su.Action)
+	if su.MergedInto != "" {
+		s += fmt.Sprintf(" into %q", su.MergedInto)
+	}
+	return s
+}
+
+// CheckDurations finds the units shorter than minMs, e.g., shorter than the processing window,
+// which would otherwise produce mostly padding, and handles them according to policy.
+// A warning is logged for each short unit and the list of short units is returned as a report.
+// Padding extends the unit's start / end times, and adjusted times, equally on both sides out to minMs,
+// but not over its neighbors, before 0 or after the end of the audio of durMs milliseconds (not limited
+// if durMs <= 0) -- the rest goes on the other side, if there is room.
+// Merging extends the neighbor's start / end times, and adjusted times, to cover the short unit --
+// a unit with no neighbors is padded
+func (seq *Sequence) CheckDurations(minMs, durMs float64, policy ShortPolicies) []ShortUnit {
+	var report []ShortUnit
+	units := make([]Unit, 0, len(seq.Units))
+	for i, u := range seq.Units {
+		dur := u.End - u.Start
+		if dur >= minMs {
+			units = append(units, u)
+			continue
+		}
+		su := ShortUnit{Idx: i, Name: u.Name, DurMs: dur}
+		switch {
+		case policy == ShortSkip:
+			su.Action = "skipped"
+		case policy == ShortMerge && (len(units) > 0 || i < len(seq.Units)-1):
+			su.Action = "merged"
+			prv := len(units) > 0
+			if prv && i < len(seq.Units)-1 { // merge into the shorter neighbor
+				pu := units[len(units)-1]
+				nu := seq.Units[i+1]
+				prv = pu.End-pu.Start <= nu.End-nu.Start
+			}
+			if prv {
+				pu := &units[len(units)-1]
+				pu.End = u.End
+				pu.AEnd = u.AEnd
+				su.MergedInto = pu.Name
+			} else {
+				nu := &seq.Units[i+1] // not yet copied to units
+				nu.Start = u.Start
+				nu.AStart = u.AStart
+				su.MergedInto = nu.Name
+			}
+		default:
+			su.Action = "padded"
+			lo, hi := 0.0, math.Inf(1)
+			if len(units) > 0 {
+				lo = units[len(units)-1].End
+			}
+			if durMs > 0 {
+				hi = durMs
+			}
+			if i < len(seq.Units)-1 {
+				hi = math.Min(hi, seq.Units[i+1].Start)
+			}
+			bef := math.Max(u.Start-lo, 0) // room before and after
+			aft := math.Max(hi-u.End, 0)
+			pad := minMs - dur
+			st := math.Min(pad-math.Min(pad/2, aft), bef)
+			ed := math.Min(pad-st, aft)
+			u.Start -= st
+			u.AStart -= st
+			u.End += ed
+			u.AEnd += ed
+			units = append(units, u)
+		}
+		log.Printf("speech.CheckDurations: %v: %v, shorter than %v ms\n", seq.File, su, minMs)
+		report = append(report, su)
+	}
+	seq.Units = units
+	return report
+}
@@ -0,0 +1,304 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package speech
+
+import (
+	"math"
+	"sort"
+
+	"github.com/emer/etable/etensor"
+)
+
+// Manner is the manner of articulation, with vowels and silence as classes of their own
+type Manner int32
+
+const (
+	MannerSilence   Manner = iota // pauses and start / end silence
+	MannerClosure                 // stop closures (e.g. TIMIT "bcl")
+	MannerStop                    // including flaps and the glottal stop
+	MannerAffricate               // ch, jh
+	MannerFricative               // including h
+	MannerNasal                   // including the nasal flap
+	MannerLiquid                  // l, r
+	MannerGlide                   // w, y
+	MannerVowel                   // monophthongs, diphthongs and r-colored vowels
+)
+
+// Place is the place of articulation of consonants, ordered front to back so that
+// the difference of two places is a rough measure of their distance
+type Place int32
+
+const (
+	PlaceBilabial Place = iota
+	PlaceLabiodental
+	PlaceDental
+	PlaceAlveolar
+	PlacePostalveolar
+	PlacePalatal
+	PlaceVelar
+	PlaceGlottal
+	PlaceN // number of places
+)
+
+// PhoneFeatures are the articulatory features of a phone used to compute phone distances.
+// Place, Voiced and Syllabic apply to consonants, Height, Back, Round, Diphthong and Rhotic to vowels
+type PhoneFeatures struct {
+	Manner    Manner
+	Place     Place
+	Voiced    bool
+	Syllabic  bool
+	Height    float64 // 0 low to 1 high
+	Back      float64 // 0 front to 1 back
+	Round     bool
+	Diphthong bool
+	Rhotic    bool
+}
+
+// cons returns the features of a consonant
+func cons(m Manner, p Place, voiced bool) PhoneFeatures {
+	return PhoneFeatures{Manner: m, Place: p, Voiced: voiced}
+}
+
+// vowel returns the features of a vowel
+func vowel(height, back float64, round, diph bool) PhoneFeatures {
+	return PhoneFeatures{Manner: MannerVowel, Voiced: true, Height: height, Back: back, Round: round, Diphthong: diph}
+}
+
+// ArpabetFeatures are the articulatory features of the ARPAbet phones used by TIMIT (the 61 phones of timit.PhoneCats61).
+// Diphthongs use the features of their first target. Add entries for other phone sets as needed
+var ArpabetFeatures = map[string]PhoneFeatures{
+	"iy":   vowel(1, 0, false, false),
+	"ih":   vowel(.8, .1, false, false),
+	"eh":   vowel(.5, 0, false, false),
+	"ae":   vowel(.2, 0, false, false),
+	"ix":   vowel(.8, .5, false, false),
+	"ah":   vowel(.4, .6, false, false),
+	"ax":   vowel(.5, .5, false, false),
+	"ax-h": vowel(.5, .5, false, false),
+	"uw":   vowel(1, 1, true, false),
+	"ux":   vowel(1, .5, true, false),
+	"uh":   vowel(.8, .9, true, false),
+	"ao":   vowel(.3, 1, true, false),
+	"aa":   vowel(0, 1, false, false),
+	"ey":   vowel(.6, 0, false, true),
+	"ay":   vowel(.1, .5, false, true),
+	"oy":   vowel(.3, 1, true, true),
+	"aw":   vowel(.1, .5, false, true),
+	"ow":   vowel(.5, 1, true, true),
+	"er":   {Manner: MannerVowel, Voiced: true, Height: .5, Back: .5, Rhotic: true},
+	"axr":  {Manner: MannerVowel, Voiced: true, Height: .5, Back: .5, Rhotic: true},
+	"l":    cons(MannerLiquid, PlaceAlveolar, true),
+	"el":   {Manner: MannerLiquid, Place: PlaceAlveolar, Voiced: true, Syllabic: true},
+	"r":    cons(MannerLiquid, PlacePostalveolar, true),
+	"y":    cons(MannerGlide, PlacePalatal, true),
+	"w":    cons(MannerGlide, PlaceVelar, true),
+	"m":    cons(MannerNasal, PlaceBilabial, true),
+	"em":   {Manner: MannerNasal, Place: PlaceBilabial, Voiced: true, Syllabic: true},
+	"n":    cons(MannerNasal, PlaceAlveolar, true),
+	"nx":   cons(MannerNasal, PlaceAlveolar, true),
+	"en":   {Manner: MannerNasal, Place: PlaceAlveolar, Voiced: true, Syllabic: true},
+	"ng":   cons(MannerNasal, PlaceVelar, true),
+	"eng":  {Manner: MannerNasal, Place: PlaceVelar, Voiced: true, Syllabic: true},
+	"ch":   cons(MannerAffricate, PlacePostalveolar, false),
+	"jh":   cons(MannerAffricate, PlacePostalveolar, true),
+	"dh":   cons(MannerFricative, PlaceDental, true),
+	"b":    cons(MannerStop, PlaceBilabial, true),
+	"d":    cons(MannerStop, PlaceAlveolar, true),
+	"dx":   cons(MannerStop, PlaceAlveolar, true),
+	"g":    cons(MannerStop, PlaceVelar, true),
+	"p":    cons(MannerStop, PlaceBilabial, false),
+	"t":    cons(MannerStop, PlaceAlveolar, false),
+	"k":    cons(MannerStop, PlaceVelar, false),
+	"z":    cons(MannerFricative, PlaceAlveolar, true),
+	"zh":   cons(MannerFricative, PlacePostalveolar, true),
+	"v":    cons(MannerFricative, PlaceLabiodental, true),
+	"f":    cons(MannerFricative, PlaceLabiodental, false),
+	"th":   cons(MannerFricative, PlaceDental, false),
+	"s":    cons(MannerFricative, PlaceAlveolar, false),
+	"sh":   cons(MannerFricative, PlacePostalveolar, false),
+	"hh":   cons(MannerFricative, PlaceGlottal, false),
+	"hv":   cons(MannerFricative, PlaceGlottal, true),
+	"pcl":  cons(MannerClosure, PlaceBilabial, false),
+	"tcl":  cons(MannerClosure, PlaceAlveolar, false),
+	"kcl":  cons(MannerClosure, PlaceVelar, false),
+	"bcl":  cons(MannerClosure, PlaceBilabial, true),
+	"dcl":  cons(MannerClosure, PlaceAlveolar, true),
+	"gcl":  cons(MannerClosure, PlaceVelar, true),
+	"q":    cons(MannerStop, PlaceGlottal, false),
+	"epi":  {Manner: MannerSilence},
+	"h#":   {Manner: MannerSilence},
+	"pau":  {Manner: MannerSilence},
+}
+
+// PhoneDistance returns the articulatory distance, from 0 (same features) to 1, between two phones
+// using ArpabetFeatures. Vowels and consonants are 1 apart, vowels are at most .8 apart (height and backness
+// plus rounding, diphthong and r-coloring), consonants differ by manner (.4), place (up to .3), voicing (.2)
+// and syllabicity (.1). Silence is .2 from closures and 1 from everything else.
+// ok is false, and the distance 1, if either phone is not in ArpabetFeatures
+func PhoneDistance(a, b string) (dist float64, ok bool) {
+	fa, oka := ArpabetFeatures[a]
+	fb, okb := ArpabetFeatures[b]
+	if !oka || !okb {
+		return 1, false
+	}
+	if a == b {
+		return 0, true
+	}
+	return FeatureDistance(fa, fb), true
+}
+
+// FeatureDistance returns the distance between two sets of phone features -- see PhoneDistance
+func FeatureDistance(fa, fb PhoneFeatures) float64 {
+	va := fa.Manner == MannerVowel
+	vb := fb.Manner == MannerVowel
+	if va != vb {
+		return 1
+	}
+	if va {
+		d := 0.5 * (math.Abs(fa.Height-fb.Height) + math.Abs(fa.Back-fb.Back)) / 2
+		d += 0.1 * b2f(fa.Round != fb.Round)
+		d += 0.1 * b2f(fa.Diphthong != fb.Diphthong)
+		d += 0.1 * b2f(fa.Rhotic != fb.Rhotic)
+		return d
+	}
+	sa := fa.Manner == MannerSilence
+	sb := fb.Manner == MannerSilence
+	if sa || sb {
+		switch {
+		case sa && sb:
+			return 0
+		case fa.Manner == MannerClosure || fb.Manner == MannerClosure:
+			return 0.2
+		}
+		return 1
+	}
+	d := 0.4 * b2f(fa.Manner != fb.Manner)
+	d += 0.3 * math.Abs(float64(fa.Place-fb.Place)) / float64(PlaceN-1)
+	d += 0.2 * b2f(fa.Voiced != fb.Voiced)
+	d += 0.1 * b2f(fa.Syllabic != fb.Syllabic)
+	return d
+}
+
+func b2f(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// PhoneDistanceMatrix returns the matrix of PhoneDistance between each pair of phones,
+// e.g., for timit.PhoneCats61, in the order given
+func PhoneDistanceMatrix(phones []string) *etensor.Float64 {
+	n := len(phones)
+	dm := etensor.NewFloat64([]int{n, n}, nil, []string{"phone", "phone"})
+	for i, a := range phones {
+		for j, b := range phones {
+			d, _ := PhoneDistance(a, b)
+			dm.Set([]int{i, j}, d)
+		}
+	}
+	return dm
+}
+
+// GroupPhones groups the phones whose distance is <= maxDist, with single linkage, i.e., a phone joins
+// a group if it is within maxDist of any phone of the group. Groups keep the order of phones,
+// ordered by their first phone, e.g., GroupPhones(timit.PhoneCats61, 0) groups the phones with the same features
+func GroupPhones(phones []string, maxDist float64) [][]string {
+	n := len(phones)
+	grp := make([]int, n) // union find
+	for i := range grp {
+		grp[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if grp[i] != i {
+			grp[i] = find(grp[i])
+		}
+		return grp[i]
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if d, _ := PhoneDistance(phones[i], phones[j]); d <= maxDist {
+				ri, rj := find(i), find(j)
+				if ri < rj {
+					grp[rj] = ri
+				} else {
+					grp[ri] = rj
+				}
+			}
+		}
+	}
+	idx := map[int]int{}
+	var groups [][]string
+	for i, p := range phones {
+		r := find(i)
+		g, ok := idx[r]
+		if !ok {
+			g = len(groups)
+			idx[r] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], p)
+	}
+	return groups
+}
+
+// PhoneGroupMap returns a map from each phone to the index of its group, in the same form as
+// the timit phone maps (e.g. timit.Phones41), for scoring with confusable phones folded together
+func PhoneGroupMap(groups [][]string) map[string]int {
+	m := map[string]int{}
+	for g, grp := range groups {
+		for _, p := range grp {
+			m[p] = g
+		}
+	}
+	return m
+}
+
+// MannerGroups groups the phones by their manner of articulation, phones not in ArpabetFeatures are left out
+func MannerGroups(phones []string) map[Manner][]string {
+	m := map[Manner][]string{}
+	for _, p := range phones {
+		f, ok := ArpabetFeatures[p]
+		if !ok {
+			continue
+		}
+		m[f.Manner] = append(m[f.Manner], p)
+	}
+	return m
+}
+
+// WeightedConfusionError returns the mean phone distance of a confusion matrix of counts
+// (rows are the target phones, columns the output phones, both in the order of phones),
+// so that errors between similar phones count less than errors between dissimilar ones.
+// It is 0 if all outputs are correct and 1 if all are maximally distant
+func WeightedConfusionError(conf etensor.Tensor, phones []string) float64 {
+	dm := PhoneDistanceMatrix(phones)
+	sum := 0.0
+	tot := 0.0
+	for i := range phones {
+		for j := range phones {
+			c := conf.FloatVal([]int{i, j})
+			sum += c * dm.Value([]int{i, j})
+			tot += c
+		}
+	}
+	if tot == 0 {
+		return 0
+	}
+	return sum / tot
+}
+
+// SortedByDistance returns the phones ordered by increasing distance from phone, e.g., to list
+// the most likely confusions of a phone first
+func SortedByDistance(phone string, phones []string) []string {
+	srt := append([]string{}, phones...)
+	sort.SliceStable(srt, func(i, j int) bool {
+		di, _ := PhoneDistance(phone, srt[i])
+		dj, _ := PhoneDistance(phone, srt[j])
+		return di < dj
+	})
+	return srt
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package speech
+
+import (
+	"log"
+
+	"github.com/emer/etable/etensor"
+)
+
+// The columns of the prosody targets, see ProsodyTargets
+const (
+	ProsPrimary    = iota // frame is in a syllable with primary stress
+	ProsSecondary         // frame is in a syllable with secondary stress
+	ProsUnstressed        // frame is in an unstressed syllable
+	ProsWordStart         // frame contains the start of a word
+	ProsInWord            // frame is within a word
+	ProsN                 // number of prosody target columns
+)
+
+// WordStresses returns the syllables of the phones of each word, with the stress of each syllable from dict.
+// The phones within a word's times are syllabified (see Syllabify) and the syllables are matched in order to
+// the stressed vowels of the word's pronunciation with the same number of syllables -- the Type of the syllable
+// units is "primary", "secondary" or "unstressed", or "syllable" if the word or a pronunciation with that
+// number of syllables isn't in dict (logged)
+func WordStresses(phones, words []Unit, dict Dict) []Unit {
+	var sylls []Unit
+	for _, w := range words {
+		var wph []Unit
+		for _, u := range phones {
+			mid := (u.Start + u.End) / 2
+			if mid >= w.Start && mid < w.End && !IsSilence(u.Name) {
+				wph = append(wph, u)
+			}
+		}
+		ws := Syllabify(wph)
+		stress, ok := dict.Stresses(w.Name, len(ws))
+		if !ok {
+			log.Printf("speech.WordStresses: no pronunciation of %q with %v syllables\n", w.Name, len(ws))
+		}
+		for i := range ws {
+			if !ok {
+				continue
+			}
+			switch stress[i] {
+			case StressPrimary:
+				ws[i].Type = "primary"
+			case StressSecondary:
+				ws[i].Type = "secondary"
+			default:
+				ws[i].Type = "unstressed"
+			}
+		}
+		sylls = append(sylls, ws...)
+	}
+	return sylls
+}
+
+// ProsodyTargets sets tsr to the stress and word boundary targets of nFrames frames of stepMs, the first
+// starting at startMs, shape [nFrames, ProsN]: a one-hot stress of the syllable at the frame center
+// (all 0 outside words and for syllables of unknown stress), a word start indicator that is 1 on the frame
+// containing the start of a word, and an in-word indicator. Times are the unit Start and End times,
+// e.g., phones from timit.LoadTimes and words from timit.LoadWords -- see WordStresses for the stress lookup
+func ProsodyTargets(phones, words []Unit, dict Dict, startMs, stepMs float64, nFrames int, tsr *etensor.Float32) {
+	tsr.SetShape([]int{nFrames, ProsN}, nil, []string{"Frame", "Target"})
+	for i := range tsr.Values {
+		tsr.Values[i] = 0
+	}
+	sylls := WordStresses(phones, words, dict)
+	for f := 0; f < nFrames; f++ {
+		fst := startMs + float64(f)*stepMs
+		mid := fst + stepMs/2
+		for _, w := range words {
+			if w.Start >= fst && w.Start < fst+stepMs {
+				tsr.Set([]int{f, ProsWordStart}, 1)
+			}
+			if mid >= w.Start && mid < w.End {
+				tsr.Set([]int{f, ProsInWord}, 1)
+			}
+		}
+		for _, s := range sylls {
+			if mid < s.Start || mid >= s.End {
+				continue
+			}
+			switch s.Type {
+			case "primary":
+				tsr.Set([]int{f, ProsPrimary}, 1)
+			case "secondary":
+				tsr.Set([]int{f, ProsSecondary}, 1)
+			case "unstressed":
+				tsr.Set([]int{f, ProsUnstressed}, 1)
+			}
+			break
+		}
+	}
+}
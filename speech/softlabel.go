@@ -0,0 +1,121 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package speech
+
+import (
+	"math"
+
+	"github.com/emer/etable/etensor"
+)
+
+// SoftShapes are the shapes of the transition between the labels of adjacent units, see SoftLabels
+type SoftShapes int32
+
+const (
+	SoftHard     SoftShapes = iota // the label switches at the boundary
+	SoftTriangle                   // the weights ramp linearly across the boundary region
+	SoftGauss                      // the weights follow the cumulative gaussian, sigma WidthMs / 4, across the boundary region
+)
+
+// SoftLabels are the params of soft labels near unit boundaries: within WidthMs / 2 of the boundary between two adjacent
+// units a frame's label is a mixture of the two units, 0.5 each at the boundary and shifting to the unit the frame is in
+// with its distance from the boundary, as the frames there are ambiguous. The width is limited to the durations of the
+// two units, so the mixture never reaches past the middle of a unit
+type SoftLabels struct {
+
+	// the shape of the transition, SoftHard for hard labels
+	Shape SoftShapes `desc:"the shape of the transition, SoftHard for hard labels"`
+
+	// [def: 20] [viewif: Shape!=SoftHard] width of the boundary region in milliseconds, centered on the boundary
+	WidthMs float64 `viewif:"Shape!=SoftHard" default:"20" desc:"width of the boundary region in milliseconds, centered on the boundary"`
+
+	// [def: 1] units separated by a gap of no more than this many milliseconds are adjacent
+	GapMs float64 `default:"1" desc:"units separated by a gap of no more than this many milliseconds are adjacent"`
+}
+
+// Defaults sets the default params, triangle soft labels 20 ms wide
+func (sl *SoftLabels) Defaults() {
+	sl.Shape = SoftTriangle
+	sl.WidthMs = 20
+	sl.GapMs = 1
+}
+
+// neighborWeight returns the weight of the neighboring unit for a frame d ms from the boundary within its unit, for a boundary region of width w
+func (sl *SoftLabels) neighborWeight(d, w float64) float64 {
+	if w <= 0 || d >= w/2 {
+		return 0
+	}
+	switch sl.Shape {
+	case SoftTriangle:
+		return 0.5 - d/w
+	case SoftGauss:
+		sigma := w / 4
+		return 0.5 * math.Erfc(d/(sigma*math.Sqrt2))
+	}
+	return 0
+}
+
+// Weights returns the indexes of the units labeling time ms and their weights, which sum to 1 -- the unit at ms and,
+// within the boundary region of an adjacent unit, that unit. None if no unit includes ms
+func (sl *SoftLabels) Weights(units []Unit, ms float64) (idxs []int, wts []float64) {
+	u := -1
+	for i := range units {
+		if ms >= units[i].Start && ms < units[i].End {
+			u = i
+			break
+		}
+	}
+	if u < 0 {
+		return nil, nil
+	}
+	if sl.Shape == SoftHard || sl.WidthMs <= 0 {
+		return []int{u}, []float64{1}
+	}
+	cur := &units[u]
+	dur := cur.End - cur.Start
+	nb, d := -1, 0.0
+	if u > 0 && cur.Start-units[u-1].End <= sl.GapMs {
+		nb, d = u-1, ms-cur.Start
+	}
+	if u < len(units)-1 && units[u+1].Start-cur.End <= sl.GapMs {
+		if de := cur.End - ms; nb < 0 || de < d {
+			nb, d = u+1, de
+		}
+	}
+	if nb < 0 {
+		return []int{u}, []float64{1}
+	}
+	w := math.Min(sl.WidthMs, math.Min(dur, units[nb].End-units[nb].Start))
+	nw := sl.neighborWeight(d, w)
+	if nw <= 0 {
+		return []int{u}, []float64{1}
+	}
+	return []int{u, nb}, []float64{1 - nw, nw}
+}
+
+// FrameTargets sets tsr to the label targets of nFrames frames of stepMs, the first starting at startMs, shape
+// [nFrames, nLabels]: the weights of the labels of the units at the center of each frame (see SoftLabels.Weights,
+// hard labels if sl is nil), all 0 for frames outside the units. labelIdx maps a unit name to its label index,
+// e.g., timit.IdxFmSnd, and the weights of units without a label are left out
+func FrameTargets(units []Unit, labelIdx func(name string) (int, bool), nLabels int, startMs, stepMs float64, nFrames int, sl *SoftLabels, tsr *etensor.Float32) {
+	tsr.SetShape([]int{nFrames, nLabels}, nil, []string{"Frame", "Label"})
+	for i := range tsr.Values {
+		tsr.Values[i] = 0
+	}
+	if sl == nil {
+		sl = &SoftLabels{}
+	}
+	for f := 0; f < nFrames; f++ {
+		mid := startMs + float64(f)*stepMs + stepMs/2
+		idxs, wts := sl.Weights(units, mid)
+		for i, u := range idxs {
+			li, ok := labelIdx(units[u].Name)
+			if !ok || li < 0 || li >= nLabels {
+				continue
+			}
+			tsr.Values[f*nLabels+li] += float32(wts[i])
+		}
+	}
+}
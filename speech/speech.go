@@ -5,6 +5,8 @@
 package speech
 
 import (
+	"path/filepath"
+
 	"github.com/goki/ki/ki"
 	"github.com/goki/ki/kit"
 )
@@ -84,3 +86,22 @@ type Sequence struct {
 func (seq *Sequence) Init() {
 	seq.Units = []Unit{}
 }
+
+// UnitAt returns the index of the unit whose start / end times (in milliseconds) include time ms
+func (seq *Sequence) UnitAt(ms float64) (idx int, ok bool) {
+	for i, u := range seq.Units {
+		if ms >= u.Start && ms < u.End {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// Speaker returns the speaker of the sequence -- the ID if set, otherwise the name of the directory
+// containing the sound file, which is the speaker in the TIMIT layout (e.g. TRAIN/DR1/FCJF0/SA1.WAV)
+func (seq *Sequence) Speaker() string {
+	if seq.ID != "" {
+		return seq.ID
+	}
+	return filepath.Base(filepath.Dir(seq.File))
+}
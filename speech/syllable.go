@@ -0,0 +1,134 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package speech
+
+import (
+	"strings"
+)
+
+// LegalOnsets are the consonant clusters of English allowed at the start of a syllable, as space separated
+// ARPAbet phones, used by Syllabify -- single consonants other than ng are always allowed.
+// Stop closures (e.g. TIMIT "tcl") are not listed, they go with their stop
+var LegalOnsets = map[string]bool{
+	"p l": true, "p r": true, "p y": true, "b l": true, "b r": true, "b y": true,
+	"t r": true, "t w": true, "d r": true, "d w": true,
+	"k l": true, "k r": true, "k w": true, "k y": true, "g l": true, "g r": true, "g w": true,
+	"f l": true, "f r": true, "f y": true, "v y": true, "th r": true, "th w": true, "sh r": true,
+	"hh y": true, "m y": true, "n y": true,
+	"s p": true, "s t": true, "s k": true, "s m": true, "s n": true, "s l": true, "s w": true, "s f": true,
+	"s p l": true, "s p r": true, "s p y": true, "s t r": true, "s t y": true,
+	"s k l": true, "s k r": true, "s k w": true, "s k y": true,
+}
+
+// closureStop maps the stop closures to their stops
+var closureStop = map[string]string{"pcl": "p", "tcl": "t", "kcl": "k", "bcl": "b", "dcl": "d", "gcl": "g"}
+
+// IsNucleus returns true for the phones that form the nucleus of a syllable -- vowels and syllabic consonants (e.g. el, en)
+func IsNucleus(phone string) bool {
+	pf, ok := ArpabetFeatures[phone]
+	return ok && (pf.Manner == MannerVowel || pf.Syllabic)
+}
+
+// IsSilence returns true for the pause and silence phones (e.g. h#, pau, epi), which separate syllables
+func IsSilence(phone string) bool {
+	pf, ok := ArpabetFeatures[phone]
+	return ok && pf.Manner == MannerSilence
+}
+
+// legalOnset returns true if the consonants can start a syllable, see LegalOnsets
+func legalOnset(cons []Unit) bool {
+	var ph []string
+	for _, u := range cons {
+		if _, cl := closureStop[u.Name]; !cl {
+			ph = append(ph, u.Name)
+		}
+	}
+	switch len(ph) {
+	case 0:
+		return true
+	case 1:
+		return ph[0] != "ng" && ph[0] != "nx"
+	}
+	return LegalOnsets[strings.Join(ph, " ")]
+}
+
+// Syllabify groups the phone units into syllable units by the maximal onset principle: each syllable has
+// a nucleus (see IsNucleus) and the consonants between two nuclei go to the onset of the second syllable,
+// as many as make a legal onset (see LegalOnsets), with the rest the coda of the first.
+// Stop closures stay with their stop. Silence units (see IsSilence) end syllables and are kept as units of their own,
+// with Silence set. Consonants with no nucleus between silences form a syllable of their own.
+// Syllable names are the phone names separated by spaces, the times span the phones and the Type is "syllable"
+func Syllabify(phones []Unit) []Unit {
+	var sylls []Unit
+	st := 0
+	for i := 0; i <= len(phones); i++ {
+		if i < len(phones) && !IsSilence(phones[i].Name) {
+			continue
+		}
+		sylls = append(sylls, syllabifyStretch(phones[st:i])...)
+		if i < len(phones) {
+			u := phones[i]
+			u.Silence = true
+			sylls = append(sylls, u)
+		}
+		st = i + 1
+	}
+	return sylls
+}
+
+// syllabifyStretch syllabifies phones with no silence
+func syllabifyStretch(phones []Unit) []Unit {
+	if len(phones) == 0 {
+		return nil
+	}
+	var nuclei []int
+	for i, u := range phones {
+		if IsNucleus(u.Name) {
+			nuclei = append(nuclei, i)
+		}
+	}
+	if len(nuclei) == 0 {
+		return []Unit{syllable(phones)}
+	}
+	var sylls []Unit
+	st := 0
+	for n := 0; n < len(nuclei)-1; n++ {
+		cons := phones[nuclei[n]+1 : nuclei[n+1]]
+		j := 0
+		for j < len(cons) && !legalOnset(cons[j:]) {
+			j++
+		}
+		if j > 0 && j < len(cons) && closureStop[cons[j-1].Name] == cons[j].Name {
+			j-- // closure goes with its stop
+		}
+		ed := nuclei[n] + 1 + j
+		sylls = append(sylls, syllable(phones[st:ed]))
+		st = ed
+	}
+	return append(sylls, syllable(phones[st:]))
+}
+
+// syllable returns the syllable unit of the phones
+func syllable(phones []Unit) Unit {
+	names := make([]string, len(phones))
+	for i, u := range phones {
+		names[i] = u.Name
+	}
+	f, l := phones[0], phones[len(phones)-1]
+	return Unit{Name: strings.Join(names, " "), Start: f.Start, End: l.End, AStart: f.AStart, AEnd: l.AEnd, Type: "syllable"}
+}
+
+// SyllableSeq returns a copy of the phone sequence with the units replaced by its syllables, see Syllabify.
+// The Sequence string lists the syllables separated by " . "
+func (seq *Sequence) SyllableSeq() *Sequence {
+	ss := *seq
+	ss.Units = Syllabify(seq.Units)
+	names := make([]string, len(ss.Units))
+	for i, u := range ss.Units {
+		names[i] = u.Name
+	}
+	ss.Sequence = strings.Join(names, " . ")
+	return &ss
+}
@@ -318,6 +318,44 @@ func LoadTimes(fn string, names []string, fuse bool) ([]speech.Unit, error) {
 	return units, nil
 }
 
+// LoadWords loads the word tier of a timit file, lines of start time, end time and word. The times are in
+// milliseconds for files ending in .MS (e.g. SA1.WRD.MS), otherwise they are samples at 16 kHz as shipped
+// with the TIMIT database (e.g. SA1.WRD). The units have Type "word"
+func LoadWords(fn string) ([]speech.Unit, error) {
+	var units []speech.Unit
+	fp, err := os.Open(fn)
+	if err != nil {
+		log.Println(err)
+		return units, err
+	}
+	defer fp.Close()
+
+	scale := 1.0
+	if !strings.HasSuffix(fn, ".MS") {
+		scale = 1000.0 / 16000.0
+	}
+	scanner := bufio.NewScanner(fp)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		flds := strings.Fields(scanner.Text())
+		if len(flds) < 3 {
+			continue
+		}
+		st, err := strconv.ParseFloat(flds[0], 64)
+		if err != nil {
+			log.Println(err)
+			return units, err
+		}
+		ed, err := strconv.ParseFloat(flds[1], 64)
+		if err != nil {
+			log.Println(err)
+			return units, err
+		}
+		units = append(units, speech.Unit{Name: flds[2], Start: st * scale, End: ed * scale, Type: "word"})
+	}
+	return units, nil
+}
+
 // LoadText retrieves the full text of the timit transcription
 func LoadText(fn string) (string, error) {
 	fp, err := os.Open(fn)
@@ -0,0 +1,183 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package vad does voice activity detection, deciding step by step whether the sound is speech or not, online, from the
+samples and power spectrum of each step as they are processed -- for skipping the silent segments of sound files when
+generating training trials, see sound.SndEnv.VAD
+*/
+package vad
+
+import (
+	"math"
+)
+
+// Modes are the ways of deciding whether a step is speech, see Params
+type Modes int32
+
+const (
+	// ModeEnergy decides a step is speech if its energy is more than ThreshDb above the noise floor
+	ModeEnergy Modes = iota
+
+	// ModeStatistical decides a step is speech if at least two of three features vote for speech: the energy above the
+	// noise floor (as ModeEnergy), a spectral flatness below MaxFlatness (tonal rather than noise like) and a zero
+	// crossing rate below MaxZCR (low rather than high frequency) -- more robust than the energy to stationary noise
+	ModeStatistical
+)
+
+// Params are the params of the voice activity detection
+type Params struct {
+
+	// detect voice activity
+	On bool `desc:"detect voice activity"`
+
+	// [viewif: On] how a step is decided to be speech
+	Mode Modes `viewif:"On" desc:"how a step is decided to be speech"`
+
+	// [def: 12] [viewif: On] steps with an energy more than this many dB above the noise floor are speech (vote for speech in ModeStatistical)
+	ThreshDb float64 `viewif:"On" default:"12" desc:"steps with an energy more than this many dB above the noise floor are speech (vote for speech in ModeStatistical)"`
+
+	// [def: -60] [viewif: On] steps with an energy below this many dB relative to full scale are never speech, whatever the noise floor
+	MinDb float64 `viewif:"On" default:"-60" desc:"steps with an energy below this many dB relative to full scale are never speech, whatever the noise floor"`
+
+	// [def: 0.01] [viewif: On] proportion of the difference the noise floor rises toward the energy of each step above it -- it falls to the energy of a step below it at once
+	FloorRise float64 `viewif:"On" default:"0.01" desc:"proportion of the difference the noise floor rises toward the energy of each step above it -- it falls to the energy of a step below it at once"`
+
+	// [def: 0.3] [viewif: Mode=ModeStatistical] steps with a spectral flatness (geometric over arithmetic mean of the power, 0 for a pure tone and 1 for white noise) below this vote for speech
+	MaxFlatness float64 `viewif:"Mode=ModeStatistical" default:"0.3" desc:"steps with a spectral flatness (geometric over arithmetic mean of the power, 0 for a pure tone and 1 for white noise) below this vote for speech"`
+
+	// [def: 0.25] [viewif: Mode=ModeStatistical] steps with a zero crossing rate, in crossings per sample, below this vote for speech
+	MaxZCR float64 `viewif:"Mode=ModeStatistical" default:"0.25" desc:"steps with a zero crossing rate, in crossings per sample, below this vote for speech"`
+
+	// [def: 5] [viewif: On] number of steps after a speech step that are also speech, so the weak ends of words and short pauses aren't cut off, see Hangover
+	HangSteps int `viewif:"On" default:"5" desc:"number of steps after a speech step that are also speech, so the weak ends of words and short pauses aren't cut off, see Hangover"`
+}
+
+// Defaults
+func (vp *Params) Defaults() {
+	vp.Mode = ModeEnergy
+	vp.ThreshDb = 12
+	vp.MinDb = -60
+	vp.FloorRise = 0.01
+	vp.MaxFlatness = 0.3
+	vp.MaxZCR = 0.25
+	vp.HangSteps = 5
+}
+
+// Detector holds the state of the online detection, the noise floor tracked over the steps
+type Detector struct {
+
+	// noise floor in dB, the running minimum of the energy of the steps, rising slowly (see FloorRise)
+	Floor float64 `desc:"noise floor in dB, the running minimum of the energy of the steps, rising slowly (see FloorRise)"`
+
+	// the floor has been set by a step
+	Started bool `desc:"the floor has been set by a step"`
+}
+
+// Reset forgets the noise floor, e.g., for a new sound file
+func (dt *Detector) Reset() {
+	dt.Floor = 0
+	dt.Started = false
+}
+
+// Step decides whether the step of the samples and their power spectrum is speech, updating the noise floor -- the
+// decision is before the hangover (see Hangover) and power is only used by ModeStatistical
+func (dt *Detector) Step(vp *Params, samples, power []float64) bool {
+	e := EnergyDb(samples)
+	if !dt.Started {
+		dt.Floor = e
+		dt.Started = true
+	}
+	loud := e >= vp.MinDb && e-dt.Floor > vp.ThreshDb
+	if e < dt.Floor {
+		dt.Floor = e
+	} else {
+		dt.Floor += vp.FloorRise * (e - dt.Floor)
+	}
+	if vp.Mode == ModeEnergy || e < vp.MinDb {
+		return loud
+	}
+	votes := 0
+	if loud {
+		votes++
+	}
+	if SpectralFlatness(power) < vp.MaxFlatness {
+		votes++
+	}
+	if ZeroCrossRate(samples) < vp.MaxZCR {
+		votes++
+	}
+	return votes >= 2
+}
+
+// EnergyDb returns the mean power of the samples in dB relative to full scale (samples of -1..1), -120 for silence
+func EnergyDb(samples []float64) float64 {
+	if len(samples) == 0 {
+		return -120
+	}
+	e := 0.0
+	for _, v := range samples {
+		e += v * v
+	}
+	return 10 * math.Log10(e/float64(len(samples))+1e-12)
+}
+
+// ZeroCrossRate returns the proportion of the pairs of successive samples that change sign
+func ZeroCrossRate(samples []float64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	n := 0
+	for i := 1; i < len(samples); i++ {
+		if (samples[i-1] >= 0) != (samples[i] >= 0) {
+			n++
+		}
+	}
+	return float64(n) / float64(len(samples)-1)
+}
+
+// SpectralFlatness returns the geometric mean of the power over its arithmetic mean, near 0 for a peaky spectrum, e.g.,
+// voiced speech, and 1 for a flat one, e.g., white noise -- 1 for an empty or silent spectrum
+func SpectralFlatness(power []float64) float64 {
+	if len(power) == 0 {
+		return 1
+	}
+	const floor = 1e-12
+	lsum, sum := 0.0, 0.0
+	for _, p := range power {
+		lsum += math.Log(p + floor)
+		sum += p + floor
+	}
+	n := float64(len(power))
+	return math.Exp(lsum/n) / (sum / n)
+}
+
+// Hangover sets the n steps after each speech step (value 1) of the mask to speech as well
+func Hangover(mask []float32, n int) {
+	hang := 0
+	for i, m := range mask {
+		if m > 0 {
+			hang = n
+			continue
+		}
+		if hang > 0 {
+			mask[i] = 1
+			hang--
+		}
+	}
+}
+
+// Frac returns the proportion of the steps of the mask that are speech
+func Frac(mask []float32) float64 {
+	if len(mask) == 0 {
+		return 0
+	}
+	n := 0
+	for _, m := range mask {
+		if m > 0 {
+			n++
+		}
+	}
+	return float64(n) / float64(len(mask))
+}